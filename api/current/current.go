@@ -4,18 +4,39 @@ import "github.com/newrelic/k8s-agents-operator/api/v1beta1"
 
 type (
 	Agent                    = v1beta1.Agent
+	AppName                  = v1beta1.AppName
+	Cohort                   = v1beta1.Cohort
+	ContainerEnv             = v1beta1.ContainerEnv
+	Exporter                 = v1beta1.Exporter
 	HealthAgent              = v1beta1.HealthAgent
+	HealthProbes             = v1beta1.HealthProbes
+	InjectionSchedule        = v1beta1.InjectionSchedule
 	Instrumentation          = v1beta1.Instrumentation
 	InstrumentationDefaulter = v1beta1.InstrumentationDefaulter
 	InstrumentationList      = v1beta1.InstrumentationList
+	InstrumentationMode      = v1beta1.InstrumentationMode
 	InstrumentationSpec      = v1beta1.InstrumentationSpec
 	InstrumentationStatus    = v1beta1.InstrumentationStatus
 	InstrumentationValidator = v1beta1.InstrumentationValidator
+	OperatorConfig           = v1beta1.OperatorConfig
+	OperatorConfigList       = v1beta1.OperatorConfigList
+	OperatorConfigSpec       = v1beta1.OperatorConfigSpec
+	OperatorConfigStatus     = v1beta1.OperatorConfigStatus
+	SchedulerNameSelector    = v1beta1.SchedulerNameSelector
 	UnhealthyPodError        = v1beta1.UnhealthyPodError
 )
 
+const (
+	InstrumentationModeReportOnly = v1beta1.InstrumentationModeReportOnly
+	OperatorConfigName            = v1beta1.OperatorConfigName
+)
+
 var (
-	AddToScheme   = v1beta1.AddToScheme
-	GroupVersion  = v1beta1.GroupVersion
-	SchemeBuilder = v1beta1.SchemeBuilder
+	AddToScheme           = v1beta1.AddToScheme
+	GroupVersion          = v1beta1.GroupVersion
+	ParsePodFieldSelector = v1beta1.ParsePodFieldSelector
+	PodFields             = v1beta1.PodFields
+	SchemeBuilder         = v1beta1.SchemeBuilder
+	ValidateImage         = v1beta1.ValidateImage
+	ValidateImageRegistry = v1beta1.ValidateImageRegistry
 )
@@ -23,19 +23,28 @@ import (
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/newrelic/k8s-agents-operator/api/v1beta1"
 )
 
-// SetupWebhookWithManager will setup the manager to manage the webhooks
-func SetupWebhookWithManager(mgr ctrl.Manager, operatorNamespace string) error {
+// SetupWebhookWithManager will setup the manager to manage the webhooks. The minimum agent version of a language, if
+// any, is enforced on the agent image tag of the instrumentations, and the allowed image registries, if any, on their
+// images.
+func SetupWebhookWithManager(mgr ctrl.Manager, operatorNamespace string, minAgentVersion func(language string) string, allowedImageRegistries []string) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(&Instrumentation{}).
-		WithValidator(&InstrumentationValidator{OperatorNamespace: operatorNamespace}).
+		WithValidator(&InstrumentationValidator{
+			OperatorNamespace:      operatorNamespace,
+			MinAgentVersion:        minAgentVersion,
+			AllowedImageRegistries: allowedImageRegistries,
+		}).
 		WithDefaulter(&InstrumentationDefaulter{}).
 		Complete()
 }
@@ -79,29 +88,51 @@ var validEnvPrefixesStr = strings.Join(validEnvPrefixes, ", ")
 var _ webhook.CustomValidator = &InstrumentationValidator{}
 
 // InstrumentationValidator is used to validate instrumentations
+// +kubebuilder:object:generate=false
 type InstrumentationValidator struct {
 	OperatorNamespace string
+	// MinAgentVersion is the minimum agent version of a language, empty when there's none
+	MinAgentVersion func(language string) string
+	// AllowedImageRegistries are the registry prefixes the images must be from, any registry when empty
+	AllowedImageRegistries []string
 }
 
 // ValidateCreate to validate the creation operation
 func (r *InstrumentationValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	inst := obj.(*Instrumentation)
 	log.FromContext(ctx).V(1).Info("Validating creation of v1alpha2.Instrumentation", "name", inst.GetName())
-	return r.validate(inst)
+	return r.Validate(inst)
 }
 
-// ValidateUpdate to validate the update operation
+// ValidateUpdate to validate the update operation. An update leaving the spec as it is, such as one of the metadata,
+// isn't validated, so that instrumentations stored before a validation rule was added can still be updated.
 func (r *InstrumentationValidator) ValidateUpdate(ctx context.Context, oldObj runtime.Object, newObj runtime.Object) (admission.Warnings, error) {
 	inst := newObj.(*Instrumentation)
 	log.FromContext(ctx).V(1).Info("Validating update of v1alpha2.Instrumentation", "name", inst.GetName())
-	return r.validate(inst)
+	if oldInst, ok := oldObj.(*Instrumentation); ok && equality.Semantic.DeepEqual(oldInst.Spec, inst.Spec) {
+		return nil, nil
+	}
+	return r.Validate(inst)
 }
 
-// ValidateDelete to validate the deletion operation
+// ValidateDelete to validate the deletion operation. The spec isn't validated, so that instrumentations stored before
+// a validation rule was added can still be deleted.
 func (r *InstrumentationValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	inst := obj.(*Instrumentation)
 	log.FromContext(ctx).V(1).Info("Validating deletion of v1alpha2.Instrumentation", "name", inst.GetName())
-	return r.validate(inst)
+	return nil, nil
+}
+
+// Validate is used to validate the instrumentation the way it's validated when created or updated, which doesn't need
+// a cluster, so that instrumentations can be linted before being applied
+func (r *InstrumentationValidator) Validate(inst *Instrumentation) (admission.Warnings, error) {
+	if warnings, err := r.validate(inst); err != nil {
+		return warnings, err
+	}
+	if err := v1beta1.ValidateImageRegistry(inst.Spec.Agent.Image, r.AllowedImageRegistries); err != nil {
+		return nil, fmt.Errorf("instrumentation %q agent.image %w", inst.Name, err)
+	}
+	return v1beta1.ValidateMinAgentVersion(inst.Name, inst.Spec.Agent.Language, inst.Spec.Agent.Image, r.MinAgentVersion)
 }
 
 // validate to validate all the fields
@@ -132,6 +163,12 @@ func (r *InstrumentationValidator) validate(inst *Instrumentation) (admission.Wa
 	if inst.Spec.Agent.IsEmpty() {
 		return nil, fmt.Errorf("instrumentation %q agent is empty", inst.Name)
 	}
+	if err := v1beta1.ValidateImage(inst.Spec.Agent.Image); err != nil {
+		return nil, fmt.Errorf("instrumentation %q agent.image %w", inst.Name, err)
+	}
+	if err := v1beta1.ValidateObjectName(inst.Spec.LicenseKeySecret); err != nil {
+		return nil, fmt.Errorf("instrumentation %q licenseKeySecret %w", inst.Name, err)
+	}
 	if _, err := metav1.LabelSelectorAsSelector(&inst.Spec.PodLabelSelector); err != nil {
 		return nil, err
 	}
@@ -185,21 +185,6 @@ func (in *InstrumentationStatus) DeepCopy() *InstrumentationStatus {
 	return out
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *InstrumentationValidator) DeepCopyInto(out *InstrumentationValidator) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstrumentationValidator.
-func (in *InstrumentationValidator) DeepCopy() *InstrumentationValidator {
-	if in == nil {
-		return nil
-	}
-	out := new(InstrumentationValidator)
-	in.DeepCopyInto(out)
-	return out
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Resource) DeepCopyInto(out *Resource) {
 	*out = *in
@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// AgentVersion is the numeric version of an agent, such as 8.12.0, compared component by component
+// +kubebuilder:object:generate=false
+type AgentVersion []int
+
+// ParseAgentVersion is used to parse the dot separated numbers leading the version, ignoring a "v" prefix and what
+// follows the numbers, so that tags like v8.12.0 and 11.2.0.15-musl are parsed as 8.12.0 and 11.2.0.15
+func ParseAgentVersion(version string) (AgentVersion, error) {
+	var parsed AgentVersion
+	for _, component := range strings.Split(strings.TrimPrefix(version, "v"), ".") {
+		end := strings.IndexFunc(component, func(r rune) bool { return r < '0' || r > '9' })
+		if end == -1 {
+			end = len(component)
+		}
+		number, err := strconv.Atoi(component[:end])
+		if err != nil {
+			break
+		}
+		parsed = append(parsed, number)
+		if end < len(component) {
+			break
+		}
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("version %q doesn't start with a number", version)
+	}
+	return parsed, nil
+}
+
+// AgentImageVersion is used to parse the agent version from the tag of the image
+func AgentImageVersion(image string) (AgentVersion, error) {
+	image, _, _ = strings.Cut(image, "@")
+	tagIndex := strings.LastIndex(image, ":")
+	if tagIndex == -1 || tagIndex < strings.LastIndex(image, "/") {
+		return nil, fmt.Errorf("image %q has no tag", image)
+	}
+	return ParseAgentVersion(image[tagIndex+1:])
+}
+
+// Compare returns -1, 0 or 1 when the version is below, the same as or above the other one. Missing components are 0,
+// so 8.12 is the same as 8.12.0.
+func (v AgentVersion) Compare(other AgentVersion) int {
+	for i := range max(len(v), len(other)) {
+		if c := cmp.Compare(v.component(i), other.component(i)); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (v AgentVersion) component(i int) int {
+	if i < len(v) {
+		return v[i]
+	}
+	return 0
+}
+
+// String returns the dot separated version
+func (v AgentVersion) String() string {
+	components := make([]string, 0, len(v))
+	for _, component := range v {
+		components = append(components, strconv.Itoa(component))
+	}
+	return strings.Join(components, ".")
+}
+
+// ValidateMinAgentVersion is used to validate the agent version of the image tag of an instrumentation isn't below the
+// minimum of the language, if any. An image without a version in its tag, such as latest, can't be checked, which is
+// warned about. It's left out of the deletion validation, so that instrumentations below a raised minimum can still be
+// deleted.
+func ValidateMinAgentVersion(instName, language, image string, minAgentVersion func(language string) string) (admission.Warnings, error) {
+	if minAgentVersion == nil || image == "" {
+		return nil, nil
+	}
+	minVersionStr := minAgentVersion(language)
+	if minVersionStr == "" {
+		return nil, nil
+	}
+	minVersion, err := ParseAgentVersion(minVersionStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minimum agent version for %s > %w", language, err)
+	}
+	version, err := AgentImageVersion(image)
+	if err != nil {
+		return admission.Warnings{fmt.Sprintf("the agent version of instrumentation %q can't be checked against the minimum %s for %s: %s", instName, minVersion, language, err)}, nil
+	}
+	if version.Compare(minVersion) < 0 {
+		return nil, fmt.Errorf("instrumentation %q agent.image %q has agent version %s, below the minimum %s for %s", instName, image, version, minVersion, language)
+	}
+	return nil, nil
+}
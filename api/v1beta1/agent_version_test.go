@@ -0,0 +1,91 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentImageVersion(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+		errStr   string
+	}{
+		{image: "newrelic/newrelic-java-init:8.12.0", expected: "8.12.0"},
+		{image: "newrelic/newrelic-java-init:v8.12.0", expected: "8.12.0"},
+		{image: "newrelic/newrelic-php-init:11.2.0.15-musl", expected: "11.2.0.15"},
+		{image: "registry:5000/newrelic/newrelic-node-init:12.1", expected: "12.1"},
+		{image: "newrelic/newrelic-node-init:12.1.0@sha256:abc", expected: "12.1.0"},
+		{image: "newrelic/newrelic-java-init:latest", errStr: `version "latest" doesn't start with a number`},
+		{image: "registry:5000/newrelic/newrelic-java-init", errStr: `image "registry:5000/newrelic/newrelic-java-init" has no tag`},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			version, err := AgentImageVersion(test.image)
+			if test.errStr != "" {
+				assert.EqualError(t, err, test.errStr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, version.String())
+		})
+	}
+}
+
+func TestAgentVersion_Compare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "8.12.0", b: "8.12.0", expected: 0},
+		{a: "8.12", b: "8.12.0", expected: 0},
+		{a: "8.9.1", b: "8.12.0", expected: -1},
+		{a: "9", b: "8.12.0", expected: 1},
+		{a: "8.12.0.1", b: "8.12.0", expected: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			a, err := ParseAgentVersion(test.a)
+			assert.NoError(t, err)
+			b, err := ParseAgentVersion(test.b)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, a.Compare(b))
+		})
+	}
+}
+
+func TestValidateMinAgentVersion(t *testing.T) {
+	minAgentVersion := func(language string) string {
+		return map[string]string{"java": "8.12.0"}[language]
+	}
+	tests := []struct {
+		name             string
+		language         string
+		image            string
+		minAgentVersion  func(string) string
+		expectedWarnings int
+		errStr           string
+	}{
+		{name: "no minimum configured", language: "java", image: "newrelic/newrelic-java-init:1.0.0"},
+		{name: "no minimum for the language", language: "python", image: "newrelic/newrelic-python-init:1.0.0", minAgentVersion: minAgentVersion},
+		{name: "at the minimum", language: "java", image: "newrelic/newrelic-java-init:8.12.0", minAgentVersion: minAgentVersion},
+		{name: "above the minimum", language: "java", image: "newrelic/newrelic-java-init:8.13.1", minAgentVersion: minAgentVersion},
+		{
+			name: "below the minimum", language: "java", image: "newrelic/newrelic-java-init:8.9.0", minAgentVersion: minAgentVersion,
+			errStr: `instrumentation "java" agent.image "newrelic/newrelic-java-init:8.9.0" has agent version 8.9.0, below the minimum 8.12.0 for java`,
+		},
+		{name: "unversioned tag", language: "java", image: "newrelic/newrelic-java-init:latest", minAgentVersion: minAgentVersion, expectedWarnings: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings, err := ValidateMinAgentVersion("java", test.language, test.image, test.minAgentVersion)
+			if test.errStr != "" {
+				assert.EqualError(t, err, test.errStr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, warnings, test.expectedWarnings)
+		})
+	}
+}
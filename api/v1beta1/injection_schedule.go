@@ -0,0 +1,101 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// scheduleDays are the days of the week of the injection schedules, by their abbreviation
+var scheduleDays = map[string]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// Validate is used to validate the days, times and time zone of the schedule
+func (s InjectionSchedule) Validate() error {
+	_, _, _, err := s.parse()
+	return err
+}
+
+// Contains is used to check if the time is within the window of the schedule. A window closing on the next day
+// belongs to the day it opens on, so a Fri 22:00 to 06:00 window contains Sat 05:00.
+func (s InjectionSchedule) Contains(t time.Time) (bool, error) {
+	location, start, end, err := s.parse()
+	if err != nil {
+		return false, err
+	}
+	t = t.In(location)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case start == end:
+		return s.opensOn(day), nil
+	case start < end:
+		return minute >= start && minute < end && s.opensOn(day), nil
+	case minute >= start:
+		return s.opensOn(day), nil
+	case minute < end:
+		return s.opensOn((day + 6) % 7), nil
+	}
+	return false, nil
+}
+
+// opensOn is used to check if the window opens on the day of the week
+func (s InjectionSchedule) opensOn(day time.Weekday) bool {
+	return len(s.Days) == 0 || slices.ContainsFunc(s.Days, func(d string) bool { return scheduleDays[d] == day })
+}
+
+// parse is used to get the time zone, and the start and end times in minutes since midnight, of the schedule
+func (s InjectionSchedule) parse() (*time.Location, int, int, error) {
+	for _, day := range s.Days {
+		if _, ok := scheduleDays[day]; !ok {
+			return nil, 0, 0, fmt.Errorf("schedule.days %q must be one of Mon, Tue, Wed, Thu, Fri, Sat or Sun", day)
+		}
+	}
+	start, err := parseTimeOfDay(s.Start)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("schedule.start %w", err)
+	}
+	end, err := parseTimeOfDay(s.End)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("schedule.end %w", err)
+	}
+	location := time.UTC
+	if s.TimeZone != "" {
+		if location, err = time.LoadLocation(s.TimeZone); err != nil {
+			return nil, 0, 0, fmt.Errorf("schedule.timeZone %q must be an IANA time zone > %w", s.TimeZone, err)
+		}
+	}
+	return location, start, end, nil
+}
+
+// parseTimeOfDay is used to parse a HH:MM time of day into minutes since midnight
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil || len(value) != len("15:04") {
+		return 0, fmt.Errorf("%q must be a time of day as HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
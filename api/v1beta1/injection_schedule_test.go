@@ -0,0 +1,68 @@
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectionSchedule_Contains(t *testing.T) {
+	// 2025-06-06 is a Friday
+	friday := func(hour, minute int) time.Time { return time.Date(2025, 6, 6, hour, minute, 0, 0, time.UTC) }
+	businessHours := InjectionSchedule{Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}, Start: "09:00", End: "17:00"}
+	tests := []struct {
+		name     string
+		schedule InjectionSchedule
+		time     time.Time
+		expected bool
+	}{
+		{name: "within business hours", schedule: businessHours, time: friday(9, 0), expected: true},
+		{name: "at the end of business hours", schedule: businessHours, time: friday(17, 0)},
+		{name: "before business hours", schedule: businessHours, time: friday(8, 59)},
+		{name: "weekend", schedule: businessHours, time: friday(12, 0).AddDate(0, 0, 1)},
+		{name: "every day", schedule: InjectionSchedule{Start: "09:00", End: "17:00"}, time: friday(12, 0).AddDate(0, 0, 1), expected: true},
+		{name: "whole day", schedule: InjectionSchedule{Days: []string{"Fri"}, Start: "00:00", End: "00:00"}, time: friday(23, 59), expected: true},
+		{name: "overnight, opening day", schedule: InjectionSchedule{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}, time: friday(23, 0), expected: true},
+		{name: "overnight, next day", schedule: InjectionSchedule{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}, time: friday(5, 0).AddDate(0, 0, 1), expected: true},
+		{name: "overnight, previous day", schedule: InjectionSchedule{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}, time: friday(5, 0)},
+		{name: "overnight, closed", schedule: InjectionSchedule{Start: "22:00", End: "06:00"}, time: friday(12, 0)},
+		{
+			name:     "time zone",
+			schedule: InjectionSchedule{Start: "09:00", End: "17:00", TimeZone: "America/New_York"},
+			time:     friday(20, 0),
+			expected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := test.schedule.Contains(test.time)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestInjectionSchedule_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule InjectionSchedule
+		errStr   string
+	}{
+		{name: "valid", schedule: InjectionSchedule{Days: []string{"Sat", "Sun"}, Start: "08:30", End: "18:00", TimeZone: "Europe/Berlin"}},
+		{name: "unknown day", schedule: InjectionSchedule{Days: []string{"Monday"}, Start: "08:30", End: "18:00"}, errStr: `schedule.days "Monday" must be one of Mon, Tue, Wed, Thu, Fri, Sat or Sun`},
+		{name: "invalid start", schedule: InjectionSchedule{Start: "8:30", End: "18:00"}, errStr: `schedule.start "8:30" must be a time of day as HH:MM`},
+		{name: "invalid end", schedule: InjectionSchedule{Start: "08:30", End: "24:00"}, errStr: `schedule.end "24:00" must be a time of day as HH:MM`},
+		{name: "invalid time zone", schedule: InjectionSchedule{Start: "08:30", End: "18:00", TimeZone: "Mars/Olympus"}, errStr: `schedule.timeZone "Mars/Olympus" must be an IANA time zone`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.schedule.Validate()
+			if test.errStr != "" {
+				assert.ErrorContains(t, err, test.errStr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -18,6 +18,7 @@ package v1beta1
 
 import (
 	"reflect"
+	"slices"
 
 	"github.com/newrelic/k8s-agents-operator/api/common"
 
@@ -58,6 +59,53 @@ type InstrumentationSpec struct {
 	// +optional
 	NamespaceLabelSelector metav1.LabelSelector `json:"namespaceLabelSelector"`
 
+	// OwnerKinds restricts the config to pods controlled by the given workload kinds, for example StatefulSet. Only the
+	// controller owner of the pod is used. It matches both the direct owner kind and the kind at the top of the owner
+	// chain, so a pod of a deployment matches ReplicaSet and Deployment, and a pod of a cronjob matches Job and CronJob.
+	// Pods without a controller owner match Pod.
+	// +optional
+	OwnerKinds []string `json:"ownerKinds,omitempty"`
+
+	// SchedulerNameSelector restricts the config to pods by their scheduler, spec.schedulerName. Like the other
+	// selectors, it has to match along with the pod label, namespace label and owner kind selectors.
+	// +optional
+	SchedulerNameSelector SchedulerNameSelector `json:"schedulerNameSelector,omitempty"`
+
+	// PodFieldSelector restricts the config to pods by their fields, for example
+	// spec.serviceAccountName=payments,spec.nodeName!=edge-1. Only metadata.name, spec.hostNetwork, spec.nodeName,
+	// spec.priorityClassName, spec.restartPolicy, spec.schedulerName and spec.serviceAccountName are supported, as the
+	// status of the pod isn't set yet when it's instrumented, and spec.nodeName is only set for pods bound to a node
+	// when they're created.
+	// +optional
+	PodFieldSelector string `json:"podFieldSelector,omitempty"`
+
+	// Schedule restricts the config to pods created within a weekly window, for example during business hours. Pods
+	// created outside of it aren't instrumented, while the pods already instrumented keep their agents.
+	// +optional
+	Schedule *InjectionSchedule `json:"schedule,omitempty"`
+
+	// Cohort routes the pods matched by the instrumentations sharing a cohort label to one of them, by the value of the
+	// label, so that a stable and a candidate agent can share their selectors while relabeling a workload moves its pods
+	// from one to the other.
+	// +optional
+	Cohort *Cohort `json:"cohort,omitempty"`
+
+	// Mode is how the injected agents run. In report-only mode the agents are injected and instrument the application,
+	// but are configured not to send any data to New Relic, so that their overhead can be measured in isolation.
+	// +kubebuilder:validation:Enum=report-only
+	// +optional
+	Mode InstrumentationMode `json:"mode,omitempty"`
+
+	// AppName defines how the agent app name is built from the pod metadata. When unset, the app name is taken from the
+	// service name labels, the owner, the pod or the container name.
+	// +optional
+	AppName AppName `json:"appName,omitempty"`
+
+	// Profiling enables the code level metrics and the profilers of the agents, such as the JFR profiling of the java
+	// agent. When unset, the operator default, off unless started with --agent-profiling, is used.
+	// +optional
+	Profiling *bool `json:"profiling,omitempty"`
+
 	// LicenseKeySecret defines where to take the licenseKeySecret from.
 	// it should be present in the operator namespace.
 	// +optional
@@ -73,6 +121,116 @@ type InstrumentationSpec struct {
 
 	// HealthAgent defines configuration for healthAgent instrumentation.
 	HealthAgent HealthAgent `json:"healthAgent,omitempty"`
+
+	// MinPodRequests defines the minimum summed container requests a pod needs to be injected, for example `cpu: 100m`.
+	// Pods requesting less of any listed resource are skipped. When unset, the operator default is used.
+	// +optional
+	MinPodRequests corev1.ResourceList `json:"minPodRequests,omitempty"`
+
+	// RequiredNodeAffinity defines node selector requirements instrumented pods must be scheduled with. They are merged
+	// into each of the pod's existing required node selector terms.
+	// +optional
+	RequiredNodeAffinity []corev1.NodeSelectorRequirement `json:"requiredNodeAffinity,omitempty"`
+
+	// Tolerations defines tolerations added to instrumented pods.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// DNSPolicy defines the DNS policy of instrumented pods, such as None to only resolve with the DNS config. It's only
+	// set on pods with the default ClusterFirst policy. When unset, the operator default is used.
+	// +optional
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNSConfig defines DNS settings merged into the ones of instrumented pods, such as a resolver for the New Relic
+	// endpoints in split-horizon DNS. The nameservers, searches and options of the pod are kept. When unset, the operator
+	// default is used.
+	// +optional
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// Containers defines the agent env vars of named containers, such as a distinct NEW_RELIC_APP_NAME for each service of
+	// a pod running several apps of the language. The agent is injected into each listed container of the pod, besides
+	// the agent container, and the container env vars win over the ones of the agent. Not supported by the php agents.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Containers []ContainerEnv `json:"containers,omitempty"`
+}
+
+// ContainerEnv is the agent env vars of a container of the instrumented pods
+type ContainerEnv struct {
+	// Name is the name of the container.
+	Name string `json:"name"`
+
+	// Env defines the agent env vars of the container, overriding the ones of agent.env.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// InstrumentationMode is how the injected agents run
+type InstrumentationMode string
+
+// InstrumentationModeReportOnly injects agents which don't send any data
+const InstrumentationModeReportOnly InstrumentationMode = "report-only"
+
+// AppName is the template of the agent app name, NEW_RELIC_APP_NAME, of the instrumented pods
+type AppName struct {
+	// Template is the app name, with the placeholders in braces replaced by the pod metadata, for example
+	// `{namespace.labels.env}-{namespace.labels.team}-{owner.name}`. A placeholder is a chain of sources separated by |,
+	// the first one set is used, and a quoted 'literal' is always set. The sources are namespace.name,
+	// namespace.labels.<key>, pod.name, pod.labels.<key>, pod.annotations.<key>, owner.name and container.name.
+	// +optional
+	Template string `json:"template,omitempty"`
+
+	// Fallback is the app name used when a placeholder of the template has none of its sources set. When empty, the
+	// default app name is used.
+	// +optional
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// SchedulerNameSelector matches pods by the name of the scheduler scheduling them. Pods without a scheduler name are
+// scheduled by the default-scheduler.
+type SchedulerNameSelector struct {
+	// In are the schedulers of the pods the config applies to. Empty matches every scheduler.
+	// +optional
+	In []string `json:"in,omitempty"`
+
+	// NotIn are the schedulers of the pods the config never applies to. It takes precedence over in.
+	// +optional
+	NotIn []string `json:"notIn,omitempty"`
+}
+
+// Cohort is a pod label whose value is the name of the instrumentation the pod is routed to
+type Cohort struct {
+	// Label is the pod label routing the pods, such as newrelic.com/agent-cohort. The config only applies to the pods
+	// whose label value is its name, along with the pods left to it as the default.
+	// +kubebuilder:validation:MinLength=1
+	Label string `json:"label"`
+
+	// Default makes the config also apply to the pods whose label is unset, or doesn't name an instrumentation sharing
+	// the label.
+	// +optional
+	Default bool `json:"default,omitempty"`
+}
+
+// InjectionSchedule is a weekly window, opening at the start time and closing at the end time of each of its days
+type InjectionSchedule struct {
+	// Days are the days of the week the window opens on. Empty is every day.
+	// +kubebuilder:validation:items:Enum=Mon;Tue;Wed;Thu;Fri;Sat;Sun
+	// +optional
+	Days []string `json:"days,omitempty"`
+
+	// Start is the time of day the window opens at, as HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// End is the time of day the window closes at, as HH:MM. An end before the start closes the window on the next day,
+	// and an end equal to the start keeps it open the whole day.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end"`
+
+	// TimeZone is the IANA time zone of the start and end times, for example Europe/Berlin. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 // Resource is the attributes that are added to the resource
@@ -97,6 +255,37 @@ type Exporter struct {
 	// Endpoint is address of the collector with OTLP endpoint.
 	// +optional
 	Endpoint string `json:"endpoint,omitempty"`
+
+	// TracesEndpoint is the url the traces are exported to, overriding the endpoint for them. Over http/protobuf it's
+	// used as is, so it includes the path, such as /v1/traces.
+	// +optional
+	TracesEndpoint string `json:"tracesEndpoint,omitempty"`
+
+	// MetricsEndpoint is the url the metrics are exported to, overriding the endpoint for them. Over http/protobuf it's
+	// used as is, so it includes the path, such as /v1/metrics.
+	// +optional
+	MetricsEndpoint string `json:"metricsEndpoint,omitempty"`
+
+	// LogsEndpoint is the url the logs are exported to, overriding the endpoint for them. Over http/protobuf it's used
+	// as is, so it includes the path, such as /v1/logs.
+	// +optional
+	LogsEndpoint string `json:"logsEndpoint,omitempty"`
+
+	// Protocol is the OTLP transport used to export to the endpoint, overriding the operator default. An endpoint
+	// without a port gets the default port of the protocol, 4317 for grpc and 4318 for http/protobuf.
+	// +kubebuilder:validation:Enum=grpc;http/protobuf
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// ClientCertSecret is the name of a kubernetes.io/tls secret in the namespace of the pod, holding the client
+	// certificate and key the agents present to the endpoint, overriding the operator default.
+	// +optional
+	ClientCertSecret string `json:"clientCertSecret,omitempty"`
+}
+
+// HasEndpoint is used to check if the exporter sets an endpoint, combined or for a signal
+func (e Exporter) HasEndpoint() bool {
+	return e.Endpoint != "" || e.TracesEndpoint != "" || e.MetricsEndpoint != "" || e.LogsEndpoint != ""
 }
 
 // Sampler defines sampling configuration.
@@ -123,6 +312,14 @@ type Agent struct {
 	// Image is a container image with Go SDK and auto-instrumentation.
 	Image string `json:"image,omitempty"`
 
+	// InstallerImage is the image of the init container copying the agent into the pod, for agents packaged as an
+	// artifact image without the tooling to copy itself. The agent image is then mounted read-only at /newrelic-agent of
+	// the init container, which runs the entrypoint of the installer image, or InitCommand when set, to copy the agent
+	// into /newrelic-instrumentation. Mounting the agent image requires the ImageVolume feature of Kubernetes. The agent
+	// image is used when it's unset.
+	// +optional
+	InstallerImage string `json:"installerImage,omitempty"`
+
 	// VolumeSizeLimit defines size limit for volume used for auto-instrumentation.
 	// The default size is 200Mi.
 	// +optional
@@ -137,12 +334,53 @@ type Agent struct {
 	// Resources describes the compute resource requirements.
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resourceRequirements,omitempty"`
+
+	// StartupWrapper is a command the app is run under, for example `["newrelic-admin", "run-program"]`. It's put in
+	// front of the container command, which stays as its arguments. The container must set a command, as the image
+	// entrypoint isn't known when injecting.
+	// +optional
+	StartupWrapper []string `json:"startupWrapper,omitempty"`
+
+	// InitCommand replaces the command of the init container copying the agent into the app container, for agent
+	// images packaged differently from the released ones. The built-in command and arguments are used when it's unset.
+	// +optional
+	InitCommand []string `json:"initCommand,omitempty"`
+
+	// InitArgs are the arguments of InitCommand, which is required to set them.
+	// +optional
+	InitArgs []string `json:"initArgs,omitempty"`
+
+	// StripEnv is the names of the env vars removed from the instrumented containers before the agent env vars are
+	// added, so that conflicting values set by the workload, such as a stale NEW_RELIC_APP_NAME, are replaced by the
+	// injected ones. When unset, the operator default is used.
+	// +optional
+	StripEnv []string `json:"stripEnv,omitempty"`
+
+	// InstallPath is where the agent is mounted in the instrumented containers, /newrelic-instrumentation by default, for
+	// apps expecting the agent at a path of their own. A relative path is resolved against the workingDir of the
+	// container. The agent paths in the env vars of the containers are moved along. It's not supported by the php agents.
+	// +optional
+	InstallPath string `json:"installPath,omitempty"`
+
+	// CommandArgs puts the agent flag into the java command of the instrumented containers, right after the java
+	// executable and ahead of the flags of the app, rather than into JAVA_TOOL_OPTIONS, for apps whose hard-coded
+	// command doesn't pick up the env var. Containers which don't run java directly keep getting the env var. It's only
+	// supported by the java agent.
+	// +optional
+	CommandArgs bool `json:"commandArgs,omitempty"`
 }
 
 // IsEmpty is used to check if the agent is empty, excluding `.Language`
 func (a *Agent) IsEmpty() bool {
 	return a.Image == "" &&
+		a.InstallerImage == "" &&
 		len(a.Env) == 0 &&
+		len(a.StartupWrapper) == 0 &&
+		len(a.InitCommand) == 0 &&
+		len(a.InitArgs) == 0 &&
+		len(a.StripEnv) == 0 &&
+		a.InstallPath == "" &&
+		!a.CommandArgs &&
 		a.VolumeSizeLimit == nil &&
 		len(a.Resources.Limits) == 0 &&
 		len(a.Resources.Requests) == 0 &&
@@ -151,7 +389,7 @@ func (a *Agent) IsEmpty() bool {
 
 // IsEqual is used to compare if an agent is equal to another, excluding `.Language`
 func (a *Agent) IsEqual(b Agent) bool {
-	return a.Image == b.Image && reflect.DeepEqual(a.Env, b.Env) && reflect.DeepEqual(a.VolumeSizeLimit, b.VolumeSizeLimit) && reflect.DeepEqual(a.Resources, b.Resources)
+	return a.Image == b.Image && a.InstallerImage == b.InstallerImage && reflect.DeepEqual(a.Env, b.Env) && reflect.DeepEqual(a.VolumeSizeLimit, b.VolumeSizeLimit) && reflect.DeepEqual(a.Resources, b.Resources) && slices.Equal(a.StartupWrapper, b.StartupWrapper) && slices.Equal(a.InitCommand, b.InitCommand) && slices.Equal(a.InitArgs, b.InitArgs) && slices.Equal(a.StripEnv, b.StripEnv) && a.InstallPath == b.InstallPath && a.CommandArgs == b.CommandArgs
 }
 
 // HealthAgent is the configuration for the healthAgent
@@ -164,6 +402,39 @@ type HealthAgent struct {
 	// If the former var had been defined, then the other vars would be ignored.
 	// +optional
 	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Probes adds probes of the agent container against the health agent, so that an agent which silently died fails
+	// the health check of the pod. The probes the container defines itself are kept.
+	// +optional
+	Probes *HealthProbes `json:"probes,omitempty"`
+}
+
+// HealthProbes are the probes of the agent container against the health endpoint of the health agent
+type HealthProbes struct {
+	// Liveness adds a liveness probe, restarting the agent container once the health check fails FailureThreshold times
+	// in a row.
+	// +optional
+	Liveness bool `json:"liveness,omitempty"`
+
+	// Readiness adds a readiness probe, taking the pod out of its services while the health check fails.
+	// +optional
+	Readiness bool `json:"readiness,omitempty"`
+
+	// InitialDelaySeconds is how long after the container starts the probes begin, giving the agent time to connect.
+	// Defaults to 30.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+
+	// PeriodSeconds is how often the probes check the health agent. Defaults to 10.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// FailureThreshold is how many failed health checks in a row fail the probes. Defaults to 3.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
 }
 
 // IsEmpty is used to check if the health agent is empty
@@ -174,7 +445,7 @@ func (a *HealthAgent) IsEmpty() bool {
 
 // IsEqual is used to compare if a health agent is equal to another
 func (a *HealthAgent) IsEqual(b HealthAgent) bool {
-	return a.Image == b.Image && reflect.DeepEqual(a.Env, b.Env)
+	return a.Image == b.Image && reflect.DeepEqual(a.Env, b.Env) && reflect.DeepEqual(a.Probes, b.Probes)
 }
 
 type UnhealthyPodError struct {
@@ -188,10 +459,14 @@ type InstrumentationStatus struct {
 	PodsInjected        int64               `json:"podsInjected,omitempty"`
 	PodsNotReady        int64               `json:"podsNotReady,omitempty"`
 	PodsOutdated        int64               `json:"podsOutdated,omitempty"`
+	PodsDrifted         int64               `json:"podsDrifted,omitempty"`
 	PodsHealthy         int64               `json:"podsHealthy,omitempty"`
 	PodsUnhealthy       int64               `json:"podsUnhealthy,omitempty"`
 	UnhealthyPodsErrors []UnhealthyPodError `json:"unhealthyPodsErrors,omitempty"`
-	LastUpdated         metav1.Time         `json:"lastUpdated,omitempty"`
+	// AutoscalingVersion is the autoscaling API version detected by the operator, such as v2 for autoscaling/v2, which
+	// anything managing autoscalers for the instrumented workloads is expected to use
+	AutoscalingVersion string      `json:"autoscalingVersion,omitempty"`
+	LastUpdated        metav1.Time `json:"lastUpdated,omitempty"`
 }
 
 // +kubebuilder:storageversion
@@ -203,6 +478,7 @@ type InstrumentationStatus struct {
 // +kubebuilder:printcolumn:name="PodsInjected",type="integer",JSONPath=".status.podsInjected"
 // +kubebuilder:printcolumn:name="PodsNotReady",type="integer",JSONPath=".status.podsNotReady"
 // +kubebuilder:printcolumn:name="PodsOutdated",type="integer",JSONPath=".status.podsOutdated"
+// +kubebuilder:printcolumn:name="PodsDrifted",type="integer",JSONPath=".status.podsDrifted"
 // +kubebuilder:printcolumn:name="PodsHealthy",type="integer",JSONPath=".status.podsHealthy"
 // +kubebuilder:printcolumn:name="PodsUnhealthy",type="integer",JSONPath=".status.podsUnhealthy"
 // +operator-sdk:csv:customresourcedefinitions:displayName="New Relic Instrumentation"
@@ -19,23 +19,35 @@ package v1beta1
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
 	"slices"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
-// SetupWebhookWithManager will setup the manager to manage the webhooks
-func SetupWebhookWithManager(mgr ctrl.Manager, operatorNamespace string) error {
+// SetupWebhookWithManager will setup the manager to manage the webhooks. The minimum agent version of a language, if
+// any, is enforced on the agent image tag of the instrumentations, and the allowed image registries, if any, on their
+// images.
+func SetupWebhookWithManager(mgr ctrl.Manager, operatorNamespace string, minAgentVersion func(language string) string, allowedImageRegistries []string) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(&Instrumentation{}).
-		WithValidator(&InstrumentationValidator{OperatorNamespace: operatorNamespace}).
+		WithValidator(&InstrumentationValidator{
+			OperatorNamespace:      operatorNamespace,
+			MinAgentVersion:        minAgentVersion,
+			AllowedImageRegistries: allowedImageRegistries,
+		}).
 		WithDefaulter(&InstrumentationDefaulter{}).
 		Complete()
 }
@@ -79,29 +91,57 @@ var validEnvPrefixesStr = strings.Join(validEnvPrefixes, ", ")
 var _ webhook.CustomValidator = &InstrumentationValidator{}
 
 // InstrumentationValidator is used to validate instrumentations
+// +kubebuilder:object:generate=false
 type InstrumentationValidator struct {
 	OperatorNamespace string
+	// MinAgentVersion is the minimum agent version of a language, empty when there's none
+	MinAgentVersion func(language string) string
+	// AllowedImageRegistries are the registry prefixes the images must be from, any registry when empty
+	AllowedImageRegistries []string
 }
 
 // ValidateCreate to validate the creation operation
 func (r *InstrumentationValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	inst := obj.(*Instrumentation)
 	log.FromContext(ctx).V(1).Info("Validating creation of v1beta1.Instrumentation", "name", inst.GetName())
-	return r.validate(inst)
+	return r.Validate(inst)
 }
 
-// ValidateUpdate to validate the update operation
+// ValidateUpdate to validate the update operation. An update leaving the spec as it is, such as one of the metadata,
+// isn't validated, so that instrumentations stored before a validation rule was added can still be updated.
 func (r *InstrumentationValidator) ValidateUpdate(ctx context.Context, oldObj runtime.Object, newObj runtime.Object) (admission.Warnings, error) {
 	inst := newObj.(*Instrumentation)
 	log.FromContext(ctx).V(1).Info("Validating update of v1beta1.Instrumentation", "name", inst.GetName())
-	return r.validate(inst)
+	if oldInst, ok := oldObj.(*Instrumentation); ok && equality.Semantic.DeepEqual(oldInst.Spec, inst.Spec) {
+		return nil, nil
+	}
+	return r.Validate(inst)
 }
 
-// ValidateDelete to validate the deletion operation
+// ValidateDelete to validate the deletion operation. The spec isn't validated, so that instrumentations stored before
+// a validation rule was added can still be deleted.
 func (r *InstrumentationValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	inst := obj.(*Instrumentation)
 	log.FromContext(ctx).V(1).Info("Validating deletion of v1beta1.Instrumentation", "name", inst.GetName())
-	return r.validate(inst)
+	return nil, nil
+}
+
+// Validate is used to validate the instrumentation the way it's validated when created or updated, which doesn't need
+// a cluster, so that instrumentations can be linted before being applied
+func (r *InstrumentationValidator) Validate(inst *Instrumentation) (admission.Warnings, error) {
+	if warnings, err := r.validate(inst); err != nil {
+		return warnings, err
+	}
+	for _, image := range []struct{ field, image string }{
+		{"agent.image", inst.Spec.Agent.Image},
+		{"agent.installerImage", inst.Spec.Agent.InstallerImage},
+		{"healthAgent.image", inst.Spec.HealthAgent.Image},
+	} {
+		if err := ValidateImageRegistry(image.image, r.AllowedImageRegistries); err != nil {
+			return nil, fmt.Errorf("instrumentation %q %s %w", inst.Name, image.field, err)
+		}
+	}
+	return ValidateMinAgentVersion(inst.Name, inst.Spec.Agent.Language, inst.Spec.Agent.Image, r.MinAgentVersion)
 }
 
 // validate to validate all the fields
@@ -132,9 +172,93 @@ func (r *InstrumentationValidator) validate(inst *Instrumentation) (admission.Wa
 	if inst.Spec.Agent.IsEmpty() {
 		return nil, fmt.Errorf("instrumentation %q agent is empty", inst.Name)
 	}
+	if err := ValidateImage(inst.Spec.Agent.Image); err != nil {
+		return nil, fmt.Errorf("instrumentation %q agent.image %w", inst.Name, err)
+	}
+	if err := ValidateImage(inst.Spec.Agent.InstallerImage); err != nil {
+		return nil, fmt.Errorf("instrumentation %q agent.installerImage %w", inst.Name, err)
+	}
+	if err := ValidateImage(inst.Spec.HealthAgent.Image); err != nil {
+		return nil, fmt.Errorf("instrumentation %q healthAgent.image %w", inst.Name, err)
+	}
+	for _, ref := range []struct{ field, name string }{
+		{"licenseKeySecret", inst.Spec.LicenseKeySecret},
+		{"agentConfigMap", inst.Spec.AgentConfigMap},
+		{"exporter.clientCertSecret", inst.Spec.Exporter.ClientCertSecret},
+	} {
+		if err := ValidateObjectName(ref.name); err != nil {
+			return nil, fmt.Errorf("instrumentation %q %s %w", inst.Name, ref.field, err)
+		}
+	}
+	for _, endpoint := range []struct{ field, url string }{
+		{"exporter.tracesEndpoint", inst.Spec.Exporter.TracesEndpoint},
+		{"exporter.metricsEndpoint", inst.Spec.Exporter.MetricsEndpoint},
+		{"exporter.logsEndpoint", inst.Spec.Exporter.LogsEndpoint},
+	} {
+		if err := ValidateOTLPEndpoint(endpoint.url); err != nil {
+			return nil, fmt.Errorf("instrumentation %q %s %w", inst.Name, endpoint.field, err)
+		}
+	}
+	if slices.Contains(inst.Spec.Agent.StartupWrapper, "") {
+		return nil, fmt.Errorf("instrumentation %q agent.startupWrapper must not contain empty arguments", inst.Name)
+	}
+	if len(inst.Spec.Agent.InitArgs) > 0 && len(inst.Spec.Agent.InitCommand) == 0 {
+		return nil, fmt.Errorf("instrumentation %q agent.initArgs requires agent.initCommand", inst.Name)
+	}
+	if slices.Contains(inst.Spec.Agent.InitCommand, "") || slices.Contains(inst.Spec.Agent.InitArgs, "") {
+		return nil, fmt.Errorf("instrumentation %q agent.initCommand and agent.initArgs must not contain empty arguments", inst.Name)
+	}
+	if slices.Contains(inst.Spec.Agent.StripEnv, "") {
+		return nil, fmt.Errorf("instrumentation %q agent.stripEnv must not contain empty names", inst.Name)
+	}
+	if err := ValidateInstallPath(inst.Spec.Agent.InstallPath); err != nil {
+		return nil, fmt.Errorf("instrumentation %q agent.installPath %w", inst.Name, err)
+	}
+	if inst.Spec.Agent.InstallPath != "" && strings.HasPrefix(agentLang, "php-") {
+		return nil, fmt.Errorf("instrumentation %q agent.installPath is not supported by the php agents", inst.Name)
+	}
+	if inst.Spec.Agent.CommandArgs && agentLang != "java" {
+		return nil, fmt.Errorf("instrumentation %q agent.commandArgs is only supported by the java agent", inst.Name)
+	}
 	if len(inst.Spec.HealthAgent.Env) > 0 && inst.Spec.HealthAgent.Image == "" {
 		return nil, fmt.Errorf("instrumentation %q healthAgent.image is empty, meanwhile the environment is not", inst.Name)
 	}
+	if probes := inst.Spec.HealthAgent.Probes; probes != nil {
+		if inst.Spec.HealthAgent.Image == "" {
+			return nil, fmt.Errorf("instrumentation %q healthAgent.image is empty, meanwhile the probes are not", inst.Name)
+		}
+		if probes.InitialDelaySeconds < 0 || probes.PeriodSeconds < 0 || probes.FailureThreshold < 0 {
+			return nil, fmt.Errorf("instrumentation %q healthAgent.probes durations and threshold cannot be negative", inst.Name)
+		}
+	}
+
+	if err := ValidateDNS(inst.Spec.DNSPolicy, inst.Spec.DNSConfig); err != nil {
+		return nil, fmt.Errorf("instrumentation %q %w", inst.Name, err)
+	}
+	if inst.Spec.Schedule != nil {
+		if err := inst.Spec.Schedule.Validate(); err != nil {
+			return nil, fmt.Errorf("instrumentation %q %w", inst.Name, err)
+		}
+	}
+	if inst.Spec.Cohort != nil {
+		if errs := validation.IsQualifiedName(inst.Spec.Cohort.Label); len(errs) > 0 {
+			return nil, fmt.Errorf("instrumentation %q cohort.label %q must be a valid label name: %s", inst.Name, inst.Spec.Cohort.Label, strings.Join(errs, ", "))
+		}
+	}
+
+	if len(inst.Spec.Containers) > 0 && strings.HasPrefix(agentLang, "php-") {
+		return nil, fmt.Errorf("instrumentation %q containers is not supported by the php agents", inst.Name)
+	}
+	var containerNames []string
+	for _, container := range inst.Spec.Containers {
+		if container.Name == "" || slices.Contains(containerNames, container.Name) {
+			return nil, fmt.Errorf("instrumentation %q containers must have unique names", inst.Name)
+		}
+		containerNames = append(containerNames, container.Name)
+		if err := r.validateEnv(container.Env); err != nil {
+			return nil, err
+		}
+	}
 
 	if _, err := metav1.LabelSelectorAsSelector(&inst.Spec.PodLabelSelector); err != nil {
 		return nil, err
@@ -142,10 +266,124 @@ func (r *InstrumentationValidator) validate(inst *Instrumentation) (admission.Wa
 	if _, err := metav1.LabelSelectorAsSelector(&inst.Spec.NamespaceLabelSelector); err != nil {
 		return nil, err
 	}
+	if _, err := ParsePodFieldSelector(inst.Spec.PodFieldSelector); err != nil {
+		return nil, fmt.Errorf("instrumentation %q podFieldSelector %w", inst.Name, err)
+	}
 
 	return nil, nil
 }
 
+// maxDNSNameservers is the most nameservers a pod can have
+const maxDNSNameservers = 3
+
+// ValidateDNS is used to validate the DNS policy and config added to instrumented pods
+func ValidateDNS(policy corev1.DNSPolicy, dnsConfig *corev1.PodDNSConfig) error {
+	switch policy {
+	case "", corev1.DNSClusterFirst, corev1.DNSClusterFirstWithHostNet, corev1.DNSDefault:
+	case corev1.DNSNone:
+		if dnsConfig == nil || len(dnsConfig.Nameservers) == 0 {
+			return fmt.Errorf("dnsPolicy None requires dnsConfig.nameservers")
+		}
+	default:
+		return fmt.Errorf("dnsPolicy %q must be one of ClusterFirst, ClusterFirstWithHostNet, Default or None", policy)
+	}
+	if dnsConfig == nil {
+		return nil
+	}
+	if len(dnsConfig.Nameservers) > maxDNSNameservers {
+		return fmt.Errorf("dnsConfig.nameservers must not have more than %d nameservers", maxDNSNameservers)
+	}
+	for _, nameserver := range dnsConfig.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			return fmt.Errorf("dnsConfig.nameservers %q must be an IP address", nameserver)
+		}
+	}
+	if slices.Contains(dnsConfig.Searches, "") {
+		return fmt.Errorf("dnsConfig.searches must not contain empty domains")
+	}
+	for _, option := range dnsConfig.Options {
+		if option.Name == "" {
+			return fmt.Errorf("dnsConfig.options must have a name")
+		}
+	}
+	return nil
+}
+
+// imageReferencePattern is the grammar of the image references, an optional registry host and port, the slash separated
+// path components, and an optional tag and digest
+var imageReferencePattern = regexp.MustCompile(`^` +
+	`(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?` +
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+// ValidateImage is used to validate an image is a valid image reference, such as newrelic/newrelic-java-init:8.12.0.
+// An empty image is valid, it's defaulted from the image repository.
+func ValidateImage(image string) error {
+	if image == "" || imageReferencePattern.MatchString(image) {
+		return nil
+	}
+	return fmt.Errorf("%q must be a valid image reference", image)
+}
+
+// ValidateImageRegistry is used to validate an image is from one of the allowed registry prefixes, such as
+// docker.io/newrelic, matched on whole path components. Images without a registry host are from docker.io. An empty
+// image, or an empty list of allowed registries, is valid.
+func ValidateImageRegistry(image string, allowedRegistries []string) error {
+	if image == "" || len(allowedRegistries) == 0 {
+		return nil
+	}
+	reference := image
+	if host, _, ok := strings.Cut(image, "/"); !ok || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		reference = "docker.io/" + image
+	}
+	for _, registry := range allowedRegistries {
+		registry = strings.TrimSuffix(registry, "/")
+		if registry != "" && (reference == registry || strings.HasPrefix(reference, registry+"/")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not from an allowed registry (%s)", image, strings.Join(allowedRegistries, ", "))
+}
+
+// ValidateObjectName is used to validate the name of an object referred to by an instrumentation, such as a secret, is
+// a valid object name. An empty name is valid, it's either defaulted or unused.
+func ValidateObjectName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("%q must be a valid object name: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// ValidateInstallPath is used to validate an agent install path is a clean path below the root directory, without the
+// separators of the env var lists the agent paths are added to. An empty path is valid, the default one is used.
+func ValidateInstallPath(installPath string) error {
+	if installPath == "" {
+		return nil
+	}
+	if path.Clean(installPath) != installPath || installPath == "/" || installPath == "." ||
+		slices.Contains(strings.Split(installPath, "/"), "..") || strings.ContainsAny(installPath, ": \t\n") {
+		return fmt.Errorf("%q must be a clean path below the root directory, without colons or spaces", installPath)
+	}
+	return nil
+}
+
+// ValidateOTLPEndpoint is used to validate an OTLP endpoint is an http or https url with a host, such as
+// https://otlp.nr-data.net:4318/v1/traces. An empty endpoint is valid, the signal uses the combined endpoint.
+func ValidateOTLPEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q must be an http or https url with a host", endpoint)
+	}
+	return nil
+}
+
 // validateEnv to validate the environment variables used all start with the required prefixes
 func (r *InstrumentationValidator) validateEnv(envs []corev1.EnvVar) error {
 	var invalidNames []string
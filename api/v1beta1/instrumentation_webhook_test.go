@@ -0,0 +1,173 @@
+package v1beta1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateDNS(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    corev1.DNSPolicy
+		dnsConfig *corev1.PodDNSConfig
+		errStr    string
+	}{
+		{name: "unset"},
+		{name: "policy", policy: corev1.DNSClusterFirstWithHostNet},
+		{
+			name:      "config",
+			dnsConfig: &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.53"}, Searches: []string{"newrelic.internal"}},
+		},
+		{
+			name:      "policy none",
+			policy:    corev1.DNSNone,
+			dnsConfig: &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.53"}},
+		},
+		{name: "policy none without nameservers", policy: corev1.DNSNone, errStr: "dnsPolicy None requires dnsConfig.nameservers"},
+		{
+			name:   "unknown policy",
+			policy: "Custom",
+			errStr: `dnsPolicy "Custom" must be one of ClusterFirst, ClusterFirstWithHostNet, Default or None`,
+		},
+		{
+			name:      "too many nameservers",
+			dnsConfig: &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}},
+			errStr:    "dnsConfig.nameservers must not have more than 3 nameservers",
+		},
+		{
+			name:      "nameserver not an ip",
+			dnsConfig: &corev1.PodDNSConfig{Nameservers: []string{"dns.newrelic.internal"}},
+			errStr:    `dnsConfig.nameservers "dns.newrelic.internal" must be an IP address`,
+		},
+		{
+			name:      "empty search",
+			dnsConfig: &corev1.PodDNSConfig{Searches: []string{""}},
+			errStr:    "dnsConfig.searches must not contain empty domains",
+		},
+		{
+			name:      "option without name",
+			dnsConfig: &corev1.PodDNSConfig{Options: []corev1.PodDNSConfigOption{{}}},
+			errStr:    "dnsConfig.options must have a name",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateDNS(test.policy, test.dnsConfig)
+			if test.errStr != "" {
+				assert.EqualError(t, err, test.errStr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	tests := []struct {
+		image  string
+		errStr string
+	}{
+		{image: ""},
+		{image: "newrelic/newrelic-java-init"},
+		{image: "newrelic/newrelic-java-init:8.12.0"},
+		{image: "registry.example.com:5000/apm/newrelic-python-init:v9.0.0-musl"},
+		{image: "newrelic/newrelic-node-init@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{image: "localhost/java:latest"},
+		{image: "newrelic/Java:latest", errStr: `"newrelic/Java:latest" must be a valid image reference`},
+		{image: "newrelic/java:", errStr: `"newrelic/java:" must be a valid image reference`},
+		{image: "newrelic/java latest", errStr: `"newrelic/java latest" must be a valid image reference`},
+		{image: "newrelic/java@sha256:abc", errStr: `"newrelic/java@sha256:abc" must be a valid image reference`},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			err := ValidateImage(test.image)
+			if test.errStr != "" {
+				assert.EqualError(t, err, test.errStr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateImageRegistry(t *testing.T) {
+	allowed := []string{"docker.io/newrelic", "registry.example.com:5000/apm/"}
+	tests := []struct {
+		image  string
+		errStr string
+	}{
+		{image: ""},
+		{image: "newrelic/newrelic-java-init:8.12.0"},
+		{image: "docker.io/newrelic/newrelic-java-init:8.12.0"},
+		{image: "registry.example.com:5000/apm/newrelic-python-init:v9.0.0-musl"},
+		{image: "newrelicx/newrelic-java-init:8.12.0", errStr: `"newrelicx/newrelic-java-init:8.12.0" is not from an allowed registry (docker.io/newrelic, registry.example.com:5000/apm/)`},
+		{image: "registry.example.com:5000/other/java:latest", errStr: `"registry.example.com:5000/other/java:latest" is not from an allowed registry (docker.io/newrelic, registry.example.com:5000/apm/)`},
+		{image: "localhost/newrelic/java:latest", errStr: `"localhost/newrelic/java:latest" is not from an allowed registry (docker.io/newrelic, registry.example.com:5000/apm/)`},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			err := ValidateImageRegistry(test.image, allowed)
+			if test.errStr != "" {
+				assert.EqualError(t, err, test.errStr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+	assert.NoError(t, ValidateImageRegistry("quay.io/java:latest", nil))
+}
+
+func TestValidateObjectName(t *testing.T) {
+	assert.NoError(t, ValidateObjectName(""))
+	assert.NoError(t, ValidateObjectName("newrelic-key-secret"))
+	assert.ErrorContains(t, ValidateObjectName("NewRelic_Key"), `"NewRelic_Key" must be a valid object name: `)
+}
+
+func TestValidateOTLPEndpoint(t *testing.T) {
+	assert.NoError(t, ValidateOTLPEndpoint(""))
+	assert.NoError(t, ValidateOTLPEndpoint("https://otlp.nr-data.net:4318/v1/traces"))
+	assert.NoError(t, ValidateOTLPEndpoint("http://collector.monitoring"))
+	for _, endpoint := range []string{"collector:4317", "grpc://collector:4317", "https://", "http://%zz"} {
+		assert.ErrorContains(t, ValidateOTLPEndpoint(endpoint), "must be an http or https url with a host")
+	}
+}
+
+func TestValidateInstallPath(t *testing.T) {
+	for _, installPath := range []string{"", "/opt/newrelic", "vendor/newrelic"} {
+		assert.NoError(t, ValidateInstallPath(installPath))
+	}
+	for _, installPath := range []string{"/", ".", "/opt/newrelic/", "../newrelic", "/opt//newrelic", "/opt/new relic", "/opt/newrelic:/x"} {
+		assert.ErrorContains(t, ValidateInstallPath(installPath), "must be a clean path below the root directory")
+	}
+}
+
+func TestInstrumentationValidator_StoredBeforeARule(t *testing.T) {
+	// stored before the image validation, it's invalid now
+	inst := &Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "newrelic"},
+		Spec:       InstrumentationSpec{Agent: Agent{Language: "java", Image: "newrelic/java:Not A Tag"}},
+	}
+	r := &InstrumentationValidator{OperatorNamespace: "newrelic"}
+	ctx := context.Background()
+
+	_, err := r.ValidateCreate(ctx, inst)
+	assert.Error(t, err)
+
+	// it can still be deleted, and its metadata updated
+	_, err = r.ValidateDelete(ctx, inst)
+	assert.NoError(t, err)
+	labeled := inst.DeepCopy()
+	labeled.Labels = map[string]string{"team": "payments"}
+	_, err = r.ValidateUpdate(ctx, inst, labeled)
+	assert.NoError(t, err)
+
+	// a spec change is validated
+	changed := labeled.DeepCopy()
+	changed.Spec.AgentConfigMap = "java-config"
+	_, err = r.ValidateUpdate(ctx, labeled, changed)
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OperatorConfigName is the name of the OperatorConfig read by the operator. Any other OperatorConfig is ignored.
+const OperatorConfigName = "default"
+
+// OperatorConfigSpec defines the operator configuration. Fields which are not set keep the value given to the
+// operator by its command line flags.
+type OperatorConfigSpec struct {
+	// LabelsFilter is the list of label regexes which are filtered out of propagations.
+	// +optional
+	LabelsFilter []string `json:"labelsFilter,omitempty"`
+
+	// LabelsAllowList is the list of label regexes which may be propagated when LabelsFilterMode is allow. The labels
+	// filter still applies to the allowed labels.
+	// +optional
+	LabelsAllowList []string `json:"labelsAllowList,omitempty"`
+
+	// LabelsFilterMode is whether only the labels filter is applied, with deny, or the labels allow-list first, with
+	// allow.
+	// +optional
+	// +kubebuilder:validation:Enum=deny;allow
+	LabelsFilterMode string `json:"labelsFilterMode,omitempty"`
+
+	// AutoDetectFrequency is how often the cluster capabilities are detected.
+	// +optional
+	AutoDetectFrequency *metav1.Duration `json:"autoDetectFrequency,omitempty"`
+
+	// AgentInitDeadline is the maximum duration an agent init container may run before an event is recorded on the
+	// pod. Use 0s to disable.
+	// +optional
+	AgentInitDeadline *metav1.Duration `json:"agentInitDeadline,omitempty"`
+
+	// ImageRepository is the repository of the agent images used by the instrumentations without an image, resolved as
+	// <repository>/<language>:<channel>.
+	// +optional
+	ImageRepository string `json:"imageRepository,omitempty"`
+
+	// ImageChannel is the channel, such as stable or canary, of the agent images used by the instrumentations without
+	// an image.
+	// +optional
+	ImageChannel string `json:"imageChannel,omitempty"`
+
+	// Region is the New Relic region the agents report to. The agents use the region of their license key when it
+	// isn't set.
+	// +optional
+	// +kubebuilder:validation:Enum=us;eu;fedramp
+	Region string `json:"region,omitempty"`
+
+	// FreezeAutoDetect pauses the auto-detection, keeping the last detected cluster capabilities, for example while the
+	// control plane is upgraded and the discovery API flaps.
+	// +optional
+	FreezeAutoDetect *bool `json:"freezeAutoDetect,omitempty"`
+}
+
+// OperatorConfigStatus defines the observed state of OperatorConfig
+type OperatorConfigStatus struct {
+	// ObservedGeneration is the generation of the spec last applied by the operator.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="ObservedGeneration",type="integer",JSONPath=".status.observedGeneration"
+
+// OperatorConfig is the Schema for the operatorconfigs API
+type OperatorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OperatorConfigSpec   `json:"spec,omitempty"`
+	Status OperatorConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// OperatorConfigList contains a list of OperatorConfig
+type OperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OperatorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OperatorConfig{}, &OperatorConfigList{})
+}
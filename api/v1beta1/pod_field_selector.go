@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// podSelectableFields are the pod fields the pod field selectors can match, which are set by the time the pod webhook
+// admits the pod. Fields such as status.phase or status.podIP are only set after the pod is created.
+var podSelectableFields = []string{
+	"metadata.name",
+	"spec.hostNetwork",
+	"spec.nodeName",
+	"spec.priorityClassName",
+	"spec.restartPolicy",
+	"spec.schedulerName",
+	"spec.serviceAccountName",
+}
+
+// ParsePodFieldSelector is used to parse the pod field selector, which may only use the supported fields
+func ParsePodFieldSelector(selector string) (fields.Selector, error) {
+	parsed, err := fields.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, requirement := range parsed.Requirements() {
+		if !slices.Contains(podSelectableFields, requirement.Field) {
+			return nil, fmt.Errorf("field %q is not supported, must be one of %v", requirement.Field, podSelectableFields)
+		}
+	}
+	return parsed, nil
+}
+
+// PodFields is used to get the values of the supported fields of the pod, as matched by the pod field selectors. The
+// service account and scheduler default to the ones the pod gets when it sets none.
+func PodFields(pod corev1.Pod) fields.Set {
+	serviceAccountName := pod.Spec.ServiceAccountName
+	if serviceAccountName == "" {
+		serviceAccountName = "default"
+	}
+	schedulerName := pod.Spec.SchedulerName
+	if schedulerName == "" {
+		schedulerName = corev1.DefaultSchedulerName
+	}
+	return fields.Set{
+		"metadata.name":           pod.Name,
+		"spec.hostNetwork":        strconv.FormatBool(pod.Spec.HostNetwork),
+		"spec.nodeName":           pod.Spec.NodeName,
+		"spec.priorityClassName":  pod.Spec.PriorityClassName,
+		"spec.restartPolicy":      string(pod.Spec.RestartPolicy),
+		"spec.schedulerName":      schedulerName,
+		"spec.serviceAccountName": serviceAccountName,
+	}
+}
@@ -0,0 +1,45 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParsePodFieldSelector(t *testing.T) {
+	bound := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec:       corev1.PodSpec{NodeName: "edge-1", ServiceAccountName: "payments"},
+	}
+	tests := []struct {
+		name     string
+		selector string
+		pod      corev1.Pod
+		expected bool
+		errStr   string
+	}{
+		{name: "empty", pod: bound, expected: true},
+		{name: "service account", pod: bound, selector: "spec.serviceAccountName=payments", expected: true},
+		{name: "service account mismatch", pod: bound, selector: "spec.serviceAccountName=checkout"},
+		{name: "default service account", selector: "spec.serviceAccountName=default", pod: corev1.Pod{}, expected: true},
+		{name: "node excluded", pod: bound, selector: "spec.serviceAccountName=payments,spec.nodeName!=edge-1"},
+		{name: "unbound pod", selector: "spec.nodeName!=edge-1", pod: corev1.Pod{}, expected: true},
+		{name: "default scheduler", pod: bound, selector: "spec.schedulerName=default-scheduler", expected: true},
+		{name: "host network", pod: bound, selector: "spec.hostNetwork=false", expected: true},
+		{name: "unsupported field", selector: "status.phase=Running", errStr: `field "status.phase" is not supported`},
+		{name: "malformed", selector: "spec.nodeName", errStr: "invalid selector"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selector, err := ParsePodFieldSelector(test.selector)
+			if test.errStr != "" {
+				assert.ErrorContains(t, err, test.errStr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, selector.Matches(PodFields(test.pod)))
+		})
+	}
+}
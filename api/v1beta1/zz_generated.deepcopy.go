@@ -22,6 +22,7 @@ package v1beta1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/newrelic/k8s-agents-operator/api/common"
@@ -43,6 +44,26 @@ func (in *Agent) DeepCopyInto(out *Agent) {
 		}
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.StartupWrapper != nil {
+		in, out := &in.StartupWrapper, &out.StartupWrapper
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InitCommand != nil {
+		in, out := &in.InitCommand, &out.InitCommand
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InitArgs != nil {
+		in, out := &in.InitArgs, &out.InitArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StripEnv != nil {
+		in, out := &in.StripEnv, &out.StripEnv
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Agent.
@@ -55,6 +76,58 @@ func (in *Agent) DeepCopy() *Agent {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppName) DeepCopyInto(out *AppName) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppName.
+func (in *AppName) DeepCopy() *AppName {
+	if in == nil {
+		return nil
+	}
+	out := new(AppName)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Cohort) DeepCopyInto(out *Cohort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Cohort.
+func (in *Cohort) DeepCopy() *Cohort {
+	if in == nil {
+		return nil
+	}
+	out := new(Cohort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerEnv) DeepCopyInto(out *ContainerEnv) {
+	*out = *in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerEnv.
+func (in *ContainerEnv) DeepCopy() *ContainerEnv {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerEnv)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Exporter) DeepCopyInto(out *Exporter) {
 	*out = *in
@@ -80,6 +153,11 @@ func (in *HealthAgent) DeepCopyInto(out *HealthAgent) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(HealthProbes)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthAgent.
@@ -92,6 +170,41 @@ func (in *HealthAgent) DeepCopy() *HealthAgent {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthProbes) DeepCopyInto(out *HealthProbes) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthProbes.
+func (in *HealthProbes) DeepCopy() *HealthProbes {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthProbes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InjectionSchedule) DeepCopyInto(out *InjectionSchedule) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InjectionSchedule.
+func (in *InjectionSchedule) DeepCopy() *InjectionSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(InjectionSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Instrumentation) DeepCopyInto(out *Instrumentation) {
 	*out = *in
@@ -179,8 +292,63 @@ func (in *InstrumentationSpec) DeepCopyInto(out *InstrumentationSpec) {
 	out.Sampler = in.Sampler
 	in.PodLabelSelector.DeepCopyInto(&out.PodLabelSelector)
 	in.NamespaceLabelSelector.DeepCopyInto(&out.NamespaceLabelSelector)
+	if in.OwnerKinds != nil {
+		in, out := &in.OwnerKinds, &out.OwnerKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.SchedulerNameSelector.DeepCopyInto(&out.SchedulerNameSelector)
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(InjectionSchedule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cohort != nil {
+		in, out := &in.Cohort, &out.Cohort
+		*out = new(Cohort)
+		**out = **in
+	}
+	out.AppName = in.AppName
+	if in.Profiling != nil {
+		in, out := &in.Profiling, &out.Profiling
+		*out = new(bool)
+		**out = **in
+	}
 	in.Agent.DeepCopyInto(&out.Agent)
 	in.HealthAgent.DeepCopyInto(&out.HealthAgent)
+	if in.MinPodRequests != nil {
+		in, out := &in.MinPodRequests, &out.MinPodRequests
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.RequiredNodeAffinity != nil {
+		in, out := &in.RequiredNodeAffinity, &out.RequiredNodeAffinity
+		*out = make([]v1.NodeSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]ContainerEnv, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstrumentationSpec.
@@ -215,16 +383,115 @@ func (in *InstrumentationStatus) DeepCopy() *InstrumentationStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *InstrumentationValidator) DeepCopyInto(out *InstrumentationValidator) {
+func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfig.
+func (in *OperatorConfig) DeepCopy() *OperatorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperatorConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigList) DeepCopyInto(out *OperatorConfigList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OperatorConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstrumentationValidator.
-func (in *InstrumentationValidator) DeepCopy() *InstrumentationValidator {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigList.
+func (in *OperatorConfigList) DeepCopy() *OperatorConfigList {
 	if in == nil {
 		return nil
 	}
-	out := new(InstrumentationValidator)
+	out := new(OperatorConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperatorConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigSpec) DeepCopyInto(out *OperatorConfigSpec) {
+	*out = *in
+	if in.LabelsFilter != nil {
+		in, out := &in.LabelsFilter, &out.LabelsFilter
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LabelsAllowList != nil {
+		in, out := &in.LabelsAllowList, &out.LabelsAllowList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutoDetectFrequency != nil {
+		in, out := &in.AutoDetectFrequency, &out.AutoDetectFrequency
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.AgentInitDeadline != nil {
+		in, out := &in.AgentInitDeadline, &out.AgentInitDeadline
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.FreezeAutoDetect != nil {
+		in, out := &in.FreezeAutoDetect, &out.FreezeAutoDetect
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigSpec.
+func (in *OperatorConfigSpec) DeepCopy() *OperatorConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigStatus) DeepCopyInto(out *OperatorConfigStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigStatus.
+func (in *OperatorConfigStatus) DeepCopy() *OperatorConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -266,6 +533,31 @@ func (in *Sampler) DeepCopy() *Sampler {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SchedulerNameSelector) DeepCopyInto(out *SchedulerNameSelector) {
+	*out = *in
+	if in.In != nil {
+		in, out := &in.In, &out.In
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotIn != nil {
+		in, out := &in.NotIn, &out.NotIn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SchedulerNameSelector.
+func (in *SchedulerNameSelector) DeepCopy() *SchedulerNameSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(SchedulerNameSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UnhealthyPodError) DeepCopyInto(out *UnhealthyPodError) {
 	*out = *in
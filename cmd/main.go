@@ -18,22 +18,34 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	routev1 "github.com/openshift/api/route/v1"
+	uberzap "go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -41,11 +53,13 @@ import (
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	webhookruntime "sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
 	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
 	"github.com/newrelic/k8s-agents-operator/internal/config"
 	"github.com/newrelic/k8s-agents-operator/internal/controller"
 	"github.com/newrelic/k8s-agents-operator/internal/instrumentation"
 	instrumentationupgrade "github.com/newrelic/k8s-agents-operator/internal/migrate/upgrade"
+	"github.com/newrelic/k8s-agents-operator/internal/tracing"
 	"github.com/newrelic/k8s-agents-operator/internal/version"
 	"github.com/newrelic/k8s-agents-operator/internal/webhook"
 
@@ -56,6 +70,9 @@ import (
 
 var healthCheckTickInterval = time.Second * 15
 
+// maxSecretRetryWait is the longest the license key secret retries may delay an admission, half the webhook timeout
+const maxSecretRetryWait = 5 * time.Second
+
 var (
 	scheme   = k8sruntime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -77,6 +94,69 @@ func main() {
 		enableLeaderElection bool
 		secureMetrics        bool
 		enableHTTP2          bool
+		agentInitDeadline    time.Duration
+		initContainerPrefix  string
+		agentVolumeName      string
+		minPodCPURequest     string
+		minPodMemoryRequest  string
+		autoDetectJitter     float64
+		autoDetectDelay      time.Duration
+		languageScheduling   string
+		serviceNameLabels    string
+		serviceVersionKeys   string
+		initContainerInsert  string
+		agentImageRollout    bool
+		podAgentImages       bool
+		secretCacheTTL       time.Duration
+		secretFailures       int
+		secretCooldown       time.Duration
+		secretRetries        int
+		secretRetryInterval  time.Duration
+		fallbackSecret       string
+		existingAgentEnvVars string
+		attributeLabels      string
+		webhookSelfCheck     time.Duration
+		imageRepository      string
+		imageChannel         string
+		allowedRegistries    string
+		otlpProtocol         string
+		agentEnvDefaults     string
+		composeInsts         bool
+		agentInitUser        int64
+		agentInitGroup       int64
+		freezeAutoDetect     bool
+		agentWarmup          string
+		mountPropagation     string
+		importConfig         string
+		exportConfig         bool
+		minAgentVersions     string
+		agentProfiling       bool
+		routesChangeCooldown time.Duration
+		agentDNSPolicy       string
+		agentDNSNameservers  string
+		agentDNSSearches     string
+		tracingEndpoint      string
+		tracingInsecure      bool
+		agentContainer       string
+		namespaceRollout     bool
+		agentReadinessGate   string
+		maxLanguagesPerPod   int
+		stripEnvVars         string
+		otlpClientCert       string
+		nodeLabelAttributes  string
+		otlpSignalEndpoints  config.OTLPSignalEndpoints
+		instrumentationSync  string
+		clusterName          string
+		clusterNameFrom      string
+		agentProxy           config.AgentProxy
+		agentNoProxy         string
+		clusterCIDRs         string
+		injectionFailures    int
+		minGracePeriod       int
+		instProvider         string
+		agentEnvPrecedence   string
+		maxInjections        int
+		injectionQueueWait   time.Duration
 	)
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
@@ -87,10 +167,152 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", true,
 		"If set, the\tflag.BoolVar(&enableHTTP2, \"enable-http2\", false,\n\t\t\"If set, HTTP/2 will be enabled for the metrics and webhook servers\")\n metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
+	flag.DurationVar(&agentInitDeadline, "agent-init-deadline", 0,
+		"The maximum duration an agent init container may run before an event is recorded on the pod. Use 0 to disable.")
+	flag.StringVar(&initContainerPrefix, "init-container-name-prefix", "newrelic-instrumentation",
+		"The prefix used for the names of the injected agent init containers.")
+	flag.StringVar(&agentVolumeName, "agent-volume-name", "newrelic-instrumentation",
+		"The name of the shared volume the agents are copied into. Pods which already have a volume of this name get a suffixed one, such as newrelic-instrumentation-2.")
+	flag.StringVar(&minPodCPURequest, "min-pod-cpu-request", "",
+		"The default minimum summed container cpu request a pod needs to be injected, for example 100m.")
+	flag.StringVar(&minPodMemoryRequest, "min-pod-memory-request", "",
+		"The default minimum summed container memory request a pod needs to be injected, for example 128Mi.")
+	flag.Float64Var(&autoDetectJitter, "auto-detect-jitter", 0.1,
+		"The fraction of the auto-detect frequency, from 0 up to, excluding, 1, by which each auto-detect run is randomly moved, to spread the load of multiple replicas.")
+	flag.DurationVar(&autoDetectDelay, "auto-detect-initial-delay", 0,
+		"The delay before the first periodic auto-detect run, letting leader election and the caches settle on startup.")
+	flag.StringVar(&languageScheduling, "language-scheduling", "",
+		"The node affinity requirements and tolerations added to instrumented pods by agent language, as JSON, for example "+
+			`{"java":{"tolerations":[{"key":"agents","operator":"Exists"}]}}.`)
+	flag.StringVar(&serviceNameLabels, "service-name-labels", "",
+		"The comma separated pod label keys used, in order, for the agent app name before falling back to the owner name, for example app.kubernetes.io/name,app.")
+	flag.StringVar(&serviceVersionKeys, "service-version-keys", "app.kubernetes.io/version",
+		"The comma separated label or annotation keys looked up, in order, on the pod and then on its workload for the service.version attribute of the agents, for example app.kubernetes.io/version,deployment.kubernetes.io/revision. Use an empty value to disable it.")
+	flag.StringVar(&initContainerInsert, "init-container-insert-position", string(config.InitContainerPositionLast),
+		"Where the agent init containers are inserted among the pod's other init containers. One of first, last or before:<init container name>.")
+	flag.StringVar(&agentContainer, "agent-container", string(config.AgentContainerPositionFirst),
+		"The container of the pod the agents are injected into. One of first, last or name:<container name>, which falls back to the first container when the pod doesn't have it.")
+	flag.BoolVar(&agentImageRollout, "agent-image-rollout", false,
+		"If set, workloads are restarted with a rollout when the agent image of their instrumentation changes. This is disruptive, so it's disabled by default.")
+	flag.BoolVar(&podAgentImages, "pod-agent-images", false,
+		"If set, the agent image of a pod can be overridden with the newrelic.com/<language>-image annotation, for testing an agent build on a single pod. It lets anyone creating pods run arbitrary agent images, so it's disabled by default.")
+	flag.StringVar(&instrumentationSync, "instrumentation-sync", "",
+		"How the pods injected by an instrumentation are kept in sync with it when it changes. annotate fixes their agent images annotation to match the agent they run, restart also restarts the workloads injected by an older generation of the instrumentation with a rollout. Unset disables it.")
+	flag.BoolVar(&namespaceRollout, "namespace-rollout", false,
+		"If set, workloads left uninstrumented are restarted with a rollout when their namespace is labeled to match an instrumentation. This is disruptive, so it's disabled by default.")
+	flag.DurationVar(&secretCacheTTL, "secret-cache-ttl", 30*time.Second,
+		"How long a resolved license key secret is remembered for, saving admissions the api server lookups. Use 0 to disable.")
+	flag.IntVar(&secretFailures, "secret-circuit-breaker-failures", 5,
+		"The number of consecutive license key secret resolution failures after which pods are admitted without injection for the cooldown. Use 0 to disable.")
+	flag.DurationVar(&secretCooldown, "secret-circuit-breaker-cooldown", 30*time.Second,
+		"How long secret resolution is skipped for once the circuit breaker opens.")
+	flag.IntVar(&secretRetries, "secret-retry-attempts", 0,
+		"The number of times the resolution of a license key secret which isn't found is retried, in case it's created moments after the first pods. The attempts and interval must not add up to more than 5s. Defaults to 0, disabled.")
+	flag.DurationVar(&secretRetryInterval, "secret-retry-interval", 500*time.Millisecond,
+		"How long to wait between the attempts to resolve a license key secret which isn't found. The attempts delay the admission, so keep them well below the timeout of the pod mutation webhook.")
+	flag.StringVar(&fallbackSecret, "fallback-license-key-secret", "",
+		"The name of the license key secret, in the operator namespace, injected when the one of the instrumentations still isn't found once retried. Unset disables the fallback.")
+	flag.IntVar(&maxInjections, "max-concurrent-injections", 0,
+		"The most pod admissions injected at once, bounding the lookups of a mass rollout. The admissions beyond it wait for the injection queue timeout, and are then admitted without injection. Use 0 to disable.")
+	flag.DurationVar(&injectionQueueWait, "injection-queue-timeout", 2*time.Second,
+		"How long a pod admission waits for an injection slot once --max-concurrent-injections is reached. Keep it well below the timeout of the pod mutation webhook.")
+	flag.IntVar(&injectionFailures, "injection-failure-threshold", 0,
+		"The number of consecutive injection failures of a workload after which its pods are admitted without injection, and annotated with newrelic.com/injection-failed, until its pod template changes. Use 0 to disable.")
+	flag.IntVar(&minGracePeriod, "min-termination-grace-period", 0,
+		"The termination grace period, in seconds, the instrumented pods with a shorter one are raised to, so that the agents can flush their data on shutdown. Use 0 to leave it alone.")
+	flag.StringVar(&instProvider, "instrumentation-provider", config.DefaultInstrumentationProvider,
+		"The instrumentation provider reported by the injected agents in their metadata, along with the operator version, so that they're told apart from the agents configured otherwise. Use an empty value to leave it out.")
+	flag.StringVar(&agentEnvPrecedence, "agent-env-precedence", string(config.AgentEnvPrecedenceInstrumentation),
+		"Which agent env vars win when the instrumentation and the newrelic.com/env pod annotation set the same name. instrumentation keeps the env vars managed centrally, annotation lets the pods override them.")
+	flag.StringVar(&stripEnvVars, "strip-env-vars", "",
+		"The comma separated env vars removed from the instrumented containers before the agent env vars are added, for example NEW_RELIC_APP_NAME, so that the injected values replace the ones set by the workload. Instrumentations can set their own with agent.stripEnv.")
+	flag.StringVar(&existingAgentEnvVars, "existing-agent-env-vars", "",
+		"The comma separated env vars which, set on a container, signal the image already has an agent so the pod isn't injected, for example NEW_RELIC_LICENSE_KEY.")
+	flag.StringVar(&attributeLabels, "attribute-labels", "",
+		"The comma separated pod label keys added to the agent labels, reported as attributes on the agent data, for example team,tier.")
+	flag.StringVar(&nodeLabelAttributes, "node-label-attributes", "",
+		"The comma separated label keys of the node the pod is scheduled on added to the agent labels, each optionally followed by =<attribute> to rename it, for example topology.kubernetes.io/region=cloud.region,node.kubernetes.io/instance-type=instance.type.")
+	flag.DurationVar(&webhookSelfCheck, "webhook-self-check-interval", 5*time.Minute,
+		"How often to verify, with a dry-run pod, that the pod mutation webhook is being called. 0 disables the self-check.")
+	flag.StringVar(&imageRepository, "image-repository", "",
+		"The repository of the agent images used by instrumentations without an image, resolved as <repository>/<language>:<channel>.")
+	flag.StringVar(&imageChannel, "image-channel", "",
+		"The channel, such as stable or canary, of the agent images used by instrumentations without an image.")
+	flag.StringVar(&allowedRegistries, "allowed-image-registries", "",
+		"The comma separated registry prefixes the agent, installer and health agent images must be from, for example docker.io/newrelic,registry.example.com/apm. Any registry is allowed when empty.")
+	flag.DurationVar(&routesChangeCooldown, "openshift-routes-change-cooldown", 0,
+		"The minimum time between the reconciles triggered by changes of the detected OpenShift Routes availability, so that flapping during upgrades is reconciled once. 0 disables the cooldown.")
+	flag.StringVar(&otlpProtocol, "otlp-protocol", "",
+		"The default OTLP transport of the instrumentations exporting to an endpoint, grpc or http/protobuf. Unset leaves it to the agent.")
+	flag.StringVar(&otlpClientCert, "otlp-client-cert-secret", "",
+		"The name of the kubernetes.io/tls secret, in the namespace of each pod, holding the client certificate and key the agents present to the OTLP endpoint, for instrumentations exporting to an endpoint without their own exporter.clientCertSecret.")
+	flag.StringVar(&otlpSignalEndpoints.Traces, "otlp-traces-endpoint", "",
+		"The default url the agents export traces to, for instrumentations exporting to an endpoint without their own exporter.tracesEndpoint. Unset leaves traces to the combined endpoint.")
+	flag.StringVar(&otlpSignalEndpoints.Metrics, "otlp-metrics-endpoint", "",
+		"The default url the agents export metrics to, for instrumentations exporting to an endpoint without their own exporter.metricsEndpoint. Unset leaves metrics to the combined endpoint.")
+	flag.StringVar(&otlpSignalEndpoints.Logs, "otlp-logs-endpoint", "",
+		"The default url the agents export logs to, for instrumentations exporting to an endpoint without their own exporter.logsEndpoint. Unset leaves logs to the combined endpoint.")
+	flag.StringVar(&clusterName, "cluster-name", "",
+		"The name of the cluster added to the telemetry of every instrumented pod as the k8s.cluster.name attribute, for filtering across clusters.")
+	flag.StringVar(&clusterNameFrom, "cluster-name-from", "",
+		"Where the cluster name is read from when the operator starts, if --cluster-name isn't set. One of configmap:<namespace>/<name>/<key> or node-label:<label>.")
+	flag.StringVar(&agentProxy.URL, "agent-http-proxy", "",
+		"The proxy of the agents, set as the NEW_RELIC_PROXY_* settings of the instrumented containers setting none, leaving the traffic of the apps alone. The containers setting HTTP_PROXY or HTTPS_PROXY get NO_PROXY entries for the in-cluster traffic either way.")
+	flag.StringVar(&agentNoProxy, "agent-no-proxy", "",
+		"The comma separated hosts, domains and CIDRs added to the default NO_PROXY entries of the instrumented containers using a proxy.")
+	flag.StringVar(&agentProxy.ClusterDomain, "cluster-domain", "cluster.local",
+		"The DNS domain of the cluster, excluded from the proxy of the instrumented containers.")
+	flag.StringVar(&clusterCIDRs, "cluster-cidrs", "",
+		"The comma separated pod and service CIDRs of the cluster, excluded from the proxy of the instrumented containers, for example 10.244.0.0/16,10.96.0.0/12.")
+	flag.StringVar(&agentDNSPolicy, "agent-dns-policy", "",
+		"The DNS policy of the pods instrumented by instrumentations without one, such as None. Only pods with the default ClusterFirst policy are changed.")
+	flag.StringVar(&agentDNSNameservers, "agent-dns-nameservers", "",
+		"The comma separated nameservers added to the pods instrumented by instrumentations without a DNS config, for example to resolve the New Relic endpoints with split-horizon DNS.")
+	flag.StringVar(&agentDNSSearches, "agent-dns-searches", "",
+		"The comma separated DNS search domains added to the pods instrumented by instrumentations without a DNS config.")
+	flag.StringVar(&tracingEndpoint, "tracing-otlp-endpoint", "",
+		"The OTLP gRPC endpoint, as host:port, receiving a span for each admission decision of the pod webhook. Unset disables tracing.")
+	flag.BoolVar(&tracingInsecure, "tracing-otlp-insecure", false,
+		"If set, the spans are sent to the tracing OTLP endpoint without TLS.")
+	flag.StringVar(&agentEnvDefaults, "agent-env-defaults", "",
+		"The comma separated NAME=value agent env vars of every instrumentation, overridden by the instrumentation and the newrelic.com/env pod annotation.")
+	flag.BoolVar(&composeInsts, "compose-instrumentations", false,
+		"If set, a pod matched by several differing instrumentations of the same language gets them composed, the newest one winning conflicts, rather than not being instrumented. Useful while migrating between instrumentations.")
+	flag.Int64Var(&agentInitUser, "agent-init-run-as-user", -1,
+		"The user the agent init containers run as when neither the instrumented container nor the pod set one. Use -1 to leave it to the image.")
+	flag.Int64Var(&agentInitGroup, "agent-init-run-as-group", -1,
+		"The group the agent init containers run as when neither the instrumented container nor the pod set one. Use -1 to leave it to the image.")
+	flag.BoolVar(&freezeAutoDetect, "freeze-auto-detect", false,
+		"If set, the auto-detection is paused and the cluster capabilities keep their last detected values. Can be toggled at runtime with the OperatorConfig freezeAutoDetect field.")
+	flag.IntVar(&maxLanguagesPerPod, "max-languages-per-pod", 3,
+		"The most languages whose agents are injected into a pod. The instrumentations of further languages matching the pod are skipped with an event. 0 removes the limit.")
+	flag.StringVar(&agentReadinessGate, "agent-readiness-gate", "",
+		"The condition type of a readiness gate added to the pods injected with the health agent, such as newrelic.com/agent-ready, which is set once the agent reports healthy so the pods don't receive traffic before. Disabled when empty.")
+	flag.StringVar(&agentWarmup, "agent-warmup", "",
+		"The comma separated language=duration agent warmups, for example java=10s, holding instrumented containers back from becoming ready with a postStart sleep while the agent initializes. This adds to the pod startup time. Needs Kubernetes 1.30 or newer, it's skipped on older clusters.")
+	flag.StringVar(&mountPropagation, "agent-mount-propagation", "",
+		"The comma separated language=mode mount propagations of the agent volume mounts, for example java=HostToContainer, for the CSI drivers and meshes needing it. One of None, HostToContainer or Bidirectional, which is only set on privileged containers, the others get HostToContainer. Defaults to None.")
+	flag.StringVar(&minAgentVersions, "min-agent-versions", "",
+		"The comma separated language=version minimum agent versions, for example java=8.12.0, below which instrumentations are rejected. The version is read from the agent image tag.")
+	flag.BoolVar(&agentProfiling, "agent-profiling", false,
+		"If set, the code level metrics and the profilers of the agents, such as the java JFR profiling, are enabled for the instrumentations which don't set profiling.")
+	flag.StringVar(&importConfig, "import-config", "",
+		"The path of a configuration document, as printed by --export-config, applied over the other flags so that every cluster runs the same configuration.")
+	flag.BoolVar(&exportConfig, "export-config", false,
+		"If set, the effective configuration is printed as a portable document, which --import-config applies, and the operator exits.")
 	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	// the log level is atomic, so that it can be changed at runtime through the log level endpoint
+	logLevel, ok := opts.Level.(uberzap.AtomicLevel)
+	if !ok {
+		logLevel = uberzap.NewAtomicLevel()
+		if opts.Development {
+			logLevel.SetLevel(uberzap.DebugLevel)
+		}
+		opts.Level = logLevel
+	}
 	logger := zap.New(zap.UseFlagOptions(&opts))
 	ctrl.SetLogger(logger)
 
@@ -130,9 +352,361 @@ func main() {
 		"go-os", runtime.GOOS,
 	)
 
+	minPodRequests := corev1.ResourceList{}
+	for resourceName, value := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    minPodCPURequest,
+		corev1.ResourceMemory: minPodMemoryRequest,
+	} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			setupLog.Error(err, "invalid minimum pod request", "resource", resourceName)
+			os.Exit(1)
+		}
+		minPodRequests[resourceName] = quantity
+	}
+
+	var serviceNameLabelKeys []string
+	if serviceNameLabels != "" {
+		serviceNameLabelKeys = strings.Split(serviceNameLabels, ",")
+	}
+	var serviceVersionKeyList []string
+	if serviceVersionKeys != "" {
+		serviceVersionKeyList = strings.Split(serviceVersionKeys, ",")
+	}
+	var allowedRegistryList []string
+	if allowedRegistries != "" {
+		allowedRegistryList = strings.Split(allowedRegistries, ",")
+	}
+
+	if autoDetectJitter < 0 || autoDetectJitter >= 1 {
+		setupLog.Info("invalid auto-detect jitter, expected a fraction from 0 up to, excluding, 1", "jitter", autoDetectJitter)
+		os.Exit(1)
+	}
+
+	insertPosition, insertBefore, _ := strings.Cut(initContainerInsert, ":")
+	switch config.InitContainerPosition(insertPosition) {
+	case config.InitContainerPositionFirst, config.InitContainerPositionLast:
+	case config.InitContainerPositionBefore:
+		if insertBefore == "" {
+			setupLog.Info("the init container insert position before requires an init container name, for example before:istio-init")
+			os.Exit(1)
+		}
+	default:
+		setupLog.Info("invalid init container insert position", "position", initContainerInsert)
+		os.Exit(1)
+	}
+
+	agentContainerPosition, agentContainerName, _ := strings.Cut(agentContainer, ":")
+	switch config.AgentContainerPosition(agentContainerPosition) {
+	case config.AgentContainerPositionFirst, config.AgentContainerPositionLast:
+	case config.AgentContainerPositionNamed:
+		if agentContainerName == "" {
+			setupLog.Info("the agent container name requires a container name, for example name:app")
+			os.Exit(1)
+		}
+	default:
+		setupLog.Info("invalid agent container", "agent-container", agentContainer)
+		os.Exit(1)
+	}
+
+	switch config.OTLPProtocol(otlpProtocol) {
+	case "", config.OTLPProtocolGRPC, config.OTLPProtocolHTTPProtobuf:
+	default:
+		setupLog.Info("invalid otlp protocol, expected grpc or http/protobuf", "protocol", otlpProtocol)
+		os.Exit(1)
+	}
+	if clusterName != "" {
+		if err := config.ValidateClusterName(clusterName); err != nil {
+			setupLog.Info("invalid cluster name", "reason", err.Error())
+			os.Exit(1)
+		}
+	}
+	var clusterNameSource config.ClusterNameSource
+	if clusterNameFrom != "" {
+		var err error
+		if clusterNameSource, err = config.ParseClusterNameSource(clusterNameFrom); err != nil {
+			setupLog.Info("invalid cluster name source", "reason", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	switch config.InstrumentationSyncPolicy(instrumentationSync) {
+	case "", config.InstrumentationSyncPolicyAnnotate, config.InstrumentationSyncPolicyRestart:
+	default:
+		setupLog.Info("invalid instrumentation sync policy, expected annotate or restart", "policy", instrumentationSync)
+		os.Exit(1)
+	}
+	for _, endpoint := range []string{otlpSignalEndpoints.Traces, otlpSignalEndpoints.Metrics, otlpSignalEndpoints.Logs} {
+		if err := newreliccomv1beta1.ValidateOTLPEndpoint(endpoint); err != nil {
+			setupLog.Info("invalid otlp signal endpoint", "reason", err.Error())
+			os.Exit(1)
+		}
+	}
+	if err := newreliccomv1beta1.ValidateOTLPEndpoint(agentProxy.URL); err != nil {
+		setupLog.Info("invalid agent http proxy", "reason", err.Error())
+		os.Exit(1)
+	}
+	if agentNoProxy != "" {
+		agentProxy.NoProxy = strings.Split(agentNoProxy, ",")
+	}
+	if clusterCIDRs != "" {
+		agentProxy.ClusterCIDRs = strings.Split(clusterCIDRs, ",")
+		for _, cidr := range agentProxy.ClusterCIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				setupLog.Info("invalid cluster cidr", "cidr", cidr)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if injectionFailures < 0 {
+		setupLog.Info("invalid injection failure threshold, must not be negative", "injectionFailureThreshold", injectionFailures)
+		os.Exit(1)
+	}
+
+	if minGracePeriod < 0 {
+		setupLog.Info("invalid min termination grace period, must not be negative", "minTerminationGracePeriod", minGracePeriod)
+		os.Exit(1)
+	}
+
+	if secretRetries < 0 || secretRetryInterval < 0 {
+		setupLog.Info("invalid secret retry, must not be negative", "secretRetryAttempts", secretRetries, "secretRetryInterval", secretRetryInterval)
+		os.Exit(1)
+	}
+	// the retries delay the admission, keep them well below the 10s timeout of the pod mutation webhook
+	if time.Duration(secretRetries)*secretRetryInterval > maxSecretRetryWait {
+		setupLog.Info("invalid secret retry, the attempts must not wait longer than the maximum", "secretRetryAttempts", secretRetries, "secretRetryInterval", secretRetryInterval, "max", maxSecretRetryWait)
+		os.Exit(1)
+	}
+	if err := newreliccomv1beta1.ValidateObjectName(fallbackSecret); err != nil {
+		setupLog.Info("invalid fallback license key secret", "reason", err.Error())
+		os.Exit(1)
+	}
+	if maxInjections < 0 || injectionQueueWait < 0 {
+		setupLog.Info("invalid injection concurrency, must not be negative", "maxConcurrentInjections", maxInjections, "injectionQueueTimeout", injectionQueueWait)
+		os.Exit(1)
+	}
+
+	switch config.AgentEnvPrecedence(agentEnvPrecedence) {
+	case config.AgentEnvPrecedenceInstrumentation, config.AgentEnvPrecedenceAnnotation:
+	default:
+		setupLog.Info("invalid agent env precedence, expected instrumentation or annotation", "precedence", agentEnvPrecedence)
+		os.Exit(1)
+	}
+
+	if maxLanguagesPerPod < 0 {
+		setupLog.Info("invalid max languages per pod, must not be negative", "maxLanguagesPerPod", maxLanguagesPerPod)
+		os.Exit(1)
+	}
+
+	if agentReadinessGate != "" {
+		if errs := validation.IsQualifiedName(agentReadinessGate); len(errs) > 0 {
+			setupLog.Info("invalid agent readiness gate", "conditionType", agentReadinessGate, "reasons", errs)
+			os.Exit(1)
+		}
+	}
+
+	if errs := validation.IsDNS1123Label(agentVolumeName); len(errs) > 0 {
+		setupLog.Info("invalid agent volume name", "volumeName", agentVolumeName, "reasons", errs)
+		os.Exit(1)
+	}
+
+	var agentDNSConfig *corev1.PodDNSConfig
+	if agentDNSNameservers != "" || agentDNSSearches != "" {
+		agentDNSConfig = &corev1.PodDNSConfig{}
+		if agentDNSNameservers != "" {
+			agentDNSConfig.Nameservers = strings.Split(agentDNSNameservers, ",")
+		}
+		if agentDNSSearches != "" {
+			agentDNSConfig.Searches = strings.Split(agentDNSSearches, ",")
+		}
+	}
+	if err := newreliccomv1beta1.ValidateDNS(corev1.DNSPolicy(agentDNSPolicy), agentDNSConfig); err != nil {
+		setupLog.Info("invalid agent dns", "reason", err.Error())
+		os.Exit(1)
+	}
+
+	agentEnvDefaultVars, malformedAgentEnvDefaults := apm.ParseEnvAnnotation(agentEnvDefaults)
+	if len(malformedAgentEnvDefaults) > 0 {
+		setupLog.Info("invalid agent env defaults, expected NAME=value", "malformed", malformedAgentEnvDefaults)
+		os.Exit(1)
+	}
+
+	var existingAgentEnvNames []string
+	if existingAgentEnvVars != "" {
+		existingAgentEnvNames = strings.Split(existingAgentEnvVars, ",")
+	}
+
+	var stripEnvNames []string
+	if stripEnvVars != "" {
+		stripEnvNames = strings.Split(stripEnvVars, ",")
+	}
+
+	var attributeLabelKeys []string
+	if attributeLabels != "" {
+		attributeLabelKeys = strings.Split(attributeLabels, ",")
+	}
+
+	var nodeLabelAttributeList []config.NodeLabelAttribute
+	if nodeLabelAttributes != "" {
+		for _, labelAttribute := range strings.Split(nodeLabelAttributes, ",") {
+			label, attribute, _ := strings.Cut(labelAttribute, "=")
+			if len(validation.IsQualifiedName(label)) > 0 || len(validation.IsQualifiedName(apm.NodeLabelAnnotation(label))) > 0 || strings.ContainsAny(attribute, ";:") {
+				setupLog.Info("invalid node label attribute, expected a node label key optionally followed by =<attribute>", "node_label_attribute", labelAttribute)
+				os.Exit(1)
+			}
+			nodeLabelAttributeList = append(nodeLabelAttributeList, config.NodeLabelAttribute{Label: label, Attribute: attribute})
+		}
+	}
+
+	var agentInitRunAsUser, agentInitRunAsGroup *int64
+	if agentInitUser >= 0 {
+		agentInitRunAsUser = &agentInitUser
+	}
+	if agentInitGroup >= 0 {
+		agentInitRunAsGroup = &agentInitGroup
+	}
+
+	var languageOpts []config.Option
+	if languageScheduling != "" {
+		schedulingByLanguage := map[string]config.Scheduling{}
+		if err := json.Unmarshal([]byte(languageScheduling), &schedulingByLanguage); err != nil {
+			setupLog.Error(err, "invalid language scheduling")
+			os.Exit(1)
+		}
+		for language, scheduling := range schedulingByLanguage {
+			languageOpts = append(languageOpts, config.WithLanguageScheduling(language, scheduling))
+		}
+	}
+
+	if agentWarmup != "" {
+		for _, languageWarmup := range strings.Split(agentWarmup, ",") {
+			language, warmupStr, _ := strings.Cut(languageWarmup, "=")
+			warmup, err := time.ParseDuration(warmupStr)
+			if language == "" || err != nil || warmup < 0 {
+				setupLog.Info("invalid agent warmup, expected language=duration", "warmup", languageWarmup)
+				os.Exit(1)
+			}
+			languageOpts = append(languageOpts, config.WithAgentWarmup(language, warmup))
+		}
+	}
+	if mountPropagation != "" {
+		for _, languageMode := range strings.Split(mountPropagation, ",") {
+			language, modeStr, _ := strings.Cut(languageMode, "=")
+			mode := corev1.MountPropagationMode(modeStr)
+			if language == "" || !slices.Contains([]corev1.MountPropagationMode{
+				corev1.MountPropagationNone, corev1.MountPropagationHostToContainer, corev1.MountPropagationBidirectional,
+			}, mode) {
+				setupLog.Info("invalid agent mount propagation, expected language=None, HostToContainer or Bidirectional", "mountPropagation", languageMode)
+				os.Exit(1)
+			}
+			languageOpts = append(languageOpts, config.WithAgentMountPropagation(language, mode))
+		}
+	}
+
+	if minAgentVersions != "" {
+		for _, languageVersion := range strings.Split(minAgentVersions, ",") {
+			language, minVersion, _ := strings.Cut(languageVersion, "=")
+			if _, err := newreliccomv1beta1.ParseAgentVersion(minVersion); language == "" || err != nil {
+				setupLog.Info("invalid minimum agent version, expected language=version", "version", languageVersion)
+				os.Exit(1)
+			}
+			languageOpts = append(languageOpts, config.WithMinAgentVersion(language, minVersion))
+		}
+	}
+
+	cfgOpts := append([]config.Option{
+		config.WithLogger(ctrl.Log.WithName("config")),
+		config.WithVersion(v),
+		config.WithAutoDetectJitter(autoDetectJitter),
+		config.WithAutoDetectInitialDelay(autoDetectDelay),
+		config.WithAgentInitDeadline(agentInitDeadline),
+		config.WithInitContainerNamePrefix(initContainerPrefix),
+		config.WithAgentVolumeName(agentVolumeName),
+		config.WithMinPodRequests(minPodRequests),
+		config.WithServiceNameLabels(serviceNameLabelKeys),
+		config.WithServiceVersionKeys(serviceVersionKeyList),
+		config.WithInitContainerInsertPosition(config.InitContainerPosition(insertPosition), insertBefore),
+		config.WithAgentContainer(config.AgentContainer{
+			Position: config.AgentContainerPosition(agentContainerPosition),
+			Name:     agentContainerName,
+		}),
+		config.WithAgentImageRollout(agentImageRollout),
+		config.WithPodAgentImages(podAgentImages),
+		config.WithNamespaceRollout(namespaceRollout),
+		config.WithInstrumentationSync(config.InstrumentationSyncPolicy(instrumentationSync)),
+		config.WithAgentReadinessGate(corev1.PodConditionType(agentReadinessGate)),
+		config.WithMaxLanguagesPerPod(maxLanguagesPerPod),
+		config.WithSecretCacheTTL(secretCacheTTL),
+		config.WithSecretCircuitBreaker(secretFailures, secretCooldown),
+		config.WithSecretRetry(secretRetries, secretRetryInterval),
+		config.WithFallbackLicenseKeySecret(fallbackSecret),
+		config.WithExistingAgentEnvVars(existingAgentEnvNames),
+		config.WithStripEnvVars(stripEnvNames),
+		config.WithAttributeLabels(attributeLabelKeys),
+		config.WithNodeLabelAttributes(nodeLabelAttributeList),
+		config.WithWebhookSelfCheckInterval(webhookSelfCheck),
+		config.WithImageRepository(imageRepository),
+		config.WithImageChannel(imageChannel),
+		config.WithAllowedImageRegistries(allowedRegistryList),
+		config.WithOTLPProtocol(config.OTLPProtocol(otlpProtocol)),
+		config.WithOTLPClientCert(otlpClientCert),
+		config.WithOTLPSignalEndpoints(otlpSignalEndpoints),
+		config.WithOpenShiftRoutesChangeCooldown(routesChangeCooldown),
+		config.WithAgentDNS(corev1.DNSPolicy(agentDNSPolicy), agentDNSConfig),
+		config.WithAgentEnvDefaults(agentEnvDefaultVars),
+		config.WithComposeInstrumentations(composeInsts),
+		config.WithAgentInitRunAs(agentInitRunAsUser, agentInitRunAsGroup),
+		config.WithFreezeAutoDetect(freezeAutoDetect),
+		config.WithAgentProfiling(agentProfiling),
+		config.WithClusterName(clusterName),
+		config.WithAgentProxy(agentProxy),
+		config.WithInjectionFailureThreshold(injectionFailures),
+		config.WithMinTerminationGracePeriod(minGracePeriod),
+		config.WithInstrumentationProvider(instProvider),
+		config.WithAgentEnvPrecedence(config.AgentEnvPrecedence(agentEnvPrecedence)),
+		config.WithInjectionConcurrency(maxInjections, injectionQueueWait),
+	}, languageOpts...)
+	if importConfig != "" {
+		document, err := os.ReadFile(importConfig)
+		if err != nil {
+			setupLog.Error(err, "failed to read the configuration to import")
+			os.Exit(1)
+		}
+		importedOpts, err := config.Import(document)
+		if err != nil {
+			setupLog.Error(err, "invalid configuration to import", "path", importConfig)
+			os.Exit(1)
+		}
+		cfgOpts = append(cfgOpts, importedOpts...)
+	}
+	if exportConfig {
+		exportCfg := config.New(cfgOpts...)
+		document, err := exportCfg.Export()
+		if err != nil {
+			setupLog.Error(err, "failed to export the configuration")
+			os.Exit(1)
+		}
+		fmt.Println(string(document))
+		os.Exit(0)
+	}
+
 	// TODO: Start determine usage
 	restConfig := ctrl.GetConfigOrDie()
 
+	if clusterName == "" && clusterNameFrom != "" {
+		resolvedName, err := resolveClusterName(restConfig, clusterNameSource)
+		if err != nil {
+			setupLog.Error(err, "failed to read the cluster name", "source", clusterNameFrom)
+			os.Exit(1)
+		}
+		setupLog.Info("read the cluster name", "source", clusterNameFrom, "cluster_name", resolvedName)
+		cfgOpts = append(cfgOpts, config.WithClusterName(resolvedName))
+	}
+
 	// builds the operator's configuration
 	ad, err := autodetect.New(restConfig)
 	if err != nil {
@@ -140,11 +714,22 @@ func main() {
 		os.Exit(1)
 	}
 
-	cfg := config.New(
-		config.WithLogger(ctrl.Log.WithName("config")),
-		config.WithVersion(v),
-		config.WithAutoDetect(ad),
-	)
+	cfg := config.New(append(cfgOpts, config.WithAutoDetect(ad))...)
+	if err = cfg.Validate(apm.DefaultInjectorRegistry.GetInjectors().Names()); err != nil {
+		setupLog.Error(err, "invalid agent image configuration")
+		os.Exit(1)
+	}
+	if document, err := cfg.Export(); err == nil {
+		setupLog.Info("effective configuration", "sha256", fmt.Sprintf("%x", sha256.Sum256(document)))
+	}
+	for _, registerMetrics := range []func(*config.Config) error{
+		apm.RegisterMetrics, instrumentation.RegisterMetrics, controller.RegisterMetrics, webhook.RegisterMetrics,
+	} {
+		if err = registerMetrics(&cfg); err != nil {
+			setupLog.Error(err, "failed to register metrics")
+			os.Exit(1)
+		}
+	}
 	// End determine usage
 
 	// Metrics endpoint is enabled in 'config/default/kustomization.yaml'. The Metrics options configure the server.
@@ -161,6 +746,11 @@ func main() {
 		// unauthorized access to sensitive metrics data. Consider replacing with CertDir, CertName, and KeyName
 		// to provide certificates, ensuring the server communicates using trusted and secure certificates.
 		TLSOpts: tlsOpts,
+		ExtraHandlers: map[string]http.Handler{
+			config.CapabilitiesPath: config.CapabilitiesHandler(&cfg),
+			config.LogLevelPath:     config.LogLevelHandler(logLevel, setupLog),
+			config.AutoDetectPath:   config.AutoDetectHandler(&cfg),
+		},
 	}
 
 	if secureMetrics {
@@ -220,19 +810,45 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the changes of the detected capabilities are recorded as events of the operator deployment
+	if deploymentName := os.Getenv("OPERATOR_DEPLOYMENT_NAME"); deploymentName != "" {
+		cfg.RegisterEventRecorder(mgr.GetEventRecorderFor("k8s-agents-operator"), &corev1.ObjectReference{
+			APIVersion: "apps/v1", Kind: "Deployment", Namespace: operatorNamespace, Name: deploymentName,
+		})
+	}
+
 	// TODO: Use controller paradigm & investigate below
 	ctx := ctrl.SetupSignalHandler()
-	err = addDependencies(ctx, mgr, cfg)
+	err = addDependencies(ctx, mgr, &cfg)
 	if err != nil {
 		setupLog.Error(err, "failed to add/run bootstrap dependencies to the controller manager")
 		os.Exit(1)
 	}
+	// a SIGHUP re-detects the cluster capabilities right away, for example after installing a CRD
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hangup:
+				cfg.TriggerAutoDetect()
+			case <-ctx.Done():
+				signal.Stop(hangup)
+				return
+			}
+		}
+	}()
 
 	instrumentationStatusUpdater := instrumentation.NewInstrumentationStatusUpdater(mgr.GetClient())
 	healthApi := instrumentation.NewHealthCheckApi(http.DefaultClient)
 	healthMonitor := instrumentation.NewHealthMonitor(
 		instrumentationStatusUpdater, healthApi, healthCheckTickInterval, 50, 50, 2,
+		cfg.AgentReadinessGate(), instrumentationStatusUpdater, cfg.AutoscalingVersion,
 	)
+	cfg.RegisterAutoscalingVersionChangeCallback(func() error {
+		healthMonitor.TriggerHealthCheck()
+		return nil
+	})
 	go func() {
 		<-ctx.Done()
 		logger.Info("Shutting down health checker")
@@ -245,12 +861,39 @@ func main() {
 		}
 	}()
 
-	if err = setupReconcilers(mgr, healthMonitor, operatorNamespace); err != nil {
-		setupLog.Error(err, "failed to setup reconcilers")
+	if tracingEndpoint != "" {
+		shutdownTracing, err := tracing.Setup(ctx, tracingEndpoint, tracingInsecure)
+		if err != nil {
+			setupLog.Error(err, "failed to setup tracing")
+			os.Exit(1)
+		}
+		go func() {
+			<-ctx.Done()
+			stopCtx, stopCtxCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer stopCtxCancel()
+			if err := shutdownTracing(stopCtx); err != nil {
+				setupLog.Error(err, "failed to flush the admission spans")
+			}
+		}()
+	}
+
+	// the reconcilers are added once the first auto-detection completed, so they don't reconcile on the defaults
+	err = mgr.Add(manager.RunnableFunc(func(c context.Context) error {
+		if err := cfg.WaitForFirstDetect(c); err != nil {
+			// the manager is stopping
+			return nil
+		}
+		if err := setupReconcilers(mgr, healthMonitor, operatorNamespace, &cfg); err != nil {
+			return fmt.Errorf("failed to setup reconcilers: %w", err)
+		}
+		return nil
+	}))
+	if err != nil {
+		setupLog.Error(err, "failed to add the reconcilers to the controller manager")
 		os.Exit(1)
 	}
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err = setupWebhooks(mgr, operatorNamespace); err != nil {
+		if err = setupWebhooks(mgr, operatorNamespace, &cfg); err != nil {
 			setupLog.Error(err, "failed to setup webhooks")
 			os.Exit(1)
 		}
@@ -281,24 +924,30 @@ func registerApiHealth(mgr manager.Manager) error {
 	return nil
 }
 
-func setupWebhooks(mgr manager.Manager, operatorNamespace string) error {
+func setupWebhooks(mgr manager.Manager, operatorNamespace string, cfg *config.Config) error {
 	var err error
-	if err = newreliccomv1alpha2.SetupWebhookWithManager(mgr, operatorNamespace); err != nil {
+	if err = newreliccomv1alpha2.SetupWebhookWithManager(mgr, operatorNamespace, cfg.MinAgentVersion, cfg.AllowedImageRegistries()); err != nil {
 		return fmt.Errorf("unable to create v1alpha2 Instrumentation webhook: %w", err)
 	}
 
-	if err = newreliccomv1beta1.SetupWebhookWithManager(mgr, operatorNamespace); err != nil {
+	if err = newreliccomv1beta1.SetupWebhookWithManager(mgr, operatorNamespace, cfg.MinAgentVersion, cfg.AllowedImageRegistries()); err != nil {
 		return fmt.Errorf("unable to create v1beta1 Instrumentation webhook: %w", err)
 	}
 
 	// Register the Pod mutation webhook
-	if err = webhook.SetupWebhookWithManager(mgr, operatorNamespace, ctrl.Log.WithName("mutation-webhook")); err != nil {
+	if err = webhook.SetupWebhookWithManager(mgr, operatorNamespace, ctrl.Log.WithName("mutation-webhook"), cfg); err != nil {
 		return fmt.Errorf("unable to register pod mutation webhook: %w", err)
 	}
+	if interval := cfg.WebhookSelfCheckInterval(); interval > 0 {
+		selfCheck := webhook.NewSelfCheck(mgr.GetClient(), ctrl.Log.WithName("webhook-self-check"), operatorNamespace, interval)
+		if err = mgr.Add(selfCheck); err != nil {
+			return fmt.Errorf("unable to add the webhook self-check: %w", err)
+		}
+	}
 	return nil
 }
 
-func setupReconcilers(mgr manager.Manager, healthMonitor *instrumentation.HealthMonitor, operatorNamespace string) error {
+func setupReconcilers(mgr manager.Manager, healthMonitor *instrumentation.HealthMonitor, operatorNamespace string, cfg *config.Config) error {
 	var err error
 	if err = (&controller.NamespaceReconciler{
 		Client: mgr.GetClient(),
@@ -307,8 +956,10 @@ func setupReconcilers(mgr manager.Manager, healthMonitor *instrumentation.Health
 		return fmt.Errorf("unable to create namespace controller: %w", err)
 	}
 	if err = (&controller.PodReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("k8s-agents-operator"),
+		Config:   cfg,
 	}).SetupWithManager(mgr, healthMonitor, operatorNamespace); err != nil {
 		return fmt.Errorf("unable to create pod controller: %w", err)
 	}
@@ -318,13 +969,78 @@ func setupReconcilers(mgr manager.Manager, healthMonitor *instrumentation.Health
 	}).SetupWithManager(mgr, healthMonitor, operatorNamespace); err != nil {
 		return fmt.Errorf("unable to create instrumentation controller: %w", err)
 	}
+	if err = (&controller.OperatorConfigReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Config: cfg,
+	}).SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("unable to create operator config controller: %w", err)
+	}
+	if cfg.AgentImageRollout() {
+		if err = (&controller.AgentImageRolloutReconciler{
+			Client:   mgr.GetClient(),
+			Config:   cfg,
+			Recorder: mgr.GetEventRecorderFor("k8s-agents-operator"),
+		}).SetupWithManager(mgr, operatorNamespace); err != nil {
+			return fmt.Errorf("unable to create agent image rollout controller: %w", err)
+		}
+	}
+	if cfg.InstrumentationSync() != "" {
+		if err = (&controller.InstrumentationSyncReconciler{
+			Client:   mgr.GetClient(),
+			Config:   cfg,
+			Recorder: mgr.GetEventRecorderFor("k8s-agents-operator"),
+		}).SetupWithManager(mgr, operatorNamespace); err != nil {
+			return fmt.Errorf("unable to create instrumentation sync controller: %w", err)
+		}
+	}
+	if len(cfg.NodeLabelAttributes()) > 0 {
+		if err = (&controller.NodeLabelReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create node label controller: %w", err)
+		}
+	}
+	if cfg.NamespaceRollout() {
+		if err = (&controller.NamespaceRolloutReconciler{
+			Client:   mgr.GetClient(),
+			Config:   cfg,
+			Recorder: mgr.GetEventRecorderFor("k8s-agents-operator"),
+		}).SetupWithManager(mgr, operatorNamespace); err != nil {
+			return fmt.Errorf("unable to create namespace rollout controller: %w", err)
+		}
+	}
 	return nil
 }
 
-func addDependencies(_ context.Context, mgr ctrl.Manager, cfg config.Config) error {
+// resolveClusterName is used to read the cluster name from its source, with a client of its own as the manager isn't
+// started yet
+func resolveClusterName(restConfig *rest.Config, source config.ClusterNameSource) (string, error) {
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the client > %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	name, err := source.Resolve(ctx, c)
+	if err != nil {
+		return "", err
+	}
+	if err = config.ValidateClusterName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func addDependencies(_ context.Context, mgr ctrl.Manager, cfg *config.Config) error {
 	// run the auto-detect mechanism for the configuration
 	err := mgr.Add(manager.RunnableFunc(func(_ context.Context) error {
-		return cfg.StartAutoDetect()
+		// a failed detection is retried periodically, so it's logged rather than returned, which would stop the manager.
+		// the reconcilers wait for the first detection
+		if err := cfg.StartAutoDetect(); err != nil {
+			setupLog.Info("auto-detection failed, retrying periodically", "error", err)
+		}
+		return nil
 	}))
 	if err != nil {
 		return fmt.Errorf("failed to start the auto-detect mechanism: %w", err)
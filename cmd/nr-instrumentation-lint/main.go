@@ -0,0 +1,219 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// nr-instrumentation-lint validates Instrumentation manifests offline, with the rules of the instrumentation validating
+// webhook, so that they can be checked before being applied, for example in CI.
+//
+//	nr-instrumentation-lint [--operator-namespace=newrelic] [--min-agent-versions=java=8.12.0] cr.yaml...
+//
+// Each file may hold several YAML or JSON documents, the ones which aren't instrumentations are skipped, and - reads
+// the standard input. The issues are printed and the exit code is 1 when there are any.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+
+	newreliccomv1alpha2 "github.com/newrelic/k8s-agents-operator/api/v1alpha2"
+	newreliccomv1beta1 "github.com/newrelic/k8s-agents-operator/api/v1beta1"
+)
+
+// linter validates the instrumentations of the manifests, the way the webhooks of the operator would
+type linter struct {
+	operatorNamespace string
+	v1alpha2          newreliccomv1alpha2.InstrumentationValidator
+	v1beta1           newreliccomv1beta1.InstrumentationValidator
+	out               io.Writer
+	issues            int
+}
+
+func main() {
+	var operatorNamespace, minAgentVersions, allowedImageRegistries string
+	flag.StringVar(&operatorNamespace, "operator-namespace", "newrelic",
+		"The namespace the operator runs in, which the instrumentations must be in. Instrumentations without a namespace are linted as if applied to it.")
+	flag.StringVar(&minAgentVersions, "min-agent-versions", "",
+		"The comma separated language=version minimum agent versions, for example java=8.12.0, as configured on the operator.")
+	flag.StringVar(&allowedImageRegistries, "allowed-image-registries", "",
+		"The comma separated registry prefixes the images must be from, for example docker.io/newrelic, as configured on the operator.")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts []config.Option
+	if minAgentVersions != "" {
+		for _, languageVersion := range strings.Split(minAgentVersions, ",") {
+			language, minVersion, _ := strings.Cut(languageVersion, "=")
+			if _, err := newreliccomv1beta1.ParseAgentVersion(minVersion); language == "" || err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "invalid minimum agent version %q, expected language=version\n", languageVersion)
+				os.Exit(2)
+			}
+			opts = append(opts, config.WithMinAgentVersion(language, minVersion))
+		}
+	}
+	if allowedImageRegistries != "" {
+		opts = append(opts, config.WithAllowedImageRegistries(strings.Split(allowedImageRegistries, ",")))
+	}
+	cfg := config.New(opts...)
+
+	l := linter{
+		operatorNamespace: operatorNamespace,
+		v1alpha2: newreliccomv1alpha2.InstrumentationValidator{
+			OperatorNamespace:      operatorNamespace,
+			MinAgentVersion:        cfg.MinAgentVersion,
+			AllowedImageRegistries: cfg.AllowedImageRegistries(),
+		},
+		v1beta1: newreliccomv1beta1.InstrumentationValidator{
+			OperatorNamespace:      operatorNamespace,
+			MinAgentVersion:        cfg.MinAgentVersion,
+			AllowedImageRegistries: cfg.AllowedImageRegistries(),
+		},
+		out: os.Stdout,
+	}
+	for _, path := range flag.Args() {
+		if err := l.lintFile(path); err != nil {
+			l.report(path, "", err)
+		}
+	}
+	if l.issues > 0 {
+		os.Exit(1)
+	}
+}
+
+// lintFile is used to lint every instrumentation of the file, or of the standard input for -
+func (l *linter) lintFile(path string) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the manifests > %w", err)
+	}
+
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var doc json.RawMessage
+		if err = decoder.Decode(&doc); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode the manifests > %w", err)
+		}
+		if len(doc) == 0 || string(doc) == "null" {
+			continue
+		}
+		l.lintDocument(path, doc)
+	}
+}
+
+// lintDocument is used to lint the document when it's an instrumentation of a served version, running the defaulting
+// and validating webhooks of the version
+func (l *linter) lintDocument(path string, doc json.RawMessage) {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(doc, &typeMeta); err != nil {
+		l.report(path, "", fmt.Errorf("failed to decode the document > %w", err))
+		return
+	}
+	if typeMeta.Kind != "Instrumentation" {
+		return
+	}
+
+	var name string
+	var warnings admission.Warnings
+	var err error
+	switch typeMeta.APIVersion {
+	case newreliccomv1beta1.GroupVersion.String():
+		inst := &newreliccomv1beta1.Instrumentation{}
+		if err = decodeStrict(doc, inst); err == nil {
+			name = l.defaultNamespace(&inst.ObjectMeta)
+			if err = (&newreliccomv1beta1.InstrumentationDefaulter{}).Default(context.Background(), inst); err == nil {
+				warnings, err = l.v1beta1.Validate(inst)
+			}
+		}
+	case newreliccomv1alpha2.GroupVersion.String():
+		inst := &newreliccomv1alpha2.Instrumentation{}
+		if err = decodeStrict(doc, inst); err == nil {
+			name = l.defaultNamespace(&inst.ObjectMeta)
+			if err = (&newreliccomv1alpha2.InstrumentationDefaulter{}).Default(context.Background(), inst); err == nil {
+				warnings, err = l.v1alpha2.Validate(inst)
+			}
+		}
+	default:
+		if strings.HasPrefix(typeMeta.APIVersion, newreliccomv1beta1.GroupVersion.Group+"/") {
+			err = fmt.Errorf("apiVersion %q isn't served, expected %s or %s", typeMeta.APIVersion, newreliccomv1beta1.GroupVersion, newreliccomv1alpha2.GroupVersion)
+		} else {
+			return
+		}
+	}
+	for _, warning := range warnings {
+		_, _ = fmt.Fprintf(l.out, "%s: %swarning: %s\n", path, instrumentationPrefix(name), warning)
+	}
+	if err != nil {
+		l.report(path, name, err)
+	}
+}
+
+// defaultNamespace is used to put the instrumentation without a namespace in the operator namespace, the way applying
+// it to that namespace would, returning its namespaced name
+func (l *linter) defaultNamespace(meta *metav1.ObjectMeta) string {
+	if meta.Namespace == "" {
+		meta.Namespace = l.operatorNamespace
+	}
+	return meta.Namespace + "/" + meta.Name
+}
+
+// report is used to print an issue of the file, and of the instrumentation when named
+func (l *linter) report(path, name string, err error) {
+	l.issues++
+	_, _ = fmt.Fprintf(l.out, "%s: %s%s\n", path, instrumentationPrefix(name), err)
+}
+
+func instrumentationPrefix(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf("instrumentation %s: ", name)
+}
+
+// decodeStrict is used to decode the instrumentation, failing on unknown fields the way kubectl does by default
+func decodeStrict(doc json.RawMessage, inst any) error {
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(inst); err != nil {
+		return fmt.Errorf("failed to decode the instrumentation > %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,455 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// nr-instrumentation-patch prints the patch the pod webhook of the operator would apply to the pods of a workload, so
+// that the instrumentation can be baked into the manifests at build time rather than at admission, for example as a
+// kustomize patch.
+//
+//	nr-instrumentation-patch --instrumentations=instrumentations.yaml [--format=json6902] workload.yaml
+//
+// The workload is a Pod, Deployment, StatefulSet, DaemonSet, ReplicaSet, Job or CronJob, and - reads it from the
+// standard input. The patch is printed as JSON, which kustomize accepts as YAML. The license key secrets the patch
+// refers to aren't replicated, they're listed on the standard error, as they must exist in the namespace the workload
+// is deployed to.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	newreliccomv1alpha2 "github.com/newrelic/k8s-agents-operator/api/v1alpha2"
+	newreliccomv1beta1 "github.com/newrelic/k8s-agents-operator/api/v1beta1"
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+	"github.com/newrelic/k8s-agents-operator/internal/instrumentation"
+)
+
+const (
+	formatJSON6902       = "json6902"
+	formatStrategicMerge = "strategic-merge"
+)
+
+// instrumentationVersionAnnotation is the annotation recording the uid and generation of the instrumentations a pod
+// was injected by, which the patch leaves out, as they're only known once the instrumentations are applied
+const instrumentationVersionAnnotation = "newrelic.com/instrumentation-versions"
+
+// workload is the pod template of the workload, with what's needed to put the patch of its pods back into it
+type workload struct {
+	typeMeta   metav1.TypeMeta
+	objectMeta metav1.ObjectMeta
+	template   corev1.PodTemplateSpec
+	// templatePath is the json pointer of the pod template in the workload, empty for a pod
+	templatePath []string
+	// ownerKinds are the owner kinds of the pods of the workload, as matched by the instrumentations owner kinds
+	ownerKinds []string
+}
+
+// noopSecretReplicator leaves the license key secret alone, as it's replicated when the pods are created anyway
+type noopSecretReplicator struct{}
+
+func (noopSecretReplicator) ReplicateSecret(context.Context, corev1.Namespace, corev1.Pod, string, string) error {
+	return nil
+}
+
+func main() {
+	var instrumentationsPath, configPath, operatorNamespace, namespace, namespaceLabels, format string
+	var matching bool
+	flag.StringVar(&instrumentationsPath, "instrumentations", "",
+		"The file of the instrumentations the workload is matched against, as applied to the cluster.")
+	flag.StringVar(&configPath, "config", "",
+		"The operator configuration exported with --export-config, so that the patch matches the settings of the operator.")
+	flag.StringVar(&operatorNamespace, "operator-namespace", "newrelic",
+		"The namespace the operator runs in. Instrumentations without a namespace are taken as if applied to it.")
+	flag.StringVar(&namespace, "namespace", "",
+		"The namespace the workload is deployed to, the namespace of the workload or default when unset.")
+	flag.StringVar(&namespaceLabels, "namespace-labels", "",
+		"The comma separated key=value labels of the namespace, matched by the instrumentations namespace label selectors.")
+	flag.StringVar(&format, "format", formatJSON6902,
+		"The format of the patch, json6902 or strategic-merge.")
+	flag.BoolVar(&matching, "matching", false,
+		"If set, the names of every instrumentation matching the pods of the workload are printed instead of the patch, including the ones of the same language of which only one is injected.")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] workload\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || instrumentationsPath == "" || (format != formatJSON6902 && format != formatStrategicMerge) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	patch, err := run(os.Stderr, instrumentationsPath, configPath, operatorNamespace, namespace, namespaceLabels, format, matching, flag.Arg(0))
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	_, _ = os.Stdout.Write(append(patch, '\n'))
+}
+
+func run(stderr io.Writer, instrumentationsPath, configPath, operatorNamespace, namespace, namespaceLabels, format string, matching bool, workloadPath string) ([]byte, error) {
+	var opts []config.Option
+	if configPath != "" {
+		document, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the operator configuration > %w", err)
+		}
+		if opts, err = config.Import(document); err != nil {
+			return nil, fmt.Errorf("failed to import the operator configuration > %w", err)
+		}
+	}
+	cfg := config.New(opts...)
+
+	data, err := readFile(instrumentationsPath)
+	if err != nil {
+		return nil, err
+	}
+	insts, err := decodeInstrumentations(data, operatorNamespace)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = readFile(workloadPath); err != nil {
+		return nil, err
+	}
+	w, err := decodeWorkload(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: map[string]string{}}}
+	if ns.Name == "" {
+		ns.Name = w.objectMeta.Namespace
+	}
+	if ns.Name == "" {
+		ns.Name = metav1.NamespaceDefault
+	}
+	if namespaceLabels != "" {
+		for _, label := range strings.Split(namespaceLabels, ",") {
+			key, value, ok := strings.Cut(label, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid namespace label %q, expected key=value", label)
+			}
+			ns.Labels[key] = value
+		}
+	}
+	ns.Labels[corev1.LabelMetadataName] = ns.Name
+
+	pod := corev1.Pod{ObjectMeta: *w.template.ObjectMeta.DeepCopy(), Spec: *w.template.Spec.DeepCopy()}
+	pod.Namespace = ns.Name
+	if pod.Name == "" && pod.GenerateName == "" {
+		pod.GenerateName = w.objectMeta.Name + "-"
+	}
+	if matching {
+		return matchingInstrumentations(ns, pod, insts, operatorNamespace, w.ownerKinds)
+	}
+	original, err := json.Marshal(pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the pod > %w", err)
+	}
+
+	logger := logr.Discard()
+	mutator := instrumentation.NewMutator(
+		logger,
+		nil,
+		instrumentation.NewNewrelicSdkInjector(logger, nil, apm.DefaultInjectorRegistry, &cfg),
+		noopSecretReplicator{},
+		instrumentation.NewStaticInstrumentationLocator(logger, insts, operatorNamespace, w.ownerKinds),
+		operatorNamespace,
+	)
+	mutator.ConfigureCompose(cfg.ComposeInstrumentations())
+	// the mutator changes the maps and slices of the pod in place
+	pristine := pod.DeepCopy()
+	mutatedPod, err := mutator.Mutate(context.Background(), ns, pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instrument the pods of the workload > %w", err)
+	}
+	// the instrumentation versions and the operator version are those of the cluster at admission, they'd be stale in
+	// the manifests, and would change the patch with every release of the operator
+	restoreMetadata(&mutatedPod.Annotations, pristine.Annotations, instrumentationVersionAnnotation)
+	restoreMetadata(&mutatedPod.Labels, pristine.Labels, apm.DescK8sAgentOperatorVersionLabelName)
+	for _, secretName := range licenseKeySecrets(*pristine, mutatedPod) {
+		_, _ = fmt.Fprintf(stderr, "the license key secret %q must exist in namespace %s\n", secretName, ns.Name)
+	}
+	mutated, err := json.Marshal(mutatedPod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the instrumented pod > %w", err)
+	}
+
+	if format == formatStrategicMerge {
+		return strategicMergePatch(w, original, mutated)
+	}
+	return json6902Patch(w, original, mutated)
+}
+
+// restoreMetadata is used to set the annotation or label of the mutated pod back to the one of the original pod
+func restoreMetadata(mutated *map[string]string, original map[string]string, key string) {
+	if value, ok := original[key]; ok {
+		(*mutated)[key] = value
+		return
+	}
+	delete(*mutated, key)
+	if len(*mutated) == 0 && original == nil {
+		*mutated = nil
+	}
+}
+
+// licenseKeySecrets is used to get the names of the license key secrets the containers of the mutated pod refer to,
+// which the original pod doesn't
+func licenseKeySecrets(original, mutated corev1.Pod) []string {
+	secretsOf := func(pod corev1.Pod) []string {
+		var names []string
+		for _, container := range slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers) {
+			for _, env := range container.Env {
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Key == apm.LicenseKey {
+					names = append(names, env.ValueFrom.SecretKeyRef.Name)
+				}
+			}
+		}
+		return names
+	}
+	existing := secretsOf(original)
+	var names []string
+	for _, name := range secretsOf(mutated) {
+		if !slices.Contains(existing, name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// matchingInstrumentations is used to get the namespaced names of every instrumentation matching the pod
+func matchingInstrumentations(ns corev1.Namespace, pod corev1.Pod, insts []current.Instrumentation, operatorNamespace string, ownerKinds []string) ([]byte, error) {
+	matching, err := instrumentation.MatchingInstrumentations(context.Background(), ns, pod, insts, operatorNamespace, ownerKinds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match the instrumentations > %w", err)
+	}
+	names := make([]string, 0, len(matching))
+	for _, inst := range matching {
+		names = append(names, inst.Namespace+"/"+inst.Name)
+	}
+	encoded, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the instrumentations > %w", err)
+	}
+	return encoded, nil
+}
+
+// json6902Patch is used to get the json patch the webhook would apply, with the paths moved into the pod template of
+// the workload
+func json6902Patch(w workload, original, mutated []byte) ([]byte, error) {
+	res := admission.PatchResponseFromRaw(original, mutated)
+	if !res.Allowed {
+		return nil, fmt.Errorf("failed to compute the patch > %s", res.Result.Message)
+	}
+	prefix := ""
+	for _, segment := range w.templatePath {
+		prefix += "/" + segment
+	}
+	for i := range res.Patches {
+		res.Patches[i].Path = prefix + res.Patches[i].Path
+	}
+	patch, err := json.MarshalIndent(res.Patches, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the patch > %w", err)
+	}
+	return patch, nil
+}
+
+// strategicMergePatch is used to get the strategic merge patch of the pods, nested into the pod template of the
+// workload along with what identifies the workload
+func strategicMergePatch(w workload, original, mutated []byte) ([]byte, error) {
+	podPatch, err := strategicpatch.CreateTwoWayMergePatch(original, mutated, corev1.Pod{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the patch > %w", err)
+	}
+	var patch map[string]any
+	if err = json.Unmarshal(podPatch, &patch); err != nil {
+		return nil, fmt.Errorf("failed to decode the patch > %w", err)
+	}
+	for i := len(w.templatePath) - 1; i >= 0; i-- {
+		patch = map[string]any{w.templatePath[i]: patch}
+	}
+	metadata, _ := patch["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata["name"] = w.objectMeta.Name
+	if w.objectMeta.Namespace != "" {
+		metadata["namespace"] = w.objectMeta.Namespace
+	} else {
+		delete(metadata, "namespace")
+	}
+	patch["metadata"] = metadata
+	patch["apiVersion"] = w.typeMeta.APIVersion
+	patch["kind"] = w.typeMeta.Kind
+	encoded, err := json.MarshalIndent(patch, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the patch > %w", err)
+	}
+	return encoded, nil
+}
+
+// readFile is used to read the file, or the standard input for -
+func readFile(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s > %w", path, err)
+	}
+	return data, nil
+}
+
+// decodeDocuments is used to decode every YAML or JSON document of the manifests
+func decodeDocuments(data []byte) ([]json.RawMessage, error) {
+	var docs []json.RawMessage
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var doc json.RawMessage
+		if err := decoder.Decode(&doc); errors.Is(err, io.EOF) {
+			return docs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode the manifests > %w", err)
+		}
+		if len(doc) == 0 || string(doc) == "null" {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// decodeInstrumentations is used to decode the instrumentations of the manifests, defaulted and converted to the
+// current version the way the operator reads them. The other documents are skipped.
+func decodeInstrumentations(data []byte, operatorNamespace string) ([]current.Instrumentation, error) {
+	docs, err := decodeDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	var insts []current.Instrumentation
+	for _, doc := range docs {
+		var typeMeta metav1.TypeMeta
+		if err = json.Unmarshal(doc, &typeMeta); err != nil {
+			return nil, fmt.Errorf("failed to decode the document > %w", err)
+		}
+		if typeMeta.Kind != "Instrumentation" {
+			continue
+		}
+		var inst current.Instrumentation
+		switch typeMeta.APIVersion {
+		case newreliccomv1beta1.GroupVersion.String():
+			if err = json.Unmarshal(doc, &inst); err != nil {
+				return nil, fmt.Errorf("failed to decode the instrumentation > %w", err)
+			}
+		case newreliccomv1alpha2.GroupVersion.String():
+			src := &newreliccomv1alpha2.Instrumentation{}
+			if err = json.Unmarshal(doc, src); err != nil {
+				return nil, fmt.Errorf("failed to decode the instrumentation > %w", err)
+			}
+			if err = src.ConvertTo(&inst); err != nil {
+				return nil, fmt.Errorf("failed to convert instrumentation %s > %w", src.Name, err)
+			}
+		default:
+			continue
+		}
+		if inst.Namespace == "" {
+			inst.Namespace = operatorNamespace
+		}
+		if err = (&newreliccomv1beta1.InstrumentationDefaulter{}).Default(context.Background(), &inst); err != nil {
+			return nil, fmt.Errorf("failed to default instrumentation %s > %w", inst.Name, err)
+		}
+		insts = append(insts, inst)
+	}
+	if len(insts) == 0 {
+		return nil, errors.New("no instrumentation found in the manifests")
+	}
+	return insts, nil
+}
+
+// decodeWorkload is used to decode the first workload of the manifests
+func decodeWorkload(data []byte) (workload, error) {
+	docs, err := decodeDocuments(data)
+	if err != nil {
+		return workload{}, err
+	}
+	for _, doc := range docs {
+		var typeMeta metav1.TypeMeta
+		if err = json.Unmarshal(doc, &typeMeta); err != nil {
+			return workload{}, fmt.Errorf("failed to decode the document > %w", err)
+		}
+		w := workload{typeMeta: typeMeta, templatePath: []string{"spec", "template"}}
+		switch typeMeta.Kind {
+		case "Pod":
+			var obj corev1.Pod
+			err = json.Unmarshal(doc, &obj)
+			w.objectMeta, w.template = obj.ObjectMeta, corev1.PodTemplateSpec{ObjectMeta: obj.ObjectMeta, Spec: obj.Spec}
+			w.templatePath, w.ownerKinds = nil, []string{"Pod"}
+		case "Deployment":
+			var obj appsv1.Deployment
+			err = json.Unmarshal(doc, &obj)
+			w.objectMeta, w.template, w.ownerKinds = obj.ObjectMeta, obj.Spec.Template, []string{"ReplicaSet", "Deployment"}
+		case "StatefulSet":
+			var obj appsv1.StatefulSet
+			err = json.Unmarshal(doc, &obj)
+			w.objectMeta, w.template, w.ownerKinds = obj.ObjectMeta, obj.Spec.Template, []string{"StatefulSet"}
+		case "DaemonSet":
+			var obj appsv1.DaemonSet
+			err = json.Unmarshal(doc, &obj)
+			w.objectMeta, w.template, w.ownerKinds = obj.ObjectMeta, obj.Spec.Template, []string{"DaemonSet"}
+		case "ReplicaSet":
+			var obj appsv1.ReplicaSet
+			err = json.Unmarshal(doc, &obj)
+			w.objectMeta, w.template, w.ownerKinds = obj.ObjectMeta, obj.Spec.Template, []string{"ReplicaSet"}
+		case "Job":
+			var obj batchv1.Job
+			err = json.Unmarshal(doc, &obj)
+			w.objectMeta, w.template, w.ownerKinds = obj.ObjectMeta, obj.Spec.Template, []string{"Job"}
+		case "CronJob":
+			var obj batchv1.CronJob
+			err = json.Unmarshal(doc, &obj)
+			w.objectMeta, w.template, w.ownerKinds = obj.ObjectMeta, obj.Spec.JobTemplate.Spec.Template, []string{"Job", "CronJob"}
+			w.templatePath = []string{"spec", "jobTemplate", "spec", "template"}
+		default:
+			continue
+		}
+		if err != nil {
+			return workload{}, fmt.Errorf("failed to decode %s %s > %w", typeMeta.Kind, w.objectMeta.Name, err)
+		}
+		return w, nil
+	}
+	return workload{}, errors.New("no workload found in the manifests")
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+)
+
+const testInstrumentations = `apiVersion: newrelic.com/v1beta1
+kind: Instrumentation
+metadata:
+  name: java
+spec:
+  agent:
+    language: java
+    image: newrelic/newrelic-java-init:latest
+  licenseKeySecret: shop-license-key
+  podLabelSelector:
+    matchLabels:
+      app: shop
+`
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  selector:
+    matchLabels:
+      app: shop
+  template:
+    metadata:
+      labels:
+        app: shop
+    spec:
+      containers:
+      - name: shop
+        image: shop:latest
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	instrumentationsPath := filepath.Join(dir, "instrumentations.yaml")
+	workloadPath := filepath.Join(dir, "deployment.yaml")
+	require.NoError(t, os.WriteFile(instrumentationsPath, []byte(testInstrumentations), 0o600))
+	require.NoError(t, os.WriteFile(workloadPath, []byte(testDeployment), 0o600))
+
+	for _, format := range []string{formatJSON6902, formatStrategicMerge} {
+		t.Run(format, func(t *testing.T) {
+			var stderr bytes.Buffer
+			patch, err := run(&stderr, instrumentationsPath, "", "newrelic", "", "", format, false, workloadPath)
+			require.NoError(t, err)
+			assert.True(t, json.Valid(patch))
+			assert.Contains(t, string(patch), "newrelic-instrumentation-java")
+			assert.NotContains(t, string(patch), instrumentationVersionAnnotation)
+			assert.NotContains(t, string(patch), apm.DescK8sAgentOperatorVersionLabelName)
+			assert.Equal(t, "the license key secret \"shop-license-key\" must exist in namespace shop\n", stderr.String())
+		})
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apm
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+// ResolveAppName is used to build the app name of the pod from the app name template of the instrumentation, with the
+// container name taken from the agent container. If a placeholder of the template can't be resolved, the fallback of
+// the instrumentation is returned with an error naming the placeholder.
+func ResolveAppName(inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod, agentContainer config.AgentContainer) (string, error) {
+	template := inst.Spec.AppName.Template
+	var name strings.Builder
+	for {
+		start := strings.Index(template, "{")
+		if start == -1 {
+			name.WriteString(template)
+			return name.String(), nil
+		}
+		end := strings.Index(template[start:], "}")
+		if end == -1 {
+			return inst.Spec.AppName.Fallback, fmt.Errorf("unclosed placeholder %q", template[start:])
+		}
+		placeholder := template[start+1 : start+end]
+		value, ok := resolveAppNamePlaceholder(placeholder, ns, pod, agentContainer)
+		if !ok {
+			return inst.Spec.AppName.Fallback, fmt.Errorf("none of the sources of placeholder {%s} are set", placeholder)
+		}
+		name.WriteString(template[:start])
+		name.WriteString(value)
+		template = template[start+end+1:]
+	}
+}
+
+// resolveAppNamePlaceholder is used to get the value of the first source of the placeholder that is set
+func resolveAppNamePlaceholder(placeholder string, ns corev1.Namespace, pod corev1.Pod, agentContainer config.AgentContainer) (string, bool) {
+	for _, source := range strings.Split(placeholder, "|") {
+		source = strings.TrimSpace(source)
+		if len(source) >= 2 && strings.HasPrefix(source, "'") && strings.HasSuffix(source, "'") {
+			return source[1 : len(source)-1], true
+		}
+		if value := appNameSource(source, ns, pod, agentContainer); value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// appNameSource is used to get the value of a source of the app name template, empty when it's unset or unknown
+func appNameSource(source string, ns corev1.Namespace, pod corev1.Pod, agentContainer config.AgentContainer) string {
+	switch {
+	case source == "namespace.name":
+		if ns.Name != "" {
+			return ns.Name
+		}
+		return pod.Namespace
+	case source == "pod.name":
+		return pod.Name
+	case source == "owner.name":
+		return ownerName(pod)
+	case source == "container.name":
+		if len(pod.Spec.Containers) == 0 {
+			return ""
+		}
+		return pod.Spec.Containers[getAgentContainerIndex(pod, agentContainer)].Name
+	case strings.HasPrefix(source, "namespace.labels."):
+		return ns.Labels[strings.TrimPrefix(source, "namespace.labels.")]
+	case strings.HasPrefix(source, "pod.labels."):
+		return pod.Labels[strings.TrimPrefix(source, "pod.labels.")]
+	case strings.HasPrefix(source, "pod.annotations."):
+		return pod.Annotations[strings.TrimPrefix(source, "pod.annotations.")]
+	}
+	return ""
+}
+
+// injectAppName sets the app name built from the app name template of the instrumentation, unless the container
+// already sets one. When the template can't be resolved and there's no fallback, the default app name is left to be
+// chosen by injectNewrelicEnvConfig.
+func (i *baseInjector) injectAppName(container *corev1.Container, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) {
+	if inst.Spec.AppName.Template == "" {
+		return
+	}
+	name, err := ResolveAppName(inst, ns, pod, config.AgentContainer{Position: config.AgentContainerPositionNamed, Name: container.Name})
+	if err != nil {
+		i.logger.V(1).Info("app name template not resolved", "template", inst.Spec.AppName.Template, "fallback", name, "reason", err.Error())
+	}
+	if name == "" {
+		return
+	}
+	setEnvVar(container, EnvNewRelicAppName, name, false)
+}
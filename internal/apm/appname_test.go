@@ -0,0 +1,89 @@
+package apm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestResolveAppName(t *testing.T) {
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"env": "prod", "team": "payments"}}}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "checkout-5d8f-abcde",
+			Labels:          map[string]string{"app": "checkout"},
+			Annotations:     map[string]string{"example.com/service": "checkout-api"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "checkout"}},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+	}
+	tests := []struct {
+		name          string
+		appName       current.AppName
+		expected      string
+		expectedError string
+	}{
+		{name: "no placeholders", appName: current.AppName{Template: "checkout"}, expected: "checkout"},
+		{name: "namespace labels and owner", appName: current.AppName{Template: "{namespace.labels.env}-{namespace.labels.team}-{owner.name}"}, expected: "prod-payments-checkout"},
+		{name: "pod metadata", appName: current.AppName{Template: "{pod.labels.app}/{pod.annotations.example.com/service}/{container.name}"}, expected: "checkout/checkout-api/web"},
+		{name: "names", appName: current.AppName{Template: "{namespace.name}.{pod.name}"}, expected: "shop.checkout-5d8f-abcde"},
+		{name: "fallback chain", appName: current.AppName{Template: "{pod.labels.service | pod.labels.app}"}, expected: "checkout"},
+		{name: "literal", appName: current.AppName{Template: "{pod.labels.tier|'web'}"}, expected: "web"},
+		{
+			name:          "unresolved",
+			appName:       current.AppName{Template: "{namespace.labels.region}-{owner.name}", Fallback: "unnamed"},
+			expected:      "unnamed",
+			expectedError: "none of the sources of placeholder {namespace.labels.region} are set",
+		},
+		{
+			name:          "unknown source",
+			appName:       current.AppName{Template: "{node.name}"},
+			expectedError: "none of the sources of placeholder {node.name} are set",
+		},
+		{
+			name:          "unclosed",
+			appName:       current.AppName{Template: "{owner.name", Fallback: "unnamed"},
+			expected:      "unnamed",
+			expectedError: `unclosed placeholder "{owner.name"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inst := current.Instrumentation{Spec: current.InstrumentationSpec{AppName: test.appName}}
+			actual, err := ResolveAppName(inst, ns, pod, config.AgentContainer{})
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			assert.Equal(t, test.expectedError, errStr)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestBaseInjector_InjectAppName(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "checkout"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+	}
+	i := &baseInjector{}
+
+	container := pod.Spec.Containers[0]
+	i.injectAppName(&container, current.Instrumentation{Spec: current.InstrumentationSpec{AppName: current.AppName{Template: "{pod.labels.app}"}}}, corev1.Namespace{}, pod)
+	assert.Equal(t, []corev1.EnvVar{{Name: EnvNewRelicAppName, Value: "checkout"}}, container.Env)
+
+	// without a fallback, the default app name is used
+	container = pod.Spec.Containers[0]
+	i.injectAppName(&container, current.Instrumentation{Spec: current.InstrumentationSpec{AppName: current.AppName{Template: "{pod.labels.service}"}}}, corev1.Namespace{}, pod)
+	assert.Empty(t, container.Env)
+
+	// the container's own app name wins
+	container = corev1.Container{Name: "web", Env: []corev1.EnvVar{{Name: EnvNewRelicAppName, Value: "mine"}}}
+	i.injectAppName(&container, current.Instrumentation{Spec: current.InstrumentationSpec{AppName: current.AppName{Template: "{pod.labels.app}"}}}, corev1.Namespace{}, pod)
+	assert.Equal(t, []corev1.EnvVar{{Name: EnvNewRelicAppName, Value: "mine"}}, container.Env)
+}
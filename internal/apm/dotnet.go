@@ -29,12 +29,12 @@ const (
 	envDotnetNewrelicHome               = "CORECLR_NEWRELIC_HOME"
 	dotnetCoreClrEnableProfilingEnabled = "1"
 	dotnetCoreClrProfilerID             = "{36032161-FFC0-4B61-B559-F6C5D41BAE5A}"
-	dotnetCoreClrProfilerPath           = "/newrelic-instrumentation/libNewRelicProfiler.so"
-	dotnetNewrelicHomePath              = "/newrelic-instrumentation"
-	dotnetInitContainerName             = initContainerName + "-dotnet"
+	dotnetCoreClrProfilerPath           = agentVolumeMountPath + "/libNewRelicProfiler.so"
+	dotnetNewrelicHomePath              = agentVolumeMountPath
 )
 
 var _ Injector = (*DotnetInjector)(nil)
+var _ LanguageInjector = (*DotnetInjector)(nil)
 
 func init() {
 	DefaultInjectorRegistry.MustRegister(&DotnetInjector{})
@@ -48,77 +48,24 @@ func (i *DotnetInjector) Language() string {
 	return "dotnet"
 }
 
-func (i *DotnetInjector) acceptable(inst current.Instrumentation, pod corev1.Pod) bool {
-	if inst.Spec.Agent.Language != i.Language() {
-		return false
-	}
-	if len(pod.Spec.Containers) == 0 {
-		return false
-	}
-	return true
+func (i *DotnetInjector) Name() string {
+	return i.Language()
 }
 
-func (i DotnetInjector) Inject(ctx context.Context, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
-	if !i.acceptable(inst, pod) {
-		return pod, nil
-	}
-	if err := i.validate(inst); err != nil {
-		return pod, err
-	}
-
-	firstContainer := 0
-	// caller checks if there is at least one container.
-	container := &pod.Spec.Containers[firstContainer]
-
-	// inject .NET instrumentation spec env vars.
-	for _, env := range inst.Spec.Agent.Env {
-		idx := getIndexOfEnv(container.Env, env.Name)
-		if idx == -1 {
-			container.Env = append(container.Env, env)
-		}
-	}
+func (i *DotnetInjector) Inject(ctx context.Context, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
+	return i.injectLanguage(ctx, i, inst, ns, pod)
+}
 
+func (i *DotnetInjector) InjectEnv(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int) error {
+	container := &pod.Spec.Containers[containerIndex]
 	setEnvVar(container, envDotnetCoreClrEnableProfiling, dotnetCoreClrEnableProfilingEnabled, false)
 	setEnvVar(container, envDotnetCoreClrProfiler, dotnetCoreClrProfilerID, false)
 	setEnvVar(container, envDotnetCoreClrProfilerPath, dotnetCoreClrProfilerPath, false)
 	setEnvVar(container, envDotnetNewrelicHome, dotnetNewrelicHomePath, false)
+	return nil
+}
 
-	if isContainerVolumeMissing(container, volumeName) {
-		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-			Name:      volumeName,
-			MountPath: "/newrelic-instrumentation",
-		})
-	}
-
-	// We just inject Volumes and init containers for the first processed container.
-	if isInitContainerMissing(pod, dotnetInitContainerName) {
-		if isPodVolumeMissing(pod, volumeName) {
-			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-				Name: volumeName,
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{},
-				}})
-		}
-
-		pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{
-			Name:    dotnetInitContainerName,
-			Image:   inst.Spec.Agent.Image,
-			Command: []string{"cp", "-a", "/instrumentation/.", "/newrelic-instrumentation/"},
-			VolumeMounts: []corev1.VolumeMount{{
-				Name:      volumeName,
-				MountPath: "/newrelic-instrumentation",
-			}},
-		})
-	}
-
-	pod = i.injectNewrelicConfig(ctx, ns, pod, firstContainer, inst.Spec.LicenseKeySecret)
-
-	pod = addAnnotationToPodFromInstrumentationVersion(ctx, pod, inst)
-
-	var err error
-	if pod, err = i.injectHealth(ctx, inst, ns, pod, firstContainer, -1); err != nil {
-		return pod, err
-	}
-
-	return pod, nil
+func (i *DotnetInjector) InjectInitContainer(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int, initContainerName string) error {
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, copyAgentInitContainer(inst, initContainerName))
+	return nil
 }
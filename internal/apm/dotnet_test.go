@@ -72,6 +72,7 @@ func TestDotnetInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				},
@@ -86,6 +87,9 @@ func TestDotnetInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
@@ -19,10 +19,12 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
 )
@@ -35,6 +37,8 @@ const (
 	healthVolumeName                      = "newrelic-apm-health-volume"
 
 	HealthInstrumentedAnnotation = "newrelic.com/apm-health"
+
+	healthProbePath = "/healthz"
 )
 
 const (
@@ -47,6 +51,13 @@ var (
 	defaultHealthDeliveryLocation = "file:///newrelic/apm/health"
 )
 
+// the defaults of the health probes, leaving the agent 30s to connect before a failing health check counts
+var (
+	defaultHealthProbeInitialDelaySeconds int32 = 30
+	defaultHealthProbePeriodSeconds       int32 = 10
+	defaultHealthProbeFailureThreshold    int32 = 3
+)
+
 var healthDefaultEnv = []corev1.EnvVar{
 	{Name: envAgentControlHealthDeliveryLocation, Value: defaultHealthDeliveryLocation},
 	{Name: envHealthListenPort, Value: fmt.Sprintf("%d", defaultHealthListenPort)},
@@ -58,7 +69,7 @@ func (i *baseInjector) injectHealth(ctx context.Context, inst current.Instrument
 	}
 
 	originalPod := pod.DeepCopy()
-	firstContainer := 0
+	firstContainer := i.agentContainerIndex(pod)
 
 	// caller checks if there is at least one container.
 	var container *corev1.Container
@@ -156,8 +167,16 @@ func (i *baseInjector) injectHealth(ctx context.Context, inst current.Instrument
 		}
 
 		pod.Spec.InitContainers = append(pod.Spec.InitContainers, sidecarContainer)
+		injectPodSecurity(&pod, ns, HealthSidecarContainerName)
+	}
+
+	// init containers which aren't sidecars can't be probed
+	if agentContainerIndex > -1 {
+		injectHealthProbes(container, inst.Spec.HealthAgent.Probes, sidecarListenPort)
 	}
 
+	i.injectReadinessGate(&pod)
+
 	if pod.Annotations == nil {
 		pod.Annotations = map[string]string{}
 	}
@@ -166,6 +185,54 @@ func (i *baseInjector) injectHealth(ctx context.Context, inst current.Instrument
 	return pod, nil
 }
 
+// injectReadinessGate is used to add the configured agent readiness gate, which the health monitor sets once the health
+// agent reports the agent healthy
+func (i *baseInjector) injectReadinessGate(pod *corev1.Pod) {
+	conditionType := i.configuration().AgentReadinessGate()
+	if conditionType == "" {
+		return
+	}
+	if slices.ContainsFunc(pod.Spec.ReadinessGates, func(g corev1.PodReadinessGate) bool { return g.ConditionType == conditionType }) {
+		return
+	}
+	pod.Spec.ReadinessGates = append(pod.Spec.ReadinessGates, corev1.PodReadinessGate{ConditionType: conditionType})
+}
+
+// injectHealthProbes is used to add the opted in liveness and readiness probes of the agent container against the
+// health endpoint of the health sidecar, which shares the network of the pod. A probe the container defines itself is
+// kept, as only one of each is allowed.
+func injectHealthProbes(container *corev1.Container, probes *current.HealthProbes, listenPort int) {
+	if probes == nil {
+		return
+	}
+	newProbe := func() *corev1.Probe {
+		probe := &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: healthProbePath, Port: intstr.FromInt32(int32(listenPort))},
+			},
+			InitialDelaySeconds: defaultHealthProbeInitialDelaySeconds,
+			PeriodSeconds:       defaultHealthProbePeriodSeconds,
+			FailureThreshold:    defaultHealthProbeFailureThreshold,
+		}
+		if probes.InitialDelaySeconds > 0 {
+			probe.InitialDelaySeconds = probes.InitialDelaySeconds
+		}
+		if probes.PeriodSeconds > 0 {
+			probe.PeriodSeconds = probes.PeriodSeconds
+		}
+		if probes.FailureThreshold > 0 {
+			probe.FailureThreshold = probes.FailureThreshold
+		}
+		return probe
+	}
+	if probes.Liveness && container.LivenessProbe == nil {
+		container.LivenessProbe = newProbe()
+	}
+	if probes.Readiness && container.ReadinessProbe == nil {
+		container.ReadinessProbe = newProbe()
+	}
+}
+
 func (i *baseInjector) injectEnvVarsIntoTargetedEnvVars(instEnvVars []corev1.EnvVar, containerEnvVars []corev1.EnvVar) []corev1.EnvVar {
 	for _, env := range instEnvVars {
 		if env.Name == envAgentControlHealthDeliveryLocation {
@@ -9,8 +9,10 @@ import (
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 )
 
 func TestHealthInjector_Inject(t *testing.T) {
@@ -442,3 +444,56 @@ func TestHealthInjector_Inject(t *testing.T) {
 		})
 	}
 }
+
+func TestHealthInjector_InjectReadinessGate(t *testing.T) {
+	cfg := config.New(config.WithAgentReadinessGate("newrelic.com/agent-ready"))
+	i := &baseInjector{config: &cfg}
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{HealthAgent: current.HealthAgent{Image: "health"}}}
+	pod := corev1.Pod{Spec: corev1.PodSpec{
+		Containers:     []corev1.Container{{Name: "test"}},
+		ReadinessGates: []corev1.PodReadinessGate{{ConditionType: "example.com/ready"}},
+	}}
+
+	actualPod, err := i.injectHealth(context.Background(), inst, corev1.Namespace{}, pod, 0, -1)
+	require.NoError(t, err)
+	actualPod, err = i.injectHealth(context.Background(), inst, corev1.Namespace{}, actualPod, 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []corev1.PodReadinessGate{
+		{ConditionType: "example.com/ready"},
+		{ConditionType: "newrelic.com/agent-ready"},
+	}, actualPod.Spec.ReadinessGates)
+
+	withoutHealth, err := i.injectHealth(context.Background(), current.Instrumentation{}, corev1.Namespace{}, pod, 0, -1)
+	require.NoError(t, err)
+	assert.Len(t, withoutHealth.Spec.ReadinessGates, 1)
+}
+
+func TestHealthInjector_InjectProbes(t *testing.T) {
+	cfg := config.New()
+	i := &baseInjector{config: &cfg}
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{HealthAgent: current.HealthAgent{
+		Image:  "health",
+		Env:    []corev1.EnvVar{{Name: envHealthListenPort, Value: "6200"}},
+		Probes: &current.HealthProbes{Liveness: true, Readiness: true, PeriodSeconds: 5},
+	}}}
+	ownReadiness := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/ready", Port: intstr.FromInt32(8080)}}}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test", ReadinessProbe: ownReadiness}}}}
+
+	actualPod, err := i.injectHealth(context.Background(), inst, corev1.Namespace{}, pod, 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt32(6200)},
+		},
+		InitialDelaySeconds: 30,
+		PeriodSeconds:       5,
+		FailureThreshold:    3,
+	}, actualPod.Spec.Containers[0].LivenessProbe)
+	assert.Equal(t, ownReadiness, actualPod.Spec.Containers[0].ReadinessProbe, "the probe of the container is kept")
+
+	inst.Spec.HealthAgent.Probes = nil
+	pod = corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}}}}
+	withoutProbes, err := i.injectHealth(context.Background(), inst, corev1.Namespace{}, pod, 0, -1)
+	require.NoError(t, err)
+	assert.Nil(t, withoutProbes.Spec.Containers[0].LivenessProbe)
+}
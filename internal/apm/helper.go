@@ -20,27 +20,37 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 	"github.com/newrelic/k8s-agents-operator/internal/version"
 )
 
 const LicenseKey = "new_relic_license_key"
 
 const (
-	volumeName          = "newrelic-instrumentation"
-	initContainerName   = "newrelic-instrumentation"
-	apmConfigVolumeName = "newrelic-apm-config"
-	apmConfigMountPath  = "/newrelic-apm-config"
+	volumeName               = "newrelic-instrumentation"
+	agentVolumeMountPath     = "/newrelic-instrumentation"
+	apmConfigVolumeName      = "newrelic-apm-config"
+	apmConfigMountPath       = "/newrelic-apm-config"
+	otlpClientCertVolumeName = "newrelic-otlp-client-cert"
+	otlpClientCertMountPath  = "/newrelic-otlp-client-cert"
+	agentArtifactMountPath   = "/newrelic-agent"
 )
 
 const (
@@ -48,11 +58,41 @@ const (
 	EnvNewRelicK8sOperatorEnabled        = "NEW_RELIC_K8S_OPERATOR_ENABLED"
 	EnvNewRelicLabels                    = "NEW_RELIC_LABELS"
 	EnvNewRelicLicenseKey                = "NEW_RELIC_LICENSE_KEY"
+	EnvNewRelicMetadataProvider          = "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER"
+	EnvNewRelicMetadataOperatorVersion   = "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION"
+	EnvNewRelicOperatorVersion           = "NEW_RELIC_OPERATOR_VERSION"
+	EnvOtelResourceAttributes            = "OTEL_RESOURCE_ATTRIBUTES"
+	EnvOtelExporterOtlpEndpoint          = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvOtelExporterOtlpProtocol          = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	EnvOtelExporterOtlpTracesEndpoint    = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	EnvOtelExporterOtlpMetricsEndpoint   = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+	EnvOtelExporterOtlpLogsEndpoint      = "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"
+	EnvOtelExporterOtlpClientCertificate = "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE"
+	EnvOtelExporterOtlpClientKey         = "OTEL_EXPORTER_OTLP_CLIENT_KEY"
 	DescK8sAgentOperatorVersionLabelName = "newrelic-k8s-agents-operator-version"
 )
 
 const instrumentationVersionAnnotation = "newrelic.com/instrumentation-versions"
 
+// AgentImagesAnnotation is the pod annotation recording the agent image injected by each instrumentation, by the
+// namespaced name of the instrumentation. It's taken from the agent init container, so it's the image the pod runs.
+const AgentImagesAnnotation = "newrelic.com/agent-images"
+
+// EnvAnnotation is the pod annotation with comma separated NAME=value env vars added to the instrumented container, so
+// that an agent setting can be changed for a single pod without editing the instrumentation.
+const EnvAnnotation = "newrelic.com/env"
+
+// The sources of the agent env vars, see resolveAgentEnv
+const (
+	envSourceContainer       = "container"
+	envSourceAnnotation      = "annotation"
+	envSourceInstrumentation = "instrumentation"
+	envSourceDefault         = "default"
+)
+
+// otelOperatorVersionAttribute is the OpenTelemetry resource attribute holding the operator version
+const otelOperatorVersionAttribute = "newrelic.k8s.operator.version"
+
 var ErrInjectorAlreadyRegistered = errors.New("injector already registered in registry")
 
 type Injector interface {
@@ -60,6 +100,7 @@ type Injector interface {
 	Language() string
 	ConfigureClient(client client.Client)
 	ConfigureLogger(logger logr.Logger)
+	ConfigureConfig(cfg *config.Config)
 }
 
 type Injectors []Injector
@@ -91,6 +132,7 @@ func (ir *InjectorRegistery) Register(injector Injector) error {
 	if _, ok := ir.injectorMap[injector.Language()]; ok {
 		return ErrInjectorAlreadyRegistered
 	}
+	ir.injectorMap[injector.Language()] = struct{}{}
 	ir.injectors = append(ir.injectors, injector)
 	return nil
 }
@@ -102,6 +144,11 @@ func (ir *InjectorRegistery) MustRegister(injector Injector) {
 	}
 }
 
+// RegisterLanguage is used to register a custom language injector, see NewLanguageInjector
+func (ir *InjectorRegistery) RegisterLanguage(languageInjector LanguageInjector) error {
+	return ir.Register(NewLanguageInjector(languageInjector))
+}
+
 func (ir *InjectorRegistery) GetInjectors() Injectors {
 	ir.mu.Lock()
 	defer ir.mu.Unlock()
@@ -112,6 +159,11 @@ func (ir *InjectorRegistery) GetInjectors() Injectors {
 
 var DefaultInjectorRegistry = NewInjectorRegistry()
 
+// IsAgentInitContainer is used to check if the init container was injected to copy an agent into the pod
+func IsAgentInitContainer(prefix string, name string) bool {
+	return strings.HasPrefix(name, prefix+"-")
+}
+
 func getContainerIndex(pod corev1.Pod, containerName string) int {
 	for i, container := range pod.Spec.Containers {
 		if container.Name == containerName {
@@ -121,6 +173,41 @@ func getContainerIndex(pod corev1.Pod, containerName string) int {
 	return -1
 }
 
+// getAgentContainerIndex is used to get the index of the container the agents are injected into, which is the one
+// chosen by the agent container config unless we injected another one before. Other mutating webhooks may add
+// containers ahead of it before our webhook is reinvoked, so the container already mounting the agent volume is
+// injected again rather than whichever is now chosen, see agentContainerIndexes for the containers added by them.
+func getAgentContainerIndex(pod corev1.Pod, agentContainer config.AgentContainer) int {
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if slices.ContainsFunc(container.VolumeMounts, func(m corev1.VolumeMount) bool { return isAgentVolume(pod, m.Name) }) ||
+			!isContainerVolumeMissing(container, healthVolumeName) {
+			return i
+		}
+	}
+	switch agentContainer.Position {
+	case config.AgentContainerPositionLast:
+		return max(len(pod.Spec.Containers)-1, 0)
+	case config.AgentContainerPositionNamed:
+		if index := getContainerIndex(pod, agentContainer.Name); index > -1 {
+			return index
+		}
+	}
+	return 0
+}
+
+// agentContainerIndex is used to get the index of the container the agents are injected into. The queue proxy of the
+// Knative pods is never chosen when Knative is available, the app is in one of the other containers.
+func (i *baseInjector) agentContainerIndex(pod corev1.Pod) int {
+	index := getAgentContainerIndex(pod, i.configuration().AgentContainer())
+	if i.isKnativeQueueProxy(pod, index) {
+		if app := slices.IndexFunc(pod.Spec.Containers, func(c corev1.Container) bool { return c.Name != knativeQueueProxyContainer }); app > -1 {
+			index = app
+		}
+	}
+	return index
+}
+
 func getInitContainerIndex(pod corev1.Pod, initContainerName string) int {
 	for i, initContainer := range pod.Spec.InitContainers {
 		if initContainer.Name == initContainerName {
@@ -150,6 +237,67 @@ func isPodVolumeMissing(pod corev1.Pod, volumeName string) bool {
 	return true
 }
 
+// isAgentVolume is used to check if the volume of the pod is an agent volume, which the agent init containers mount at
+// the agent volume path. A mount of the default name the pod has no volume for yet is one being injected.
+func isAgentVolume(pod corev1.Pod, name string) bool {
+	for _, initContainer := range pod.Spec.InitContainers {
+		if slices.ContainsFunc(initContainer.VolumeMounts, func(m corev1.VolumeMount) bool {
+			return m.Name == name && m.MountPath == agentVolumeMountPath
+		}) {
+			return true
+		}
+	}
+	return name == volumeName && isPodVolumeMissing(pod, name)
+}
+
+// agentVolumeName is used to get the name of the agent volume of the pod, which is the configured name unless the pod
+// already has a volume of that name which isn't an agent volume, such as one of the app. The first free name suffixed
+// with -2, -3 and so on is used then, rather than producing a pod with duplicate volume names.
+func (i *baseInjector) agentVolumeName(pod corev1.Pod) string {
+	name := i.configuration().AgentVolumeName()
+	candidate := name
+	for n := 2; !isPodVolumeMissing(pod, candidate) && !isAgentVolume(pod, candidate); n++ {
+		candidate = fmt.Sprintf("%s-%d", name, n)
+	}
+	return candidate
+}
+
+// useAgentVolume is used to mount the agent volume of the pod into the init container, in place of the default agent
+// volume name the language injectors mount, with the mount propagation of the language
+func (i *baseInjector) useAgentVolume(pod *corev1.Pod, initContainerName string, agentVolume string, language string) {
+	index := getInitContainerIndex(*pod, initContainerName)
+	if index == -1 {
+		return
+	}
+	initContainer := &pod.Spec.InitContainers[index]
+	for j := range initContainer.VolumeMounts {
+		if initContainer.VolumeMounts[j].Name == volumeName {
+			initContainer.VolumeMounts[j].Name = agentVolume
+			initContainer.VolumeMounts[j].MountPropagation = i.agentMountPropagation(*initContainer, language)
+		}
+	}
+}
+
+// agentMountPropagation is used to get the configured mount propagation of the agent volume mounts of the container
+// for the language, nil for the default None. Kubernetes only allows Bidirectional for privileged containers and would
+// reject the pod, so the other containers get HostToContainer instead.
+func (i *baseInjector) agentMountPropagation(container corev1.Container, language string) *corev1.MountPropagationMode {
+	mode := i.configuration().AgentMountPropagation(language)
+	if mode == "" {
+		return nil
+	}
+	if mode == corev1.MountPropagationBidirectional && !isPrivileged(container) {
+		i.logger.Info("the container isn't privileged, mounting the agent volume with HostToContainer instead of Bidirectional", "container", container.Name)
+		mode = corev1.MountPropagationHostToContainer
+	}
+	return &mode
+}
+
+// isPrivileged is used to check if the container runs privileged
+func isPrivileged(container corev1.Container) bool {
+	return container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged
+}
+
 // Calculate if we already inject a Volume.
 func isContainerVolumeMissing(container *corev1.Container, volumeName string) bool {
 	for _, volume := range container.VolumeMounts {
@@ -197,6 +345,22 @@ func setEnvVar(container *corev1.Container, envVarName string, envVarValue strin
 	}
 }
 
+// validateInitContainerName is used to make sure the agent init container name doesn't collide with one of the pod's
+// containers, which would produce an invalid pod spec, or with one of its init containers which isn't an agent init
+// container, which would keep the agent from being copied while its env vars point at an empty volume. An init
+// container of that name mounting the agent volume of the pod, see agentVolumeName, is the one of an earlier injection.
+func validateInitContainerName(pod corev1.Pod, initContainerName string, agentVolume string) error {
+	if getContainerIndex(pod, initContainerName) > -1 {
+		return fmt.Errorf("the pod already has a container named %q", initContainerName)
+	}
+	if index := getInitContainerIndex(pod, initContainerName); index > -1 && !slices.ContainsFunc(pod.Spec.InitContainers[index].VolumeMounts, func(m corev1.VolumeMount) bool {
+		return m.Name == agentVolume
+	}) {
+		return fmt.Errorf("the pod already has an init container named %q which isn't an agent init container", initContainerName)
+	}
+	return nil
+}
+
 func validateContainerEnv(envs []corev1.EnvVar, envsToBeValidated ...string) error {
 	for _, envToBeValidated := range envsToBeValidated {
 		for _, containerEnv := range envs {
@@ -214,6 +378,7 @@ func validateContainerEnv(envs []corev1.EnvVar, envsToBeValidated ...string) err
 type baseInjector struct {
 	logger logr.Logger
 	client client.Client
+	config *config.Config
 }
 
 func (i *baseInjector) ConfigureLogger(logger logr.Logger) {
@@ -224,6 +389,283 @@ func (i *baseInjector) ConfigureClient(client client.Client) {
 	i.client = client
 }
 
+func (i *baseInjector) ConfigureConfig(cfg *config.Config) {
+	i.config = cfg
+}
+
+// configuration returns the operator configuration the injector was configured with. An injector used on its own, such
+// as in tests, gets the defaults.
+func (i *baseInjector) configuration() *config.Config {
+	if i.config == nil {
+		cfg := config.New()
+		return &cfg
+	}
+	return i.config
+}
+
+// agentInitContainerName is used to get the name of the init container that copies the agent into the pod
+func (i *baseInjector) agentInitContainerName(suffix string) string {
+	return AgentInitContainerName(i.configuration().InitContainerNamePrefix(), suffix)
+}
+
+// AgentInitContainerName is the name of the init container that copies the agent for the language into the pod. All
+// the php versions share the same init container.
+func AgentInitContainerName(prefix string, language string) string {
+	return prefix + "-" + agentName(language)
+}
+
+// AgentImage is used to get the agent image of the instrumentation. Instrumentations without an image fall back to the
+// image of the configured channel, <repository>/<language>:<channel>, so that switching the channel switches the image
+// of every language at once.
+func AgentImage(cfg *config.Config, inst current.Instrumentation) string {
+	if inst.Spec.Agent.Image != "" || cfg == nil {
+		return inst.Spec.Agent.Image
+	}
+	repository, channel := cfg.ImageChannel()
+	if repository == "" || channel == "" {
+		return ""
+	}
+	return strings.TrimSuffix(repository, "/") + "/" + agentName(inst.Spec.Agent.Language) + ":" + channel
+}
+
+// AgentImageAnnotation is the annotation of a pod overriding the agent image of the language for that pod only, such as
+// newrelic.com/java-image, honored when the pod agent images are enabled
+func AgentImageAnnotation(language string) string {
+	return "newrelic.com/" + language + "-image"
+}
+
+// PodAgentImage is used to get the agent image of the instrumentation for the pod. When the pod agent images are
+// enabled, the agent image annotation of the pod overrides the one of the instrumentation, see AgentImage. An
+// annotation which isn't a valid image reference, or isn't from an allowed image registry, is returned as an error along
+// with the image of the instrumentation.
+func PodAgentImage(cfg *config.Config, inst current.Instrumentation, pod corev1.Pod) (string, error) {
+	image := AgentImage(cfg, inst)
+	if cfg == nil || !cfg.PodAgentImages() {
+		return image, nil
+	}
+	annotation := AgentImageAnnotation(inst.Spec.Agent.Language)
+	podImage, ok := pod.Annotations[annotation]
+	if !ok {
+		return image, nil
+	}
+	if err := current.ValidateImage(podImage); podImage == "" || err != nil {
+		return image, fmt.Errorf("the %s annotation %q must be a valid image reference", annotation, podImage)
+	}
+	if err := current.ValidateImageRegistry(podImage, cfg.AllowedImageRegistries()); err != nil {
+		return image, fmt.Errorf("the %s annotation %w", annotation, err)
+	}
+	return podImage, nil
+}
+
+// agentName is used to get the name of the agent for the language, which is the same for all the php versions
+func agentName(language string) string {
+	if strings.HasPrefix(language, "php-") {
+		return "php"
+	}
+	return language
+}
+
+// positionInitContainer moves the named init container, appended by the language injector, to the configured position
+// among the pod's other init containers, so that the agent can coexist with init containers added by other operators.
+// When the cluster runs native sidecars, it's never put after one, so that the agent is copied by the time they start.
+func (i *baseInjector) positionInitContainer(pod *corev1.Pod, initContainerName string) {
+	from := getInitContainerIndex(*pod, initContainerName)
+	if from == -1 {
+		return
+	}
+	initContainer := pod.Spec.InitContainers[from]
+	initContainers := slices.Delete(slices.Clone(pod.Spec.InitContainers), from, from+1)
+
+	to := len(initContainers)
+	position, before := i.configuration().InitContainerInsertPosition()
+	switch position {
+	case config.InitContainerPositionFirst:
+		to = 0
+	case config.InitContainerPositionBefore:
+		if idx := slices.IndexFunc(initContainers, func(c corev1.Container) bool { return c.Name == before }); idx != -1 {
+			to = idx
+		}
+	}
+	if i.configuration().NativeSidecars() == autodetect.NativeSidecarsAvailable {
+		if idx := slices.IndexFunc(initContainers, isNativeSidecar); idx != -1 && idx < to {
+			to = idx
+		}
+	}
+	pod.Spec.InitContainers = slices.Insert(initContainers, to, initContainer)
+}
+
+// isNativeSidecar is whether the init container is restartable, so that it keeps running alongside the app containers
+func isNativeSidecar(initContainer corev1.Container) bool {
+	return initContainer.RestartPolicy != nil && *initContainer.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
+
+// injectInitContainerRunAs runs the agent init container as the user and group of the instrumented container, so that
+// the agent files it copies belong to, and are readable by, the app. The container security context wins over the
+// pod's, which the init container inherits anyway, and the configured user and group are used when neither sets them.
+func (i *baseInjector) injectInitContainerRunAs(pod *corev1.Pod, containerIndex int, initContainerName string) {
+	initContainerIndex := getInitContainerIndex(*pod, initContainerName)
+	if initContainerIndex == -1 {
+		return
+	}
+	var user, group, podUser, podGroup *int64
+	if sc := pod.Spec.Containers[containerIndex].SecurityContext; sc != nil {
+		user, group = sc.RunAsUser, sc.RunAsGroup
+	}
+	if psc := pod.Spec.SecurityContext; psc != nil {
+		podUser, podGroup = psc.RunAsUser, psc.RunAsGroup
+	}
+	defaultUser, defaultGroup := i.configuration().AgentInitRunAs()
+	if user == nil && podUser == nil {
+		user = defaultUser
+	}
+	if group == nil && podGroup == nil {
+		group = defaultGroup
+	}
+	if user == nil && group == nil {
+		return
+	}
+
+	initContainer := &pod.Spec.InitContainers[initContainerIndex]
+	if initContainer.SecurityContext == nil {
+		initContainer.SecurityContext = &corev1.SecurityContext{}
+	}
+	if user != nil && initContainer.SecurityContext.RunAsUser == nil {
+		initContainer.SecurityContext.RunAsUser = ptr.To(*user)
+	}
+	if group != nil && initContainer.SecurityContext.RunAsGroup == nil {
+		initContainer.SecurityContext.RunAsGroup = ptr.To(*group)
+	}
+}
+
+// injectInitContainerCommand replaces the command and arguments of the agent init container with the ones of the
+// instrumentation, when it sets a command
+func injectInitContainerCommand(pod *corev1.Pod, inst current.Instrumentation, initContainerName string) {
+	if len(inst.Spec.Agent.InitCommand) == 0 {
+		return
+	}
+	initContainerIndex := getInitContainerIndex(*pod, initContainerName)
+	if initContainerIndex == -1 {
+		return
+	}
+	initContainer := &pod.Spec.InitContainers[initContainerIndex]
+	initContainer.Command = slices.Clone(inst.Spec.Agent.InitCommand)
+	initContainer.Args = slices.Clone(inst.Spec.Agent.InitArgs)
+}
+
+// injectInitContainerResources sets the resource requirements of the instrumentation agent on the agent init container,
+// when it sets any
+func injectInitContainerResources(pod *corev1.Pod, inst current.Instrumentation, initContainerName string) {
+	resources := inst.Spec.Agent.Resources
+	if len(resources.Limits) == 0 && len(resources.Requests) == 0 && len(resources.Claims) == 0 {
+		return
+	}
+	initContainerIndex := getInitContainerIndex(*pod, initContainerName)
+	if initContainerIndex == -1 {
+		return
+	}
+	pod.Spec.InitContainers[initContainerIndex].Resources = *resources.DeepCopy()
+}
+
+// agentArtifactVolumeName is the name of the image volume of the agent image mounted into the agent init container
+func agentArtifactVolumeName(initContainerName string) string {
+	return initContainerName + "-agent"
+}
+
+// injectInstallerImage is used to run the agent init container with the installer image of the instrumentation, when
+// it differs from the agent image. The agent image is mounted into it as an image volume, and it runs the entrypoint
+// of the installer image unless the instrumentation sets an init command.
+func injectInstallerImage(pod *corev1.Pod, inst current.Instrumentation, initContainerName string) {
+	installerImage := inst.Spec.Agent.InstallerImage
+	if installerImage == "" || installerImage == inst.Spec.Agent.Image {
+		return
+	}
+	initContainerIndex := getInitContainerIndex(*pod, initContainerName)
+	if initContainerIndex == -1 {
+		return
+	}
+	artifactVolumeName := agentArtifactVolumeName(initContainerName)
+	if isPodVolumeMissing(*pod, artifactVolumeName) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: artifactVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Image: &corev1.ImageVolumeSource{Reference: inst.Spec.Agent.Image, PullPolicy: corev1.PullIfNotPresent},
+			},
+		})
+	}
+	initContainer := &pod.Spec.InitContainers[initContainerIndex]
+	initContainer.Image = installerImage
+	if len(inst.Spec.Agent.InitCommand) == 0 {
+		initContainer.Command = nil
+		initContainer.Args = nil
+	}
+	if isContainerVolumeMissing(initContainer, artifactVolumeName) {
+		initContainer.VolumeMounts = append(initContainer.VolumeMounts, corev1.VolumeMount{
+			Name:      artifactVolumeName,
+			MountPath: agentArtifactMountPath,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// InjectedAgentImage is used to get the agent image copied by the agent init container of the pod. That's the image
+// of the init container, unless it runs an installer image, in which case it's the image mounted into it.
+func InjectedAgentImage(pod corev1.Pod, initContainerName string) (string, bool) {
+	index := getInitContainerIndex(pod, initContainerName)
+	if index == -1 {
+		return "", false
+	}
+	artifactVolumeName := agentArtifactVolumeName(initContainerName)
+	if !isContainerVolumeMissing(&pod.Spec.InitContainers[index], artifactVolumeName) {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Name == artifactVolumeName && volume.Image != nil {
+				return volume.Image.Reference, true
+			}
+		}
+	}
+	return pod.Spec.InitContainers[index].Image, true
+}
+
+// injectStartupWrapper puts the wrapper in front of the container command, so that the app runs under it. A container
+// without a command runs the image entrypoint, which isn't known here, so it can't be wrapped.
+func injectStartupWrapper(container *corev1.Container, wrapper []string) error {
+	if len(wrapper) == 0 {
+		return nil
+	}
+	if len(container.Command) == 0 {
+		return fmt.Errorf("the startup wrapper requires container %q to set a command", container.Name)
+	}
+	if slices.Equal(container.Command[:min(len(wrapper), len(container.Command))], wrapper) {
+		return nil
+	}
+	container.Command = append(slices.Clone(wrapper), container.Command...)
+	return nil
+}
+
+// injectAgentWarmup adds a postStart sleep to the container for the agent warmup of the language, as the kubelet only
+// starts the readiness probes, and so the pod becomes ready and receives traffic, once the postStart hook completes. A
+// container with its own postStart hook keeps it, as a container only has one. The warmup is skipped on the clusters
+// which don't enable the sleep action, before Kubernetes 1.30, as they'd reject the pod.
+func (i *baseInjector) injectAgentWarmup(container *corev1.Container, language string) {
+	warmup := i.configuration().AgentWarmup(language)
+	if warmup <= 0 {
+		return
+	}
+	if i.configuration().SleepAction() != autodetect.SleepActionAvailable {
+		i.logger.V(1).Info("the cluster doesn't enable the sleep action, skipping the agent warmup", "container", container.Name)
+		return
+	}
+	if container.Lifecycle != nil && container.Lifecycle.PostStart != nil {
+		i.logger.V(1).Info("container has a postStart hook, skipping the agent warmup", "container", container.Name)
+		return
+	}
+	if container.Lifecycle == nil {
+		container.Lifecycle = &corev1.Lifecycle{}
+	}
+	// the sleep action takes whole seconds, round up so the warmup is never shortened
+	seconds := int64((warmup + time.Second - 1) / time.Second)
+	container.Lifecycle.PostStart = &corev1.LifecycleHandler{Sleep: &corev1.SleepAction{Seconds: seconds}}
+}
+
 func (i *baseInjector) validate(inst current.Instrumentation) error {
 	if inst.Spec.LicenseKeySecret == "" {
 		return fmt.Errorf("licenseKeySecret must not be blank")
@@ -259,16 +701,59 @@ func (i *baseInjector) injectNewrelicEnvConfig(ctx context.Context, pod corev1.P
 	if idx := getIndexOfEnv(container.Env, EnvNewRelicAppName); idx == -1 {
 		container.Env = append(container.Env, corev1.EnvVar{
 			Name:  EnvNewRelicAppName,
-			Value: chooseServiceName(pod, index),
+			Value: chooseServiceName(pod, index, i.configuration().ServiceNameLabels()),
 		})
 	}
+	// pod labels in the allow-list become agent labels, which are reported as attributes, unless the labels filter
+	// drops them. The container's own labels win over the pod labels.
+	podLabelAttributes := map[string]string{}
+	for _, key := range i.configuration().AttributeLabels() {
+		value, ok := pod.Labels[key]
+		if !ok {
+			continue
+		}
+		if filter, filtered := i.configuration().LabelFilteredBy(key); filtered {
+			i.logger.V(1).Info("label dropped by the labels filter", "label", key, "filter", filter)
+			labelsFiltered.Inc()
+			continue
+		}
+		podLabelAttributes[key] = value
+	}
+	// the allow-listed node labels, the cluster name and the service version are added to both the agent labels and the
+	// resource attributes
+	clusterAttributes := i.injectNodeLabelEnv(&pod, container)
+	if clusterName := i.configuration().ClusterName(); clusterName != "" {
+		if clusterAttributes == nil {
+			clusterAttributes = map[string]string{}
+		}
+		if _, ok := clusterAttributes[config.ClusterNameAttribute]; !ok {
+			clusterAttributes[config.ClusterNameAttribute] = clusterName
+		}
+	}
+	if serviceVersion := i.serviceVersion(ctx, pod); serviceVersion != "" {
+		if clusterAttributes == nil {
+			clusterAttributes = map[string]string{}
+		}
+		clusterAttributes[serviceVersionAttribute] = serviceVersion
+	}
+	for key, value := range clusterAttributes {
+		if _, ok := podLabelAttributes[key]; !ok {
+			podLabelAttributes[key] = value
+		}
+	}
 	if idx := getIndexOfEnv(container.Env, EnvNewRelicLabels); idx == -1 {
+		podLabelAttributes["operator"] = "auto-injection"
 		container.Env = append(container.Env, corev1.EnvVar{
 			Name:  EnvNewRelicLabels,
-			Value: "operator:auto-injection",
+			Value: encodeAttributes(podLabelAttributes, ";", ":"),
 		})
 	} else {
 		labelAttributes := decodeAttributes(container.Env[idx].Value, ";", ":")
+		for key, value := range podLabelAttributes {
+			if _, ok := labelAttributes[key]; !ok {
+				labelAttributes[key] = value
+			}
+		}
 		labelAttributes["operator"] = "auto-injection"
 		container.Env[idx].Value = encodeAttributes(labelAttributes, ";", ":")
 	}
@@ -278,6 +763,30 @@ func (i *baseInjector) injectNewrelicEnvConfig(ctx context.Context, pod corev1.P
 			Value: "true",
 		})
 	}
+	if idx := getIndexOfEnv(container.Env, EnvNewRelicOperatorVersion); idx == -1 {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  EnvNewRelicOperatorVersion,
+			Value: version.Get().Operator,
+		})
+	}
+	// the agents report the NEW_RELIC_METADATA_ variables along with their other metadata
+	if provider := i.configuration().InstrumentationProvider(); provider != "" {
+		setEnvVar(container, EnvNewRelicMetadataProvider, provider, false)
+		setEnvVar(container, EnvNewRelicMetadataOperatorVersion, version.Get().Operator, false)
+	}
+	// OpenTelemetry instrumented apps get the version as a resource attribute as well. The variable isn't added to
+	// other apps, and values from a source are left alone as they can't be merged.
+	if idx := getIndexOfEnv(container.Env, EnvOtelResourceAttributes); idx != -1 && container.Env[idx].ValueFrom == nil {
+		resourceAttributes := decodeAttributes(container.Env[idx].Value, ",", "=")
+		resourceAttributes[otelOperatorVersionAttribute] = version.Get().Operator
+		for key, value := range clusterAttributes {
+			if _, ok := resourceAttributes[key]; !ok {
+				resourceAttributes[key] = value
+			}
+		}
+		container.Env[idx].Value = encodeAttributes(resourceAttributes, ",", "=")
+	}
+	i.injectAgentProxy(container)
 	// Also apply specific pod labels indicating that operator is being attached and it's version
 	applyLabelToPod(&pod, DescK8sAgentOperatorVersionLabelName, version.Get().Operator)
 	return pod
@@ -314,19 +823,34 @@ func encodeAttributes(m map[string]string, fieldSeparator string, valueSeparator
 	return str
 }
 
-func chooseServiceName(pod corev1.Pod, index int) string {
-	for _, owner := range pod.ObjectMeta.OwnerReferences {
-		switch strings.ToLower(owner.Kind) {
-		case "deployment", "statefulset", "job", "cronjob":
-			return owner.Name
+// chooseServiceName uses the first of the service name labels set on the pod, falling back to the owner, pod and then
+// container name
+func chooseServiceName(pod corev1.Pod, index int, serviceNameLabels []string) string {
+	for _, label := range serviceNameLabels {
+		if name := pod.Labels[label]; name != "" {
+			return name
 		}
 	}
+	if name := ownerName(pod); name != "" {
+		return name
+	}
 	if pod.Name != "" {
 		return pod.Name
 	}
 	return pod.Spec.Containers[index].Name
 }
 
+// ownerName is used to get the name of the deployment, statefulset, job or cronjob owning the pod
+func ownerName(pod corev1.Pod) string {
+	for _, owner := range pod.ObjectMeta.OwnerReferences {
+		switch strings.ToLower(owner.Kind) {
+		case "deployment", "statefulset", "job", "cronjob":
+			return owner.Name
+		}
+	}
+	return ""
+}
+
 func applyLabelToPod(pod *corev1.Pod, key, val string) *corev1.Pod {
 	labels := pod.Labels
 	if labels == nil {
@@ -360,6 +884,35 @@ func addAnnotationToPodFromInstrumentationVersion(ctx context.Context, pod corev
 	return pod
 }
 
+// StampAgentImage is used to record the agent image of the agent init container of the instrumentation, see
+// InjectedAgentImage, in the agent images annotation of the pod, returning whether the annotation changed. Pods without
+// the init container are left alone.
+func StampAgentImage(pod *corev1.Pod, inst types.NamespacedName, initContainerName string) (bool, error) {
+	image, ok := InjectedAgentImage(*pod, initContainerName)
+	if !ok {
+		return false, nil
+	}
+	images := map[string]string{}
+	if v, ok := pod.Annotations[AgentImagesAnnotation]; ok {
+		if err := json.Unmarshal([]byte(v), &images); err != nil {
+			return false, fmt.Errorf("failed to unmarshal the agent images annotation > %w", err)
+		}
+	}
+	if stamped, ok := images[inst.String()]; ok && stamped == image {
+		return false, nil
+	}
+	images[inst.String()] = image
+	imagesBytes, err := json.Marshal(images)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal the agent images annotation > %w", err)
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[AgentImagesAnnotation] = string(imagesBytes)
+	return true, nil
+}
+
 func injectAgentConfigMap(pod *corev1.Pod, index int, configMapName string) {
 	container := &pod.Spec.Containers[index]
 
@@ -383,3 +936,317 @@ func injectAgentConfigMap(pod *corev1.Pod, index int, configMapName string) {
 		})
 	}
 }
+
+// ParseEnvAnnotation is used to parse the value of the env annotation, returning the env vars and the entries which
+// aren't a valid NAME=value pair
+func ParseEnvAnnotation(value string) ([]corev1.EnvVar, []string) {
+	var envVars []corev1.EnvVar
+	var malformed []string
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, envValue, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || len(validation.IsEnvVarName(name)) > 0 {
+			malformed = append(malformed, entry)
+			continue
+		}
+		envVars = append(envVars, corev1.EnvVar{Name: name, Value: envValue})
+	}
+	return envVars, malformed
+}
+
+// resolveAgentEnv is used to merge the agent env vars of the pod's env annotation, the instrumentation and the operator
+// defaults. When a name is set by more than one of them, the instrumentation wins over the annotation, unless the
+// precedence is the annotation one, and both win over the defaults. The env vars keep the order of the instrumentation,
+// followed by the ones only set by the annotation, and then the ones only set by the defaults. The source of each env
+// var is returned along with them. Malformed annotation entries are ignored.
+func resolveAgentEnv(defaults []corev1.EnvVar, precedence config.AgentEnvPrecedence, inst current.Instrumentation, pod corev1.Pod) ([]corev1.EnvVar, map[string]string) {
+	var annotationEnv []corev1.EnvVar
+	if value, ok := pod.Annotations[EnvAnnotation]; ok {
+		annotationEnv, _ = ParseEnvAnnotation(value)
+	}
+
+	var envVars []corev1.EnvVar
+	sources := map[string]string{}
+	add := func(layer []corev1.EnvVar, source string) {
+		for _, env := range layer {
+			if _, ok := sources[env.Name]; ok {
+				continue
+			}
+			sources[env.Name] = source
+			envVars = append(envVars, env)
+		}
+	}
+	add(inst.Spec.Agent.Env, envSourceInstrumentation)
+	add(annotationEnv, envSourceAnnotation)
+	add(defaults, envSourceDefault)
+	if precedence != config.AgentEnvPrecedenceAnnotation {
+		return envVars, sources
+	}
+
+	// the annotation overrides the value of the instrumentation env vars, without moving them
+	for _, env := range annotationEnv {
+		if idx := getIndexOfEnv(envVars, env.Name); idx > -1 && sources[env.Name] != envSourceAnnotation {
+			envVars[idx] = env
+			sources[env.Name] = envSourceAnnotation
+		}
+	}
+	return envVars, sources
+}
+
+// stripEnv is used to remove the env vars the instrumentation, or else the operator, strips from the container, so
+// that the agent env vars added next replace the conflicting values set by the workload
+func (i *baseInjector) stripEnv(container *corev1.Container, inst current.Instrumentation) {
+	names := inst.Spec.Agent.StripEnv
+	if len(names) == 0 {
+		names = i.configuration().StripEnvVars()
+	}
+	if len(names) == 0 {
+		return
+	}
+	// the env is cloned, as it's shared with the pod the injection falls back to on errors
+	container.Env = slices.DeleteFunc(slices.Clone(container.Env), func(env corev1.EnvVar) bool { return slices.Contains(names, env.Name) })
+}
+
+// injectAgentEnv is used to add the resolved agent env vars to the container. The container's own env vars win over
+// every source, as they're never replaced. The resolved sources, but not the values, are logged at V(4), as the values
+// may be secrets.
+func (i *baseInjector) injectAgentEnv(container *corev1.Container, inst current.Instrumentation, pod corev1.Pod) {
+	envVars, sources := resolveAgentEnv(i.configuration().AgentEnvDefaults(), i.configuration().AgentEnvPrecedence(), inst, pod)
+	for _, env := range envVars {
+		if getIndexOfEnv(container.Env, env.Name) == -1 {
+			container.Env = append(container.Env, env)
+		} else {
+			sources[env.Name] = envSourceContainer
+		}
+	}
+	if len(sources) > 0 {
+		i.logger.V(4).Info("resolved agent env", "container", container.Name, "sources", sources)
+	}
+}
+
+// ErrAgentPathNotWritable is returned when the container has a read-only root filesystem, and the agent path is mounted
+// read-only or by another volume, so the agent can't write its files
+var ErrAgentPathNotWritable = errors.New("the agent path is not writable")
+
+// readOnlyRootEnv is the env vars moving the agent logs, which are written relative to the application or under
+// /var/log by default, into the writable agent volume. The other agents already log under their home in the volume.
+var readOnlyRootEnv = map[string][]corev1.EnvVar{
+	"nodejs": {{Name: "NEW_RELIC_LOG", Value: agentVolumeMountPath + "/newrelic_agent.log"}},
+	"ruby":   {{Name: "NEW_RELIC_LOG_FILE_PATH", Value: agentVolumeMountPath + "/logs/"}},
+	"php": {
+		{Name: "NEW_RELIC_LOGFILE", Value: agentVolumeMountPath + "/php_agent.log"},
+		{Name: "NEW_RELIC_DAEMON_LOGFILE", Value: agentVolumeMountPath + "/newrelic-daemon.log"},
+	},
+}
+
+// injectReadOnlyRootFilesystem is used to keep the agent writing only to the agent volume when the container has a
+// read-only root filesystem. The agent logs are moved into the volume, unless the container sets their paths. It fails
+// when something else is mounted at the agent install path, or the agent volume is mounted read-only.
+func injectReadOnlyRootFilesystem(container *corev1.Container, language string, installPath string, agentVolume string) error {
+	if container.SecurityContext == nil || container.SecurityContext.ReadOnlyRootFilesystem == nil || !*container.SecurityContext.ReadOnlyRootFilesystem {
+		return nil
+	}
+	for _, mount := range container.VolumeMounts {
+		if mount.MountPath != installPath {
+			continue
+		}
+		if mount.Name != agentVolume {
+			return fmt.Errorf("%w: container %q has a read-only root filesystem and mounts volume %q at %s", ErrAgentPathNotWritable, container.Name, mount.Name, mount.MountPath)
+		}
+		if mount.ReadOnly {
+			return fmt.Errorf("%w: container %q has a read-only root filesystem and mounts %s read-only", ErrAgentPathNotWritable, container.Name, mount.MountPath)
+		}
+	}
+	for _, env := range readOnlyRootEnv[agentName(language)] {
+		setEnvVar(container, env.Name, env.Value, false)
+	}
+	return nil
+}
+
+// regionCollectorHosts is the collector host of the agents for each region
+var regionCollectorHosts = map[config.Region]string{
+	config.RegionUS:      "collector.newrelic.com",
+	config.RegionEU:      "collector.eu01.nr-data.net",
+	config.RegionFedRAMP: "gov-collector.newrelic.com",
+}
+
+// regionHostEnvNames is the env var setting the collector host of the agents which don't read NEW_RELIC_HOST
+var regionHostEnvNames = map[string]string{
+	"php": "NEW_RELIC_DAEMON_COLLECTOR_HOST",
+}
+
+// injectRegion is used to point the agent at the collector of the configured region, rather than the region of its
+// license key. A container setting the collector host keeps its value, and the report-only mode replaces it.
+func (i *baseInjector) injectRegion(container *corev1.Container, inst current.Instrumentation) {
+	host, ok := regionCollectorHosts[i.configuration().Region()]
+	if !ok {
+		return
+	}
+	envName, ok := regionHostEnvNames[agentName(inst.Spec.Agent.Language)]
+	if !ok {
+		envName = "NEW_RELIC_HOST"
+	}
+	setEnvVar(container, envName, host, false)
+}
+
+// reportOnlyEnv is the env vars keeping each agent from sending data, while still instrumenting the application. The
+// python and ruby agents have a setting for it. The others, including any custom language, get their collector host
+// pointed at the loopback, so they can't connect.
+var reportOnlyEnv = map[string][]corev1.EnvVar{
+	"python": {{Name: "NEW_RELIC_DEVELOPER_MODE", Value: "true"}},
+	"ruby":   {{Name: "NEW_RELIC_MONITOR_MODE", Value: "false"}},
+	"php":    {{Name: "NEW_RELIC_DAEMON_COLLECTOR_HOST", Value: "localhost:1"}},
+	"":       {{Name: "NEW_RELIC_HOST", Value: "localhost"}, {Name: "NEW_RELIC_PORT", Value: "1"}},
+}
+
+// injectReportOnly is used to keep the agent from sending data when the instrumentation is in report-only mode. The
+// env vars replace any value set by the container or the other sources, as nothing may be sent.
+func injectReportOnly(container *corev1.Container, inst current.Instrumentation) {
+	if inst.Spec.Mode != current.InstrumentationModeReportOnly {
+		return
+	}
+	envVars, ok := reportOnlyEnv[agentName(inst.Spec.Agent.Language)]
+	if !ok {
+		envVars = reportOnlyEnv[""]
+	}
+	for _, env := range envVars {
+		if idx := getIndexOfEnv(container.Env, env.Name); idx > -1 {
+			container.Env[idx] = env
+		} else {
+			container.Env = append(container.Env, env)
+		}
+	}
+}
+
+// profilingEnv is the env vars enabling the code level metrics of each agent, along with the JFR based profiling of the
+// java agent. Languages without an agent setting for it, such as go, aren't listed.
+var profilingEnv = map[string][]corev1.EnvVar{
+	"dotnet": {{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"}},
+	"java": {
+		{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"},
+		{Name: "NEW_RELIC_JFR_ENABLED", Value: "true"},
+	},
+	"nodejs": {{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"}},
+	"php":    {{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"}},
+	"python": {{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"}},
+	"ruby":   {{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"}},
+}
+
+// injectProfiling is used to enable the profiling of the agent, when the instrumentation or else the operator default
+// enables it. A container setting the env vars keeps its values, so that a workload can opt out.
+func (i *baseInjector) injectProfiling(container *corev1.Container, inst current.Instrumentation) {
+	enabled := i.configuration().AgentProfiling()
+	if inst.Spec.Profiling != nil {
+		enabled = *inst.Spec.Profiling
+	}
+	if !enabled {
+		return
+	}
+	for _, env := range profilingEnv[agentName(inst.Spec.Agent.Language)] {
+		setEnvVar(container, env.Name, env.Value, false)
+	}
+}
+
+// injectOTLPExporter is used to set the OTLP endpoints and protocol of the instrumentation exporter, unless the
+// container already sets them. The protocol and the per signal endpoints fall back to the operator defaults, and an
+// endpoint without a port gets the default port of the protocol.
+func (i *baseInjector) injectOTLPExporter(container *corev1.Container, inst current.Instrumentation) {
+	if !inst.Spec.Exporter.HasEndpoint() {
+		return
+	}
+	protocol := config.OTLPProtocol(inst.Spec.Exporter.Protocol)
+	if protocol == "" {
+		protocol = i.configuration().OTLPProtocol()
+	}
+	defaults := i.configuration().OTLPSignalEndpoints()
+	for _, signal := range []struct {
+		name     string
+		endpoint string
+		fallback string
+	}{
+		{name: EnvOtelExporterOtlpEndpoint, endpoint: inst.Spec.Exporter.Endpoint},
+		{name: EnvOtelExporterOtlpTracesEndpoint, endpoint: inst.Spec.Exporter.TracesEndpoint, fallback: defaults.Traces},
+		{name: EnvOtelExporterOtlpMetricsEndpoint, endpoint: inst.Spec.Exporter.MetricsEndpoint, fallback: defaults.Metrics},
+		{name: EnvOtelExporterOtlpLogsEndpoint, endpoint: inst.Spec.Exporter.LogsEndpoint, fallback: defaults.Logs},
+	} {
+		endpoint := signal.endpoint
+		if endpoint == "" {
+			endpoint = signal.fallback
+		}
+		if endpoint != "" {
+			setEnvVar(container, signal.name, otlpEndpoint(endpoint, protocol), false)
+		}
+	}
+	if protocol != "" {
+		setEnvVar(container, EnvOtelExporterOtlpProtocol, string(protocol), false)
+	}
+}
+
+// OTLPClientCertSecret is used to get the name of the kubernetes.io/tls secret holding the client certificate of the
+// instrumentation exporter, falling back to the operator default. It's empty when the instrumentation doesn't export
+// to an endpoint or there's no certificate.
+func OTLPClientCertSecret(cfg *config.Config, inst current.Instrumentation) string {
+	if !inst.Spec.Exporter.HasEndpoint() {
+		return ""
+	}
+	if inst.Spec.Exporter.ClientCertSecret != "" || cfg == nil {
+		return inst.Spec.Exporter.ClientCertSecret
+	}
+	return cfg.OTLPClientCert()
+}
+
+// injectOTLPClientCert is used to mount the client certificate secret of the instrumentation exporter, see
+// OTLPClientCertSecret, into the container and point the OTLP exporter at its certificate and key, unless the container
+// already sets them. The secret is replicated into the namespace of the pod by the mutator. It's skipped when it isn't
+// there, as the pod would otherwise never start with a missing secret volume.
+func (i *baseInjector) injectOTLPClientCert(ctx context.Context, container *corev1.Container, inst current.Instrumentation, ns corev1.Namespace, pod *corev1.Pod) {
+	secretName := OTLPClientCertSecret(i.configuration(), inst)
+	if secretName == "" {
+		return
+	}
+	if isPodVolumeMissing(*pod, otlpClientCertVolumeName) && i.client != nil {
+		if err := i.client.Get(ctx, client.ObjectKey{Namespace: ns.Name, Name: secretName}, &corev1.Secret{}); err != nil {
+			i.logger.Info("skipping the OTLP client certificate, the secret can't be read in the namespace of the pod", "secret_name", secretName, "namespace", ns.Name, "reason", err.Error())
+			return
+		}
+	}
+	if isPodVolumeMissing(*pod, otlpClientCertVolumeName) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: otlpClientCertVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+			},
+		})
+	}
+	if isContainerVolumeMissing(container, otlpClientCertVolumeName) {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      otlpClientCertVolumeName,
+			MountPath: otlpClientCertMountPath,
+			ReadOnly:  true,
+		})
+	}
+	setEnvVar(container, EnvOtelExporterOtlpClientCertificate, otlpClientCertMountPath+"/"+corev1.TLSCertKey, false)
+	setEnvVar(container, EnvOtelExporterOtlpClientKey, otlpClientCertMountPath+"/"+corev1.TLSPrivateKeyKey, false)
+}
+
+// otlpEndpoint is used to add the default port of the protocol to an endpoint url without a port
+func otlpEndpoint(endpoint string, protocol config.OTLPProtocol) string {
+	var port string
+	switch protocol {
+	case config.OTLPProtocolGRPC:
+		port = "4317"
+	case config.OTLPProtocolHTTPProtobuf:
+		port = "4318"
+	default:
+		return endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || u.Port() != "" {
+		return endpoint
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), port)
+	return u.String()
+}
@@ -1,12 +1,25 @@
 package apm
 
 import (
+	"context"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+	"github.com/newrelic/k8s-agents-operator/internal/version"
 )
 
 func TestBaseInjector_ConfigureClient(t *testing.T) {
@@ -83,3 +96,1060 @@ func TestEncodeDecodeAttributes(t *testing.T) {
 		assert.Fail(t, diff)
 	}
 }
+
+func TestChooseServiceName(t *testing.T) {
+	serviceNameLabels := []string{"app.kubernetes.io/name", "app"}
+	owners := []metav1.OwnerReference{{Kind: "Deployment", Name: "owner"}}
+	tests := []struct {
+		name     string
+		pod      corev1.Pod
+		labels   []string
+		expected string
+	}{
+		{
+			name:     "owner name without service name labels",
+			pod:      corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", OwnerReferences: owners, Labels: map[string]string{"app": "app"}}},
+			expected: "owner",
+		},
+		{
+			name:     "first label",
+			pod:      corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", OwnerReferences: owners, Labels: map[string]string{"app": "app", "app.kubernetes.io/name": "name"}}},
+			labels:   serviceNameLabels,
+			expected: "name",
+		},
+		{
+			name:     "second label",
+			pod:      corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", OwnerReferences: owners, Labels: map[string]string{"app": "app"}}},
+			labels:   serviceNameLabels,
+			expected: "app",
+		},
+		{
+			name:     "no matching label",
+			pod:      corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", OwnerReferences: owners}},
+			labels:   serviceNameLabels,
+			expected: "owner",
+		},
+		{
+			name:     "container name",
+			pod:      corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "container"}}}},
+			labels:   serviceNameLabels,
+			expected: "container",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, chooseServiceName(test.pod, 0, test.labels))
+		})
+	}
+}
+
+func TestBaseInjector_PositionInitContainer(t *testing.T) {
+	initContainers := func(names ...string) []corev1.Container {
+		var containers []corev1.Container
+		for _, name := range names {
+			containers = append(containers, corev1.Container{Name: name})
+		}
+		return containers
+	}
+	tests := []struct {
+		name     string
+		position config.InitContainerPosition
+		before   string
+		expected []corev1.Container
+	}{
+		{name: "default", expected: initContainers("istio-init", "vault-agent-init", "agent")},
+		{name: "last", position: config.InitContainerPositionLast, expected: initContainers("istio-init", "vault-agent-init", "agent")},
+		{name: "first", position: config.InitContainerPositionFirst, expected: initContainers("agent", "istio-init", "vault-agent-init")},
+		{name: "before", position: config.InitContainerPositionBefore, before: "vault-agent-init", expected: initContainers("istio-init", "agent", "vault-agent-init")},
+		{name: "before missing container", position: config.InitContainerPositionBefore, before: "missing", expected: initContainers("istio-init", "vault-agent-init", "agent")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.New(config.WithInitContainerInsertPosition(test.position, test.before))
+			i := baseInjector{config: &cfg}
+			pod := corev1.Pod{Spec: corev1.PodSpec{InitContainers: initContainers("istio-init", "vault-agent-init", "agent")}}
+			i.positionInitContainer(&pod, "agent")
+			assert.Equal(t, test.expected, pod.Spec.InitContainers)
+		})
+	}
+}
+
+func TestBaseInjector_PositionInitContainer_NativeSidecars(t *testing.T) {
+	restartAlways := corev1.ContainerRestartPolicyAlways
+	initContainers := []corev1.Container{{Name: "istio-init"}, {Name: "istio-proxy", RestartPolicy: &restartAlways}, {Name: "vault-agent-init"}, {Name: "agent"}}
+	tests := []struct {
+		name           string
+		nativeSidecars autodetect.NativeSidecarsAvailability
+		position       config.InitContainerPosition
+		before         string
+		expected       []string
+	}{
+		{name: "last", nativeSidecars: autodetect.NativeSidecarsAvailable, expected: []string{"istio-init", "agent", "istio-proxy", "vault-agent-init"}},
+		{name: "first", nativeSidecars: autodetect.NativeSidecarsAvailable, position: config.InitContainerPositionFirst, expected: []string{"agent", "istio-init", "istio-proxy", "vault-agent-init"}},
+		{name: "before a later container", nativeSidecars: autodetect.NativeSidecarsAvailable, position: config.InitContainerPositionBefore, before: "vault-agent-init", expected: []string{"istio-init", "agent", "istio-proxy", "vault-agent-init"}},
+		{name: "not available", nativeSidecars: autodetect.NativeSidecarsNotAvailable, expected: []string{"istio-init", "istio-proxy", "vault-agent-init", "agent"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.New(config.WithNativeSidecars(test.nativeSidecars), config.WithInitContainerInsertPosition(test.position, test.before))
+			i := baseInjector{config: &cfg}
+			pod := corev1.Pod{Spec: corev1.PodSpec{InitContainers: slices.Clone(initContainers)}}
+			i.positionInitContainer(&pod, "agent")
+			var actual []string
+			for _, initContainer := range pod.Spec.InitContainers {
+				actual = append(actual, initContainer.Name)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestAgentInitContainerName(t *testing.T) {
+	assert.Equal(t, "newrelic-instrumentation-java", AgentInitContainerName("newrelic-instrumentation", "java"))
+	assert.Equal(t, "newrelic-instrumentation-php", AgentInitContainerName("newrelic-instrumentation", "php-8.3"))
+}
+
+func TestBaseInjector_InjectNewrelicEnvConfig_OtelResourceAttributes(t *testing.T) {
+	i := baseInjector{}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "app",
+		Env:  []corev1.EnvVar{{Name: EnvOtelResourceAttributes, Value: "service.namespace=shop,deployment.environment=prod"}},
+	}}}}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env := pod.Spec.Containers[0].Env
+	assert.Equal(t, "deployment.environment=prod,newrelic.k8s.operator.version="+version.Get().Operator+",service.namespace=shop", env[getIndexOfEnv(env, EnvOtelResourceAttributes)].Value)
+	assert.Equal(t, version.Get().Operator, env[getIndexOfEnv(env, EnvNewRelicOperatorVersion)].Value)
+
+	pod = corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	assert.Equal(t, -1, getIndexOfEnv(pod.Spec.Containers[0].Env, EnvOtelResourceAttributes))
+}
+
+func TestBaseInjector_InjectNewrelicEnvConfig_InstrumentationProvider(t *testing.T) {
+	i := baseInjector{}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "app",
+		Env:  []corev1.EnvVar{{Name: EnvNewRelicMetadataProvider, Value: "helm"}},
+	}}}}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env := pod.Spec.Containers[0].Env
+	assert.Equal(t, "helm", env[getIndexOfEnv(env, EnvNewRelicMetadataProvider)].Value)
+	assert.Equal(t, version.Get().Operator, env[getIndexOfEnv(env, EnvNewRelicMetadataOperatorVersion)].Value)
+
+	cfg := config.New(config.WithInstrumentationProvider(""))
+	i = baseInjector{config: &cfg}
+	pod = corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env = pod.Spec.Containers[0].Env
+	assert.Equal(t, -1, getIndexOfEnv(env, EnvNewRelicMetadataProvider))
+	assert.Equal(t, -1, getIndexOfEnv(env, EnvNewRelicMetadataOperatorVersion))
+}
+
+func TestBaseInjector_InjectNewrelicEnvConfig_AttributeLabels(t *testing.T) {
+	cfg := config.New(config.WithAttributeLabels([]string{"team", "tier"}))
+	i := baseInjector{config: &cfg}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "payments", "app": "checkout"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env := pod.Spec.Containers[0].Env
+	assert.Equal(t, "operator:auto-injection;team:payments", env[getIndexOfEnv(env, EnvNewRelicLabels)].Value)
+
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: EnvNewRelicLabels, Value: "team:checkout"}}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env = pod.Spec.Containers[0].Env
+	assert.Equal(t, "operator:auto-injection;team:checkout", env[getIndexOfEnv(env, EnvNewRelicLabels)].Value)
+}
+
+func TestBaseInjector_InjectNewrelicEnvConfig_LabelsFilter(t *testing.T) {
+	cfg := config.New(config.WithAttributeLabels([]string{"team", "tier"}), config.WithLabelsFilter([]string{"^ti"}))
+	i := baseInjector{config: &cfg}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "payments", "tier": "backend"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}
+	filteredBefore := testutil.ToFloat64(labelsFiltered)
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env := pod.Spec.Containers[0].Env
+	assert.Equal(t, "operator:auto-injection;team:payments", env[getIndexOfEnv(env, EnvNewRelicLabels)].Value)
+	assert.Equal(t, filteredBefore+1, testutil.ToFloat64(labelsFiltered))
+}
+
+func TestBaseInjector_InjectNewrelicEnvConfig_ClusterName(t *testing.T) {
+	cfg := config.New(config.WithClusterName("prod-us-east"))
+	i := baseInjector{config: &cfg}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "app",
+		Env:  []corev1.EnvVar{{Name: EnvOtelResourceAttributes, Value: "service.namespace=shop"}},
+	}}}}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env := pod.Spec.Containers[0].Env
+	assert.Equal(t, "k8s.cluster.name:prod-us-east;operator:auto-injection", env[getIndexOfEnv(env, EnvNewRelicLabels)].Value)
+	assert.Equal(t, "k8s.cluster.name=prod-us-east,newrelic.k8s.operator.version="+version.Get().Operator+",service.namespace=shop", env[getIndexOfEnv(env, EnvOtelResourceAttributes)].Value)
+
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: EnvNewRelicLabels, Value: "k8s.cluster.name:staging"}}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env = pod.Spec.Containers[0].Env
+	assert.Equal(t, "k8s.cluster.name:staging;operator:auto-injection", env[getIndexOfEnv(env, EnvNewRelicLabels)].Value)
+}
+
+func TestAgentImage(t *testing.T) {
+	canary := config.New(config.WithImageRepository("newrelic"), config.WithImageChannel("canary"))
+	stable := config.New(config.WithImageRepository("newrelic"), config.WithImageChannel("stable"))
+	noChannel := config.New(config.WithImageRepository("newrelic"))
+	inst := func(language string, image string) current.Instrumentation {
+		return current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: language, Image: image}}}
+	}
+	tests := []struct {
+		name     string
+		cfg      *config.Config
+		inst     current.Instrumentation
+		expected string
+	}{
+		{name: "channel", cfg: &canary, inst: inst("java", ""), expected: "newrelic/java:canary"},
+		{name: "switched channel", cfg: &stable, inst: inst("java", ""), expected: "newrelic/java:stable"},
+		{name: "php version", cfg: &canary, inst: inst("php-8.3", ""), expected: "newrelic/php:canary"},
+		{name: "explicit image wins", cfg: &canary, inst: inst("java", "custom/java:1.0"), expected: "custom/java:1.0"},
+		{name: "no channel", cfg: &noChannel, inst: inst("java", "")},
+		{name: "no config", inst: inst("java", "")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, AgentImage(test.cfg, test.inst))
+		})
+	}
+}
+
+func TestPodAgentImage(t *testing.T) {
+	enabled := config.New(config.WithPodAgentImages(true))
+	disabled := config.New()
+	allowed := config.New(config.WithPodAgentImages(true), config.WithAllowedImageRegistries([]string{"docker.io/newrelic"}))
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "java", Image: "newrelic/newrelic-java-init:8.12.0"}}}
+	pod := func(annotations map[string]string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	}
+	tests := []struct {
+		name          string
+		cfg           *config.Config
+		pod           corev1.Pod
+		expected      string
+		expectedError string
+	}{
+		{name: "annotation", cfg: &enabled, pod: pod(map[string]string{"newrelic.com/java-image": "newrelic/java:9.1.0"}), expected: "newrelic/java:9.1.0"},
+		{name: "no annotation", cfg: &enabled, pod: pod(nil), expected: "newrelic/newrelic-java-init:8.12.0"},
+		{name: "annotation of another language", cfg: &enabled, pod: pod(map[string]string{"newrelic.com/python-image": "newrelic/python:9.1.0"}), expected: "newrelic/newrelic-java-init:8.12.0"},
+		{name: "disabled", cfg: &disabled, pod: pod(map[string]string{"newrelic.com/java-image": "newrelic/java:9.1.0"}), expected: "newrelic/newrelic-java-init:8.12.0"},
+		{
+			name:          "invalid image",
+			cfg:           &enabled,
+			pod:           pod(map[string]string{"newrelic.com/java-image": "newrelic/Java:9.1.0 "}),
+			expected:      "newrelic/newrelic-java-init:8.12.0",
+			expectedError: `the newrelic.com/java-image annotation "newrelic/Java:9.1.0 " must be a valid image reference`,
+		},
+		{
+			name:          "empty image",
+			cfg:           &enabled,
+			pod:           pod(map[string]string{"newrelic.com/java-image": ""}),
+			expected:      "newrelic/newrelic-java-init:8.12.0",
+			expectedError: `the newrelic.com/java-image annotation "" must be a valid image reference`,
+		},
+		{name: "allowed registry", cfg: &allowed, pod: pod(map[string]string{"newrelic.com/java-image": "newrelic/java:9.1.0"}), expected: "newrelic/java:9.1.0"},
+		{
+			name:          "disallowed registry",
+			cfg:           &allowed,
+			pod:           pod(map[string]string{"newrelic.com/java-image": "quay.io/acme/java:9.1.0"}),
+			expected:      "newrelic/newrelic-java-init:8.12.0",
+			expectedError: `the newrelic.com/java-image annotation "quay.io/acme/java:9.1.0" is not from an allowed registry (docker.io/newrelic)`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image, err := PodAgentImage(test.cfg, inst, test.pod)
+			if test.expectedError != "" {
+				require.EqualError(t, err, test.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expected, image)
+		})
+	}
+}
+
+func TestParseEnvAnnotation(t *testing.T) {
+	envVars, malformed := ParseEnvAnnotation("NEW_RELIC_LOG_LEVEL=debug, FOO=bar=baz,,EMPTY=,novalue,=nokey,1BAD=x")
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "NEW_RELIC_LOG_LEVEL", Value: "debug"},
+		{Name: "FOO", Value: "bar=baz"},
+		{Name: "EMPTY", Value: ""},
+	}, envVars)
+	assert.Equal(t, []string{"novalue", "=nokey", "1BAD=x"}, malformed)
+}
+
+func TestBaseInjector_InjectOTLPExporter(t *testing.T) {
+	grpc := config.New(config.WithOTLPProtocol(config.OTLPProtocolGRPC))
+	signals := config.New(config.WithOTLPSignalEndpoints(config.OTLPSignalEndpoints{
+		Traces: "http://default-traces-collector:4318",
+		Logs:   "http://default-logs-collector:4318",
+	}))
+	tests := []struct {
+		name     string
+		cfg      *config.Config
+		exporter current.Exporter
+		env      []corev1.EnvVar
+		expected []corev1.EnvVar
+	}{
+		{name: "no endpoint", cfg: &grpc},
+		{
+			name:     "no protocol",
+			exporter: current.Exporter{Endpoint: "http://collector:4318"},
+			expected: []corev1.EnvVar{{Name: EnvOtelExporterOtlpEndpoint, Value: "http://collector:4318"}},
+		},
+		{
+			name:     "operator default protocol adds the port",
+			cfg:      &grpc,
+			exporter: current.Exporter{Endpoint: "http://collector"},
+			expected: []corev1.EnvVar{
+				{Name: EnvOtelExporterOtlpEndpoint, Value: "http://collector:4317"},
+				{Name: EnvOtelExporterOtlpProtocol, Value: "grpc"},
+			},
+		},
+		{
+			name:     "instrumentation protocol wins",
+			cfg:      &grpc,
+			exporter: current.Exporter{Endpoint: "https://collector/", Protocol: "http/protobuf"},
+			expected: []corev1.EnvVar{
+				{Name: EnvOtelExporterOtlpEndpoint, Value: "https://collector:4318/"},
+				{Name: EnvOtelExporterOtlpProtocol, Value: "http/protobuf"},
+			},
+		},
+		{
+			name:     "explicit port is kept",
+			cfg:      &grpc,
+			exporter: current.Exporter{Endpoint: "http://collector:9000"},
+			expected: []corev1.EnvVar{
+				{Name: EnvOtelExporterOtlpEndpoint, Value: "http://collector:9000"},
+				{Name: EnvOtelExporterOtlpProtocol, Value: "grpc"},
+			},
+		},
+		{
+			name:     "container env wins",
+			cfg:      &grpc,
+			exporter: current.Exporter{Endpoint: "http://collector"},
+			env:      []corev1.EnvVar{{Name: EnvOtelExporterOtlpProtocol, Value: "http/json"}},
+			expected: []corev1.EnvVar{
+				{Name: EnvOtelExporterOtlpProtocol, Value: "http/json"},
+				{Name: EnvOtelExporterOtlpEndpoint, Value: "http://collector:4317"},
+			},
+		},
+		{
+			name: "signal endpoints",
+			cfg:  &grpc,
+			exporter: current.Exporter{
+				TracesEndpoint:  "http://traces-collector",
+				MetricsEndpoint: "http://metrics-collector:9000",
+			},
+			expected: []corev1.EnvVar{
+				{Name: EnvOtelExporterOtlpTracesEndpoint, Value: "http://traces-collector:4317"},
+				{Name: EnvOtelExporterOtlpMetricsEndpoint, Value: "http://metrics-collector:9000"},
+				{Name: EnvOtelExporterOtlpProtocol, Value: "grpc"},
+			},
+		},
+		{
+			name:     "operator default signal endpoints",
+			cfg:      &signals,
+			exporter: current.Exporter{Endpoint: "http://collector:4318", LogsEndpoint: "http://logs-collector:4318"},
+			expected: []corev1.EnvVar{
+				{Name: EnvOtelExporterOtlpEndpoint, Value: "http://collector:4318"},
+				{Name: EnvOtelExporterOtlpTracesEndpoint, Value: "http://default-traces-collector:4318"},
+				{Name: EnvOtelExporterOtlpLogsEndpoint, Value: "http://logs-collector:4318"},
+			},
+		},
+		{
+			name: "operator default signal endpoints need an endpoint",
+			cfg:  &signals,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := baseInjector{config: test.cfg}
+			container := corev1.Container{Env: test.env}
+			i.injectOTLPExporter(&container, current.Instrumentation{Spec: current.InstrumentationSpec{Exporter: test.exporter}})
+			assert.Equal(t, test.expected, container.Env)
+		})
+	}
+}
+
+func TestBaseInjector_InjectOTLPClientCert(t *testing.T) {
+	withDefault := config.New(config.WithOTLPClientCert("default-client-cert"))
+	tests := []struct {
+		name             string
+		cfg              *config.Config
+		exporter         current.Exporter
+		expectedSecret   string
+		expectedInjected bool
+	}{
+		{name: "no secret", exporter: current.Exporter{Endpoint: "http://collector:4317"}},
+		{name: "no endpoint", cfg: &withDefault, exporter: current.Exporter{ClientCertSecret: "client-cert"}},
+		{
+			name:             "operator default",
+			cfg:              &withDefault,
+			exporter:         current.Exporter{Endpoint: "http://collector:4317"},
+			expectedSecret:   "default-client-cert",
+			expectedInjected: true,
+		},
+		{
+			name:             "instrumentation wins",
+			cfg:              &withDefault,
+			exporter:         current.Exporter{Endpoint: "http://collector:4317", ClientCertSecret: "client-cert"},
+			expectedSecret:   "client-cert",
+			expectedInjected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := baseInjector{config: test.cfg}
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "worker"}}}}
+			inst := current.Instrumentation{Spec: current.InstrumentationSpec{Exporter: test.exporter}}
+			for index := range pod.Spec.Containers {
+				i.injectOTLPClientCert(context.Background(), &pod.Spec.Containers[index], inst, corev1.Namespace{}, &pod)
+			}
+			if !test.expectedInjected {
+				assert.Empty(t, pod.Spec.Volumes)
+				assert.Empty(t, pod.Spec.Containers[0].Env)
+				return
+			}
+			assert.Equal(t, []corev1.Volume{{
+				Name:         otlpClientCertVolumeName,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: test.expectedSecret}},
+			}}, pod.Spec.Volumes)
+			for _, container := range pod.Spec.Containers {
+				assert.Equal(t, []corev1.VolumeMount{{Name: otlpClientCertVolumeName, MountPath: otlpClientCertMountPath, ReadOnly: true}}, container.VolumeMounts)
+				assert.Equal(t, []corev1.EnvVar{
+					{Name: EnvOtelExporterOtlpClientCertificate, Value: "/newrelic-otlp-client-cert/tls.crt"},
+					{Name: EnvOtelExporterOtlpClientKey, Value: "/newrelic-otlp-client-cert/tls.key"},
+				}, container.Env)
+			}
+		})
+	}
+}
+
+func TestBaseInjector_InjectOTLPClientCert_SecretInNamespace(t *testing.T) {
+	cfg := config.New(config.WithOTLPClientCert("client-cert"))
+	c := fake.NewClientBuilder().WithObjects(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "with-secret", Name: "client-cert"}}).Build()
+	i := baseInjector{config: &cfg, client: c}
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Exporter: current.Exporter{Endpoint: "http://collector:4317"}}}
+
+	for _, namespace := range []string{"with-secret", "without-secret"} {
+		t.Run(namespace, func(t *testing.T) {
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}}
+			i.injectOTLPClientCert(context.Background(), &pod.Spec.Containers[0], inst, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, &pod)
+			if namespace == "with-secret" {
+				assert.Len(t, pod.Spec.Volumes, 1)
+				assert.Len(t, pod.Spec.Containers[0].Env, 2)
+				return
+			}
+			assert.Empty(t, pod.Spec.Volumes, "a missing secret would keep the pod from starting")
+			assert.Empty(t, pod.Spec.Containers[0].VolumeMounts)
+			assert.Empty(t, pod.Spec.Containers[0].Env)
+		})
+	}
+}
+
+func TestResolveAgentEnv(t *testing.T) {
+	defaults := []corev1.EnvVar{
+		{Name: "NEW_RELIC_LOG_LEVEL", Value: "info"},
+		{Name: "NEW_RELIC_DISTRIBUTED_TRACING_ENABLED", Value: "true"},
+		{Name: "FROM_DEFAULT", Value: "default"},
+	}
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Env: []corev1.EnvVar{
+		{Name: "NEW_RELIC_DISTRIBUTED_TRACING_ENABLED", Value: "false"},
+		{Name: "NEW_RELIC_LOG_LEVEL", Value: "warn"},
+	}}}}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		EnvAnnotation: "NEW_RELIC_LOG_LEVEL=debug,FROM_ANNOTATION=annotation,FROM_DEFAULT=annotation",
+	}}}
+
+	envVars, sources := resolveAgentEnv(defaults, config.AgentEnvPrecedenceInstrumentation, inst, pod)
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "NEW_RELIC_DISTRIBUTED_TRACING_ENABLED", Value: "false"},
+		{Name: "NEW_RELIC_LOG_LEVEL", Value: "warn"},
+		{Name: "FROM_ANNOTATION", Value: "annotation"},
+		{Name: "FROM_DEFAULT", Value: "annotation"},
+	}, envVars)
+	assert.Equal(t, map[string]string{
+		"NEW_RELIC_DISTRIBUTED_TRACING_ENABLED": envSourceInstrumentation,
+		"NEW_RELIC_LOG_LEVEL":                   envSourceInstrumentation,
+		"FROM_ANNOTATION":                       envSourceAnnotation,
+		"FROM_DEFAULT":                          envSourceAnnotation,
+	}, sources)
+
+	envVars, sources = resolveAgentEnv(defaults, config.AgentEnvPrecedenceAnnotation, inst, pod)
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "NEW_RELIC_DISTRIBUTED_TRACING_ENABLED", Value: "false"},
+		{Name: "NEW_RELIC_LOG_LEVEL", Value: "debug"},
+		{Name: "FROM_ANNOTATION", Value: "annotation"},
+		{Name: "FROM_DEFAULT", Value: "annotation"},
+	}, envVars)
+	assert.Equal(t, map[string]string{
+		"NEW_RELIC_DISTRIBUTED_TRACING_ENABLED": envSourceInstrumentation,
+		"NEW_RELIC_LOG_LEVEL":                   envSourceAnnotation,
+		"FROM_ANNOTATION":                       envSourceAnnotation,
+		"FROM_DEFAULT":                          envSourceAnnotation,
+	}, sources)
+
+	envVars, sources = resolveAgentEnv(defaults, config.AgentEnvPrecedenceInstrumentation, current.Instrumentation{}, corev1.Pod{})
+	assert.Equal(t, defaults, envVars)
+	assert.Equal(t, envSourceDefault, sources["FROM_DEFAULT"])
+}
+
+func TestBaseInjector_InjectAgentEnv_ContainerWins(t *testing.T) {
+	cfg := config.New(config.WithAgentEnvDefaults([]corev1.EnvVar{{Name: "NEW_RELIC_LOG_LEVEL", Value: "info"}}))
+	i := baseInjector{config: &cfg}
+	container := corev1.Container{Env: []corev1.EnvVar{{Name: "NEW_RELIC_LOG_LEVEL", Value: "trace"}}}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{EnvAnnotation: "NEW_RELIC_LOG_LEVEL=debug"}}}
+	i.injectAgentEnv(&container, current.Instrumentation{}, pod)
+	assert.Equal(t, []corev1.EnvVar{{Name: "NEW_RELIC_LOG_LEVEL", Value: "trace"}}, container.Env)
+}
+
+func TestInjectReportOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     current.InstrumentationMode
+		language string
+		env      []corev1.EnvVar
+		expected []corev1.EnvVar
+	}{
+		{name: "default mode", language: "python"},
+		{name: "python", mode: current.InstrumentationModeReportOnly, language: "python", expected: []corev1.EnvVar{{Name: "NEW_RELIC_DEVELOPER_MODE", Value: "true"}}},
+		{name: "ruby", mode: current.InstrumentationModeReportOnly, language: "ruby", expected: []corev1.EnvVar{{Name: "NEW_RELIC_MONITOR_MODE", Value: "false"}}},
+		{name: "php version", mode: current.InstrumentationModeReportOnly, language: "php-8.3", expected: []corev1.EnvVar{{Name: "NEW_RELIC_DAEMON_COLLECTOR_HOST", Value: "localhost:1"}}},
+		{
+			name:     "java replaces the collector host",
+			mode:     current.InstrumentationModeReportOnly,
+			language: "java",
+			env:      []corev1.EnvVar{{Name: "NEW_RELIC_HOST", Value: "collector.newrelic.com"}},
+			expected: []corev1.EnvVar{{Name: "NEW_RELIC_HOST", Value: "localhost"}, {Name: "NEW_RELIC_PORT", Value: "1"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container := corev1.Container{Env: test.env}
+			injectReportOnly(&container, current.Instrumentation{Spec: current.InstrumentationSpec{
+				Mode:  test.mode,
+				Agent: current.Agent{Language: test.language},
+			}})
+			assert.Equal(t, test.expected, container.Env)
+		})
+	}
+}
+
+func TestBaseInjector_InjectRegion(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   config.Region
+		language string
+		env      []corev1.EnvVar
+		expected []corev1.EnvVar
+	}{
+		{name: "region of the license key", language: "java"},
+		{name: "eu", region: config.RegionEU, language: "java", expected: []corev1.EnvVar{{Name: "NEW_RELIC_HOST", Value: "collector.eu01.nr-data.net"}}},
+		{name: "fedramp php version", region: config.RegionFedRAMP, language: "php-8.3", expected: []corev1.EnvVar{{Name: "NEW_RELIC_DAEMON_COLLECTOR_HOST", Value: "gov-collector.newrelic.com"}}},
+		{
+			name:     "container env var wins",
+			region:   config.RegionUS,
+			language: "python",
+			env:      []corev1.EnvVar{{Name: "NEW_RELIC_HOST", Value: "collector.example.com"}},
+			expected: []corev1.EnvVar{{Name: "NEW_RELIC_HOST", Value: "collector.example.com"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.New(config.WithRegion(test.region))
+			i := &baseInjector{config: &cfg}
+			container := corev1.Container{Env: slices.Clone(test.env)}
+			i.injectRegion(&container, current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: test.language}}})
+			assert.Equal(t, test.expected, container.Env)
+		})
+	}
+}
+
+func TestInjectReadOnlyRootFilesystem(t *testing.T) {
+	readOnly := true
+	container := corev1.Container{
+		Name:            "app",
+		SecurityContext: &corev1.SecurityContext{ReadOnlyRootFilesystem: &readOnly},
+		VolumeMounts:    []corev1.VolumeMount{{Name: "app-data", MountPath: "/newrelic-instrumentation"}},
+	}
+	err := injectReadOnlyRootFilesystem(&container, "ruby", agentVolumeMountPath, volumeName)
+	assert.ErrorIs(t, err, ErrAgentPathNotWritable)
+
+	container.VolumeMounts = []corev1.VolumeMount{{Name: volumeName, MountPath: "/newrelic-instrumentation"}}
+	container.Env = []corev1.EnvVar{{Name: "NEW_RELIC_LOG_FILE_PATH", Value: "/tmp/"}}
+	assert.NoError(t, injectReadOnlyRootFilesystem(&container, "ruby", agentVolumeMountPath, volumeName))
+	assert.Equal(t, []corev1.EnvVar{{Name: "NEW_RELIC_LOG_FILE_PATH", Value: "/tmp/"}}, container.Env)
+
+	container = corev1.Container{Name: "app"}
+	assert.NoError(t, injectReadOnlyRootFilesystem(&container, "ruby", agentVolumeMountPath, volumeName))
+	assert.Empty(t, container.Env)
+}
+
+func TestBaseInjector_InjectInitContainerRunAs(t *testing.T) {
+	uid, gid, podUID, defaultUID, defaultGID := int64(1000), int64(3000), int64(2000), int64(65532), int64(65533)
+	tests := []struct {
+		name                     string
+		containerSecurityContext *corev1.SecurityContext
+		podSecurityContext       *corev1.PodSecurityContext
+		initSecurityContext      *corev1.SecurityContext
+		opts                     []config.Option
+		expected                 *corev1.SecurityContext
+	}{
+		{name: "root app"},
+		{
+			name:                     "non-root app container",
+			containerSecurityContext: &corev1.SecurityContext{RunAsUser: &uid, RunAsGroup: &gid},
+			expected:                 &corev1.SecurityContext{RunAsUser: &uid, RunAsGroup: &gid},
+		},
+		{
+			name:                     "container wins over the pod",
+			containerSecurityContext: &corev1.SecurityContext{RunAsUser: &uid},
+			podSecurityContext:       &corev1.PodSecurityContext{RunAsUser: &podUID},
+			expected:                 &corev1.SecurityContext{RunAsUser: &uid},
+		},
+		{
+			name:               "inherited from the pod",
+			podSecurityContext: &corev1.PodSecurityContext{RunAsUser: &podUID},
+			opts:               []config.Option{config.WithAgentInitRunAs(&defaultUID, nil)},
+		},
+		{
+			name:     "configured",
+			opts:     []config.Option{config.WithAgentInitRunAs(&defaultUID, &defaultGID)},
+			expected: &corev1.SecurityContext{RunAsUser: &defaultUID, RunAsGroup: &defaultGID},
+		},
+		{
+			name:                     "configured group only",
+			containerSecurityContext: &corev1.SecurityContext{RunAsUser: &uid},
+			opts:                     []config.Option{config.WithAgentInitRunAs(&defaultUID, &defaultGID)},
+			expected:                 &corev1.SecurityContext{RunAsUser: &uid, RunAsGroup: &defaultGID},
+		},
+		{
+			name:                     "init container keeps its own",
+			containerSecurityContext: &corev1.SecurityContext{RunAsUser: &uid, RunAsGroup: &gid},
+			initSecurityContext:      &corev1.SecurityContext{RunAsUser: &defaultUID},
+			expected:                 &corev1.SecurityContext{RunAsUser: &defaultUID, RunAsGroup: &gid},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.New(test.opts...)
+			i := &baseInjector{config: &cfg}
+			pod := corev1.Pod{Spec: corev1.PodSpec{
+				SecurityContext: test.podSecurityContext,
+				Containers:      []corev1.Container{{Name: "app", SecurityContext: test.containerSecurityContext}},
+				InitContainers:  []corev1.Container{{Name: "newrelic-instrumentation-java", SecurityContext: test.initSecurityContext}},
+			}}
+			i.injectInitContainerRunAs(&pod, 0, "newrelic-instrumentation-java")
+			if diff := cmp.Diff(test.expected, pod.Spec.InitContainers[0].SecurityContext); diff != "" {
+				assert.Fail(t, diff)
+			}
+		})
+	}
+}
+
+func TestInjectInitContainerCommand(t *testing.T) {
+	defaultInitContainer := corev1.Container{
+		Name:    "newrelic-instrumentation-php",
+		Command: []string{"/bin/sh"},
+		Args:    []string{"-c", "cp -a /instrumentation/. /newrelic-instrumentation/"},
+	}
+	tests := []struct {
+		name     string
+		agent    current.Agent
+		expected corev1.Container
+	}{
+		{
+			name:     "built-in command",
+			expected: defaultInitContainer,
+		},
+		{
+			name:  "command without arguments",
+			agent: current.Agent{InitCommand: []string{"/prerelease/install.sh"}},
+			expected: corev1.Container{
+				Name:    "newrelic-instrumentation-php",
+				Command: []string{"/prerelease/install.sh"},
+			},
+		},
+		{
+			name:  "command with arguments",
+			agent: current.Agent{InitCommand: []string{"/bin/sh"}, InitArgs: []string{"-c", "cp -a /prerelease/. /newrelic-instrumentation/"}},
+			expected: corev1.Container{
+				Name:    "newrelic-instrumentation-php",
+				Command: []string{"/bin/sh"},
+				Args:    []string{"-c", "cp -a /prerelease/. /newrelic-instrumentation/"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{Spec: corev1.PodSpec{InitContainers: []corev1.Container{*defaultInitContainer.DeepCopy()}}}
+			injectInitContainerCommand(&pod, current.Instrumentation{Spec: current.InstrumentationSpec{Agent: test.agent}}, "newrelic-instrumentation-php")
+			if diff := cmp.Diff(test.expected, pod.Spec.InitContainers[0]); diff != "" {
+				assert.Fail(t, diff)
+			}
+		})
+	}
+}
+
+func TestInjectInitContainerResources(t *testing.T) {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+	}
+	tests := []struct {
+		name     string
+		agent    current.Agent
+		expected corev1.Container
+	}{
+		{
+			name:     "no resources",
+			expected: corev1.Container{Name: "newrelic-instrumentation-java"},
+		},
+		{
+			name:     "resources",
+			agent:    current.Agent{Resources: resources},
+			expected: corev1.Container{Name: "newrelic-instrumentation-java", Resources: resources},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{Spec: corev1.PodSpec{InitContainers: []corev1.Container{{Name: "newrelic-instrumentation-java"}}}}
+			injectInitContainerResources(&pod, current.Instrumentation{Spec: current.InstrumentationSpec{Agent: test.agent}}, "newrelic-instrumentation-java")
+			if diff := cmp.Diff(test.expected, pod.Spec.InitContainers[0]); diff != "" {
+				assert.Fail(t, diff)
+			}
+		})
+	}
+}
+
+func TestInjectInstallerImage(t *testing.T) {
+	defaultInitContainer := corev1.Container{
+		Name:         "newrelic-instrumentation-java",
+		Image:        "newrelic/newrelic-java-init:8.12.0",
+		Command:      []string{"cp", "/newrelic-agent.jar", "/newrelic-instrumentation/newrelic-agent.jar"},
+		VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
+	}
+	artifactVolume := corev1.Volume{
+		Name: "newrelic-instrumentation-java-agent",
+		VolumeSource: corev1.VolumeSource{
+			Image: &corev1.ImageVolumeSource{Reference: "newrelic/newrelic-java-init:8.12.0", PullPolicy: corev1.PullIfNotPresent},
+		},
+	}
+	artifactMount := corev1.VolumeMount{Name: "newrelic-instrumentation-java-agent", MountPath: "/newrelic-agent", ReadOnly: true}
+	tests := []struct {
+		name               string
+		agent              current.Agent
+		expected           corev1.Container
+		expectedVolumes    []corev1.Volume
+		expectedAgentImage string
+	}{
+		{
+			name:               "no installer image",
+			agent:              current.Agent{Image: "newrelic/newrelic-java-init:8.12.0"},
+			expected:           defaultInitContainer,
+			expectedAgentImage: "newrelic/newrelic-java-init:8.12.0",
+		},
+		{
+			name:               "installer image same as the agent image",
+			agent:              current.Agent{Image: "newrelic/newrelic-java-init:8.12.0", InstallerImage: "newrelic/newrelic-java-init:8.12.0"},
+			expected:           defaultInitContainer,
+			expectedAgentImage: "newrelic/newrelic-java-init:8.12.0",
+		},
+		{
+			name:  "installer image",
+			agent: current.Agent{Image: "newrelic/newrelic-java-init:8.12.0", InstallerImage: "example/agent-installer:1"},
+			expected: corev1.Container{
+				Name:         "newrelic-instrumentation-java",
+				Image:        "example/agent-installer:1",
+				VolumeMounts: append(slices.Clone(defaultInitContainer.VolumeMounts), artifactMount),
+			},
+			expectedVolumes:    []corev1.Volume{artifactVolume},
+			expectedAgentImage: "newrelic/newrelic-java-init:8.12.0",
+		},
+		{
+			name: "installer image with an init command",
+			agent: current.Agent{
+				Image: "newrelic/newrelic-java-init:8.12.0", InstallerImage: "example/agent-installer:1",
+				InitCommand: []string{"/install.sh"},
+			},
+			expected: corev1.Container{
+				Name:         "newrelic-instrumentation-java",
+				Image:        "example/agent-installer:1",
+				Command:      []string{"/install.sh"},
+				VolumeMounts: append(slices.Clone(defaultInitContainer.VolumeMounts), artifactMount),
+			},
+			expectedVolumes:    []corev1.Volume{artifactVolume},
+			expectedAgentImage: "newrelic/newrelic-java-init:8.12.0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{Spec: corev1.PodSpec{InitContainers: []corev1.Container{*defaultInitContainer.DeepCopy()}}}
+			inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: test.agent}}
+			injectInitContainerCommand(&pod, inst, "newrelic-instrumentation-java")
+			injectInstallerImage(&pod, inst, "newrelic-instrumentation-java")
+			if diff := cmp.Diff(test.expected, pod.Spec.InitContainers[0]); diff != "" {
+				assert.Fail(t, diff)
+			}
+			assert.Equal(t, test.expectedVolumes, pod.Spec.Volumes)
+			image, ok := InjectedAgentImage(pod, "newrelic-instrumentation-java")
+			assert.True(t, ok)
+			assert.Equal(t, test.expectedAgentImage, image)
+		})
+	}
+}
+
+func TestBaseInjector_InjectAgentWarmup(t *testing.T) {
+	existingHook := &corev1.Lifecycle{PostStart: &corev1.LifecycleHandler{Exec: &corev1.ExecAction{Command: []string{"warm.sh"}}}}
+	tests := []struct {
+		name      string
+		language  string
+		lifecycle *corev1.Lifecycle
+		expected  *corev1.Lifecycle
+	}{
+		{
+			name:     "no warmup for the language",
+			language: "python",
+		},
+		{
+			name:     "warmup",
+			language: "java",
+			expected: &corev1.Lifecycle{PostStart: &corev1.LifecycleHandler{Sleep: &corev1.SleepAction{Seconds: 10}}},
+		},
+		{
+			name:     "warmup rounded up to seconds",
+			language: "nodejs",
+			expected: &corev1.Lifecycle{PostStart: &corev1.LifecycleHandler{Sleep: &corev1.SleepAction{Seconds: 2}}},
+		},
+		{
+			name:      "keeps the preStop hook",
+			language:  "java",
+			lifecycle: &corev1.Lifecycle{PreStop: &corev1.LifecycleHandler{Sleep: &corev1.SleepAction{Seconds: 5}}},
+			expected: &corev1.Lifecycle{
+				PostStart: &corev1.LifecycleHandler{Sleep: &corev1.SleepAction{Seconds: 10}},
+				PreStop:   &corev1.LifecycleHandler{Sleep: &corev1.SleepAction{Seconds: 5}},
+			},
+		},
+		{
+			name:      "container postStart hook wins",
+			language:  "java",
+			lifecycle: existingHook,
+			expected:  existingHook,
+		},
+	}
+	cfg := config.New(
+		config.WithAgentWarmup("java", 10*time.Second),
+		config.WithAgentWarmup("nodejs", 1500*time.Millisecond),
+		config.WithSleepAction(autodetect.SleepActionAvailable),
+	)
+	i := &baseInjector{config: &cfg}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container := corev1.Container{Name: "app", Lifecycle: test.lifecycle.DeepCopy()}
+			i.injectAgentWarmup(&container, test.language)
+			if diff := cmp.Diff(test.expected, container.Lifecycle); diff != "" {
+				assert.Fail(t, diff)
+			}
+		})
+	}
+
+	// the clusters without the sleep action, or not detected yet, would reject the hook
+	cfg = config.New(config.WithAgentWarmup("java", 10*time.Second))
+	container := corev1.Container{Name: "app"}
+	(&baseInjector{config: &cfg}).injectAgentWarmup(&container, "java")
+	assert.Nil(t, container.Lifecycle)
+}
+
+func TestBaseInjector_InjectProfiling(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name            string
+		operatorDefault bool
+		language        string
+		profiling       *bool
+		env             []corev1.EnvVar
+		expectedEnvVars []corev1.EnvVar
+	}{
+		{
+			name:     "off by default",
+			language: "java",
+		},
+		{
+			name:            "operator default",
+			operatorDefault: true,
+			language:        "python",
+			expectedEnvVars: []corev1.EnvVar{{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"}},
+		},
+		{
+			name:      "instrumentation enables the java profilers",
+			language:  "java",
+			profiling: &enabled,
+			expectedEnvVars: []corev1.EnvVar{
+				{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"},
+				{Name: "NEW_RELIC_JFR_ENABLED", Value: "true"},
+			},
+		},
+		{
+			name:            "instrumentation opts out of the operator default",
+			operatorDefault: true,
+			language:        "java",
+			profiling:       &disabled,
+		},
+		{
+			name:            "php flavor",
+			language:        "php-8.3",
+			profiling:       &enabled,
+			expectedEnvVars: []corev1.EnvVar{{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"}},
+		},
+		{
+			name:      "language without profiling",
+			language:  "go",
+			profiling: &enabled,
+		},
+		{
+			name:      "container env var wins",
+			language:  "java",
+			profiling: &enabled,
+			env:       []corev1.EnvVar{{Name: "NEW_RELIC_JFR_ENABLED", Value: "false"}},
+			expectedEnvVars: []corev1.EnvVar{
+				{Name: "NEW_RELIC_JFR_ENABLED", Value: "false"},
+				{Name: "NEW_RELIC_CODE_LEVEL_METRICS_ENABLED", Value: "true"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.New(config.WithAgentProfiling(test.operatorDefault))
+			i := &baseInjector{config: &cfg}
+			container := corev1.Container{Name: "app", Env: slices.Clone(test.env)}
+			inst := current.Instrumentation{Spec: current.InstrumentationSpec{
+				Agent:     current.Agent{Language: test.language},
+				Profiling: test.profiling,
+			}}
+			i.injectProfiling(&container, inst)
+			if diff := cmp.Diff(test.expectedEnvVars, container.Env); diff != "" {
+				assert.Fail(t, diff)
+			}
+		})
+	}
+}
+
+func TestGetAgentContainerIndex(t *testing.T) {
+	containers := []corev1.Container{{Name: "istio-proxy"}, {Name: "app"}, {Name: "log-shipper"}}
+	injected := []corev1.Container{{Name: "istio-proxy"}, {Name: "app"}, {Name: "log-shipper", VolumeMounts: []corev1.VolumeMount{{Name: volumeName}}}}
+	tests := []struct {
+		name           string
+		containers     []corev1.Container
+		agentContainer config.AgentContainer
+		expected       int
+	}{
+		{name: "default", containers: containers, expected: 0},
+		{name: "first", containers: containers, agentContainer: config.AgentContainer{Position: config.AgentContainerPositionFirst}, expected: 0},
+		{name: "last", containers: containers, agentContainer: config.AgentContainer{Position: config.AgentContainerPositionLast}, expected: 2},
+		{
+			name:           "named",
+			containers:     containers,
+			agentContainer: config.AgentContainer{Position: config.AgentContainerPositionNamed, Name: "app"},
+			expected:       1,
+		},
+		{
+			name:           "named container missing",
+			containers:     containers,
+			agentContainer: config.AgentContainer{Position: config.AgentContainerPositionNamed, Name: "web"},
+			expected:       0,
+		},
+		{
+			name:           "injected before",
+			containers:     injected,
+			agentContainer: config.AgentContainer{Position: config.AgentContainerPositionNamed, Name: "app"},
+			expected:       2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: test.containers}}
+			assert.Equal(t, test.expected, getAgentContainerIndex(pod, test.agentContainer))
+		})
+	}
+}
+
+func TestBaseInjector_AgentContainerIndex(t *testing.T) {
+	containers := []corev1.Container{{Name: "user-container"}, {Name: "queue-proxy"}}
+	knativeLabels := map[string]string{"serving.knative.dev/revision": "hello-00001"}
+	tests := []struct {
+		name           string
+		knative        autodetect.KnativeAvailability
+		labels         map[string]string
+		agentContainer config.AgentContainer
+		expected       int
+	}{
+		{
+			name:           "knative queue proxy",
+			knative:        autodetect.KnativeAvailable,
+			labels:         knativeLabels,
+			agentContainer: config.AgentContainer{Position: config.AgentContainerPositionLast},
+			expected:       0,
+		},
+		{
+			name:           "named knative queue proxy",
+			knative:        autodetect.KnativeAvailable,
+			labels:         knativeLabels,
+			agentContainer: config.AgentContainer{Position: config.AgentContainerPositionNamed, Name: "queue-proxy"},
+			expected:       0,
+		},
+		{
+			name:           "queue proxy outside of knative",
+			knative:        autodetect.KnativeAvailable,
+			agentContainer: config.AgentContainer{Position: config.AgentContainerPositionLast},
+			expected:       1,
+		},
+		{
+			name:           "knative not available",
+			knative:        autodetect.KnativeNotAvailable,
+			labels:         knativeLabels,
+			agentContainer: config.AgentContainer{Position: config.AgentContainerPositionLast},
+			expected:       1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.New(config.WithKnative(test.knative), config.WithAgentContainer(test.agentContainer))
+			i := &baseInjector{config: &cfg}
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: test.labels}, Spec: corev1.PodSpec{Containers: containers}}
+			assert.Equal(t, test.expected, i.agentContainerIndex(pod))
+		})
+	}
+}
+
+func TestStampAgentImage(t *testing.T) {
+	inst := types.NamespacedName{Namespace: "newrelic", Name: "java"}
+	pod := corev1.Pod{Spec: corev1.PodSpec{InitContainers: []corev1.Container{
+		{Name: "newrelic-instrumentation-java", Image: "newrelic/newrelic-java-init:8.12.0"},
+	}}}
+
+	changed, err := StampAgentImage(&pod, inst, "newrelic-instrumentation-java")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, `{"newrelic/java":"newrelic/newrelic-java-init:8.12.0"}`, pod.Annotations[AgentImagesAnnotation])
+
+	changed, err = StampAgentImage(&pod, inst, "newrelic-instrumentation-java")
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, err = StampAgentImage(&pod, inst, "newrelic-instrumentation-python")
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	pod.Annotations[AgentImagesAnnotation] = "{"
+	_, err = StampAgentImage(&pod, inst, "newrelic-instrumentation-java")
+	assert.Error(t, err)
+}
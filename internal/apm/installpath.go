@@ -0,0 +1,99 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apm
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+)
+
+// ErrAgentInstallPath is returned when the agent install path of the instrumentation can't be used in the container
+var ErrAgentInstallPath = errors.New("the agent install path can't be used")
+
+// agentInstallPath is used to get the path the agent volume is mounted at in the container. A relative install path is
+// resolved against the working directory of the container, which has to be set as the image one isn't known when
+// injecting. It fails when the agent volume would hide the working directory of the app.
+func agentInstallPath(container corev1.Container, inst current.Instrumentation) (string, error) {
+	installPath := inst.Spec.Agent.InstallPath
+	if installPath == "" {
+		installPath = agentVolumeMountPath
+	}
+	if !path.IsAbs(installPath) {
+		if !path.IsAbs(container.WorkingDir) {
+			return "", fmt.Errorf("%w: container %q has no absolute workingDir to resolve %s against", ErrAgentInstallPath, container.Name, installPath)
+		}
+		installPath = path.Join(container.WorkingDir, installPath)
+	}
+	if container.WorkingDir != "" {
+		workingDir := path.Clean(container.WorkingDir)
+		if workingDir == installPath || strings.HasPrefix(workingDir, installPath+"/") {
+			return "", fmt.Errorf("%w: mounting the agent at %s would hide the workingDir %s of container %q", ErrAgentInstallPath, installPath, container.WorkingDir, container.Name)
+		}
+	}
+	return installPath, nil
+}
+
+// relocateAgentEnv is used to point the agent paths in the env vars of the container, and the java agent flag of its
+// command, at the install path, when it's not the default one. Only the env vars added or changed by the injection,
+// compared to the env the container had before it, are relocated, so the ones of the app are kept as they are.
+func relocateAgentEnv(container *corev1.Container, env []corev1.EnvVar, installPath string) {
+	if installPath == agentVolumeMountPath {
+		return
+	}
+	for i := range container.Env {
+		if index := getIndexOfEnv(env, container.Env[i].Name); index > -1 && env[index].Value == container.Env[i].Value {
+			continue
+		}
+		container.Env[i].Value = relocateAgentPath(container.Env[i].Value, installPath)
+	}
+	for _, args := range [][]string{container.Command, container.Args} {
+		for i := range args {
+			if isJavaAgentArg(args[i]) {
+				args[i] = relocateAgentPath(args[i], installPath)
+			}
+		}
+	}
+}
+
+// relocateAgentPath is used to replace the default agent path with the install path in an env var value. Only whole
+// paths are replaced, at the start of the value or after a space, colon or equal sign, such as in
+// `-javaagent:/newrelic-instrumentation/newrelic-agent.jar` or in a PYTHONPATH list.
+func relocateAgentPath(value string, installPath string) string {
+	var relocated strings.Builder
+	for {
+		index := strings.Index(value, agentVolumeMountPath)
+		if index == -1 {
+			relocated.WriteString(value)
+			return relocated.String()
+		}
+		end := index + len(agentVolumeMountPath)
+		relocated.WriteString(value[:index])
+		if (index == 0 || strings.ContainsRune(" :=", rune(value[index-1]))) &&
+			(end == len(value) || strings.ContainsRune(" :/", rune(value[end]))) {
+			relocated.WriteString(installPath)
+		} else {
+			relocated.WriteString(agentVolumeMountPath)
+		}
+		value = value[end:]
+	}
+}
@@ -0,0 +1,80 @@
+package apm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestRelocateAgentPath(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "/newrelic-instrumentation", expected: "/opt/newrelic"},
+		{value: "-javaagent:/newrelic-instrumentation/newrelic-agent.jar", expected: "-javaagent:/opt/newrelic/newrelic-agent.jar"},
+		{value: "--require /newrelic-instrumentation/newrelicinstrumentation.js", expected: "--require /opt/newrelic/newrelicinstrumentation.js"},
+		{value: "/newrelic-instrumentation/newrelic/bootstrap:/newrelic-instrumentation:/app", expected: "/opt/newrelic/newrelic/bootstrap:/opt/newrelic:/app"},
+		{value: "/data/newrelic-instrumentation/x", expected: "/data/newrelic-instrumentation/x"},
+		{value: "/newrelic-instrumentation-old/x", expected: "/newrelic-instrumentation-old/x"},
+		{value: "info", expected: "info"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			assert.Equal(t, test.expected, relocateAgentPath(test.value, "/opt/newrelic"))
+		})
+	}
+}
+
+func TestInject_InstallPath_Reinvocation(t *testing.T) {
+	tests := []struct {
+		injector Injector
+		env      string
+		expected string
+	}{
+		{injector: &JavaInjector{}, env: "JAVA_TOOL_OPTIONS", expected: "-Xmx1g -javaagent:/opt/nr/newrelic-agent.jar"},
+		{injector: &RubyInjector{}, env: "RUBYOPT", expected: "-W0 -r /opt/nr/lib/boot/strap"},
+		{injector: &NodejsInjector{}, env: "NODE_OPTIONS", expected: "--max-old-space-size=512 --require /opt/nr/newrelicinstrumentation.js"},
+		{injector: &PythonInjector{}, env: "PYTHONPATH", expected: "/opt/nr:/app"},
+	}
+	cfg := config.New()
+	for _, test := range tests {
+		t.Run(test.injector.Language(), func(t *testing.T) {
+			test.injector.ConfigureConfig(&cfg)
+			original := map[string]string{
+				"JAVA_TOOL_OPTIONS": "-Xmx1g",
+				"RUBYOPT":           "-W0",
+				"NODE_OPTIONS":      "--max-old-space-size=512",
+				"PYTHONPATH":        "/app",
+			}[test.env]
+			inst := current.Instrumentation{Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: test.injector.Language(), InstallPath: "/opt/nr"},
+				LicenseKeySecret: "newrelic-key-secret",
+			}}
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "app",
+				Env: []corev1.EnvVar{
+					{Name: test.env, Value: original},
+					{Name: "APP_DATA", Value: "/newrelic-instrumentation"},
+				},
+			}}}}
+
+			// the api server reinvokes the webhook, which injects the pod once more
+			injected, err := test.injector.Inject(context.Background(), inst, corev1.Namespace{}, pod)
+			require.NoError(t, err)
+			reinjected, err := test.injector.Inject(context.Background(), inst, corev1.Namespace{}, *injected.DeepCopy())
+			require.NoError(t, err)
+
+			env := reinjected.Spec.Containers[0].Env
+			assert.Equal(t, test.expected, env[getIndexOfEnv(env, test.env)].Value)
+			assert.Equal(t, "/newrelic-instrumentation", env[getIndexOfEnv(env, "APP_DATA")].Value, "the env vars of the app aren't relocated")
+			assert.Equal(t, injected.Spec.Containers[0], reinjected.Spec.Containers[0])
+		})
+	}
+}
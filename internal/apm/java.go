@@ -17,6 +17,8 @@ package apm
 
 import (
 	"context"
+	"path"
+	"slices"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -25,14 +27,14 @@ import (
 )
 
 const (
-	envJavaToolsOptions   = "JAVA_TOOL_OPTIONS"
-	envApmConfigFile      = "NEWRELIC_FILE"
-	javaJVMArgument       = "-javaagent:/newrelic-instrumentation/newrelic-agent.jar"
-	javaInitContainerName = initContainerName + "-java"
-	javaApmConfigPath     = apmConfigMountPath + "/newrelic.yaml"
+	envJavaToolsOptions = "JAVA_TOOL_OPTIONS"
+	envApmConfigFile    = "NEWRELIC_FILE"
+	javaJVMArgument     = "-javaagent:/newrelic-instrumentation/newrelic-agent.jar"
+	javaApmConfigPath   = apmConfigMountPath + "/newrelic.yaml"
 )
 
 var _ Injector = (*JavaInjector)(nil)
+var _ LanguageInjector = (*JavaInjector)(nil)
 
 func init() {
 	DefaultInjectorRegistry.MustRegister(&JavaInjector{})
@@ -46,54 +48,38 @@ func (i *JavaInjector) Language() string {
 	return "java"
 }
 
-func (i *JavaInjector) acceptable(inst current.Instrumentation, pod corev1.Pod) bool {
-	if inst.Spec.Agent.Language != i.Language() {
-		return false
-	}
-	if len(pod.Spec.Containers) == 0 {
-		return false
-	}
-	return true
+func (i *JavaInjector) Name() string {
+	return i.Language()
 }
 
 func (i *JavaInjector) Inject(ctx context.Context, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
-	if !i.acceptable(inst, pod) {
-		return pod, nil
-	}
-	if err := i.validate(inst); err != nil {
-		return pod, err
-	}
+	return i.injectLanguage(ctx, i, inst, ns, pod)
+}
 
-	firstContainer := 0
-	// caller checks if there is at least one container.
-	container := &pod.Spec.Containers[firstContainer]
+func (i *JavaInjector) InjectEnv(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int) error {
+	container := &pod.Spec.Containers[containerIndex]
 
 	err := validateContainerEnv(container.Env, envJavaToolsOptions)
 	if err != nil {
-		return pod, err
+		return err
 	}
 
-	// inject Java instrumentation spec env vars.
-	for _, env := range inst.Spec.Agent.Env {
-		idx := getIndexOfEnv(container.Env, env.Name)
-		if idx == -1 {
-			container.Env = append(container.Env, env)
-		}
-	}
-
-	if idx := getIndexOfEnv(container.Env, envJavaToolsOptions); idx == -1 {
-		container.Env = append(container.Env, corev1.EnvVar{
-			Name:  envJavaToolsOptions,
-			Value: javaJVMArgument,
-		})
-	} else {
-		if !strings.Contains(" "+container.Env[idx].Value+" ", " "+javaJVMArgument+" ") {
-			container.Env[idx].Value = container.Env[idx].Value + " " + javaJVMArgument
+	// an agent loaded by the command isn't added to JAVA_TOOL_OPTIONS too, which would load it twice
+	if !inst.Spec.Agent.CommandArgs || !injectJavaCommandArg(container) {
+		if idx := getIndexOfEnv(container.Env, envJavaToolsOptions); idx == -1 {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  envJavaToolsOptions,
+				Value: javaJVMArgument,
+			})
+		} else {
+			if !slices.ContainsFunc(strings.Fields(container.Env[idx].Value), isJavaAgentArg) {
+				container.Env[idx].Value = container.Env[idx].Value + " " + javaJVMArgument
+			}
 		}
 	}
 
 	if inst.Spec.AgentConfigMap != "" {
-		injectAgentConfigMap(&pod, firstContainer, inst.Spec.AgentConfigMap)
+		injectAgentConfigMap(pod, containerIndex, inst.Spec.AgentConfigMap)
 
 		// Add ENV
 		if apmIdx := getIndexOfEnv(container.Env, envApmConfigFile); apmIdx == -1 {
@@ -103,42 +89,47 @@ func (i *JavaInjector) Inject(ctx context.Context, inst current.Instrumentation,
 			})
 		}
 	}
+	return nil
+}
 
-	if isContainerVolumeMissing(container, volumeName) {
-		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-			Name:      volumeName,
-			MountPath: "/newrelic-instrumentation",
-		})
-	}
-
-	// We just inject Volumes and init containers for the first processed container.
-	if isInitContainerMissing(pod, javaInitContainerName) {
-		if isPodVolumeMissing(pod, volumeName) {
-			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-				Name: volumeName,
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{},
-				}})
+// injectJavaCommandArg is used to put the agent flag right after the java executable of the container command, or of
+// its args when the image entrypoint runs them, so that the flags of the app, such as -XX options or -jar, are kept
+// after it. Only the executable itself is considered, the first command arg, or the first arg without a command, as a
+// java arg anywhere else is an argument of another program, such as a wrapper script. It returns false when the
+// container doesn't run java directly.
+func injectJavaCommandArg(container *corev1.Container) bool {
+	if len(container.Command) > 0 {
+		if path.Base(container.Command[0]) != "java" {
+			return false
 		}
-
-		pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{
-			Name:    javaInitContainerName,
-			Image:   inst.Spec.Agent.Image,
-			Command: []string{"cp", "/newrelic-agent.jar", "/newrelic-instrumentation/newrelic-agent.jar"},
-			VolumeMounts: []corev1.VolumeMount{{
-				Name:      volumeName,
-				MountPath: "/newrelic-instrumentation",
-			}},
-		})
+		if !slices.ContainsFunc(slices.Concat(container.Command[1:], container.Args), isJavaAgentArg) {
+			container.Command = slices.Insert(slices.Clone(container.Command), 1, javaJVMArgument)
+		}
+		return true
 	}
-
-	pod = i.injectNewrelicConfig(ctx, ns, pod, firstContainer, inst.Spec.LicenseKeySecret)
-
-	pod = addAnnotationToPodFromInstrumentationVersion(ctx, pod, inst)
-
-	if pod, err = i.injectHealth(ctx, inst, ns, pod, firstContainer, -1); err != nil {
-		return pod, err
+	if len(container.Args) == 0 || path.Base(container.Args[0]) != "java" {
+		return false
+	}
+	if !slices.ContainsFunc(container.Args[1:], isJavaAgentArg) {
+		container.Args = slices.Insert(slices.Clone(container.Args), 1, javaJVMArgument)
 	}
+	return true
+}
 
-	return pod, nil
+// isJavaAgentArg is used to check if a command arg is the flag loading the java agent, at any install path
+func isJavaAgentArg(arg string) bool {
+	return strings.HasPrefix(arg, "-javaagent:") && strings.HasSuffix(arg, "/newrelic-agent.jar")
+}
+
+func (i *JavaInjector) InjectInitContainer(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int, initContainerName string) error {
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{
+		Name:    initContainerName,
+		Image:   inst.Spec.Agent.Image,
+		Command: []string{"cp", "/newrelic-agent.jar", agentVolumeMountPath + "/newrelic-agent.jar"},
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      volumeName,
+			MountPath: agentVolumeMountPath,
+		}},
+	})
+	return nil
 }
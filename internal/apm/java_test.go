@@ -11,6 +11,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 	"github.com/newrelic/k8s-agents-operator/internal/version"
 )
 
@@ -72,6 +73,7 @@ func TestJavaInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				},
@@ -83,6 +85,9 @@ func TestJavaInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
@@ -112,6 +117,7 @@ func TestJavaInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				},
@@ -123,6 +129,9 @@ func TestJavaInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
@@ -152,6 +161,7 @@ func TestJavaInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				},
@@ -163,6 +173,9 @@ func TestJavaInjector_Inject(t *testing.T) {
 							{Name: "JAVA_TOOL_OPTIONS", Value: "-javaagent:/newrelic-instrumentation/newrelic-agent.jar"},
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
@@ -187,6 +200,7 @@ func TestJavaInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				},
@@ -199,6 +213,9 @@ func TestJavaInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-apm-config", MountPath: "/newrelic-apm-config"}, {Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
@@ -243,3 +260,117 @@ func TestJavaInjector_Inject(t *testing.T) {
 		})
 	}
 }
+
+func TestJavaInjector_InjectWithInitContainerNamePrefix(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.New(config.WithInitContainerNamePrefix("acme-agent"))
+	i := &JavaInjector{}
+	i.ConfigureConfig(&cfg)
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "java"}, LicenseKeySecret: "newrelic-key-secret"}}
+
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}}}}
+	actualPod, err := i.Inject(ctx, inst, corev1.Namespace{}, pod)
+	require.NoError(t, err)
+	require.Len(t, actualPod.Spec.InitContainers, 1)
+	assert.Equal(t, "acme-agent-java", actualPod.Spec.InitContainers[0].Name)
+	assert.True(t, IsAgentInitContainer(cfg.InitContainerNamePrefix(), actualPod.Spec.InitContainers[0].Name))
+
+	pod = corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}, {Name: "acme-agent-java"}}}}
+	_, err = i.Inject(ctx, inst, corev1.Namespace{}, pod)
+	require.EqualError(t, err, `the pod already has a container named "acme-agent-java"`)
+
+	pod = corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "acme-agent-java", VolumeMounts: []corev1.VolumeMount{{Name: "cache", MountPath: "/cache"}}}},
+		Containers:     []corev1.Container{{Name: "test"}},
+	}}
+	_, err = i.Inject(ctx, inst, corev1.Namespace{}, pod)
+	require.EqualError(t, err, `the pod already has an init container named "acme-agent-java" which isn't an agent init container`)
+
+	reinjectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, *actualPod.DeepCopy())
+	require.NoError(t, err, "the agent init container of an earlier injection isn't a conflict")
+	assert.Len(t, reinjectedPod.Spec.InitContainers, 1)
+}
+
+func TestJavaInjector_Inject_CommandArgs(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name            string
+		container       corev1.Container
+		installPath     string
+		expectedCommand []string
+		expectedArgs    []string
+		expectedEnv     bool
+	}{
+		{
+			name:            "java command",
+			container:       corev1.Container{Name: "test", Command: []string{"java", "-XX:MaxRAMPercentage=75", "-jar", "app.jar"}},
+			expectedCommand: []string{"java", "-javaagent:/newrelic-instrumentation/newrelic-agent.jar", "-XX:MaxRAMPercentage=75", "-jar", "app.jar"},
+		},
+		{
+			name:            "java command with args",
+			container:       corev1.Container{Name: "test", Command: []string{"/opt/java/bin/java"}, Args: []string{"-Xmx512m", "-jar", "app.jar"}},
+			expectedCommand: []string{"/opt/java/bin/java", "-javaagent:/newrelic-instrumentation/newrelic-agent.jar"},
+			expectedArgs:    []string{"-Xmx512m", "-jar", "app.jar"},
+		},
+		{
+			name:         "java args of the entrypoint",
+			container:    corev1.Container{Name: "test", Args: []string{"java", "-Xmx512m", "-jar", "app.jar"}},
+			expectedArgs: []string{"java", "-javaagent:/newrelic-instrumentation/newrelic-agent.jar", "-Xmx512m", "-jar", "app.jar"},
+		},
+		{
+			name:            "install path",
+			container:       corev1.Container{Name: "test", Command: []string{"java", "-jar", "app.jar"}},
+			installPath:     "/opt/newrelic",
+			expectedCommand: []string{"java", "-javaagent:/opt/newrelic/newrelic-agent.jar", "-jar", "app.jar"},
+		},
+		{
+			name:            "java arg of another program",
+			container:       corev1.Container{Name: "test", Command: []string{"/entrypoint.sh", "java"}, Args: []string{"-jar", "app.jar"}},
+			expectedCommand: []string{"/entrypoint.sh", "java"},
+			expectedArgs:    []string{"-jar", "app.jar"},
+			expectedEnv:     true,
+		},
+		{
+			name:            "java args of another command",
+			container:       corev1.Container{Name: "test", Command: []string{"tini", "--"}, Args: []string{"java", "-jar", "app.jar"}},
+			expectedCommand: []string{"tini", "--"},
+			expectedArgs:    []string{"java", "-jar", "app.jar"},
+			expectedEnv:     true,
+		},
+		{
+			name:         "java arg of the entrypoint args",
+			container:    corev1.Container{Name: "test", Args: []string{"run", "java"}},
+			expectedArgs: []string{"run", "java"},
+			expectedEnv:  true,
+		},
+		{
+			name:            "shell script",
+			container:       corev1.Container{Name: "test", Command: []string{"sh", "-c", "java -jar app.jar"}},
+			expectedCommand: []string{"sh", "-c", "java -jar app.jar"},
+			expectedEnv:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := &JavaInjector{}
+			inst := current.Instrumentation{Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "java", CommandArgs: true, InstallPath: test.installPath},
+				LicenseKeySecret: "newrelic-key-secret",
+			}}
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{test.container}}}
+
+			injectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, pod)
+			require.NoError(t, err)
+			container := injectedPod.Spec.Containers[0]
+			assert.Equal(t, test.expectedCommand, container.Command)
+			assert.Equal(t, test.expectedArgs, container.Args)
+			assert.Equal(t, test.expectedEnv, getIndexOfEnv(container.Env, envJavaToolsOptions) > -1)
+
+			reinjectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, *injectedPod.DeepCopy())
+			require.NoError(t, err)
+			if diff := cmp.Diff(injectedPod, reinjectedPod); diff != "" {
+				assert.Fail(t, "reinjecting changed the pod", diff)
+			}
+		})
+	}
+}
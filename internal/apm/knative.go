@@ -0,0 +1,44 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apm
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+)
+
+const (
+	// knativeRevisionLabel is the label Knative sets on the pods of its revisions, holding the name of the revision
+	knativeRevisionLabel = "serving.knative.dev/revision"
+	// knativeQueueProxyContainer is the container Knative adds to the pods of its revisions, proxying the requests to the
+	// app containers
+	knativeQueueProxyContainer = "queue-proxy"
+)
+
+// isKnativeQueueProxy is used to check if the container at index is the queue proxy of a pod of a Knative revision.
+// It's part of Knative rather than of the app, so the agents aren't injected into it. Only the pods of clusters where
+// Knative is available are checked.
+func (i *baseInjector) isKnativeQueueProxy(pod corev1.Pod, index int) bool {
+	if i.configuration().Knative() != autodetect.KnativeAvailable {
+		return false
+	}
+	if _, ok := pod.Labels[knativeRevisionLabel]; !ok || index < 0 || index >= len(pod.Spec.Containers) {
+		return false
+	}
+	return pod.Spec.Containers[index].Name == knativeQueueProxyContainer
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package apm
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+// LanguageInjector is the language specific part of injecting an agent into a pod. The common parts (matching the
+// instrumentation language, validating the license key secret, the shared agent volume, the new relic config, the
+// version annotation and the health sidecar) are handled by the injector returned from NewLanguageInjector.
+type LanguageInjector interface {
+	// Name is the spec.agent.language value handled by the injector. It's also used as the suffix of the init
+	// container name.
+	Name() string
+	// InjectEnv adds the environment the agent needs to the container at containerIndex. The instrumentation
+	// spec.agent.env has already been added by the time it's called.
+	InjectEnv(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int) error
+	// InjectInitContainer adds the init container named initContainerName, which copies the agent into the shared
+	// agent volume. It's only called when the pod doesn't already have the init container.
+	InjectInitContainer(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int, initContainerName string) error
+}
+
+var _ Injector = (*languageInjectorWrapper)(nil)
+
+type languageInjectorWrapper struct {
+	baseInjector
+	languageInjector LanguageInjector
+}
+
+// NewLanguageInjector wraps a LanguageInjector so that it can be registered in an InjectorRegistery
+func NewLanguageInjector(languageInjector LanguageInjector) Injector {
+	return &languageInjectorWrapper{languageInjector: languageInjector}
+}
+
+func (i *languageInjectorWrapper) Language() string {
+	return i.languageInjector.Name()
+}
+
+func (i *languageInjectorWrapper) Inject(ctx context.Context, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
+	return i.injectLanguage(ctx, i.languageInjector, inst, ns, pod)
+}
+
+// injectLanguage runs the common injection flow, calling into the language injector for the agent specific parts
+func (i *baseInjector) injectLanguage(ctx context.Context, languageInjector LanguageInjector, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
+	if inst.Spec.Agent.Language != languageInjector.Name() {
+		return pod, nil
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return pod, nil
+	}
+	if err := i.validate(inst); err != nil {
+		return pod, err
+	}
+
+	initContainerName := i.agentInitContainerName(languageInjector.Name())
+	agentVolume := i.agentVolumeName(pod)
+	if err := validateInitContainerName(pod, initContainerName, agentVolume); err != nil {
+		return pod, err
+	}
+
+	containerIndexes := i.agentContainerIndexes(pod, inst)
+	firstContainer := containerIndexes[0]
+	for _, index := range containerIndexes {
+		if err := i.injectLanguageContainer(ctx, languageInjector, inst, ns, &pod, index, agentVolume); err != nil {
+			return pod, err
+		}
+	}
+
+	// We just inject Volumes and init containers for the first processed container.
+	if isInitContainerMissing(pod, initContainerName) {
+		if isPodVolumeMissing(pod, agentVolume) {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+				Name: agentVolume,
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{},
+				}})
+		}
+
+		if err := languageInjector.InjectInitContainer(ctx, inst, &pod, firstContainer, initContainerName); err != nil {
+			return pod, err
+		}
+		i.useAgentVolume(&pod, initContainerName, agentVolume, inst.Spec.Agent.Language)
+		injectInitContainerCommand(&pod, inst, initContainerName)
+		injectInstallerImage(&pod, inst, initContainerName)
+		injectInitContainerResources(&pod, inst, initContainerName)
+		i.injectInitContainerRunAs(&pod, firstContainer, initContainerName)
+		injectPodSecurity(&pod, ns, initContainerName)
+		i.positionInitContainer(&pod, initContainerName)
+	}
+
+	for _, index := range containerIndexes {
+		pod = i.injectNewrelicConfig(ctx, ns, pod, index, inst.Spec.LicenseKeySecret)
+	}
+
+	pod = addAnnotationToPodFromInstrumentationVersion(ctx, pod, inst)
+	if _, err := StampAgentImage(&pod, types.NamespacedName{Namespace: inst.Namespace, Name: inst.Name}, initContainerName); err != nil {
+		log.FromContext(ctx).Error(err, "failed to stamp the agent image, skipping it")
+	}
+
+	var err error
+	if pod, err = i.injectHealth(ctx, inst, ns, pod, firstContainer, -1); err != nil {
+		return pod, err
+	}
+
+	return pod, nil
+}
+
+// injectLanguageContainer is used to inject the agent env vars and the agent volume into the container at index
+func (i *baseInjector) injectLanguageContainer(ctx context.Context, languageInjector LanguageInjector, inst current.Instrumentation, ns corev1.Namespace, pod *corev1.Pod, index int, agentVolume string) error {
+	container := &pod.Spec.Containers[index]
+	installPath, err := agentInstallPath(*container, inst)
+	if err != nil {
+		return err
+	}
+	i.stripEnv(container, inst)
+	env := slices.Clone(container.Env)
+
+	if err := injectStartupWrapper(container, inst.Spec.Agent.StartupWrapper); err != nil {
+		return err
+	}
+
+	// inject the agent env vars of the container, the pod annotation, the instrumentation spec and the operator defaults.
+	injectContainerEnv(container, inst)
+	i.injectAgentEnv(container, inst, *pod)
+	i.injectAppName(container, inst, ns, *pod)
+	i.injectOTLPExporter(container, inst)
+	i.injectOTLPClientCert(ctx, container, inst, ns, pod)
+	i.injectRegion(container, inst)
+	injectReportOnly(container, inst)
+	i.injectProfiling(container, inst)
+	i.injectAgentWarmup(container, inst.Spec.Agent.Language)
+
+	if err := languageInjector.InjectEnv(ctx, inst, pod, index); err != nil {
+		return err
+	}
+
+	if isContainerVolumeMissing(container, agentVolume) {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:             agentVolume,
+			MountPath:        installPath,
+			MountPropagation: i.agentMountPropagation(*container, inst.Spec.Agent.Language),
+		})
+	}
+	if err = injectReadOnlyRootFilesystem(container, inst.Spec.Agent.Language, installPath, agentVolume); err != nil {
+		return err
+	}
+	relocateAgentEnv(container, env, installPath)
+	return nil
+}
+
+// agentContainerIndexes is used to get the indexes of the containers the agent is injected into, the agent container
+// followed by the containers of the instrumentation found in the pod. When the webhook is reinvoked, the agent
+// container is the one injected before, so the named agent container is added too, in case another webhook added it
+// since, along with the containers of the instrumentation added by them.
+func (i *baseInjector) agentContainerIndexes(pod corev1.Pod, inst current.Instrumentation) []int {
+	indexes := []int{i.agentContainerIndex(pod)}
+	if agentContainer := i.configuration().AgentContainer(); agentContainer.Position == config.AgentContainerPositionNamed {
+		if index := getContainerIndex(pod, agentContainer.Name); index > -1 && !slices.Contains(indexes, index) && !i.isKnativeQueueProxy(pod, index) {
+			indexes = append(indexes, index)
+		}
+	}
+	for _, container := range inst.Spec.Containers {
+		if index := getContainerIndex(pod, container.Name); index > -1 && !slices.Contains(indexes, index) && !i.isKnativeQueueProxy(pod, index) {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// injectContainerEnv is used to add the agent env vars the instrumentation defines for the container, unless the
+// container already sets them
+func injectContainerEnv(container *corev1.Container, inst current.Instrumentation) {
+	index := slices.IndexFunc(inst.Spec.Containers, func(c current.ContainerEnv) bool { return c.Name == container.Name })
+	if index == -1 {
+		return
+	}
+	for _, env := range inst.Spec.Containers[index].Env {
+		if getIndexOfEnv(container.Env, env.Name) == -1 {
+			container.Env = append(container.Env, *env.DeepCopy())
+		}
+	}
+}
+
+// copyAgentInitContainer is the init container used by most agents, copying the agent image's /instrumentation
+// directory into the shared agent volume
+func copyAgentInitContainer(inst current.Instrumentation, initContainerName string) corev1.Container {
+	return corev1.Container{
+		Name:    initContainerName,
+		Image:   inst.Spec.Agent.Image,
+		Command: []string{"cp", "-a", "/instrumentation/.", agentVolumeMountPath + "/"},
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      volumeName,
+			MountPath: agentVolumeMountPath,
+		}},
+	}
+}
@@ -0,0 +1,403 @@
+package apm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+	"github.com/newrelic/k8s-agents-operator/internal/version"
+)
+
+type customLanguageInjector struct{}
+
+func (c *customLanguageInjector) Name() string {
+	return "custom"
+}
+
+func (c *customLanguageInjector) InjectEnv(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int) error {
+	setEnvVar(&pod.Spec.Containers[containerIndex], "CUSTOM_AGENT", "/newrelic-instrumentation/custom", false)
+	return nil
+}
+
+func (c *customLanguageInjector) InjectInitContainer(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int, initContainerName string) error {
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, copyAgentInitContainer(inst, initContainerName))
+	return nil
+}
+
+func TestInjectorRegistery_RegisterLanguage(t *testing.T) {
+	registry := NewInjectorRegistry()
+	require.NoError(t, registry.RegisterLanguage(&customLanguageInjector{}))
+	require.ErrorIs(t, registry.RegisterLanguage(&customLanguageInjector{}), ErrInjectorAlreadyRegistered)
+	assert.Equal(t, []string{"custom"}, registry.GetInjectors().Names())
+}
+
+func TestNewLanguageInjector_Inject(t *testing.T) {
+	vtrue := true
+	tests := []struct {
+		name           string
+		pod            corev1.Pod
+		inst           current.Instrumentation
+		expectedPod    corev1.Pod
+		expectedErrStr string
+	}{
+		{
+			name: "a container, wrong instrumentation (not the correct lang)",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "test"},
+			}}},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "test"},
+			}}},
+			inst: current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "java"}}},
+		},
+		{
+			name: "a container, instrumentation",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "test"},
+			}}},
+			expectedPod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
+					},
+					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
+						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name: "test",
+						Env: []corev1.EnvVar{
+							{Name: "CUSTOM_AGENT", Value: "/newrelic-instrumentation/custom"},
+							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
+							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
+							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
+					}},
+					InitContainers: []corev1.Container{{
+						Name:         "newrelic-instrumentation-custom",
+						Command:      []string{"cp", "-a", "/instrumentation/.", "/newrelic-instrumentation/"},
+						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
+					}},
+					Volumes: []corev1.Volume{{Name: "newrelic-instrumentation", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+				}},
+			inst: current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "custom"}, LicenseKeySecret: "newrelic-key-secret"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			i := NewLanguageInjector(&customLanguageInjector{})
+			require.Equal(t, "custom", i.Language())
+			// inject multiple times to assert that it's idempotent
+			var err error
+			var actualPod corev1.Pod
+			for ic := 0; ic < 3; ic++ {
+				actualPod, err = i.Inject(ctx, test.inst, corev1.Namespace{}, test.pod)
+				if err != nil {
+					break
+				}
+			}
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			require.Equal(t, test.expectedErrStr, errStr)
+			if diff := cmp.Diff(test.expectedPod, actualPod); diff != "" {
+				assert.Fail(t, diff)
+			}
+		})
+	}
+}
+
+func TestNewLanguageInjector_Inject_Reinvocation(t *testing.T) {
+	ctx := context.Background()
+	i := NewLanguageInjector(&customLanguageInjector{})
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "custom"}, LicenseKeySecret: "newrelic-key-secret"}}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}}}}
+
+	injectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, pod)
+	require.NoError(t, err)
+
+	// a mesh injector running after us adds its proxy ahead of the app container, and its own init container
+	expectedPod := *injectedPod.DeepCopy()
+	meshedPod := *injectedPod.DeepCopy()
+	meshedPod.Spec.Containers = append([]corev1.Container{{Name: "istio-proxy"}}, meshedPod.Spec.Containers...)
+	meshedPod.Spec.InitContainers = append(meshedPod.Spec.InitContainers, corev1.Container{Name: "istio-init"})
+	expectedPod.Spec.Containers = append([]corev1.Container{{Name: "istio-proxy"}}, expectedPod.Spec.Containers...)
+	expectedPod.Spec.InitContainers = append(expectedPod.Spec.InitContainers, corev1.Container{Name: "istio-init"})
+
+	for ic := 0; ic < 2; ic++ {
+		meshedPod, err = i.Inject(ctx, inst, corev1.Namespace{}, meshedPod)
+		require.NoError(t, err)
+	}
+	if diff := cmp.Diff(expectedPod, meshedPod); diff != "" {
+		assert.Fail(t, diff)
+	}
+}
+
+func TestNewLanguageInjector_Inject_VolumeCollision(t *testing.T) {
+	ctx := context.Background()
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "custom"}, LicenseKeySecret: "newrelic-key-secret"}}
+	appVolume := corev1.Volume{Name: "newrelic-instrumentation", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"},
+	}}}
+	tests := []struct {
+		name           string
+		cfg            config.Config
+		volumes        []corev1.Volume
+		expectedVolume string
+	}{
+		{name: "no collision", cfg: config.New(), expectedVolume: "newrelic-instrumentation"},
+		{name: "collision", cfg: config.New(), volumes: []corev1.Volume{appVolume}, expectedVolume: "newrelic-instrumentation-2"},
+		{
+			name:           "collisions",
+			cfg:            config.New(),
+			volumes:        []corev1.Volume{appVolume, {Name: "newrelic-instrumentation-2"}},
+			expectedVolume: "newrelic-instrumentation-3",
+		},
+		{name: "configured name", cfg: config.New(config.WithAgentVolumeName("acme-agent")), volumes: []corev1.Volume{appVolume}, expectedVolume: "acme-agent"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := NewLanguageInjector(&customLanguageInjector{}).(*languageInjectorWrapper)
+			i.ConfigureConfig(&test.cfg)
+			pod := corev1.Pod{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "test", VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/etc/app"}}}},
+				Volumes:    test.volumes,
+			}}
+			if len(test.volumes) == 0 {
+				pod.Spec.Containers[0].VolumeMounts = nil
+			}
+
+			injectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, pod)
+			require.NoError(t, err)
+			reinjectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, *injectedPod.DeepCopy())
+			require.NoError(t, err)
+			if diff := cmp.Diff(injectedPod, reinjectedPod); diff != "" {
+				assert.Fail(t, "reinjecting changed the pod", diff)
+			}
+
+			volumeNames := map[string]int{}
+			for _, volume := range injectedPod.Spec.Volumes {
+				volumeNames[volume.Name]++
+			}
+			for name, count := range volumeNames {
+				assert.Equal(t, 1, count, "duplicate volume %q", name)
+			}
+			require.Len(t, injectedPod.Spec.Volumes, len(test.volumes)+1)
+			agentVolume := injectedPod.Spec.Volumes[len(test.volumes)]
+			assert.Equal(t, test.expectedVolume, agentVolume.Name)
+			assert.NotNil(t, agentVolume.EmptyDir)
+			assert.Equal(t, []corev1.VolumeMount{{Name: test.expectedVolume, MountPath: "/newrelic-instrumentation"}}, injectedPod.Spec.InitContainers[0].VolumeMounts)
+			assert.Contains(t, injectedPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: test.expectedVolume, MountPath: "/newrelic-instrumentation"})
+			if len(test.volumes) > 0 {
+				assert.Contains(t, injectedPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "newrelic-instrumentation", MountPath: "/etc/app"}, "the mount of the app volume is kept")
+			}
+		})
+	}
+}
+
+func TestNewLanguageInjector_Inject_MountPropagation(t *testing.T) {
+	hostToContainer := corev1.MountPropagationHostToContainer
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "custom"}, LicenseKeySecret: "newrelic-key-secret"}}
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		expected *corev1.MountPropagationMode
+	}{
+		{name: "default", cfg: config.New()},
+		{name: "configured", cfg: config.New(config.WithAgentMountPropagation("custom", hostToContainer)), expected: &hostToContainer},
+		{name: "configured for another language", cfg: config.New(config.WithAgentMountPropagation("java", hostToContainer))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := NewLanguageInjector(&customLanguageInjector{}).(*languageInjectorWrapper)
+			i.ConfigureConfig(&test.cfg)
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}}}}
+
+			actualPod, err := i.Inject(context.Background(), inst, corev1.Namespace{}, pod)
+			require.NoError(t, err)
+			assert.Equal(t, []corev1.VolumeMount{{Name: volumeName, MountPath: "/newrelic-instrumentation", MountPropagation: test.expected}}, actualPod.Spec.InitContainers[0].VolumeMounts)
+			assert.Contains(t, actualPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: volumeName, MountPath: "/newrelic-instrumentation", MountPropagation: test.expected})
+		})
+	}
+}
+
+func TestNewLanguageInjector_Inject_MountPropagationBidirectional(t *testing.T) {
+	hostToContainer, bidirectional := corev1.MountPropagationHostToContainer, corev1.MountPropagationBidirectional
+	privileged := true
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "custom"}, LicenseKeySecret: "newrelic-key-secret"}}
+	tests := []struct {
+		name            string
+		securityContext *corev1.SecurityContext
+		expected        *corev1.MountPropagationMode
+	}{
+		{name: "unprivileged falls back", expected: &hostToContainer},
+		{name: "privileged", securityContext: &corev1.SecurityContext{Privileged: &privileged}, expected: &bidirectional},
+	}
+	cfg := config.New(config.WithAgentMountPropagation("custom", bidirectional))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := NewLanguageInjector(&customLanguageInjector{}).(*languageInjectorWrapper)
+			i.ConfigureConfig(&cfg)
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test", SecurityContext: test.securityContext}}}}
+
+			actualPod, err := i.Inject(context.Background(), inst, corev1.Namespace{}, pod)
+			require.NoError(t, err)
+			assert.Contains(t, actualPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: volumeName, MountPath: "/newrelic-instrumentation", MountPropagation: test.expected})
+			// the agent init container is never privileged
+			assert.Equal(t, []corev1.VolumeMount{{Name: volumeName, MountPath: "/newrelic-instrumentation", MountPropagation: &hostToContainer}}, actualPod.Spec.InitContainers[0].VolumeMounts)
+		})
+	}
+}
+
+func TestNewLanguageInjector_Inject_NonRoot(t *testing.T) {
+	uid, gid := int64(1000), int64(1000)
+	i := NewLanguageInjector(&customLanguageInjector{})
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "custom"}, LicenseKeySecret: "newrelic-key-secret"}}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "test", SecurityContext: &corev1.SecurityContext{RunAsUser: &uid, RunAsGroup: &gid}},
+	}}}
+
+	actualPod, err := i.Inject(context.Background(), inst, corev1.Namespace{}, pod)
+	require.NoError(t, err)
+	require.Len(t, actualPod.Spec.InitContainers, 1)
+	assert.Equal(t, &corev1.SecurityContext{RunAsUser: &uid, RunAsGroup: &gid}, actualPod.Spec.InitContainers[0].SecurityContext)
+}
+
+func TestNewLanguageInjector_Inject_Containers(t *testing.T) {
+	i := NewLanguageInjector(&customLanguageInjector{})
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{
+		Agent: current.Agent{
+			Language: "custom",
+			Env:      []corev1.EnvVar{{Name: "NEW_RELIC_LOG_LEVEL", Value: "info"}},
+		},
+		LicenseKeySecret: "newrelic-key-secret",
+		Containers: []current.ContainerEnv{
+			{Name: "orders", Env: []corev1.EnvVar{{Name: EnvNewRelicAppName, Value: "orders"}}},
+			{Name: "billing", Env: []corev1.EnvVar{
+				{Name: EnvNewRelicAppName, Value: "billing"},
+				{Name: "NEW_RELIC_LOG_LEVEL", Value: "debug"},
+			}},
+			{Name: "missing", Env: []corev1.EnvVar{{Name: EnvNewRelicAppName, Value: "missing"}}},
+		},
+	}}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "orders"}, {Name: "sidecar"}, {Name: "billing"},
+	}}}
+
+	actualPod, err := i.Inject(context.Background(), inst, corev1.Namespace{}, pod)
+	require.NoError(t, err)
+	require.Len(t, actualPod.Spec.InitContainers, 1)
+
+	env := func(container corev1.Container, name string) string {
+		if index := getIndexOfEnv(container.Env, name); index > -1 {
+			return container.Env[index].Value
+		}
+		return ""
+	}
+	orders, sidecar, billing := actualPod.Spec.Containers[0], actualPod.Spec.Containers[1], actualPod.Spec.Containers[2]
+	assert.Equal(t, "orders", env(orders, EnvNewRelicAppName))
+	assert.Equal(t, "info", env(orders, "NEW_RELIC_LOG_LEVEL"))
+	assert.Equal(t, "billing", env(billing, EnvNewRelicAppName))
+	assert.Equal(t, "debug", env(billing, "NEW_RELIC_LOG_LEVEL"))
+	for _, container := range []corev1.Container{orders, billing} {
+		assert.Equal(t, "/newrelic-instrumentation/custom", env(container, "CUSTOM_AGENT"))
+		assert.NotEqual(t, -1, getIndexOfEnv(container.Env, EnvNewRelicLicenseKey))
+		assert.False(t, isContainerVolumeMissing(&container, volumeName))
+	}
+	assert.Empty(t, sidecar.Env)
+	assert.Empty(t, sidecar.VolumeMounts)
+}
+
+func TestNewLanguageInjector_Inject_StripEnv(t *testing.T) {
+	cfg := config.New(config.WithStripEnvVars([]string{EnvNewRelicAppName}))
+	i := NewLanguageInjector(&customLanguageInjector{})
+	i.ConfigureConfig(&cfg)
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkout"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "app",
+			Env: []corev1.EnvVar{
+				{Name: EnvNewRelicAppName, Value: "stale"},
+				{Name: "NEW_RELIC_LOG_LEVEL", Value: "debug"},
+			},
+		}}},
+	}
+	tests := []struct {
+		name             string
+		stripEnv         []string
+		expectedAppName  string
+		expectedLogLevel string
+	}{
+		{name: "operator default", expectedAppName: "checkout", expectedLogLevel: "debug"},
+		{name: "instrumentation override", stripEnv: []string{"NEW_RELIC_LOG_LEVEL"}, expectedAppName: "stale"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inst := current.Instrumentation{Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "custom", StripEnv: test.stripEnv},
+				LicenseKeySecret: "newrelic-key-secret",
+			}}
+			actualPod, err := i.Inject(context.Background(), inst, corev1.Namespace{}, *pod.DeepCopy())
+			require.NoError(t, err)
+			env := actualPod.Spec.Containers[0].Env
+			appName, _ := getValueFromEnv(env, EnvNewRelicAppName)
+			logLevel, _ := getValueFromEnv(env, "NEW_RELIC_LOG_LEVEL")
+			assert.Equal(t, test.expectedAppName, appName)
+			assert.Equal(t, test.expectedLogLevel, logLevel)
+		})
+	}
+}
+
+func TestNewLanguageInjector_Inject_InstallPath(t *testing.T) {
+	i := NewLanguageInjector(&customLanguageInjector{})
+	tests := []struct {
+		name                string
+		installPath         string
+		workingDir          string
+		expectedMountPath   string
+		expectedCustomAgent string
+		expectedErr         error
+	}{
+		{name: "default", workingDir: "/srv/app", expectedMountPath: "/newrelic-instrumentation", expectedCustomAgent: "/newrelic-instrumentation/custom"},
+		{name: "absolute", installPath: "/opt/newrelic", workingDir: "/srv/app", expectedMountPath: "/opt/newrelic", expectedCustomAgent: "/opt/newrelic/custom"},
+		{name: "relative to the working dir", installPath: "vendor/newrelic", workingDir: "/srv/app", expectedMountPath: "/srv/app/vendor/newrelic", expectedCustomAgent: "/srv/app/vendor/newrelic/custom"},
+		{name: "relative without a working dir", installPath: "vendor/newrelic", expectedErr: ErrAgentInstallPath},
+		{name: "hides the working dir", installPath: "/srv", workingDir: "/srv/app", expectedErr: ErrAgentInstallPath},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inst := current.Instrumentation{Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "custom", InstallPath: test.installPath},
+				LicenseKeySecret: "newrelic-key-secret",
+			}}
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", WorkingDir: test.workingDir}}}}
+			actualPod, err := i.Inject(context.Background(), inst, corev1.Namespace{}, pod)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			container := actualPod.Spec.Containers[0]
+			customAgent, _ := getValueFromEnv(container.Env, "CUSTOM_AGENT")
+			assert.Equal(t, test.expectedCustomAgent, customAgent)
+			assert.Contains(t, container.VolumeMounts, corev1.VolumeMount{Name: volumeName, MountPath: test.expectedMountPath})
+			assert.Equal(t, []string{"cp", "-a", "/instrumentation/.", "/newrelic-instrumentation/"}, actualPod.Spec.InitContainers[0].Command)
+		})
+	}
+}
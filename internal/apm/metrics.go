@@ -0,0 +1,124 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apm
+
+import (
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+	"github.com/newrelic/k8s-agents-operator/internal/version"
+)
+
+// labelsFiltered is the number of pod labels which would have been propagated to the agents, but were dropped because
+// they match the labels filter
+var labelsFiltered = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "operator_labels_filtered_total",
+		Help: "Number of pod labels dropped from the propagation to the agents by the labels filter",
+	},
+)
+
+// buildInfoLabels are the labels of the operator_build_info metric, followed by the <agent>_image label of each agent
+var buildInfoLabels = []string{
+	"version",
+	"build_date",
+	"go_version",
+	"image_repository",
+	"image_channel",
+	"instrumentation_provider",
+	"cluster_name",
+	"autoscaling_version",
+	"knative",
+	"native_sidecars",
+	"openshift_routes",
+	"sleep_action",
+	"vpa",
+}
+
+// buildInfoCollector reports the operator_build_info metric, which is always 1 and whose labels are the operator
+// version, the non-secret configuration, the agent image each language resolves to when its instrumentations have
+// none, and the detected cluster capabilities. The labels are read when the metrics are scraped, so they follow the
+// reloads of the configuration and the auto-detection.
+type buildInfoCollector struct {
+	cfg    *config.Config
+	agents []string
+	desc   *prometheus.Desc
+}
+
+// newBuildInfoCollector is the constructor for the build info of the operator, with an image label for the agent of
+// each of the languages
+func newBuildInfoCollector(cfg *config.Config, languages []string) *buildInfoCollector {
+	agents := make([]string, 0, len(languages))
+	for _, language := range languages {
+		agents = append(agents, agentName(language))
+	}
+	slices.Sort(agents)
+	agents = slices.Compact(agents)
+	labels := slices.Clone(buildInfoLabels)
+	for _, agent := range agents {
+		labels = append(labels, agent+"_image")
+	}
+	return &buildInfoCollector{
+		cfg:    cfg,
+		agents: agents,
+		desc: prometheus.NewDesc(
+			"operator_build_info",
+			"Build and configuration of the operator, with the agent images it resolves and the capabilities it detected",
+			labels,
+			nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *buildInfoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c *buildInfoCollector) Collect(ch chan<- prometheus.Metric) {
+	buildVersion := version.Get()
+	repository, channel := c.cfg.ImageChannel()
+	values := []string{
+		buildVersion.Operator,
+		buildVersion.BuildDate,
+		buildVersion.Go,
+		repository,
+		channel,
+		c.cfg.InstrumentationProvider(),
+		c.cfg.ClusterName(),
+		c.cfg.AutoscalingVersion().String(),
+		c.cfg.Knative().String(),
+		c.cfg.NativeSidecars().String(),
+		c.cfg.OpenShiftRoutes().String(),
+		c.cfg.SleepAction().String(),
+		c.cfg.VPAAvailability().String(),
+	}
+	for _, agent := range c.agents {
+		inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: agent}}}
+		values = append(values, AgentImage(c.cfg, inst))
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, values...)
+}
+
+// RegisterMetrics is used to register the metrics of the package with the metrics registry of the config
+func RegisterMetrics(cfg *config.Config) error {
+	return cfg.RegisterMetrics(labelsFiltered, newBuildInfoCollector(cfg, DefaultInjectorRegistry.GetInjectors().Names()))
+}
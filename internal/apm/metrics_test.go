@@ -0,0 +1,44 @@
+package apm
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestBuildInfoCollector(t *testing.T) {
+	cfg := config.New(
+		config.WithImageRepository("registry.example.com/newrelic"),
+		config.WithImageChannel("stable"),
+		config.WithClusterName("prod-eu"),
+	)
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(newBuildInfoCollector(&cfg, []string{"java", "php-8.1", "php-8.2", "nodejs"})))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "operator_build_info", families[0].GetName())
+	require.Len(t, families[0].GetMetric(), 1)
+	metric := families[0].GetMetric()[0]
+	assert.Equal(t, float64(1), metric.GetGauge().GetValue())
+	labels := map[string]string{}
+	for _, label := range metric.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	assert.Equal(t, runtime.Version(), labels["go_version"])
+	assert.Equal(t, "registry.example.com/newrelic", labels["image_repository"])
+	assert.Equal(t, "stable", labels["image_channel"])
+	assert.Equal(t, "prod-eu", labels["cluster_name"])
+	assert.Equal(t, "NotAvailable", labels["knative"])
+	assert.Equal(t, "NotAvailable", labels["sleep_action"])
+	assert.Equal(t, "registry.example.com/newrelic/java:stable", labels["java_image"])
+	assert.Equal(t, "registry.example.com/newrelic/nodejs:stable", labels["nodejs_image"])
+	assert.Equal(t, "registry.example.com/newrelic/php:stable", labels["php_image"])
+	assert.NotContains(t, labels, "python_image")
+}
@@ -25,12 +25,14 @@ import (
 )
 
 const (
-	envNodeOptions          = "NODE_OPTIONS"
-	nodeRequireArgument     = "--require /newrelic-instrumentation/newrelicinstrumentation.js"
-	nodejsInitContainerName = initContainerName + "-nodejs"
+	envNodeOptions      = "NODE_OPTIONS"
+	nodeRequireArgument = "--require " + agentVolumeMountPath + nodeRequireSuffix
+	// nodeRequireSuffix is the path of the agent bootstrap within the agent volume
+	nodeRequireSuffix = "/newrelicinstrumentation.js"
 )
 
 var _ Injector = (*NodejsInjector)(nil)
+var _ LanguageInjector = (*NodejsInjector)(nil)
 
 func init() {
 	DefaultInjectorRegistry.MustRegister(&NodejsInjector{})
@@ -44,39 +46,20 @@ func (i *NodejsInjector) Language() string {
 	return "nodejs"
 }
 
-func (i *NodejsInjector) acceptable(inst current.Instrumentation, pod corev1.Pod) bool {
-	if inst.Spec.Agent.Language != i.Language() {
-		return false
-	}
-	if len(pod.Spec.Containers) == 0 {
-		return false
-	}
-	return true
+func (i *NodejsInjector) Name() string {
+	return i.Language()
 }
 
 func (i *NodejsInjector) Inject(ctx context.Context, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
-	if !i.acceptable(inst, pod) {
-		return pod, nil
-	}
-	if err := i.validate(inst); err != nil {
-		return pod, err
-	}
+	return i.injectLanguage(ctx, i, inst, ns, pod)
+}
 
-	firstContainer := 0
-	// caller checks if there is at least one container.
-	container := &pod.Spec.Containers[firstContainer]
+func (i *NodejsInjector) InjectEnv(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int) error {
+	container := &pod.Spec.Containers[containerIndex]
 
 	err := validateContainerEnv(container.Env, envNodeOptions)
 	if err != nil {
-		return pod, err
-	}
-
-	// inject NodeJS instrumentation spec env vars.
-	for _, env := range inst.Spec.Agent.Env {
-		idx := getIndexOfEnv(container.Env, env.Name)
-		if idx == -1 {
-			container.Env = append(container.Env, env)
-		}
+		return err
 	}
 
 	idx := getIndexOfEnv(container.Env, envNodeOptions)
@@ -86,46 +69,25 @@ func (i *NodejsInjector) Inject(ctx context.Context, inst current.Instrumentatio
 			Value: nodeRequireArgument,
 		})
 	} else if idx > -1 {
-		if !strings.Contains(" "+container.Env[idx].Value+" ", " "+nodeRequireArgument+" ") {
+		if !hasNodeRequireArgument(container.Env[idx].Value) {
 			container.Env[idx].Value = container.Env[idx].Value + " " + nodeRequireArgument
 		}
 	}
+	return nil
+}
 
-	if isContainerVolumeMissing(container, volumeName) {
-		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-			Name:      volumeName,
-			MountPath: "/newrelic-instrumentation",
-		})
-	}
-
-	// We just inject Volumes and init containers for the first processed container
-	if isInitContainerMissing(pod, nodejsInitContainerName) {
-		if isPodVolumeMissing(pod, volumeName) {
-			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-				Name: volumeName,
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{},
-				}})
+// hasNodeRequireArgument is used to check if NODE_OPTIONS already requires the agent, at any install path
+func hasNodeRequireArgument(nodeOptions string) bool {
+	fields := strings.Fields(nodeOptions)
+	for i := 1; i < len(fields); i++ {
+		if fields[i-1] == "--require" && strings.HasSuffix(fields[i], nodeRequireSuffix) {
+			return true
 		}
-
-		pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{
-			Name:    nodejsInitContainerName,
-			Image:   inst.Spec.Agent.Image,
-			Command: []string{"cp", "-a", "/instrumentation/.", "/newrelic-instrumentation/"},
-			VolumeMounts: []corev1.VolumeMount{{
-				Name:      volumeName,
-				MountPath: "/newrelic-instrumentation",
-			}},
-		})
-	}
-
-	pod = i.injectNewrelicConfig(ctx, ns, pod, firstContainer, inst.Spec.LicenseKeySecret)
-
-	pod = addAnnotationToPodFromInstrumentationVersion(ctx, pod, inst)
-
-	if pod, err = i.injectHealth(ctx, inst, ns, pod, firstContainer, -1); err != nil {
-		return pod, err
 	}
+	return false
+}
 
-	return pod, nil
+func (i *NodejsInjector) InjectInitContainer(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int, initContainerName string) error {
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, copyAgentInitContainer(inst, initContainerName))
+	return nil
 }
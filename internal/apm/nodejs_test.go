@@ -72,6 +72,7 @@ func TestNodejsInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				},
@@ -83,6 +84,9 @@ func TestNodejsInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
@@ -96,6 +100,69 @@ func TestNodejsInjector_Inject(t *testing.T) {
 				}},
 			inst: current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "nodejs"}, LicenseKeySecret: "newrelic-key-secret"}},
 		},
+		{
+			name: "a container with a read-only root filesystem, instrumentation",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "test", SecurityContext: &corev1.SecurityContext{ReadOnlyRootFilesystem: &vtrue}},
+			}}},
+			expectedPod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
+					},
+					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
+						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:            "test",
+						SecurityContext: &corev1.SecurityContext{ReadOnlyRootFilesystem: &vtrue},
+						Env: []corev1.EnvVar{
+							{Name: "NODE_OPTIONS", Value: "--require /newrelic-instrumentation/newrelicinstrumentation.js"},
+							{Name: "NEW_RELIC_LOG", Value: "/newrelic-instrumentation/newrelic_agent.log"},
+							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
+							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
+							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
+					}},
+					InitContainers: []corev1.Container{{
+						Name:         "newrelic-instrumentation-nodejs",
+						Command:      []string{"cp", "-a", "/instrumentation/.", "/newrelic-instrumentation/"},
+						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
+					}},
+					Volumes: []corev1.Volume{{Name: "newrelic-instrumentation", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+				}},
+			inst: current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "nodejs"}, LicenseKeySecret: "newrelic-key-secret"}},
+		},
+		{
+			name: "a container with a read-only root filesystem and a read-only agent path, instrumentation",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{
+					Name:            "test",
+					SecurityContext: &corev1.SecurityContext{ReadOnlyRootFilesystem: &vtrue},
+					VolumeMounts:    []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation", ReadOnly: true}},
+				},
+			}}},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{
+					Name:            "test",
+					SecurityContext: &corev1.SecurityContext{ReadOnlyRootFilesystem: &vtrue},
+					VolumeMounts:    []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation", ReadOnly: true}},
+					Env: []corev1.EnvVar{
+						{Name: "NODE_OPTIONS", Value: "--require /newrelic-instrumentation/newrelicinstrumentation.js"},
+					},
+				},
+			}}},
+			expectedErrStr: `the agent path is not writable: container "test" has a read-only root filesystem and mounts /newrelic-instrumentation read-only`,
+			inst:           current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "nodejs"}, LicenseKeySecret: "newrelic-key-secret"}},
+		},
 		{
 			name: "a container, instrumentation, with existing env NODE_OPTIONS",
 			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
@@ -112,6 +179,7 @@ func TestNodejsInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				},
@@ -123,6 +191,9 @@ func TestNodejsInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
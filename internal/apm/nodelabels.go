@@ -0,0 +1,101 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apm
+
+import (
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// NodeLabelsAnnotation is the pod annotation with the comma separated keys of the node labels the agents of the pod
+	// report, which are copied to the pod annotations once the pod is scheduled
+	NodeLabelsAnnotation = "newrelic.com/node-labels"
+	// nodeLabelAnnotationPrefix is the prefix of the pod annotations holding the values of the node labels
+	nodeLabelAnnotationPrefix = "node-label.newrelic.com/"
+	// nodeLabelEnvPrefix is the prefix of the env vars reading the node labels from the pod annotations
+	nodeLabelEnvPrefix = "NEW_RELIC_NODE_LABEL_"
+)
+
+// NodeLabelAnnotation is used to get the pod annotation holding the value of the node label, such as
+// node-label.newrelic.com/topology.kubernetes.io_region for topology.kubernetes.io/region
+func NodeLabelAnnotation(label string) string {
+	return nodeLabelAnnotationPrefix + strings.ReplaceAll(label, "/", "_")
+}
+
+// nodeLabelEnvName is used to get the env var reading the node label, such as
+// NEW_RELIC_NODE_LABEL_TOPOLOGY_KUBERNETES_IO_REGION for topology.kubernetes.io/region
+func nodeLabelEnvName(label string) string {
+	return nodeLabelEnvPrefix + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, label)
+}
+
+// injectNodeLabelEnv is used to add the env vars reading the allow-listed node labels from the pod annotations, which
+// are only set once the pod is scheduled, as the node isn't known at admission. They're added to the front of the
+// container env, so that the agent labels can refer to them with $(NAME), and the pod is annotated with the labels to
+// copy. It returns the attributes referring to the env vars, by name.
+func (i *baseInjector) injectNodeLabelEnv(pod *corev1.Pod, container *corev1.Container) map[string]string {
+	nodeLabelAttributes := i.configuration().NodeLabelAttributes()
+	if len(nodeLabelAttributes) == 0 {
+		return nil
+	}
+	attributes := map[string]string{}
+	var labels []string
+	var envVars []corev1.EnvVar
+	for _, nodeLabelAttribute := range nodeLabelAttributes {
+		if filter, filtered := i.configuration().LabelFilteredBy(nodeLabelAttribute.Label); filtered {
+			i.logger.V(1).Info("node label dropped by the labels filter", "label", nodeLabelAttribute.Label, "filter", filter)
+			labelsFiltered.Inc()
+			continue
+		}
+		labels = append(labels, nodeLabelAttribute.Label)
+		name := nodeLabelEnvName(nodeLabelAttribute.Label)
+		if getIndexOfEnv(container.Env, name) == -1 {
+			envVars = append(envVars, corev1.EnvVar{
+				Name: name,
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{
+						FieldPath: "metadata.annotations['" + NodeLabelAnnotation(nodeLabelAttribute.Label) + "']",
+					},
+				},
+			})
+		}
+		attribute := nodeLabelAttribute.Attribute
+		if attribute == "" {
+			attribute = nodeLabelAttribute.Label
+		}
+		attributes[attribute] = "$(" + name + ")"
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	container.Env = slices.Insert(container.Env, 0, envVars...)
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[NodeLabelsAnnotation] = strings.Join(labels, ",")
+	return attributes
+}
@@ -0,0 +1,63 @@
+package apm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+	"github.com/newrelic/k8s-agents-operator/internal/version"
+)
+
+func TestNodeLabelNames(t *testing.T) {
+	assert.Equal(t, "node-label.newrelic.com/topology.kubernetes.io_region", NodeLabelAnnotation("topology.kubernetes.io/region"))
+	assert.Empty(t, validation.IsQualifiedName(NodeLabelAnnotation("topology.kubernetes.io/region")))
+	assert.Equal(t, "NEW_RELIC_NODE_LABEL_NODE_KUBERNETES_IO_INSTANCE_TYPE", nodeLabelEnvName("node.kubernetes.io/instance-type"))
+}
+
+func TestBaseInjector_InjectNewrelicEnvConfig_NodeLabelAttributes(t *testing.T) {
+	cfg := config.New(
+		config.WithNodeLabelAttributes([]config.NodeLabelAttribute{
+			{Label: "topology.kubernetes.io/region", Attribute: "cloud.region"},
+			{Label: "node.kubernetes.io/instance-type"},
+			{Label: "tier"},
+		}),
+		config.WithLabelsFilter([]string{"^ti"}),
+	)
+	i := baseInjector{config: &cfg}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "app",
+		Env:  []corev1.EnvVar{{Name: EnvOtelResourceAttributes, Value: "service.namespace=shop"}},
+	}}}}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+
+	env := pod.Spec.Containers[0].Env
+	assert.Equal(t, []corev1.EnvVar{
+		{
+			Name: "NEW_RELIC_NODE_LABEL_TOPOLOGY_KUBERNETES_IO_REGION",
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.annotations['node-label.newrelic.com/topology.kubernetes.io_region']",
+			}},
+		},
+		{
+			Name: "NEW_RELIC_NODE_LABEL_NODE_KUBERNETES_IO_INSTANCE_TYPE",
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.annotations['node-label.newrelic.com/node.kubernetes.io_instance-type']",
+			}},
+		},
+	}, env[:2])
+	assert.Equal(t,
+		"cloud.region:$(NEW_RELIC_NODE_LABEL_TOPOLOGY_KUBERNETES_IO_REGION);node.kubernetes.io/instance-type:$(NEW_RELIC_NODE_LABEL_NODE_KUBERNETES_IO_INSTANCE_TYPE);operator:auto-injection",
+		env[getIndexOfEnv(env, EnvNewRelicLabels)].Value)
+	assert.Equal(t,
+		"cloud.region=$(NEW_RELIC_NODE_LABEL_TOPOLOGY_KUBERNETES_IO_REGION),newrelic.k8s.operator.version="+version.Get().Operator+",node.kubernetes.io/instance-type=$(NEW_RELIC_NODE_LABEL_NODE_KUBERNETES_IO_INSTANCE_TYPE),service.namespace=shop",
+		env[getIndexOfEnv(env, EnvOtelResourceAttributes)].Value)
+	assert.Equal(t, "topology.kubernetes.io/region,node.kubernetes.io/instance-type", pod.Annotations[NodeLabelsAnnotation])
+
+	// injecting again, as for another language, doesn't add the env vars twice
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	assert.Len(t, pod.Spec.Containers[0].Env, len(env))
+}
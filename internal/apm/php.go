@@ -21,17 +21,19 @@ import (
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
 )
 
 const (
-	envIniScanDirKey     = "PHP_INI_SCAN_DIR"
-	envIniScanDirVal     = "/newrelic-instrumentation/php-agent/ini"
-	phpInitContainerName = initContainerName + "-php"
+	envIniScanDirKey = "PHP_INI_SCAN_DIR"
+	envIniScanDirVal = agentVolumeMountPath + "/php-agent/ini"
 )
 
 var _ Injector = (*PhpInjector)(nil)
+var _ LanguageInjector = (*PhpInjector)(nil)
 
 func init() {
 	for _, v := range phpAcceptVersions {
@@ -84,6 +86,10 @@ type PhpInjector struct {
 	acceptVersion
 }
 
+func (i *PhpInjector) Name() string {
+	return i.Language()
+}
+
 // Inject is used to inject the PHP agent.
 func (i *PhpInjector) Inject(ctx context.Context, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
 	if !i.acceptable(inst, pod) {
@@ -93,68 +99,64 @@ func (i *PhpInjector) Inject(ctx context.Context, inst current.Instrumentation,
 		return pod, err
 	}
 
-	firstContainer := 0
+	phpInitContainerName := i.agentInitContainerName("php")
+	agentVolume := i.agentVolumeName(pod)
+	if err := validateInitContainerName(pod, phpInitContainerName, agentVolume); err != nil {
+		return pod, err
+	}
 
-	apiNum, ok := phpApiMap[acceptVersion(i.Language())]
-	if !ok {
-		return pod, errors.New("invalid php version")
+	firstContainer := i.agentContainerIndex(pod)
+	i.stripEnv(&pod.Spec.Containers[firstContainer], inst)
+	if err := injectStartupWrapper(&pod.Spec.Containers[firstContainer], inst.Spec.Agent.StartupWrapper); err != nil {
+		return pod, err
+	}
+	if err := i.InjectEnv(ctx, inst, &pod, firstContainer); err != nil {
+		return pod, err
 	}
+	i.injectAppName(&pod.Spec.Containers[firstContainer], inst, ns, pod)
+	i.injectProfiling(&pod.Spec.Containers[firstContainer], inst)
 
 	// caller checks if there is at least one container.
 	container := &pod.Spec.Containers[firstContainer]
-
-	setEnvVar(container, envIniScanDirKey, envIniScanDirVal, true)
-
-	// inject PHP instrumentation spec env vars.
-	for _, env := range inst.Spec.Agent.Env {
-		if idx := getIndexOfEnv(container.Env, env.Name); idx == -1 {
-			container.Env = append(container.Env, env)
-		}
-	}
-
-	if isContainerVolumeMissing(container, volumeName) {
+	if isContainerVolumeMissing(container, agentVolume) {
 		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-			Name:      volumeName,
-			MountPath: "/newrelic-instrumentation",
+			Name:             agentVolume,
+			MountPath:        agentVolumeMountPath,
+			MountPropagation: i.agentMountPropagation(*container, inst.Spec.Agent.Language),
 		})
 	}
+	if err := injectReadOnlyRootFilesystem(container, inst.Spec.Agent.Language, agentVolumeMountPath, agentVolume); err != nil {
+		return pod, err
+	}
+	i.injectAgentWarmup(container, inst.Spec.Agent.Language)
 
 	pod = i.injectNewrelicEnvConfig(ctx, pod, firstContainer)
 
 	// We just inject Volumes and init containers for the first processed container.
 	if isInitContainerMissing(pod, phpInitContainerName) {
-		if isPodVolumeMissing(pod, volumeName) {
+		if isPodVolumeMissing(pod, agentVolume) {
 			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-				Name: volumeName,
+				Name: agentVolume,
 				VolumeSource: corev1.VolumeSource{
 					EmptyDir: &corev1.EmptyDirVolumeSource{},
 				}})
 		}
 
-		copyOfContainerEnv := make([]corev1.EnvVar, 0, len(container.Env))
-		for _, entry := range container.Env {
-			if strings.HasPrefix(entry.Name, "NEWRELIC_") || strings.HasPrefix(entry.Name, "NEW_RELIC_") {
-				copyOfContainerEnv = append(copyOfContainerEnv, *entry.DeepCopy())
-			}
-		}
-		initContainer := corev1.Container{
-			Name:    phpInitContainerName,
-			Image:   inst.Spec.Agent.Image,
-			Command: []string{"/bin/sh"},
-			Args: []string{
-				"-c", "cp -a /instrumentation/. /newrelic-instrumentation/ && /newrelic-instrumentation/k8s-php-install.sh " + apiNum + " && /newrelic-instrumentation/nr_env_to_ini.sh",
-			},
-			Env: copyOfContainerEnv,
-			VolumeMounts: []corev1.VolumeMount{{
-				Name:      volumeName,
-				MountPath: "/newrelic-instrumentation",
-			}},
+		if err := i.InjectInitContainer(ctx, inst, &pod, firstContainer, phpInitContainerName); err != nil {
+			return pod, err
 		}
-		initContainer = i.injectNewrelicLicenseKeyIntoContainer(initContainer, inst.Spec.LicenseKeySecret)
-		pod.Spec.InitContainers = append(pod.Spec.InitContainers, initContainer)
+		i.useAgentVolume(&pod, phpInitContainerName, agentVolume, inst.Spec.Agent.Language)
+		injectInitContainerCommand(&pod, inst, phpInitContainerName)
+		injectInstallerImage(&pod, inst, phpInitContainerName)
+		i.injectInitContainerRunAs(&pod, firstContainer, phpInitContainerName)
+		injectPodSecurity(&pod, ns, phpInitContainerName)
+		i.positionInitContainer(&pod, phpInitContainerName)
 	}
 
 	pod = addAnnotationToPodFromInstrumentationVersion(ctx, pod, inst)
+	if _, err := StampAgentImage(&pod, types.NamespacedName{Namespace: inst.Namespace, Name: inst.Name}, phpInitContainerName); err != nil {
+		log.FromContext(ctx).Error(err, "failed to stamp the agent image, skipping it")
+	}
 
 	var err error
 	if pod, err = i.injectHealth(ctx, inst, ns, pod, -1, getInitContainerIndex(pod, phpInitContainerName)); err != nil {
@@ -163,3 +165,49 @@ func (i *PhpInjector) Inject(ctx context.Context, inst current.Instrumentation,
 
 	return pod, nil
 }
+
+// InjectEnv sets the ini scan dir read by the php agent, and the agent env vars. Unlike the other
+// agents, the php agent is configured by the ini files written by the init container.
+func (i *PhpInjector) InjectEnv(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int) error {
+	container := &pod.Spec.Containers[containerIndex]
+	setEnvVar(container, envIniScanDirKey, envIniScanDirVal, true)
+
+	// inject the PHP agent env vars of the pod annotation, the instrumentation spec and the operator defaults.
+	i.injectAgentEnv(container, inst, *pod)
+	i.injectRegion(container, inst)
+	injectReportOnly(container, inst)
+	return nil
+}
+
+// InjectInitContainer adds the init container which installs the agent for the php api version, and writes the
+// ini files from the new relic env vars of the container at containerIndex.
+func (i *PhpInjector) InjectInitContainer(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int, initContainerName string) error {
+	apiNum, ok := phpApiMap[acceptVersion(i.Language())]
+	if !ok {
+		return errors.New("invalid php version")
+	}
+
+	container := pod.Spec.Containers[containerIndex]
+	copyOfContainerEnv := make([]corev1.EnvVar, 0, len(container.Env))
+	for _, entry := range container.Env {
+		if strings.HasPrefix(entry.Name, "NEWRELIC_") || strings.HasPrefix(entry.Name, "NEW_RELIC_") {
+			copyOfContainerEnv = append(copyOfContainerEnv, *entry.DeepCopy())
+		}
+	}
+	initContainer := corev1.Container{
+		Name:    initContainerName,
+		Image:   inst.Spec.Agent.Image,
+		Command: []string{"/bin/sh"},
+		Args: []string{
+			"-c", "cp -a /instrumentation/. /newrelic-instrumentation/ && /newrelic-instrumentation/k8s-php-install.sh " + apiNum + " && /newrelic-instrumentation/nr_env_to_ini.sh",
+		},
+		Env: copyOfContainerEnv,
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      volumeName,
+			MountPath: agentVolumeMountPath,
+		}},
+	}
+	initContainer = i.injectNewrelicLicenseKeyIntoContainer(initContainer, inst.Spec.LicenseKeySecret)
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, initContainer)
+	return nil
+}
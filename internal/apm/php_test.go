@@ -11,6 +11,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 	"github.com/newrelic/k8s-agents-operator/internal/version"
 )
 
@@ -78,6 +79,7 @@ func TestPhpInjector_Inject(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Annotations: map[string]string{
 						"instrumentation.newrelic.com/php-version": "8.3",
+						"newrelic.com/agent-images":                `{"/":""}`,
 						"newrelic.com/instrumentation-versions":    `{"/":"/0"}`,
 					},
 					Labels: map[string]string{
@@ -92,6 +94,9 @@ func TestPhpInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
 					}},
@@ -101,6 +106,9 @@ func TestPhpInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						Command:      []string{"/bin/sh"},
@@ -130,6 +138,7 @@ func TestPhpInjector_Inject(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Annotations: map[string]string{
 						"instrumentation.newrelic.com/php-version": "8.3",
+						"newrelic.com/agent-images":                `{"/":""}`,
 						"newrelic.com/instrumentation-versions":    `{"/":"/0"}`,
 					},
 					Labels: map[string]string{
@@ -144,6 +153,9 @@ func TestPhpInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
 					}},
@@ -153,6 +165,9 @@ func TestPhpInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						Command:      []string{"/bin/sh"},
@@ -189,3 +204,61 @@ func TestPhpInjector_Inject(t *testing.T) {
 		})
 	}
 }
+
+func TestPhpInjector_Inject_Reinvocation(t *testing.T) {
+	ctx := context.Background()
+	i := &PhpInjector{acceptVersion: acceptVersion("php-8.3")}
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "php-8.3"}, LicenseKeySecret: "newrelic-key-secret"}}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}}}}
+
+	injectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, pod)
+	require.NoError(t, err)
+
+	// a mesh injector running after us adds its proxy ahead of the app container
+	expectedPod := *injectedPod.DeepCopy()
+	expectedPod.Spec.Containers = append([]corev1.Container{{Name: "istio-proxy"}}, expectedPod.Spec.Containers...)
+	meshedPod := *expectedPod.DeepCopy()
+
+	meshedPod, err = i.Inject(ctx, inst, corev1.Namespace{}, meshedPod)
+	require.NoError(t, err)
+	if diff := cmp.Diff(expectedPod, meshedPod); diff != "" {
+		assert.Fail(t, diff)
+	}
+}
+
+func TestPhpInjector_Inject_VolumeCollision(t *testing.T) {
+	ctx := context.Background()
+	i := &PhpInjector{acceptVersion: acceptVersion("php-8.3")}
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "php-8.3"}, LicenseKeySecret: "newrelic-key-secret"}}
+	pod := corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "test", VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/etc/app"}}}},
+		Volumes:    []corev1.Volume{{Name: "newrelic-instrumentation"}},
+	}}
+
+	injectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, pod)
+	require.NoError(t, err)
+	require.Len(t, injectedPod.Spec.Volumes, 2)
+	assert.Equal(t, "newrelic-instrumentation-2", injectedPod.Spec.Volumes[1].Name)
+	assert.Equal(t, "newrelic-instrumentation-2", injectedPod.Spec.InitContainers[0].VolumeMounts[0].Name)
+	assert.Contains(t, injectedPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "newrelic-instrumentation-2", MountPath: "/newrelic-instrumentation"})
+
+	reinjectedPod, err := i.Inject(ctx, inst, corev1.Namespace{}, *injectedPod.DeepCopy())
+	require.NoError(t, err)
+	if diff := cmp.Diff(injectedPod, reinjectedPod); diff != "" {
+		assert.Fail(t, "reinjecting changed the pod", diff)
+	}
+}
+
+func TestPhpInjector_Inject_MountPropagation(t *testing.T) {
+	hostToContainer := corev1.MountPropagationHostToContainer
+	cfg := config.New(config.WithAgentMountPropagation("php-8.3", hostToContainer))
+	i := &PhpInjector{acceptVersion: acceptVersion("php-8.3")}
+	i.ConfigureConfig(&cfg)
+	inst := current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "php-8.3"}, LicenseKeySecret: "newrelic-key-secret"}}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}}}}
+
+	injectedPod, err := i.Inject(context.Background(), inst, corev1.Namespace{}, pod)
+	require.NoError(t, err)
+	assert.Equal(t, &hostToContainer, injectedPod.Spec.InitContainers[0].VolumeMounts[0].MountPropagation)
+	assert.Contains(t, injectedPod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation", MountPropagation: &hostToContainer})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apm
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+// PodSecurityEnforceLabel is the namespace label with the Pod Security Admission level enforced on the pods of the
+// namespace
+const PodSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// The Pod Security Admission levels, from the least to the most restrictive
+const (
+	PodSecurityLevelPrivileged = "privileged"
+	PodSecurityLevelBaseline   = "baseline"
+	PodSecurityLevelRestricted = "restricted"
+)
+
+// podSecurityLevel is used to get the Pod Security Admission level enforced on the namespace, empty when the namespace
+// doesn't label one, or labels an unknown one
+func podSecurityLevel(ns corev1.Namespace) string {
+	switch level := ns.Labels[PodSecurityEnforceLabel]; level {
+	case PodSecurityLevelPrivileged, PodSecurityLevelBaseline, PodSecurityLevelRestricted:
+		return level
+	}
+	return ""
+}
+
+// injectPodSecurity hardens the security context of the injected init container for the Pod Security Admission level
+// of the namespace, so that it's neither rejected by the admission nor more constrained than it needs to be. Privileged
+// and baseline namespaces only get privilege escalation disabled, restricted namespaces also get all the capabilities
+// dropped, a non-root user required and the runtime default seccomp profile, unless the pod already sets them. The
+// settings the init container already has are kept, and namespaces without a level are left alone.
+func injectPodSecurity(pod *corev1.Pod, ns corev1.Namespace, initContainerName string) {
+	level := podSecurityLevel(ns)
+	if level == "" {
+		return
+	}
+	initContainerIndex := getInitContainerIndex(*pod, initContainerName)
+	if initContainerIndex == -1 {
+		return
+	}
+	initContainer := &pod.Spec.InitContainers[initContainerIndex]
+	if initContainer.SecurityContext == nil {
+		initContainer.SecurityContext = &corev1.SecurityContext{}
+	}
+	sc := initContainer.SecurityContext
+	if sc.AllowPrivilegeEscalation == nil {
+		sc.AllowPrivilegeEscalation = ptr.To(false)
+	}
+	if level != PodSecurityLevelRestricted {
+		return
+	}
+
+	if sc.Capabilities == nil {
+		sc.Capabilities = &corev1.Capabilities{}
+	}
+	if len(sc.Capabilities.Drop) == 0 {
+		sc.Capabilities.Drop = []corev1.Capability{"ALL"}
+	}
+	psc := pod.Spec.SecurityContext
+	if sc.RunAsNonRoot == nil && (psc == nil || psc.RunAsNonRoot == nil) {
+		sc.RunAsNonRoot = ptr.To(true)
+	}
+	if sc.SeccompProfile == nil && (psc == nil || psc.SeccompProfile == nil) {
+		sc.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+}
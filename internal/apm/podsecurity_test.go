@@ -0,0 +1,71 @@
+package apm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestInjectPodSecurity(t *testing.T) {
+	restricted := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: ptr.To(false),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		RunAsNonRoot:             ptr.To(true),
+		SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	tests := []struct {
+		name       string
+		level      string
+		podSC      *corev1.PodSecurityContext
+		sc         *corev1.SecurityContext
+		expectedSC *corev1.SecurityContext
+	}{
+		{name: "no level"},
+		{name: "unknown level", level: "strict"},
+		{name: "privileged", level: PodSecurityLevelPrivileged, expectedSC: &corev1.SecurityContext{AllowPrivilegeEscalation: ptr.To(false)}},
+		{name: "baseline", level: PodSecurityLevelBaseline, expectedSC: &corev1.SecurityContext{AllowPrivilegeEscalation: ptr.To(false)}},
+		{name: "restricted", level: PodSecurityLevelRestricted, expectedSC: restricted},
+		{
+			name:  "restricted, pod sets the user and seccomp profile",
+			level: PodSecurityLevelRestricted,
+			podSC: &corev1.PodSecurityContext{
+				RunAsNonRoot:   ptr.To(true),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: ptr.To("app.json")},
+			},
+			expectedSC: &corev1.SecurityContext{
+				AllowPrivilegeEscalation: ptr.To(false),
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+			},
+		},
+		{
+			name:  "restricted, init container settings are kept",
+			level: PodSecurityLevelRestricted,
+			sc:    &corev1.SecurityContext{RunAsUser: ptr.To(int64(1000)), Capabilities: &corev1.Capabilities{Drop: []corev1.Capability{"NET_RAW"}}},
+			expectedSC: &corev1.SecurityContext{
+				AllowPrivilegeEscalation: ptr.To(false),
+				Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"NET_RAW"}},
+				RunAsUser:                ptr.To(int64(1000)),
+				RunAsNonRoot:             ptr.To(true),
+				SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}}
+			if test.level != "" {
+				ns.Labels = map[string]string{PodSecurityEnforceLabel: test.level}
+			}
+			pod := corev1.Pod{Spec: corev1.PodSpec{
+				SecurityContext: test.podSC,
+				InitContainers:  []corev1.Container{{Name: "istio-init"}, {Name: "nri-java", SecurityContext: test.sc}},
+			}}
+			injectPodSecurity(&pod, ns, "nri-java")
+			assert.Equal(t, test.expectedSC, pod.Spec.InitContainers[1].SecurityContext)
+			assert.Nil(t, pod.Spec.InitContainers[0].SecurityContext)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apm
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	EnvHTTPProxy  = "HTTP_PROXY"
+	EnvHTTPSProxy = "HTTPS_PROXY"
+	EnvNoProxy    = "NO_PROXY"
+
+	EnvNewRelicProxyURL    = "NEW_RELIC_PROXY_URL"
+	EnvNewRelicProxyScheme = "NEW_RELIC_PROXY_SCHEME"
+	EnvNewRelicProxyHost   = "NEW_RELIC_PROXY_HOST"
+	EnvNewRelicProxyPort   = "NEW_RELIC_PROXY_PORT"
+	EnvNewRelicProxyUser   = "NEW_RELIC_PROXY_USER"
+	EnvNewRelicProxyPass   = "NEW_RELIC_PROXY_PASS"
+)
+
+// defaultClusterDomain is the DNS domain of the cluster when the proxy of the agents doesn't set one
+const defaultClusterDomain = "cluster.local"
+
+// proxyEnvNames are the env vars setting a proxy, in both cases as the http clients of the languages differ in which
+// one they read
+var proxyEnvNames = []string{EnvHTTPProxy, EnvHTTPSProxy, strings.ToLower(EnvHTTPProxy), strings.ToLower(EnvHTTPSProxy)}
+
+// newRelicProxyEnvNames are the env vars setting the proxy of the agents only, read as the url by the nodejs agent
+// and as its parts by the others
+var newRelicProxyEnvNames = []string{
+	EnvNewRelicProxyURL, EnvNewRelicProxyScheme, EnvNewRelicProxyHost, EnvNewRelicProxyPort, EnvNewRelicProxyUser, EnvNewRelicProxyPass,
+}
+
+// injectAgentProxy sets the proxy of the agents on the container, unless it already sets one, and excludes the
+// in-cluster traffic from the proxy of the containers using one, so that the agents reach an in-cluster collector
+// directly. The proxy of the agents is set with their own NEW_RELIC_PROXY_* settings, leaving the traffic of the app
+// alone. The exclusions are only added to the containers setting HTTP_PROXY or HTTPS_PROXY themselves, merged into both
+// NO_PROXY and no_proxy, after the entries the container already has. Values from a source are left alone as they
+// can't be merged.
+func (i *baseInjector) injectAgentProxy(container *corev1.Container) {
+	proxy := i.configuration().AgentProxy()
+	injectNewRelicProxy(container, proxy.URL)
+	if !slices.ContainsFunc(proxyEnvNames, func(name string) bool { return getIndexOfEnv(container.Env, name) != -1 }) {
+		return
+	}
+
+	noProxyEnvNames := []string{EnvNoProxy, strings.ToLower(EnvNoProxy)}
+	var entries []string
+	for _, name := range noProxyEnvNames {
+		idx := getIndexOfEnv(container.Env, name)
+		if idx == -1 {
+			continue
+		}
+		if container.Env[idx].ValueFrom != nil {
+			return
+		}
+		entries = appendNoProxyEntries(entries, strings.Split(container.Env[idx].Value, ","))
+	}
+	clusterDomain := proxy.ClusterDomain
+	if clusterDomain == "" {
+		clusterDomain = defaultClusterDomain
+	}
+	entries = appendNoProxyEntries(entries, []string{"localhost", "127.0.0.1", ".svc", "." + strings.TrimPrefix(clusterDomain, ".")})
+	entries = appendNoProxyEntries(entries, proxy.ClusterCIDRs)
+	entries = appendNoProxyEntries(entries, proxy.NoProxy)
+
+	noProxy := strings.Join(entries, ",")
+	for _, name := range noProxyEnvNames {
+		if idx := getIndexOfEnv(container.Env, name); idx != -1 {
+			container.Env[idx].Value = noProxy
+			continue
+		}
+		container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: noProxy})
+	}
+}
+
+// injectNewRelicProxy is used to set the proxy of the agents to the url, unless it's empty or the container already
+// sets any of the agent proxy settings. The port defaults to the one of the scheme.
+func injectNewRelicProxy(container *corev1.Container, proxyURL string) {
+	if proxyURL == "" || slices.ContainsFunc(newRelicProxyEnvNames, func(name string) bool { return getIndexOfEnv(container.Env, name) != -1 }) {
+		return
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Hostname() == "" {
+		return
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	setEnvVar(container, EnvNewRelicProxyURL, proxyURL, false)
+	setEnvVar(container, EnvNewRelicProxyScheme, u.Scheme, false)
+	setEnvVar(container, EnvNewRelicProxyHost, u.Hostname(), false)
+	setEnvVar(container, EnvNewRelicProxyPort, port, false)
+	if u.User != nil {
+		setEnvVar(container, EnvNewRelicProxyUser, u.User.Username(), false)
+		if password, ok := u.User.Password(); ok {
+			setEnvVar(container, EnvNewRelicProxyPass, password, false)
+		}
+	}
+}
+
+// appendNoProxyEntries is used to add the no proxy entries missing from the list, ignoring the empty ones
+func appendNoProxyEntries(entries []string, add []string) []string {
+	for _, entry := range add {
+		entry = strings.TrimSpace(entry)
+		if entry != "" && !slices.Contains(entries, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -26,12 +26,12 @@ import (
 )
 
 const (
-	envPythonPath           = "PYTHONPATH"
-	pythonPathPrefix        = "/newrelic-instrumentation"
-	pythonInitContainerName = initContainerName + "-python"
+	envPythonPath    = "PYTHONPATH"
+	pythonPathPrefix = agentVolumeMountPath
 )
 
 var _ Injector = (*PythonInjector)(nil)
+var _ LanguageInjector = (*PythonInjector)(nil)
 
 func init() {
 	DefaultInjectorRegistry.MustRegister(&PythonInjector{})
@@ -45,39 +45,20 @@ func (i *PythonInjector) Language() string {
 	return "python"
 }
 
-func (i *PythonInjector) acceptable(inst current.Instrumentation, pod corev1.Pod) bool {
-	if inst.Spec.Agent.Language != i.Language() {
-		return false
-	}
-	if len(pod.Spec.Containers) == 0 {
-		return false
-	}
-	return true
+func (i *PythonInjector) Name() string {
+	return i.Language()
 }
 
 func (i *PythonInjector) Inject(ctx context.Context, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
-	if !i.acceptable(inst, pod) {
-		return pod, nil
-	}
-	if err := i.validate(inst); err != nil {
-		return pod, err
-	}
+	return i.injectLanguage(ctx, i, inst, ns, pod)
+}
 
-	firstContainer := 0
-	// caller checks if there is at least one container.
-	container := &pod.Spec.Containers[firstContainer]
+func (i *PythonInjector) InjectEnv(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int) error {
+	container := &pod.Spec.Containers[containerIndex]
 
 	err := validateContainerEnv(container.Env, envPythonPath)
 	if err != nil {
-		return pod, err
-	}
-
-	// inject Python instrumentation spec env vars.
-	for _, env := range inst.Spec.Agent.Env {
-		idx := getIndexOfEnv(container.Env, env.Name)
-		if idx == -1 {
-			container.Env = append(container.Env, env)
-		}
+		return err
 	}
 
 	idx := getIndexOfEnv(container.Env, envPythonPath)
@@ -87,46 +68,17 @@ func (i *PythonInjector) Inject(ctx context.Context, inst current.Instrumentatio
 			Value: pythonPathPrefix,
 		})
 	} else if idx > -1 {
-		if !strings.Contains(":"+container.Env[idx].Value+":", ":"+pythonPathPrefix+":") {
+		// the prefix may have been relocated to the install path by an earlier injection
+		installPath, _ := agentInstallPath(*container, inst)
+		pythonPath := ":" + container.Env[idx].Value + ":"
+		if !strings.Contains(pythonPath, ":"+pythonPathPrefix+":") && !strings.Contains(pythonPath, ":"+installPath+":") {
 			container.Env[idx].Value = fmt.Sprintf("%s:%s", pythonPathPrefix, container.Env[idx].Value)
 		}
 	}
+	return nil
+}
 
-	if isContainerVolumeMissing(container, volumeName) {
-		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-			Name:      volumeName,
-			MountPath: "/newrelic-instrumentation",
-		})
-	}
-
-	// We just inject Volumes and init containers for the first processed container.
-	if isInitContainerMissing(pod, pythonInitContainerName) {
-		if isPodVolumeMissing(pod, volumeName) {
-			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-				Name: volumeName,
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{},
-				}})
-		}
-
-		pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{
-			Name:    pythonInitContainerName,
-			Image:   inst.Spec.Agent.Image,
-			Command: []string{"cp", "-a", "/instrumentation/.", "/newrelic-instrumentation/"},
-			VolumeMounts: []corev1.VolumeMount{{
-				Name:      volumeName,
-				MountPath: "/newrelic-instrumentation",
-			}},
-		})
-	}
-
-	pod = i.injectNewrelicConfig(ctx, ns, pod, firstContainer, inst.Spec.LicenseKeySecret)
-
-	pod = addAnnotationToPodFromInstrumentationVersion(ctx, pod, inst)
-
-	if pod, err = i.injectHealth(ctx, inst, ns, pod, firstContainer, -1); err != nil {
-		return pod, err
-	}
-
-	return pod, nil
+func (i *PythonInjector) InjectInitContainer(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int, initContainerName string) error {
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, copyAgentInitContainer(inst, initContainerName))
+	return nil
 }
@@ -17,6 +17,9 @@ package apm
 
 import (
 	"context"
+	"fmt"
+	"path"
+	"slices"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -25,12 +28,18 @@ import (
 )
 
 const (
-	envRubyOpt            = "RUBYOPT"
-	rubyOptRequire        = "-r /newrelic-instrumentation/lib/boot/strap"
-	rubyInitContainerName = initContainerName + "-ruby"
+	envRubyOpt     = "RUBYOPT"
+	rubyOptRequire = "-r " + agentVolumeMountPath + rubyOptRequireSuffix
+	// rubyOptRequireSuffix is the path of the agent bootstrap within the agent volume
+	rubyOptRequireSuffix = "/lib/boot/strap"
+
+	// rubyOptBundlerSetup is the flag `bundle exec` (and images with bundler baked into RUBYOPT) use to lock the
+	// load path down to the gems in the Gemfile.
+	rubyOptBundlerSetup = "-rbundler/setup"
 )
 
 var _ Injector = (*RubyInjector)(nil)
+var _ LanguageInjector = (*RubyInjector)(nil)
 
 func init() {
 	DefaultInjectorRegistry.MustRegister(&RubyInjector{})
@@ -44,39 +53,23 @@ func (i *RubyInjector) Language() string {
 	return "ruby"
 }
 
-func (i *RubyInjector) acceptable(inst current.Instrumentation, pod corev1.Pod) bool {
-	if inst.Spec.Agent.Language != i.Language() {
-		return false
-	}
-	if len(pod.Spec.Containers) == 0 {
-		return false
-	}
-	return true
+func (i *RubyInjector) Name() string {
+	return i.Language()
 }
 
 func (i *RubyInjector) Inject(ctx context.Context, inst current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
-	if !i.acceptable(inst, pod) {
-		return pod, nil
-	}
-	if err := i.validate(inst); err != nil {
-		return pod, err
-	}
+	return i.injectLanguage(ctx, i, inst, ns, pod)
+}
 
-	firstContainer := 0
-	// caller checks if there is at least one container.
-	container := &pod.Spec.Containers[firstContainer]
+func (i *RubyInjector) InjectEnv(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int) error {
+	container := &pod.Spec.Containers[containerIndex]
 
 	err := validateContainerEnv(container.Env, envRubyOpt)
 	if err != nil {
-		return pod, err
+		return err
 	}
-
-	// inject Ruby instrumentation spec env vars.
-	for _, env := range inst.Spec.Agent.Env {
-		idx := getIndexOfEnv(container.Env, env.Name)
-		if idx == -1 {
-			container.Env = append(container.Env, env)
-		}
+	if arg := rubyOptDisabledArg(container); arg != "" {
+		return fmt.Errorf("the ruby command of container %q ignores %s with %s, so the agent can't be loaded", container.Name, envRubyOpt, arg)
 	}
 
 	idx := getIndexOfEnv(container.Env, envRubyOpt)
@@ -86,46 +79,64 @@ func (i *RubyInjector) Inject(ctx context.Context, inst current.Instrumentation,
 			Value: rubyOptRequire,
 		})
 	} else if idx > -1 {
-		if !strings.Contains(" "+container.Env[idx].Value+" ", " "+rubyOptRequire+" ") {
-			container.Env[idx].Value = container.Env[idx].Value + " " + rubyOptRequire
-		}
+		container.Env[idx].Value = addRubyOptRequire(container.Env[idx].Value)
 	}
+	return nil
+}
 
-	if isContainerVolumeMissing(container, volumeName) {
-		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-			Name:      volumeName,
-			MountPath: "/newrelic-instrumentation",
-		})
-	}
+func (i *RubyInjector) InjectInitContainer(ctx context.Context, inst current.Instrumentation, pod *corev1.Pod, containerIndex int, initContainerName string) error {
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, copyAgentInitContainer(inst, initContainerName))
+	return nil
+}
 
-	// We just inject Volumes and init containers for the first processed container.
-	if isInitContainerMissing(pod, rubyInitContainerName) {
-		if isPodVolumeMissing(pod, volumeName) {
-			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-				Name: volumeName,
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{},
-				}})
+// addRubyOptRequire adds the agent bootstrap to an existing RUBYOPT. The bootstrap is required by an absolute path, so
+// it loads even when bundler has restricted the load path to the Gemfile, and it patches bundler so the agent gem is
+// kept on the load path when the app (or Rails) calls Bundler.setup/Bundler.require itself. When bundler/setup is
+// already part of RUBYOPT we place the bootstrap ahead of it, so the agent is loaded before bundler locks things down.
+func addRubyOptRequire(rubyOpt string) string {
+	fields := strings.Fields(rubyOpt)
+	for i := 1; i < len(fields); i++ {
+		if fields[i-1] == "-r" && strings.HasSuffix(fields[i], rubyOptRequireSuffix) {
+			return rubyOpt
 		}
-
-		pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{
-			Name:    rubyInitContainerName,
-			Image:   inst.Spec.Agent.Image,
-			Command: []string{"cp", "-a", "/instrumentation/.", "/newrelic-instrumentation/"},
-			VolumeMounts: []corev1.VolumeMount{{
-				Name:      volumeName,
-				MountPath: "/newrelic-instrumentation",
-			}},
-		})
 	}
-
-	pod = i.injectNewrelicConfig(ctx, ns, pod, firstContainer, inst.Spec.LicenseKeySecret)
-
-	pod = addAnnotationToPodFromInstrumentationVersion(ctx, pod, inst)
-
-	if pod, err = i.injectHealth(ctx, inst, ns, pod, firstContainer, -1); err != nil {
-		return pod, err
+	for i, field := range fields {
+		if field == rubyOptBundlerSetup || (field == "-r" && i+1 < len(fields) && fields[i+1] == "bundler/setup") {
+			return strings.Join(append(append(fields[:i:i], rubyOptRequire), fields[i:]...), " ")
+		}
 	}
+	return rubyOpt + " " + rubyOptRequire
+}
 
-	return pod, nil
+// rubyOptDisabledArg returns the flag of the ruby command of the container which makes ruby ignore RUBYOPT, such as
+// --disable=rubyopt, looking past `bundle exec`. Only the flags ahead of the script are considered. It's empty when the
+// container doesn't run ruby directly or when ruby reads RUBYOPT, which is the case for Rails servers, rake tasks and
+// scripts, run with `bundle exec` or not, as each ruby process they start loads the bootstrap.
+//
+// No BUNDLE_* env var is set, the bootstrap keeps the agent on the load path itself, and setting one would change how
+// the app resolves the gems of its Gemfile, such as for frozen deployments.
+func rubyOptDisabledArg(container *corev1.Container) string {
+	args := slices.Concat(container.Command, container.Args)
+	if len(args) > 2 && path.Base(args[0]) == "bundle" && args[1] == "exec" {
+		args = args[2:]
+	}
+	if len(args) == 0 || path.Base(args[0]) != "ruby" {
+		return ""
+	}
+	for _, arg := range args[1:] {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		if arg == "--disable-rubyopt" || arg == "--disable-all" {
+			return arg
+		}
+		if features, ok := strings.CutPrefix(arg, "--disable="); ok {
+			for _, feature := range strings.Split(features, ",") {
+				if feature == "rubyopt" || feature == "all" {
+					return arg
+				}
+			}
+		}
+	}
+	return ""
 }
@@ -72,6 +72,7 @@ func TestRubyInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				}, Spec: corev1.PodSpec{
@@ -82,6 +83,9 @@ func TestRubyInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
@@ -114,6 +118,7 @@ func TestRubyInjector_Inject(t *testing.T) {
 						DescK8sAgentOperatorVersionLabelName: version.Get().Operator,
 					},
 					Annotations: map[string]string{
+						"newrelic.com/agent-images":             `{"/":""}`,
 						"newrelic.com/instrumentation-versions": `{"/":"/0"}`,
 					},
 				}, Spec: corev1.PodSpec{
@@ -124,6 +129,9 @@ func TestRubyInjector_Inject(t *testing.T) {
 							{Name: "NEW_RELIC_APP_NAME", Value: "test"},
 							{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 							{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+							{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+							{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+							{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}, Key: "new_relic_license_key", Optional: &vtrue}}},
 						},
 						VolumeMounts: []corev1.VolumeMount{{Name: "newrelic-instrumentation", MountPath: "/newrelic-instrumentation"}},
@@ -137,6 +145,17 @@ func TestRubyInjector_Inject(t *testing.T) {
 				}},
 			inst: current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "ruby"}, LicenseKeySecret: "newrelic-key-secret"}},
 		},
+		{
+			name: "a container ignoring RUBYOPT, instrumentation",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "test", Command: []string{"ruby", "--disable-rubyopt", "script.rb"}},
+			}}},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "test", Command: []string{"ruby", "--disable-rubyopt", "script.rb"}},
+			}}},
+			expectedErrStr: `the ruby command of container "test" ignores RUBYOPT with --disable-rubyopt, so the agent can't be loaded`,
+			inst:           current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "ruby"}, LicenseKeySecret: "newrelic-key-secret"}},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -162,3 +181,82 @@ func TestRubyInjector_Inject(t *testing.T) {
 		})
 	}
 }
+
+func TestAddRubyOptRequire(t *testing.T) {
+	tests := []struct {
+		name     string
+		rubyOpt  string
+		expected string
+	}{
+		{
+			name:     "bare ruby script with other flags",
+			rubyOpt:  "-W0",
+			expected: "-W0 -r /newrelic-instrumentation/lib/boot/strap",
+		},
+		{
+			name:     "rails app with bundler setup",
+			rubyOpt:  "-rbundler/setup",
+			expected: "-r /newrelic-instrumentation/lib/boot/strap -rbundler/setup",
+		},
+		{
+			name:     "rails app with spaced bundler setup",
+			rubyOpt:  "-W0 -r bundler/setup",
+			expected: "-W0 -r /newrelic-instrumentation/lib/boot/strap -r bundler/setup",
+		},
+		{
+			name:     "already injected",
+			rubyOpt:  "-r /newrelic-instrumentation/lib/boot/strap -rbundler/setup",
+			expected: "-r /newrelic-instrumentation/lib/boot/strap -rbundler/setup",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, addRubyOptRequire(test.rubyOpt))
+		})
+	}
+}
+
+func TestRubyOptDisabledArg(t *testing.T) {
+	tests := []struct {
+		name      string
+		container corev1.Container
+		expected  string
+	}{
+		{
+			name:      "rails server with bundle exec",
+			container: corev1.Container{Command: []string{"bundle", "exec", "rails", "server"}},
+		},
+		{
+			name:      "bare ruby script",
+			container: corev1.Container{Command: []string{"ruby", "-W0", "script.rb"}},
+		},
+		{
+			name:      "script flag after the script",
+			container: corev1.Container{Command: []string{"ruby"}, Args: []string{"script.rb", "--disable-all"}},
+		},
+		{
+			name:      "ruby ignoring rubyopt",
+			container: corev1.Container{Command: []string{"/usr/local/bin/ruby", "--disable-rubyopt", "script.rb"}},
+			expected:  "--disable-rubyopt",
+		},
+		{
+			name:      "ruby ignoring rubyopt with bundle exec",
+			container: corev1.Container{Args: []string{"bin/bundle", "exec", "ruby", "--disable=gems,rubyopt", "script.rb"}},
+			expected:  "--disable=gems,rubyopt",
+		},
+		{
+			name:      "ruby ignoring all",
+			container: corev1.Container{Command: []string{"ruby", "--disable=all", "script.rb"}},
+			expected:  "--disable=all",
+		},
+		{
+			name:      "ruby disabling other features",
+			container: corev1.Container{Command: []string{"ruby", "--disable=did_you_mean", "script.rb"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, rubyOptDisabledArg(&test.container))
+		})
+	}
+}
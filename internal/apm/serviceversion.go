@@ -0,0 +1,100 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apm
+
+import (
+	"context"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serviceVersionAttribute is the agent attribute, and resource attribute, holding the version of the app
+const serviceVersionAttribute = "service.version"
+
+// serviceVersion is used to get the version of the app from the first service version key set as a label or an
+// annotation of the pod. When the pod has none of them, the workload owning it is looked up, its replicaset and
+// deployment for the pods of a deployment, as the version is often only set on the workload. The first key set on any
+// of them wins. Empty when it isn't set anywhere, the owner can't be read, or the version has the separators of the
+// attribute lists.
+func (i *baseInjector) serviceVersion(ctx context.Context, pod corev1.Pod) string {
+	keys := i.configuration().ServiceVersionKeys()
+	if len(keys) == 0 {
+		return ""
+	}
+	version := labelOrAnnotation(keys, &pod)
+	if version == "" {
+		version = labelOrAnnotation(keys, i.podOwners(ctx, pod)...)
+	}
+	if strings.ContainsAny(version, ";:,=") {
+		i.logger.V(1).Info("the service version has attribute separators, skipping it", "version", version)
+		return ""
+	}
+	return version
+}
+
+// podOwners is used to get the controller owner of the pod, followed by the deployment owning it when it's a
+// replicaset. Only the replicasets, statefulsets and daemonsets are read, and nothing without a client.
+func (i *baseInjector) podOwners(ctx context.Context, pod corev1.Pod) []metav1.Object {
+	owner := metav1.GetControllerOf(&pod)
+	if i.client == nil || owner == nil {
+		return nil
+	}
+	var ownerObj client.Object
+	switch owner.Kind {
+	case "ReplicaSet":
+		ownerObj = &appsv1.ReplicaSet{}
+	case "StatefulSet":
+		ownerObj = &appsv1.StatefulSet{}
+	case "DaemonSet":
+		ownerObj = &appsv1.DaemonSet{}
+	default:
+		return nil
+	}
+	if err := i.client.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: owner.Name}, ownerObj); err != nil {
+		i.logger.V(1).Info("failed to get the pod owner, skipping its service version", "owner_kind", owner.Kind, "owner_name", owner.Name, "reason", err.Error())
+		return nil
+	}
+	owners := []metav1.Object{ownerObj}
+	if topOwner := metav1.GetControllerOf(ownerObj); topOwner != nil && owner.Kind == "ReplicaSet" && topOwner.Kind == "Deployment" {
+		deployment := &appsv1.Deployment{}
+		if err := i.client.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: topOwner.Name}, deployment); err != nil {
+			i.logger.V(1).Info("failed to get the pod owner, skipping its service version", "owner_kind", topOwner.Kind, "owner_name", topOwner.Name, "reason", err.Error())
+			return owners
+		}
+		owners = append(owners, deployment)
+	}
+	return owners
+}
+
+// labelOrAnnotation is used to get the value of the first key one of the objects has as a label or an annotation
+func labelOrAnnotation(keys []string, objs ...metav1.Object) string {
+	for _, key := range keys {
+		for _, obj := range objs {
+			if value := obj.GetLabels()[key]; value != "" {
+				return value
+			}
+			if value := obj.GetAnnotations()[key]; value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
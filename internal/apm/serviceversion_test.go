@@ -0,0 +1,73 @@
+package apm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+	"github.com/newrelic/k8s-agents-operator/internal/version"
+)
+
+func TestBaseInjector_ServiceVersion(t *testing.T) {
+	controlledBy := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: name, Controller: ptr.To(true)}}
+	}
+	c := fake.NewClientBuilder().WithObjects(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout", Labels: map[string]string{"app.kubernetes.io/version": "2.4.0"}}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "shop",
+			Name:            "checkout-5d9f",
+			Annotations:     map[string]string{"deployment.kubernetes.io/revision": "7"},
+			OwnerReferences: controlledBy("Deployment", "checkout"),
+		}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "cart", Annotations: map[string]string{"app.kubernetes.io/version": "1.0.3"}}},
+	).Build()
+	keys := config.New(config.WithServiceVersionKeys([]string{"app.kubernetes.io/version", "deployment.kubernetes.io/revision"}))
+	revision := config.New(config.WithServiceVersionKeys([]string{"deployment.kubernetes.io/revision"}))
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		pod      metav1.ObjectMeta
+		expected string
+	}{
+		{name: "no keys", cfg: config.New(), pod: metav1.ObjectMeta{Labels: map[string]string{"app.kubernetes.io/version": "2.5.0"}}},
+		{name: "pod label", cfg: keys, pod: metav1.ObjectMeta{Labels: map[string]string{"app.kubernetes.io/version": "2.5.0"}}, expected: "2.5.0"},
+		{name: "pod annotation", cfg: keys, pod: metav1.ObjectMeta{Annotations: map[string]string{"deployment.kubernetes.io/revision": "8"}}, expected: "8"},
+		{name: "deployment", cfg: keys, pod: metav1.ObjectMeta{Namespace: "shop", OwnerReferences: controlledBy("ReplicaSet", "checkout-5d9f")}, expected: "2.4.0"},
+		{name: "replicaset", cfg: revision, pod: metav1.ObjectMeta{Namespace: "shop", OwnerReferences: controlledBy("ReplicaSet", "checkout-5d9f")}, expected: "7"},
+		{name: "statefulset", cfg: keys, pod: metav1.ObjectMeta{Namespace: "shop", OwnerReferences: controlledBy("StatefulSet", "cart")}, expected: "1.0.3"},
+		{name: "missing owner", cfg: keys, pod: metav1.ObjectMeta{Namespace: "shop", OwnerReferences: controlledBy("ReplicaSet", "checkout-0000")}},
+		{name: "not set", cfg: revision, pod: metav1.ObjectMeta{Namespace: "shop", OwnerReferences: controlledBy("StatefulSet", "cart")}},
+		{name: "separators", cfg: keys, pod: metav1.ObjectMeta{Labels: map[string]string{"app.kubernetes.io/version": "a=b"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := baseInjector{client: c, config: &test.cfg}
+			pod := corev1.Pod{ObjectMeta: test.pod, Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}}
+			assert.Equal(t, test.expected, i.serviceVersion(context.Background(), pod))
+		})
+	}
+}
+
+func TestBaseInjector_InjectNewrelicEnvConfig_ServiceVersion(t *testing.T) {
+	cfg := config.New(config.WithServiceVersionKeys([]string{"app.kubernetes.io/version"}))
+	i := baseInjector{config: &cfg}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app.kubernetes.io/version": "2.5.0"}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "app",
+			Env:  []corev1.EnvVar{{Name: EnvOtelResourceAttributes, Value: "service.namespace=shop"}},
+		}}},
+	}
+	pod = i.injectNewrelicEnvConfig(context.Background(), pod, 0)
+	env := pod.Spec.Containers[0].Env
+	assert.Equal(t, "operator:auto-injection;service.version:2.5.0", env[getIndexOfEnv(env, EnvNewRelicLabels)].Value)
+	assert.Equal(t, "newrelic.k8s.operator.version="+version.Get().Operator+",service.namespace=shop,service.version=2.5.0", env[getIndexOfEnv(env, EnvOtelResourceAttributes)].Value)
+}
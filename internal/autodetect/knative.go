@@ -0,0 +1,36 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autodetect
+
+// KnativeAvailability holds the auto-detected availability of Knative Serving, whose revisions manage the pods of the
+// Knative services.
+type KnativeAvailability int
+
+const (
+	// KnativeAvailable represents the serving.knative.dev API is available.
+	KnativeAvailable KnativeAvailability = iota
+
+	// KnativeNotAvailable represents the serving.knative.dev API is not available.
+	KnativeNotAvailable
+)
+
+// DefaultKnativeAvailability is assumed until the API groups of the cluster are detected
+const DefaultKnativeAvailability = KnativeNotAvailable
+
+func (p KnativeAvailability) String() string {
+	return [...]string{"Available", "NotAvailable"}[p]
+}
@@ -19,8 +19,10 @@ package autodetect
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 )
@@ -31,6 +33,10 @@ var _ AutoDetect = (*autoDetect)(nil)
 type AutoDetect interface {
 	OpenShiftRoutesAvailability() (OpenShiftRoutesAvailability, error)
 	HPAVersion() (AutoscalingVersion, error)
+	VPAAvailability() (VPAAvailability, error)
+	NativeSidecarsAvailability() (NativeSidecarsAvailability, error)
+	SleepActionAvailability() (SleepActionAvailability, error)
+	KnativeAvailability() (KnativeAvailability, error)
 }
 
 type autoDetect struct {
@@ -79,6 +85,80 @@ func (a *autoDetect) OpenShiftRoutesAvailability() (OpenShiftRoutesAvailability,
 	return OpenShiftRoutesNotAvailable, nil
 }
 
+// VPAAvailability checks if the Vertical Pod Autoscaler API is available.
+func (a *autoDetect) VPAAvailability() (VPAAvailability, error) {
+	apiList, err := a.dcl.ServerGroups()
+	if err != nil {
+		return VPANotAvailable, err
+	}
+
+	for _, apiGroup := range apiList.Groups {
+		if apiGroup.Name != "autoscaling.k8s.io" {
+			continue
+		}
+		resources, err := a.dcl.ServerResourcesForGroupVersion(apiGroup.PreferredVersion.GroupVersion)
+		if err != nil {
+			return VPANotAvailable, err
+		}
+		for _, resource := range resources.APIResources {
+			if resource.Kind == "VerticalPodAutoscaler" {
+				return VPAAvailable, nil
+			}
+		}
+	}
+
+	return VPANotAvailable, nil
+}
+
+// NativeSidecarsAvailability checks if the Kubernetes version of the cluster runs native sidecars by default.
+func (a *autoDetect) NativeSidecarsAvailability() (NativeSidecarsAvailability, error) {
+	info, err := a.dcl.ServerVersion()
+	if err != nil {
+		return NativeSidecarsNotAvailable, err
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return NativeSidecarsNotAvailable, fmt.Errorf("failed to parse the kubernetes version %q > %w", info.GitVersion, err)
+	}
+	if serverVersion.AtLeast(nativeSidecarsMinVersion) {
+		return NativeSidecarsAvailable, nil
+	}
+	return NativeSidecarsNotAvailable, nil
+}
+
+// SleepActionAvailability checks if the Kubernetes version of the cluster enables the sleep action of the lifecycle
+// hooks by default.
+func (a *autoDetect) SleepActionAvailability() (SleepActionAvailability, error) {
+	info, err := a.dcl.ServerVersion()
+	if err != nil {
+		return SleepActionNotAvailable, err
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return SleepActionNotAvailable, fmt.Errorf("failed to parse the kubernetes version %q > %w", info.GitVersion, err)
+	}
+	if serverVersion.AtLeast(sleepActionMinVersion) {
+		return SleepActionAvailable, nil
+	}
+	return SleepActionNotAvailable, nil
+}
+
+// KnativeAvailability checks if the Knative Serving API is available.
+func (a *autoDetect) KnativeAvailability() (KnativeAvailability, error) {
+	apiList, err := a.dcl.ServerGroups()
+	if err != nil {
+		return KnativeNotAvailable, err
+	}
+
+	for _, apiGroup := range apiList.Groups {
+		if apiGroup.Name == "serving.knative.dev" {
+			return KnativeAvailable, nil
+		}
+	}
+
+	return KnativeNotAvailable, nil
+}
+
 func (a *autoDetect) HPAVersion() (AutoscalingVersion, error) {
 	apiList, err := a.dcl.ServerGroups()
 	if err != nil {
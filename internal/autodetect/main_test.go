@@ -27,6 +27,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/rest"
 )
 
@@ -73,6 +74,168 @@ func TestDetectPlatformBasedOnAvailableAPIGroups(t *testing.T) {
 	}
 }
 
+func TestDetectKnativeBasedOnAvailableAPIGroups(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		apiGroupList *metav1.APIGroupList
+		expected     autodetect.KnativeAvailability
+	}{
+		{name: "no groups", apiGroupList: &metav1.APIGroupList{}, expected: autodetect.KnativeNotAvailable},
+		{
+			name:         "eventing only",
+			apiGroupList: &metav1.APIGroupList{Groups: []metav1.APIGroup{{Name: "eventing.knative.dev"}}},
+			expected:     autodetect.KnativeNotAvailable,
+		},
+		{
+			name:         "serving",
+			apiGroupList: &metav1.APIGroupList{Groups: []metav1.APIGroup{{Name: "serving.knative.dev"}}},
+			expected:     autodetect.KnativeAvailable,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				output, err := json.Marshal(tt.apiGroupList)
+				require.NoError(t, err)
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_, err = w.Write(output)
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			autoDetect, err := autodetect.New(&rest.Config{Host: server.URL})
+			require.NoError(t, err)
+
+			// test
+			knative, err := autoDetect.KnativeAvailability()
+
+			// verify
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, knative)
+		})
+	}
+}
+
+func TestDetectVPABasedOnAvailableAPIGroups(t *testing.T) {
+	vpaGroup := metav1.APIGroup{
+		Name:             "autoscaling.k8s.io",
+		PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "autoscaling.k8s.io/v1", Version: "v1"},
+		Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "autoscaling.k8s.io/v1", Version: "v1"}},
+	}
+	for _, tt := range []struct {
+		name            string
+		apiGroupList    *metav1.APIGroupList
+		apiResourceList *metav1.APIResourceList
+		expected        autodetect.VPAAvailability
+	}{
+		{
+			name:         "no groups",
+			apiGroupList: &metav1.APIGroupList{},
+			expected:     autodetect.VPANotAvailable,
+		},
+		{
+			name:            "group without the vpa resource",
+			apiGroupList:    &metav1.APIGroupList{Groups: []metav1.APIGroup{vpaGroup}},
+			apiResourceList: &metav1.APIResourceList{GroupVersion: "autoscaling.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "verticalpodautoscalercheckpoints", Kind: "VerticalPodAutoscalerCheckpoint"}}},
+			expected:        autodetect.VPANotAvailable,
+		},
+		{
+			name:            "group with the vpa resource",
+			apiGroupList:    &metav1.APIGroupList{Groups: []metav1.APIGroup{vpaGroup}},
+			apiResourceList: &metav1.APIResourceList{GroupVersion: "autoscaling.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "verticalpodautoscalers", Kind: "VerticalPodAutoscaler"}}},
+			expected:        autodetect.VPAAvailable,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var body any = tt.apiGroupList
+				if req.URL.Path == "/apis/autoscaling.k8s.io/v1" {
+					body = tt.apiResourceList
+				}
+				output, err := json.Marshal(body)
+				require.NoError(t, err)
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_, err = w.Write(output)
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			autoDetect, err := autodetect.New(&rest.Config{Host: server.URL})
+			require.NoError(t, err)
+
+			// test
+			vpa, err := autoDetect.VPAAvailability()
+
+			// verify
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, vpa)
+		})
+	}
+}
+
+func TestDetectNativeSidecarsBasedOnServerVersion(t *testing.T) {
+	for _, tt := range []struct {
+		gitVersion string
+		expected   autodetect.NativeSidecarsAvailability
+	}{
+		{"v1.28.9", autodetect.NativeSidecarsNotAvailable},
+		{"v1.29.0", autodetect.NativeSidecarsAvailable},
+		{"v1.31.2-eks-7f9249a", autodetect.NativeSidecarsAvailable},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			output, err := json.Marshal(version.Info{GitVersion: tt.gitVersion})
+			require.NoError(t, err)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(output)
+			require.NoError(t, err)
+		}))
+		defer server.Close()
+
+		autoDetect, err := autodetect.New(&rest.Config{Host: server.URL})
+		require.NoError(t, err)
+
+		availability, err := autoDetect.NativeSidecarsAvailability()
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, availability, tt.gitVersion)
+	}
+}
+
+func TestDetectSleepActionBasedOnServerVersion(t *testing.T) {
+	for _, tt := range []struct {
+		gitVersion string
+		expected   autodetect.SleepActionAvailability
+	}{
+		{"v1.29.6", autodetect.SleepActionNotAvailable},
+		{"v1.30.0", autodetect.SleepActionAvailable},
+		{"v1.31.2-eks-7f9249a", autodetect.SleepActionAvailable},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			output, err := json.Marshal(version.Info{GitVersion: tt.gitVersion})
+			require.NoError(t, err)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(output)
+			require.NoError(t, err)
+		}))
+		defer server.Close()
+
+		autoDetect, err := autodetect.New(&rest.Config{Host: server.URL})
+		require.NoError(t, err)
+
+		availability, err := autoDetect.SleepActionAvailability()
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, availability, tt.gitVersion)
+	}
+}
+
 func TestAutoscalingVersionToString(t *testing.T) {
 	assert.Equal(t, "v2", autodetect.AutoscalingVersionV2.String())
 	assert.Equal(t, "v2beta2", autodetect.AutoscalingVersionV2Beta2.String())
@@ -0,0 +1,42 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autodetect
+
+import "k8s.io/apimachinery/pkg/util/version"
+
+// NativeSidecarsAvailability holds the auto-detected support of native sidecars, the restartable init containers
+// running alongside the app containers.
+type NativeSidecarsAvailability int
+
+const (
+	// NativeSidecarsAvailable represents the cluster runs the init containers with an Always restart policy as sidecars.
+	NativeSidecarsAvailable NativeSidecarsAvailability = iota
+
+	// NativeSidecarsNotAvailable represents the cluster doesn't run native sidecars by default.
+	NativeSidecarsNotAvailable
+)
+
+// DefaultNativeSidecarsAvailability is assumed until the Kubernetes version is detected, as the health sidecar relies
+// on native sidecars anyway
+const DefaultNativeSidecarsAvailability = NativeSidecarsAvailable
+
+// nativeSidecarsMinVersion is the first Kubernetes version enabling native sidecars by default
+var nativeSidecarsMinVersion = version.MajorMinor(1, 29)
+
+func (p NativeSidecarsAvailability) String() string {
+	return [...]string{"Available", "NotAvailable"}[p]
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autodetect
+
+import "k8s.io/apimachinery/pkg/util/version"
+
+// SleepActionAvailability holds the auto-detected support of the sleep action of the container lifecycle hooks.
+type SleepActionAvailability int
+
+const (
+	// SleepActionAvailable represents the cluster accepts lifecycle hooks with a sleep action.
+	SleepActionAvailable SleepActionAvailability = iota
+
+	// SleepActionNotAvailable represents the cluster doesn't enable the sleep action by default.
+	SleepActionNotAvailable
+)
+
+// DefaultSleepActionAvailability is assumed until the Kubernetes version is detected, as the api server rejects the
+// pods with a sleep action it doesn't enable
+const DefaultSleepActionAvailability = SleepActionNotAvailable
+
+// sleepActionMinVersion is the first Kubernetes version enabling the sleep action, PodLifecycleSleepAction, by default
+var sleepActionMinVersion = version.MajorMinor(1, 30)
+
+func (p SleepActionAvailability) String() string {
+	return [...]string{"Available", "NotAvailable"}[p]
+}
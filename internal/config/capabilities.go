@@ -0,0 +1,62 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// CapabilitiesPath is the path the capabilities handler is served on
+const CapabilitiesPath = "/debug/capabilities"
+
+// Capabilities is a snapshot of the cluster capabilities detected by auto-detect
+type Capabilities struct {
+	OpenShiftRoutes       string `json:"openshiftRoutes"`
+	VerticalPodAutoscaler string `json:"verticalPodAutoscaler"`
+	AutoscalingVersion    string `json:"autoscalingVersion"`
+	NativeSidecars        string `json:"nativeSidecars"`
+	SleepAction           string `json:"sleepAction"`
+	Knative               string `json:"knative"`
+}
+
+// Capabilities returns a snapshot of the detected cluster capabilities.
+func (c *Config) Capabilities() Capabilities {
+	return Capabilities{
+		OpenShiftRoutes:       c.OpenShiftRoutes().String(),
+		VerticalPodAutoscaler: c.VPAAvailability().String(),
+		AutoscalingVersion:    c.AutoscalingVersion().String(),
+		NativeSidecars:        c.NativeSidecars().String(),
+		SleepAction:           c.SleepAction().String(),
+		Knative:               c.Knative().String(),
+	}
+}
+
+// CapabilitiesHandler serves the detected cluster capabilities as json
+func CapabilitiesHandler(c *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.Capabilities()); err != nil {
+			c.logger.Error(err, "failed to write capabilities")
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package config_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestCapabilitiesHandler(t *testing.T) {
+	cfg := config.New(
+		config.WithAutoDetect(&mockAutoDetect{
+			OpenShiftRoutesAvailabilityFunc: func() (autodetect.OpenShiftRoutesAvailability, error) {
+				return autodetect.OpenShiftRoutesAvailable, nil
+			},
+		}),
+	)
+	require.NoError(t, cfg.AutoDetect())
+
+	rec := httptest.NewRecorder()
+	config.CapabilitiesHandler(&cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, config.CapabilitiesPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"openshiftRoutes":"Available","verticalPodAutoscaler":"NotAvailable","autoscalingVersion":"v2","nativeSidecars":"Available","sleepAction":"NotAvailable","knative":"NotAvailable"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	config.CapabilitiesHandler(&cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, config.CapabilitiesPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
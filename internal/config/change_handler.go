@@ -19,6 +19,7 @@ package config
 
 import (
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -64,3 +65,51 @@ func (o *onChange) Register(f func() error) {
 	defer o.muCallbacks.Unlock()
 	o.callbacks = append(o.callbacks, f)
 }
+
+// newCooldownOnChange returns a ChangeHandler calling the callbacks of the handler at most once per cooldown, or the
+// handler itself without a cooldown. A change within the cooldown of the last call is deferred to the end of the
+// cooldown, and the changes deferred together are handled by a single call, so the callbacks of a flapping detection
+// aren't called repeatedly but still see its last state.
+func newCooldownOnChange(handler changeHandler, cooldown time.Duration) changeHandler {
+	if cooldown <= 0 {
+		return handler
+	}
+	return &cooldownOnChange{handler: handler, cooldown: cooldown, mu: &sync.Mutex{}}
+}
+
+type cooldownOnChange struct {
+	handler  changeHandler
+	cooldown time.Duration
+
+	mu      *sync.Mutex
+	last    time.Time
+	pending bool
+}
+
+func (o *cooldownOnChange) Do() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pending {
+		return nil
+	}
+	if wait := o.cooldown - time.Since(o.last); wait > 0 {
+		o.pending = true
+		time.AfterFunc(wait, o.doPending)
+		return nil
+	}
+	o.last = time.Now()
+	return o.handler.Do()
+}
+
+// doPending is used to handle the changes deferred to the end of the cooldown
+func (o *cooldownOnChange) doPending() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending = false
+	o.last = time.Now()
+	_ = o.handler.Do()
+}
+
+func (o *cooldownOnChange) Register(f func() error) {
+	o.handler.Register(f)
+}
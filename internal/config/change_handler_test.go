@@ -18,7 +18,9 @@ limitations under the License.
 package config
 
 import (
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -41,3 +43,31 @@ func TestChangeHandler(t *testing.T) {
 		assert.Equal(t, i+1, internal)
 	}
 }
+
+func TestCooldownChangeHandler(t *testing.T) {
+	// prepare
+	var calls atomic.Int32
+	h := newCooldownOnChange(newOnChange(), 50*time.Millisecond)
+	h.Register(func() error {
+		calls.Add(1)
+		return nil
+	})
+
+	// the first change is handled right away
+	require.NoError(t, h.Do())
+	assert.Equal(t, int32(1), calls.Load())
+
+	// the changes within the cooldown are handled once, at its end
+	for i := 0; i < 5; i++ {
+		require.NoError(t, h.Do())
+	}
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCooldownChangeHandler_Disabled(t *testing.T) {
+	h := newOnChange()
+	assert.Same(t, h, newCooldownOnChange(h, 0))
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterNameAttribute is the attribute holding the cluster name on the telemetry of the instrumented pods
+const ClusterNameAttribute = "k8s.cluster.name"
+
+// ClusterNameSource is where the cluster name is read from when the operator starts, either a key of a configmap or a
+// node label
+type ClusterNameSource struct {
+	ConfigMap types.NamespacedName
+	Key       string
+	NodeLabel string
+}
+
+// ParseClusterNameSource is used to parse a cluster name source, configmap:<namespace>/<name>/<key> or
+// node-label:<label>
+func ParseClusterNameSource(source string) (ClusterNameSource, error) {
+	kind, value, _ := strings.Cut(source, ":")
+	switch kind {
+	case "configmap":
+		parts := strings.Split(value, "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return ClusterNameSource{}, fmt.Errorf("cluster name source %q must be configmap:<namespace>/<name>/<key>", source)
+		}
+		return ClusterNameSource{ConfigMap: types.NamespacedName{Namespace: parts[0], Name: parts[1]}, Key: parts[2]}, nil
+	case "node-label":
+		if errs := validation.IsQualifiedName(value); len(errs) > 0 {
+			return ClusterNameSource{}, fmt.Errorf("cluster name source %q must be node-label:<label>: %s", source, strings.Join(errs, ", "))
+		}
+		return ClusterNameSource{NodeLabel: value}, nil
+	}
+	return ClusterNameSource{}, fmt.Errorf("cluster name source %q must be configmap:<namespace>/<name>/<key> or node-label:<label>", source)
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+// Resolve is used to read the cluster name from the source. The node label is taken from the first node with it, as
+// it's expected to be the same on every node of the cluster.
+func (s ClusterNameSource) Resolve(ctx context.Context, c client.Reader) (string, error) {
+	if s.NodeLabel == "" {
+		var configMap corev1.ConfigMap
+		if err := c.Get(ctx, s.ConfigMap, &configMap); err != nil {
+			return "", fmt.Errorf("failed to get the cluster name configmap %s > %w", s.ConfigMap, err)
+		}
+		name, ok := configMap.Data[s.Key]
+		if !ok {
+			return "", fmt.Errorf("the cluster name configmap %s has no key %q", s.ConfigMap, s.Key)
+		}
+		return name, nil
+	}
+	var nodes corev1.NodeList
+	if err := c.List(ctx, &nodes, client.HasLabels{s.NodeLabel}, client.Limit(1)); err != nil {
+		return "", fmt.Errorf("failed to list the nodes with the cluster name label %s > %w", s.NodeLabel, err)
+	}
+	if len(nodes.Items) == 0 {
+		return "", fmt.Errorf("no node has the cluster name label %s", s.NodeLabel)
+	}
+	return nodes.Items[0].Labels[s.NodeLabel], nil
+}
+
+// ValidateClusterName is used to validate the cluster name can be added to the agent labels and the OpenTelemetry
+// resource attributes, which are separated by ;: and ,= respectively
+func ValidateClusterName(name string) error {
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, ";:,=") {
+		return fmt.Errorf("cluster name %q must not be empty or contain any of ;:,=", name)
+	}
+	return nil
+}
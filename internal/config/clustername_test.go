@@ -0,0 +1,71 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseClusterNameSource(t *testing.T) {
+	tests := []struct {
+		source      string
+		expected    ClusterNameSource
+		expectedErr string
+	}{
+		{
+			source:   "configmap:kube-system/cluster-info/name",
+			expected: ClusterNameSource{ConfigMap: types.NamespacedName{Namespace: "kube-system", Name: "cluster-info"}, Key: "name"},
+		},
+		{source: "node-label:alpha.eksctl.io/cluster-name", expected: ClusterNameSource{NodeLabel: "alpha.eksctl.io/cluster-name"}},
+		{source: "configmap:cluster-info/name", expectedErr: "must be configmap:<namespace>/<name>/<key>"},
+		{source: "node-label:", expectedErr: "must be node-label:<label>"},
+		{source: "cluster", expectedErr: "must be configmap:<namespace>/<name>/<key> or node-label:<label>"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			actual, err := ParseClusterNameSource(test.source)
+			if test.expectedErr != "" {
+				assert.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestClusterNameSource_Resolve(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "newrelic", Name: "cluster"}, Data: map[string]string{"name": "prod-us-east"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b", Labels: map[string]string{"example.com/cluster": "prod-eu-west"}}},
+	).Build()
+	ctx := context.Background()
+
+	name, err := ClusterNameSource{ConfigMap: types.NamespacedName{Namespace: "newrelic", Name: "cluster"}, Key: "name"}.Resolve(ctx, c)
+	require.NoError(t, err)
+	assert.Equal(t, "prod-us-east", name)
+
+	_, err = ClusterNameSource{ConfigMap: types.NamespacedName{Namespace: "newrelic", Name: "cluster"}, Key: "id"}.Resolve(ctx, c)
+	assert.ErrorContains(t, err, `has no key "id"`)
+
+	name, err = ClusterNameSource{NodeLabel: "example.com/cluster"}.Resolve(ctx, c)
+	require.NoError(t, err)
+	assert.Equal(t, "prod-eu-west", name)
+
+	_, err = ClusterNameSource{NodeLabel: "example.com/region"}.Resolve(ctx, c)
+	assert.ErrorContains(t, err, "no node has the cluster name label")
+}
+
+func TestValidateClusterName(t *testing.T) {
+	assert.NoError(t, ValidateClusterName("prod-us-east"))
+	for _, name := range []string{"", " ", "prod;us", "prod:us", "prod,us", "prod=us"} {
+		assert.Error(t, ValidateClusterName(name))
+	}
+}
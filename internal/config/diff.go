@@ -0,0 +1,93 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"slices"
+)
+
+// ConfigDiff reports which parts of the configuration changed between two configurations, so that a reload only
+// reconciles the resources affected by the change.
+type ConfigDiff struct {
+	// Images is whether the agent image repository or channel changed.
+	Images bool
+	// Region is whether the region the agents report to changed.
+	Region bool
+	// LabelsFilter is whether the filter of the labels propagated to the agents, its allow-list or its mode changed.
+	LabelsFilter bool
+	// AttributeLabels is whether the allow-list of the pod labels added to the agent labels changed.
+	AttributeLabels bool
+	// AutoDetectFrequency is whether the frequency of the auto-detection changed.
+	AutoDetectFrequency bool
+	// AgentInitDeadline is whether the deadline of the agent init containers changed.
+	AgentInitDeadline bool
+	// InitContainerNamePrefix is whether the name prefix of the agent init containers changed.
+	InitContainerNamePrefix bool
+	// OpenShiftRoutes is whether the detected availability of the OpenShift Routes API changed.
+	OpenShiftRoutes bool
+	// VPA is whether the detected availability of the Vertical Pod Autoscaler API changed.
+	VPA bool
+	// AutoscalingVersion is whether the detected autoscaling version changed.
+	AutoscalingVersion bool
+	// NativeSidecars is whether the detected support of native sidecars changed.
+	NativeSidecars bool
+	// SleepAction is whether the detected support of the sleep action changed.
+	SleepAction bool
+	// Knative is whether the detected availability of the Knative Serving API changed.
+	Knative bool
+	// FreezeAutoDetect is whether the auto-detection was paused or resumed.
+	FreezeAutoDetect bool
+}
+
+// Diff compares the old configuration with the new one.
+func Diff(oldConfig, newConfig *Config) ConfigDiff {
+	oldRepository, oldChannel := oldConfig.ImageChannel()
+	newRepository, newChannel := newConfig.ImageChannel()
+	return ConfigDiff{
+		Images:                  oldRepository != newRepository || oldChannel != newChannel,
+		Region:                  oldConfig.Region() != newConfig.Region(),
+		LabelsFilter:            labelsFilterChanged(oldConfig, newConfig),
+		AttributeLabels:         !slices.Equal(oldConfig.AttributeLabels(), newConfig.AttributeLabels()),
+		AutoDetectFrequency:     oldConfig.AutoDetectFrequency() != newConfig.AutoDetectFrequency(),
+		AgentInitDeadline:       oldConfig.AgentInitDeadline() != newConfig.AgentInitDeadline(),
+		InitContainerNamePrefix: oldConfig.InitContainerNamePrefix() != newConfig.InitContainerNamePrefix(),
+		OpenShiftRoutes:         oldConfig.OpenShiftRoutes() != newConfig.OpenShiftRoutes(),
+		VPA:                     oldConfig.VPAAvailability() != newConfig.VPAAvailability(),
+		AutoscalingVersion:      oldConfig.AutoscalingVersion() != newConfig.AutoscalingVersion(),
+		NativeSidecars:          oldConfig.NativeSidecars() != newConfig.NativeSidecars(),
+		SleepAction:             oldConfig.SleepAction() != newConfig.SleepAction(),
+		Knative:                 oldConfig.Knative() != newConfig.Knative(),
+		FreezeAutoDetect:        oldConfig.FreezeAutoDetect() != newConfig.FreezeAutoDetect(),
+	}
+}
+
+// labelsFilterChanged is whether the labels filter, the labels allow-list or the labels filter mode changed
+func labelsFilterChanged(oldConfig, newConfig *Config) bool {
+	return !slices.Equal(oldConfig.LabelsFilter(), newConfig.LabelsFilter()) ||
+		!slices.Equal(oldConfig.LabelsAllowList(), newConfig.LabelsAllowList()) ||
+		oldConfig.LabelsFilterMode() != newConfig.LabelsFilterMode()
+}
+
+// HasChanges is whether anything changed.
+func (d ConfigDiff) HasChanges() bool {
+	return d != ConfigDiff{}
+}
+
+// DetectedStateChanged is whether anything found by the auto-detection changed, rather than the configured values.
+func (d ConfigDiff) DetectedStateChanged() bool {
+	return d.OpenShiftRoutes || d.VPA || d.AutoscalingVersion || d.NativeSidecars || d.SleepAction || d.Knative
+}
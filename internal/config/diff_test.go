@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+)
+
+func TestDiff(t *testing.T) {
+	base := []Option{
+		WithImageRepository("newrelic"),
+		WithImageChannel("stable"),
+		WithLabelsFilter([]string{"app.*"}),
+		WithAttributeLabels([]string{"team"}),
+		WithAutoDetectFrequency(time.Minute),
+		WithAgentInitDeadline(time.Minute),
+		WithInitContainerNamePrefix("newrelic-instrumentation"),
+		WithPlatform(autodetect.OpenShiftRoutesNotAvailable),
+		WithVPA(autodetect.VPANotAvailable),
+	}
+	tests := []struct {
+		name     string
+		opts     []Option
+		update   func(c *Config)
+		expected ConfigDiff
+	}{
+		{name: "nothing"},
+		{name: "image repository", opts: []Option{WithImageRepository("docker.io/newrelic")}, expected: ConfigDiff{Images: true}},
+		{name: "image channel", opts: []Option{WithImageChannel("canary")}, expected: ConfigDiff{Images: true}},
+		{name: "region", opts: []Option{WithRegion(RegionEU)}, expected: ConfigDiff{Region: true}},
+		{name: "labels filter", opts: []Option{WithLabelsFilter([]string{"app.*", "tier"})}, expected: ConfigDiff{LabelsFilter: true}},
+		{name: "labels allow-list", opts: []Option{WithLabelsAllowList([]string{"^team$"})}, expected: ConfigDiff{LabelsFilter: true}},
+		{name: "labels filter mode", opts: []Option{WithLabelsFilterMode(LabelsFilterModeAllow)}, expected: ConfigDiff{LabelsFilter: true}},
+		{name: "attribute labels", opts: []Option{WithAttributeLabels([]string{"team", "tier"})}, expected: ConfigDiff{AttributeLabels: true}},
+		{name: "auto-detect frequency", opts: []Option{WithAutoDetectFrequency(time.Hour)}, expected: ConfigDiff{AutoDetectFrequency: true}},
+		{name: "agent init deadline", opts: []Option{WithAgentInitDeadline(time.Hour)}, expected: ConfigDiff{AgentInitDeadline: true}},
+		{name: "init container name prefix", opts: []Option{WithInitContainerNamePrefix("nr")}, expected: ConfigDiff{InitContainerNamePrefix: true}},
+		{name: "openshift routes", opts: []Option{WithPlatform(autodetect.OpenShiftRoutesAvailable)}, expected: ConfigDiff{OpenShiftRoutes: true}},
+		{name: "vpa", opts: []Option{WithVPA(autodetect.VPAAvailable)}, expected: ConfigDiff{VPA: true}},
+		{name: "native sidecars", opts: []Option{WithNativeSidecars(autodetect.NativeSidecarsNotAvailable)}, expected: ConfigDiff{NativeSidecars: true}},
+		{name: "sleep action", opts: []Option{WithSleepAction(autodetect.SleepActionAvailable)}, expected: ConfigDiff{SleepAction: true}},
+		{name: "knative", opts: []Option{WithKnative(autodetect.KnativeAvailable)}, expected: ConfigDiff{Knative: true}},
+		{name: "freeze auto-detect", opts: []Option{WithFreezeAutoDetect(true)}, expected: ConfigDiff{FreezeAutoDetect: true}},
+		{
+			name:     "autoscaling version",
+			update:   func(c *Config) { c.autoscalingVersion = autodetect.AutoscalingVersionV2Beta2 },
+			expected: ConfigDiff{AutoscalingVersion: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldConfig := New(base...)
+			newConfig := New(append(append([]Option{}, base...), test.opts...)...)
+			if test.update != nil {
+				test.update(&newConfig)
+			}
+			diff := Diff(&oldConfig, &newConfig)
+			assert.Equal(t, test.expected, diff)
+			assert.Equal(t, test.expected != ConfigDiff{}, diff.HasChanges())
+		})
+	}
+}
+
+func TestConfigDiff_DetectedStateChanged(t *testing.T) {
+	assert.False(t, ConfigDiff{Images: true, LabelsFilter: true, AutoDetectFrequency: true}.DetectedStateChanged())
+	assert.True(t, ConfigDiff{OpenShiftRoutes: true}.DetectedStateChanged())
+	assert.True(t, ConfigDiff{VPA: true}.DetectedStateChanged())
+	assert.True(t, ConfigDiff{AutoscalingVersion: true}.DetectedStateChanged())
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The reasons of the events recorded when a detected capability changes
+const (
+	reasonOpenShiftRoutesChanged    = "OpenShiftRoutesChanged"
+	reasonVPAChanged                = "VerticalPodAutoscalerChanged"
+	reasonAutoscalingVersionChanged = "AutoscalingVersionChanged"
+	reasonNativeSidecarsChanged     = "NativeSidecarsChanged"
+	reasonSleepActionChanged        = "SleepActionChanged"
+	reasonKnativeChanged            = "KnativeChanged"
+)
+
+// recordDetectionChange is used to record an event on the event object when a detected capability changes, leaving a
+// trail of the cluster changes the operator reacted to. Nothing is recorded without an event recorder and object.
+func (c *Config) recordDetectionChange(reason, messageFmt string, args ...any) {
+	c.mu.RLock()
+	recorder, object := c.eventRecorder, c.eventObject
+	c.mu.RUnlock()
+	if recorder == nil || object == nil {
+		return
+	}
+	recorder.Eventf(object, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
@@ -0,0 +1,54 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestAutoDetect_RecordsChangeEvents(t *testing.T) {
+	routes := autodetect.OpenShiftRoutesAvailable
+	mock := &mockAutoDetect{
+		OpenShiftRoutesAvailabilityFunc: func() (autodetect.OpenShiftRoutesAvailability, error) {
+			return routes, nil
+		},
+	}
+	recorder := record.NewFakeRecorder(4)
+	cfg := config.New(config.WithAutoDetect(mock))
+	cfg.RegisterEventRecorder(recorder, &corev1.ObjectReference{Kind: "Deployment", Namespace: "newrelic", Name: "k8s-agents-operator"})
+
+	require.NoError(t, cfg.AutoDetect())
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Normal OpenShiftRoutesChanged OpenShift routes detected as Available", <-recorder.Events)
+
+	require.NoError(t, cfg.AutoDetect())
+	assert.Empty(t, recorder.Events, "nothing changed")
+
+	routes = autodetect.OpenShiftRoutesNotAvailable
+	require.NoError(t, cfg.AutoDetect())
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Normal OpenShiftRoutesChanged OpenShift routes detected as NotAvailable", <-recorder.Events)
+}
+
+func TestAutoDetect_RecordsChangeEvents_WithEventRecorder(t *testing.T) {
+	mock := &mockAutoDetect{
+		HPAVersionFunc: func() (autodetect.AutoscalingVersion, error) {
+			return autodetect.AutoscalingVersionV2Beta2, nil
+		},
+	}
+	recorder := record.NewFakeRecorder(4)
+	cfg := config.New(
+		config.WithAutoDetect(mock),
+		config.WithEventRecorder(recorder, &corev1.ObjectReference{Kind: "Deployment", Namespace: "newrelic", Name: "k8s-agents-operator"}),
+	)
+
+	require.NoError(t, cfg.AutoDetect())
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Normal AutoscalingVersionChanged Autoscaling version detected as v2beta2", <-recorder.Events)
+}
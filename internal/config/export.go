@@ -0,0 +1,291 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// documentVersion is the version of the exported configuration document, bumped on incompatible changes
+const documentVersion = 1
+
+// document is the portable form of the configured values, leaving out what's detected from the cluster and how the
+// operator is wired, such as the logger, the callbacks and the metrics registry. Maps are encoded with sorted keys, so
+// identical configurations export to identical documents.
+type document struct {
+	Version                  int                        `json:"version"`
+	LabelsFilter             []string                   `json:"labelsFilter,omitempty"`
+	LabelsAllowList          []string                   `json:"labelsAllowList,omitempty"`
+	LabelsFilterMode         LabelsFilterMode           `json:"labelsFilterMode"`
+	AutoDetectFrequency      metav1.Duration            `json:"autoDetectFrequency"`
+	AutoDetectJitter         float64                    `json:"autoDetectJitter,omitempty"`
+	AutoDetectInitialDelay   metav1.Duration            `json:"autoDetectInitialDelay"`
+	FreezeAutoDetect         bool                       `json:"freezeAutoDetect,omitempty"`
+	AgentInitDeadline        metav1.Duration            `json:"agentInitDeadline"`
+	AgentInitRunAsUser       *int64                     `json:"agentInitRunAsUser,omitempty"`
+	AgentInitRunAsGroup      *int64                     `json:"agentInitRunAsGroup,omitempty"`
+	AgentWarmup              map[string]metav1.Duration `json:"agentWarmup"`
+	AgentMountPropagation    map[string]string          `json:"agentMountPropagation,omitempty"`
+	AgentProfiling           bool                       `json:"agentProfiling,omitempty"`
+	AgentContainer           AgentContainer             `json:"agentContainer"`
+	AgentDNSPolicy           corev1.DNSPolicy           `json:"agentDNSPolicy,omitempty"`
+	AgentDNSConfig           *corev1.PodDNSConfig       `json:"agentDNSConfig,omitempty"`
+	InitContainerNamePrefix  string                     `json:"initContainerNamePrefix"`
+	InitContainerPosition    InitContainerPosition      `json:"initContainerPosition"`
+	InitContainerBefore      string                     `json:"initContainerBefore,omitempty"`
+	MinPodRequests           corev1.ResourceList        `json:"minPodRequests,omitempty"`
+	MinAgentVersions         map[string]string          `json:"minAgentVersions,omitempty"`
+	LanguageScheduling       map[string]Scheduling      `json:"languageScheduling,omitempty"`
+	ServiceNameLabels        []string                   `json:"serviceNameLabels,omitempty"`
+	ServiceVersionKeys       []string                   `json:"serviceVersionKeys,omitempty"`
+	AttributeLabels          []string                   `json:"attributeLabels,omitempty"`
+	NodeLabelAttributes      []NodeLabelAttribute       `json:"nodeLabelAttributes,omitempty"`
+	ExistingAgentEnvVars     []string                   `json:"existingAgentEnvVars,omitempty"`
+	StripEnvVars             []string                   `json:"stripEnvVars,omitempty"`
+	AgentEnvDefaults         []corev1.EnvVar            `json:"agentEnvDefaults,omitempty"`
+	AgentImageRollout        bool                       `json:"agentImageRollout,omitempty"`
+	PodAgentImages           bool                       `json:"podAgentImages,omitempty"`
+	NamespaceRollout         bool                       `json:"namespaceRollout,omitempty"`
+	AgentReadinessGate       corev1.PodConditionType    `json:"agentReadinessGate,omitempty"`
+	MaxLanguagesPerPod       *int                       `json:"maxLanguagesPerPod,omitempty"`
+	ComposeInstrumentations  bool                       `json:"composeInstrumentations,omitempty"`
+	ImageRepository          string                     `json:"imageRepository,omitempty"`
+	ImageChannel             string                     `json:"imageChannel,omitempty"`
+	Region                   Region                     `json:"region,omitempty"`
+	OTLPProtocol             OTLPProtocol               `json:"otlpProtocol,omitempty"`
+	OTLPClientCert           string                     `json:"otlpClientCert,omitempty"`
+	OTLPSignalEndpoints      OTLPSignalEndpoints        `json:"otlpSignalEndpoints,omitzero"`
+	InstrumentationSync      InstrumentationSyncPolicy  `json:"instrumentationSync,omitempty"`
+	ClusterName              string                     `json:"clusterName,omitempty"`
+	AgentProxy               AgentProxy                 `json:"agentProxy,omitzero"`
+	InjectionFailures        int                        `json:"injectionFailureThreshold,omitempty"`
+	MinGracePeriod           int                        `json:"minTerminationGracePeriod,omitempty"`
+	InstrumentationProvider  string                     `json:"instrumentationProvider"`
+	AgentEnvPrecedence       AgentEnvPrecedence         `json:"agentEnvPrecedence"`
+	MaxInjections            int                        `json:"maxConcurrentInjections,omitempty"`
+	AgentVolumeName          string                     `json:"agentVolumeName"`
+	AllowedImageRegistries   []string                   `json:"allowedImageRegistries,omitempty"`
+	InjectionQueueTimeout    metav1.Duration            `json:"injectionQueueTimeout"`
+	OpenShiftRoutesCooldown  metav1.Duration            `json:"openshiftRoutesCooldown"`
+	SecretCacheTTL           metav1.Duration            `json:"secretCacheTTL"`
+	SecretFailureThreshold   int                        `json:"secretFailureThreshold,omitempty"`
+	SecretCooldown           metav1.Duration            `json:"secretCooldown"`
+	SecretRetryAttempts      int                        `json:"secretRetryAttempts,omitempty"`
+	SecretRetryInterval      metav1.Duration            `json:"secretRetryInterval"`
+	FallbackLicenseKeySecret string                     `json:"fallbackLicenseKeySecret,omitempty"`
+	WebhookSelfCheckInterval metav1.Duration            `json:"webhookSelfCheckInterval"`
+}
+
+// Export is used to get the effective configuration as a portable JSON document, which Import turns back into options.
+// A GitOps pipeline can apply the same document to every cluster, and comparing the exported documents verifies that
+// clusters run the same configuration.
+func (c *Config) Export() ([]byte, error) {
+	c.mu.RLock()
+	doc := document{
+		Version:                  documentVersion,
+		LabelsFilter:             c.labelsFilter,
+		LabelsAllowList:          c.labelsAllowList,
+		LabelsFilterMode:         c.labelsFilterMode,
+		AutoDetectFrequency:      metav1.Duration{Duration: c.autoDetectFrequency},
+		AutoDetectJitter:         c.autoDetectJitter,
+		AutoDetectInitialDelay:   metav1.Duration{Duration: c.autoDetectInitialDelay},
+		FreezeAutoDetect:         c.freezeAutoDetect,
+		AgentInitDeadline:        metav1.Duration{Duration: c.agentInitDeadline},
+		AgentInitRunAsUser:       c.agentInitRunAsUser,
+		AgentInitRunAsGroup:      c.agentInitRunAsGroup,
+		AgentProfiling:           c.agentProfiling,
+		AgentContainer:           c.agentContainer,
+		AgentDNSPolicy:           c.agentDNSPolicy,
+		AgentDNSConfig:           c.agentDNSConfig,
+		InitContainerNamePrefix:  c.initContainerNamePrefix,
+		InitContainerPosition:    c.initContainerPosition,
+		InitContainerBefore:      c.initContainerBefore,
+		MinPodRequests:           c.minPodRequests,
+		MinAgentVersions:         c.minAgentVersions,
+		LanguageScheduling:       c.languageScheduling,
+		ServiceNameLabels:        c.serviceNameLabels,
+		ServiceVersionKeys:       c.serviceVersionKeys,
+		AttributeLabels:          c.attributeLabels,
+		NodeLabelAttributes:      c.nodeLabelAttributes,
+		ExistingAgentEnvVars:     c.existingAgentEnvVars,
+		StripEnvVars:             c.stripEnvVars,
+		AgentEnvDefaults:         c.agentEnvDefaults,
+		AgentImageRollout:        c.agentImageRollout,
+		PodAgentImages:           c.podAgentImages,
+		NamespaceRollout:         c.namespaceRollout,
+		AgentReadinessGate:       c.agentReadinessGate,
+		MaxLanguagesPerPod:       &c.maxLanguagesPerPod,
+		ComposeInstrumentations:  c.composeInstrumentations,
+		ImageRepository:          c.imageRepository,
+		ImageChannel:             c.imageChannel,
+		Region:                   c.region,
+		OTLPProtocol:             c.otlpProtocol,
+		OTLPClientCert:           c.otlpClientCert,
+		OTLPSignalEndpoints:      c.otlpSignalEndpoints,
+		InstrumentationSync:      c.instrumentationSync,
+		ClusterName:              c.clusterName,
+		AgentProxy:               c.agentProxy,
+		InjectionFailures:        c.injectionFailureThreshold,
+		MinGracePeriod:           c.minTerminationGracePeriod,
+		InstrumentationProvider:  c.instrumentationProvider,
+		AgentEnvPrecedence:       c.agentEnvPrecedence,
+		MaxInjections:            c.maxConcurrentInjections,
+		AgentVolumeName:          c.agentVolumeName,
+		AllowedImageRegistries:   c.allowedImageRegistries,
+		InjectionQueueTimeout:    metav1.Duration{Duration: c.injectionQueueTimeout},
+		OpenShiftRoutesCooldown:  metav1.Duration{Duration: c.openshiftRoutesCooldown},
+		SecretCacheTTL:           metav1.Duration{Duration: c.secretCacheTTL},
+		SecretFailureThreshold:   c.secretFailureThreshold,
+		SecretCooldown:           metav1.Duration{Duration: c.secretCooldown},
+		SecretRetryAttempts:      c.secretRetryAttempts,
+		SecretRetryInterval:      metav1.Duration{Duration: c.secretRetryInterval},
+		FallbackLicenseKeySecret: c.fallbackLicenseKeySecret,
+		WebhookSelfCheckInterval: metav1.Duration{Duration: c.webhookSelfCheckInterval},
+	}
+	c.mu.RUnlock()
+	if len(c.agentWarmup) > 0 {
+		doc.AgentWarmup = make(map[string]metav1.Duration, len(c.agentWarmup))
+		for language, warmup := range c.agentWarmup {
+			doc.AgentWarmup[language] = metav1.Duration{Duration: warmup}
+		}
+	}
+	if len(c.agentMountPropagation) > 0 {
+		doc.AgentMountPropagation = make(map[string]string, len(c.agentMountPropagation))
+		for language, mode := range c.agentMountPropagation {
+			doc.AgentMountPropagation[language] = string(mode)
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the configuration > %w", err)
+	}
+	return data, nil
+}
+
+// Import is used to turn a document from Export into the options recreating the configuration. Unknown fields are
+// rejected, so that a document from a newer operator isn't silently applied only in part.
+func Import(data []byte) ([]Option, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var doc document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode the configuration > %w", err)
+	}
+	if doc.Version != documentVersion {
+		return nil, fmt.Errorf("unsupported configuration version %d, expected %d", doc.Version, documentVersion)
+	}
+
+	opts := []Option{
+		WithLabelsFilter(doc.LabelsFilter),
+		WithLabelsAllowList(doc.LabelsAllowList),
+		WithAutoDetectJitter(doc.AutoDetectJitter),
+		WithAutoDetectInitialDelay(doc.AutoDetectInitialDelay.Duration),
+		WithFreezeAutoDetect(doc.FreezeAutoDetect),
+		WithAgentInitDeadline(doc.AgentInitDeadline.Duration),
+		WithAgentInitRunAs(doc.AgentInitRunAsUser, doc.AgentInitRunAsGroup),
+		WithAgentProfiling(doc.AgentProfiling),
+		WithAgentDNS(doc.AgentDNSPolicy, doc.AgentDNSConfig),
+		WithMinPodRequests(doc.MinPodRequests),
+		WithServiceNameLabels(doc.ServiceNameLabels),
+		WithServiceVersionKeys(doc.ServiceVersionKeys),
+		WithAttributeLabels(doc.AttributeLabels),
+		WithNodeLabelAttributes(doc.NodeLabelAttributes),
+		WithExistingAgentEnvVars(doc.ExistingAgentEnvVars),
+		WithStripEnvVars(doc.StripEnvVars),
+		WithAgentEnvDefaults(doc.AgentEnvDefaults),
+		WithAgentImageRollout(doc.AgentImageRollout),
+		WithPodAgentImages(doc.PodAgentImages),
+		WithNamespaceRollout(doc.NamespaceRollout),
+		WithAgentReadinessGate(doc.AgentReadinessGate),
+		WithComposeInstrumentations(doc.ComposeInstrumentations),
+		WithImageRepository(doc.ImageRepository),
+		WithImageChannel(doc.ImageChannel),
+		WithRegion(doc.Region),
+		WithOTLPProtocol(doc.OTLPProtocol),
+		WithOTLPClientCert(doc.OTLPClientCert),
+		WithOTLPSignalEndpoints(doc.OTLPSignalEndpoints),
+		WithInstrumentationSync(doc.InstrumentationSync),
+		WithClusterName(doc.ClusterName),
+		WithAgentProxy(doc.AgentProxy),
+		WithInjectionFailureThreshold(doc.InjectionFailures),
+		WithMinTerminationGracePeriod(doc.MinGracePeriod),
+		WithInstrumentationProvider(doc.InstrumentationProvider),
+		WithOpenShiftRoutesChangeCooldown(doc.OpenShiftRoutesCooldown.Duration),
+		WithSecretCacheTTL(doc.SecretCacheTTL.Duration),
+		WithSecretCircuitBreaker(doc.SecretFailureThreshold, doc.SecretCooldown.Duration),
+		WithSecretRetry(doc.SecretRetryAttempts, doc.SecretRetryInterval.Duration),
+		WithFallbackLicenseKeySecret(doc.FallbackLicenseKeySecret),
+		WithInjectionConcurrency(doc.MaxInjections, doc.InjectionQueueTimeout.Duration),
+		WithAgentVolumeName(doc.AgentVolumeName),
+		WithAllowedImageRegistries(doc.AllowedImageRegistries),
+		WithWebhookSelfCheckInterval(doc.WebhookSelfCheckInterval.Duration),
+		replaceLanguageOptions(),
+	}
+	if doc.MaxLanguagesPerPod != nil {
+		opts = append(opts, WithMaxLanguagesPerPod(*doc.MaxLanguagesPerPod))
+	}
+	if doc.AutoDetectFrequency.Duration > 0 {
+		opts = append(opts, WithAutoDetectFrequency(doc.AutoDetectFrequency.Duration))
+	}
+	if doc.InitContainerNamePrefix != "" {
+		opts = append(opts, WithInitContainerNamePrefix(doc.InitContainerNamePrefix))
+	}
+	if doc.AgentContainer.Position != "" {
+		opts = append(opts, WithAgentContainer(doc.AgentContainer))
+	}
+	if doc.InitContainerPosition != "" {
+		opts = append(opts, WithInitContainerInsertPosition(doc.InitContainerPosition, doc.InitContainerBefore))
+	}
+	if doc.LabelsFilterMode != "" {
+		opts = append(opts, WithLabelsFilterMode(doc.LabelsFilterMode))
+	}
+	if doc.AgentEnvPrecedence != "" {
+		opts = append(opts, WithAgentEnvPrecedence(doc.AgentEnvPrecedence))
+	}
+	for _, language := range slices.Sorted(maps.Keys(doc.LanguageScheduling)) {
+		opts = append(opts, WithLanguageScheduling(language, doc.LanguageScheduling[language]))
+	}
+	for _, language := range slices.Sorted(maps.Keys(doc.AgentWarmup)) {
+		opts = append(opts, WithAgentWarmup(language, doc.AgentWarmup[language].Duration))
+	}
+	for _, language := range slices.Sorted(maps.Keys(doc.MinAgentVersions)) {
+		opts = append(opts, WithMinAgentVersion(language, doc.MinAgentVersions[language]))
+	}
+	for _, language := range slices.Sorted(maps.Keys(doc.AgentMountPropagation)) {
+		opts = append(opts, WithAgentMountPropagation(language, corev1.MountPropagationMode(doc.AgentMountPropagation[language])))
+	}
+	return opts, nil
+}
+
+// replaceLanguageOptions clears the per language options, so that the imported ones replace, rather than add to, the
+// ones set before the import
+func replaceLanguageOptions() Option {
+	return func(o *options) {
+		o.languageScheduling = nil
+		o.agentWarmup = nil
+		o.minAgentVersions = nil
+		o.agentMountPropagation = nil
+	}
+}
@@ -0,0 +1,99 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestExportImport(t *testing.T) {
+	user := int64(1000)
+	cfg := config.New(
+		config.WithLabelsFilter([]string{"^internal"}),
+		config.WithLabelsAllowList([]string{"^app", "^team$"}),
+		config.WithLabelsFilterMode(config.LabelsFilterModeAllow),
+		config.WithAutoDetectFrequency(time.Minute),
+		config.WithAgentInitDeadline(2*time.Minute),
+		config.WithAgentInitRunAs(&user, nil),
+		config.WithAgentWarmup("java", 10*time.Second),
+		config.WithMinAgentVersion("java", "8.12.0"),
+		config.WithAgentMountPropagation("java", corev1.MountPropagationHostToContainer),
+		config.WithInitContainerInsertPosition(config.InitContainerPositionBefore, "istio-init"),
+		config.WithMinPodRequests(corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}),
+		config.WithLanguageScheduling("java", config.Scheduling{Tolerations: []corev1.Toleration{{Key: "agents", Operator: corev1.TolerationOpExists}}}),
+		config.WithLanguageScheduling("python", config.Scheduling{Tolerations: []corev1.Toleration{{Key: "python", Operator: corev1.TolerationOpExists}}}),
+		config.WithAttributeLabels([]string{"team"}),
+		config.WithNodeLabelAttributes([]config.NodeLabelAttribute{{Label: "topology.kubernetes.io/region", Attribute: "cloud.region"}}),
+		config.WithAgentEnvDefaults([]corev1.EnvVar{{Name: "NEW_RELIC_LOG_LEVEL", Value: "info"}}),
+		config.WithImageRepository("newrelic"),
+		config.WithImageChannel("stable"),
+		config.WithOTLPProtocol(config.OTLPProtocolGRPC),
+		config.WithOTLPClientCert("otlp-client-cert"),
+		config.WithOTLPSignalEndpoints(config.OTLPSignalEndpoints{Logs: "https://logs-collector:4318/v1/logs"}),
+		config.WithInstrumentationSync(config.InstrumentationSyncPolicyRestart),
+		config.WithClusterName("prod-us-east"),
+		config.WithAgentProxy(config.AgentProxy{URL: "http://proxy:3128", ClusterCIDRs: []string{"10.0.0.0/16"}}),
+		config.WithInjectionFailureThreshold(3),
+		config.WithMinTerminationGracePeriod(45),
+		config.WithInstrumentationProvider("platform-team"),
+		config.WithAgentEnvPrecedence(config.AgentEnvPrecedenceAnnotation),
+		config.WithAgentVolumeName("acme-newrelic-agent"),
+		config.WithAllowedImageRegistries([]string{"docker.io/newrelic", "registry.example.com/apm"}),
+		config.WithPodAgentImages(true),
+		config.WithServiceVersionKeys([]string{"app.kubernetes.io/version", "deployment.kubernetes.io/revision"}),
+		config.WithInjectionConcurrency(20, 2*time.Second),
+		config.WithSecretCircuitBreaker(3, time.Minute),
+		config.WithSecretRetry(2, 250*time.Millisecond),
+		config.WithFallbackLicenseKeySecret("newrelic-key-secret-fallback"),
+	)
+	document, err := cfg.Export()
+	require.NoError(t, err)
+
+	// a cluster started with other values converges on the imported ones
+	opts, err := config.Import(document)
+	require.NoError(t, err)
+	other := config.New(append([]config.Option{
+		config.WithLanguageScheduling("ruby", config.Scheduling{Tolerations: []corev1.Toleration{{Key: "ruby"}}}),
+		config.WithAgentWarmup("nodejs", time.Second),
+		config.WithImageChannel("canary"),
+	}, opts...)...)
+	otherDocument, err := other.Export()
+	require.NoError(t, err)
+	assert.Equal(t, string(document), string(otherDocument))
+
+	assert.Equal(t, 10*time.Second, other.AgentWarmup("java"))
+	assert.Equal(t, "8.12.0", other.MinAgentVersion("java"))
+	assert.Zero(t, other.AgentWarmup("nodejs"))
+	assert.Empty(t, other.LanguageScheduling("ruby"))
+	_, channel := other.ImageChannel()
+	assert.Equal(t, "stable", channel)
+
+	// the export is deterministic
+	again, err := cfg.Export()
+	require.NoError(t, err)
+	assert.Equal(t, string(document), string(again))
+}
+
+func TestImport_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		errStr   string
+	}{
+		{name: "malformed", document: `{`, errStr: "failed to decode the configuration > unexpected EOF"},
+		{name: "unknown field", document: `{"version":1,"unknown":true}`, errStr: `failed to decode the configuration > json: unknown field "unknown"`},
+		{name: "unsupported version", document: `{"version":2}`, errStr: "unsupported configuration version 2, expected 1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := config.Import([]byte(test.document))
+			assert.EqualError(t, err, test.errStr)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, 5*time.Second, jitter(5*time.Second, 0))
+
+	for i := 0; i < 100; i++ {
+		d := jitter(5*time.Second, 0.1)
+		assert.GreaterOrEqual(t, d, 4500*time.Millisecond)
+		assert.LessOrEqual(t, d, 5500*time.Millisecond)
+	}
+}
+
+func TestJitter_FractionOfOneOrMore(t *testing.T) {
+	for _, fraction := range []float64{1, 1.5, 10} {
+		for i := 0; i < 100; i++ {
+			assert.Greater(t, jitter(5*time.Second, fraction), time.Duration(0), "fraction %v", fraction)
+		}
+	}
+}
+
+func TestWithAutoDetectJitter_Clamped(t *testing.T) {
+	for fraction, expected := range map[float64]float64{-1: 0, 0.5: 0.5, 1: maxAutoDetectJitter, 3: maxAutoDetectJitter} {
+		var o options
+		WithAutoDetectJitter(fraction)(&o)
+		assert.Equal(t, expected, o.autoDetectJitter, "fraction %v", fraction)
+	}
+}
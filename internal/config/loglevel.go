@@ -0,0 +1,67 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevelPath is the path the log level handler is served on
+const LogLevelPath = "/debug/loglevel"
+
+// LogLevel is the verbosity of the operator logs, where the logs up to V(Level) are written. 0 only writes the info
+// logs.
+type LogLevel struct {
+	Level int `json:"level"`
+}
+
+// LogLevelHandler serves the verbosity of the operator logs as json, and changes it when a LogLevel is posted, so that
+// the V(n) logs can be captured without a restart. The atomic level is the one of the shared zap logger.
+func LogLevelHandler(level zap.AtomicLevel, logger logr.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var logLevel LogLevel
+			if err := json.NewDecoder(r.Body).Decode(&logLevel); err != nil {
+				http.Error(w, fmt.Sprintf("invalid log level > %s", err), http.StatusBadRequest)
+				return
+			}
+			if logLevel.Level < 0 || logLevel.Level > math.MaxInt8 {
+				http.Error(w, fmt.Sprintf("invalid log level %d, must be between 0 and %d", logLevel.Level, math.MaxInt8), http.StatusBadRequest)
+				return
+			}
+			level.SetLevel(zapcore.Level(-logLevel.Level))
+			logger.Info("changed the log level", "level", logLevel.Level)
+		default:
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(LogLevel{Level: max(0, -int(level.Level()))}); err != nil {
+			logger.Error(err, "failed to write the log level")
+		}
+	})
+}
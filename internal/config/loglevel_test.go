@@ -0,0 +1,42 @@
+package config_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	level := zap.NewAtomicLevel()
+	handler := config.LogLevelHandler(level, logr.Discard())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, config.LogLevelPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":0}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, config.LogLevelPath, strings.NewReader(`{"level":4}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":4}`, rec.Body.String())
+	assert.True(t, level.Enabled(-4))
+	assert.False(t, level.Enabled(-5))
+
+	for _, body := range []string{`{"level":-1}`, `{"level":"debug"}`} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, config.LogLevelPath, strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+	assert.True(t, level.Enabled(-4))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, config.LogLevelPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -17,59 +17,380 @@ limitations under the License.
 package config
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
 	"github.com/newrelic/k8s-agents-operator/internal/version"
 )
 
 const (
-	defaultAutoDetectFrequency = 5 * time.Second
+	defaultAutoDetectFrequency     = 5 * time.Second
+	forbiddenRetryInterval         = 5 * time.Minute
+	defaultInitContainerNamePrefix = "newrelic-instrumentation"
+	defaultAgentVolumeName         = "newrelic-instrumentation"
+	defaultMaxLanguagesPerPod      = 3
+
+	// DefaultInstrumentationProvider identifies the agents injected by the operator in the metadata they report
+	DefaultInstrumentationProvider = "k8s-agents-operator"
+)
+
+// InitContainerPosition is where among the pod's existing init containers the agent init containers are inserted.
+type InitContainerPosition string
+
+const (
+	// InitContainerPositionFirst inserts the agent init containers before all the other init containers.
+	InitContainerPositionFirst InitContainerPosition = "first"
+	// InitContainerPositionLast inserts the agent init containers after all the other init containers.
+	InitContainerPositionLast InitContainerPosition = "last"
+	// InitContainerPositionBefore inserts the agent init containers before the named init container, or last when the
+	// pod doesn't have it.
+	InitContainerPositionBefore InitContainerPosition = "before"
+)
+
+// AgentContainerPosition is which container of a pod the agents are injected into, unless they were injected into one
+// before.
+type AgentContainerPosition string
+
+const (
+	// AgentContainerPositionFirst injects the agents into the first container of the pod.
+	AgentContainerPositionFirst AgentContainerPosition = "first"
+	// AgentContainerPositionLast injects the agents into the last container of the pod.
+	AgentContainerPositionLast AgentContainerPosition = "last"
+	// AgentContainerPositionNamed injects the agents into the named container, or the first one when the pod doesn't
+	// have it.
+	AgentContainerPositionNamed AgentContainerPosition = "name"
+)
+
+// AgentContainer is the container of a pod the agents are injected into. The zero value is the first container.
+type AgentContainer struct {
+	Position AgentContainerPosition `json:"position,omitempty"`
+	// Name is the container name, only set for AgentContainerPositionNamed.
+	Name string `json:"name,omitempty"`
+}
+
+// NodeLabelAttribute is a label of the node a pod is scheduled on, reported by the agents of the pod as the attribute.
+type NodeLabelAttribute struct {
+	// Label is the key of the node label.
+	Label string `json:"label"`
+	// Attribute is the name of the attribute, the label key when empty.
+	Attribute string `json:"attribute,omitempty"`
+}
+
+// OTLPProtocol is the transport used by the OpenTelemetry based agents to export to the collector.
+type OTLPProtocol string
+
+const (
+	// OTLPProtocolGRPC exports OTLP over gRPC.
+	OTLPProtocolGRPC OTLPProtocol = "grpc"
+	// OTLPProtocolHTTPProtobuf exports OTLP over HTTP, encoded as protobuf.
+	OTLPProtocolHTTPProtobuf OTLPProtocol = "http/protobuf"
+)
+
+// OTLPSignalEndpoints are the urls the OpenTelemetry based agents export each signal to, overriding the combined
+// endpoint for the signal.
+type OTLPSignalEndpoints struct {
+	Traces  string `json:"traces,omitempty"`
+	Metrics string `json:"metrics,omitempty"`
+	Logs    string `json:"logs,omitempty"`
+}
+
+// AgentProxy is the proxy the agents send their telemetry through, and the in-cluster hosts they reach without it.
+type AgentProxy struct {
+	// URL is the proxy set as the NEW_RELIC_PROXY_* settings of the agents of the containers setting none
+	URL string `json:"url,omitempty"`
+	// NoProxy are the hosts, domains and CIDRs added to the default NO_PROXY entries
+	NoProxy []string `json:"noProxy,omitempty"`
+	// ClusterDomain is the DNS domain of the cluster, cluster.local when empty
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+	// ClusterCIDRs are the pod and service CIDRs of the cluster
+	ClusterCIDRs []string `json:"clusterCIDRs,omitempty"`
+}
+
+// InstrumentationSyncPolicy is how the pods injected by an instrumentation are kept in sync with it when it changes.
+type InstrumentationSyncPolicy string
+
+const (
+	// InstrumentationSyncPolicyAnnotate fixes the agent images annotation of the pods to match their agent init container.
+	InstrumentationSyncPolicyAnnotate InstrumentationSyncPolicy = "annotate"
+	// InstrumentationSyncPolicyRestart also restarts the workloads of the pods injected by an older generation.
+	InstrumentationSyncPolicyRestart InstrumentationSyncPolicy = "restart"
+)
+
+// AgentEnvPrecedence is which of the instrumentation and the pod annotation env vars wins when both set a name.
+type AgentEnvPrecedence string
+
+const (
+	// AgentEnvPrecedenceInstrumentation keeps the instrumentation env vars, the annotation only adding the other ones.
+	AgentEnvPrecedenceInstrumentation AgentEnvPrecedence = "instrumentation"
+	// AgentEnvPrecedenceAnnotation lets the annotation env vars override the instrumentation ones.
+	AgentEnvPrecedenceAnnotation AgentEnvPrecedence = "annotation"
 )
 
-// Config holds the static configuration for this operator.
+// LabelsFilterMode is how the labels propagated to the agents are filtered.
+type LabelsFilterMode string
+
+const (
+	// LabelsFilterModeDeny propagates every label but the ones matching the labels filter.
+	LabelsFilterModeDeny LabelsFilterMode = "deny"
+	// LabelsFilterModeAllow only propagates the labels matching the labels allow-list, and then drops the ones matching
+	// the labels filter.
+	LabelsFilterModeAllow LabelsFilterMode = "allow"
+)
+
+// Region is the New Relic region the agents report to. The agents pick it from the license key when it isn't set.
+type Region string
+
+const (
+	// RegionUS reports to the US region.
+	RegionUS Region = "us"
+	// RegionEU reports to the EU region.
+	RegionEU Region = "eu"
+	// RegionFedRAMP reports to the FedRAMP endpoints of the US region, which the license key doesn't tell apart.
+	RegionFedRAMP Region = "fedramp"
+)
+
+// labelsAllowListFilter is the filter reported for the labels dropped for not matching the labels allow-list
+const labelsAllowListFilter = "labels allow-list"
+
+const (
+	detectionOpenShiftRoutes = "openshift_routes"
+	detectionVPA             = "vpa"
+	detectionHPA             = "hpa"
+	detectionNativeSidecars  = "native_sidecars"
+	detectionSleepAction     = "sleep_action"
+	detectionKnative         = "knative"
+)
+
+// Config holds the configuration for this operator.
 type Config struct {
-	autoDetect              autodetect.AutoDetect
-	logger                  logr.Logger
-	onOpenShiftRoutesChange changeHandler
-	labelsFilter            []string
-	openshiftRoutes         openshiftRoutesStore
-	autoDetectFrequency     time.Duration
-	autoscalingVersion      autodetect.AutoscalingVersion
+	autoDetect                 autodetect.AutoDetect
+	logger                     logr.Logger
+	eventRecorder              record.EventRecorder
+	eventObject                runtime.Object
+	onOpenShiftRoutesChange    changeHandler
+	onVPAChange                changeHandler
+	onAutoscalingVersionChange changeHandler
+	onConfigChange             changeHandler
+	mu                         *sync.RWMutex
+	defaults                   options
+	forbidden                  map[string]time.Time
+	redetect                   chan struct{}
+	labelsFilter               []string
+	labelsFilterRegexps        []*regexp.Regexp
+	labelsAllowList            []string
+	labelsAllowListRegexps     []*regexp.Regexp
+	labelsFilterMode           LabelsFilterMode
+	openshiftRoutes            openshiftRoutesStore
+	vpa                        vpaStore
+	autoDetectFrequency        time.Duration
+	autoDetectJitter           float64
+	autoDetectInitialDelay     time.Duration
+	autoscalingVersion         autodetect.AutoscalingVersion
+	nativeSidecars             autodetect.NativeSidecarsAvailability
+	sleepAction                autodetect.SleepActionAvailability
+	knative                    autodetect.KnativeAvailability
+	agentInitDeadline          time.Duration
+	initContainerNamePrefix    string
+	minPodRequests             corev1.ResourceList
+	languageScheduling         map[string]Scheduling
+	serviceNameLabels          []string
+	serviceVersionKeys         []string
+	initContainerPosition      InitContainerPosition
+	initContainerBefore        string
+	agentImageRollout          bool
+	podAgentImages             bool
+	secretCacheTTL             time.Duration
+	secretFailureThreshold     int
+	secretCooldown             time.Duration
+	secretRetryAttempts        int
+	secretRetryInterval        time.Duration
+	fallbackLicenseKeySecret   string
+	existingAgentEnvVars       []string
+	attributeLabels            []string
+	webhookSelfCheckInterval   time.Duration
+	imageRepository            string
+	imageChannel               string
+	region                     Region
+	otlpProtocol               OTLPProtocol
+	agentEnvDefaults           []corev1.EnvVar
+	composeInstrumentations    bool
+	agentInitRunAsUser         *int64
+	agentInitRunAsGroup        *int64
+	freezeAutoDetect           bool
+	agentWarmup                map[string]time.Duration
+	metricsRegistry            prometheus.Registerer
+	firstDetect                chan struct{}
+	firstDetectOnce            *sync.Once
+	minAgentVersions           map[string]string
+	agentProfiling             bool
+	openshiftRoutesCooldown    time.Duration
+	agentDNSPolicy             corev1.DNSPolicy
+	agentDNSConfig             *corev1.PodDNSConfig
+	agentContainer             AgentContainer
+	namespaceRollout           bool
+	agentReadinessGate         corev1.PodConditionType
+	maxLanguagesPerPod         int
+	stripEnvVars               []string
+	otlpClientCert             string
+	nodeLabelAttributes        []NodeLabelAttribute
+	otlpSignalEndpoints        OTLPSignalEndpoints
+	instrumentationSync        InstrumentationSyncPolicy
+	clusterName                string
+	agentProxy                 AgentProxy
+	injectionFailureThreshold  int
+	minTerminationGracePeriod  int
+	instrumentationProvider    string
+	agentEnvPrecedence         AgentEnvPrecedence
+	maxConcurrentInjections    int
+	injectionQueueTimeout      time.Duration
+	agentVolumeName            string
+	allowedImageRegistries     []string
+	agentMountPropagation      map[string]corev1.MountPropagationMode
+}
+
+// Scheduling is the node affinity requirements and tolerations added to the pods instrumented for a language.
+type Scheduling struct {
+	RequiredNodeAffinity []corev1.NodeSelectorRequirement `json:"requiredNodeAffinity,omitempty"`
+	Tolerations          []corev1.Toleration              `json:"tolerations,omitempty"`
 }
 
 // New constructs a new configuration based on the given options.
 func New(opts ...Option) Config {
 	// initialize with the default values
 	o := options{
-		autoDetectFrequency:     defaultAutoDetectFrequency,
-		logger:                  logf.Log.WithName("config"),
-		openshiftRoutes:         newOpenShiftRoutesWrapper(),
-		version:                 version.Get(),
-		autoscalingVersion:      autodetect.DefaultAutoscalingVersion,
-		onOpenShiftRoutesChange: newOnChange(),
+		autoDetectFrequency:        defaultAutoDetectFrequency,
+		initContainerNamePrefix:    defaultInitContainerNamePrefix,
+		agentVolumeName:            defaultAgentVolumeName,
+		initContainerPosition:      InitContainerPositionLast,
+		agentContainer:             AgentContainer{Position: AgentContainerPositionFirst},
+		maxLanguagesPerPod:         defaultMaxLanguagesPerPod,
+		instrumentationProvider:    DefaultInstrumentationProvider,
+		agentEnvPrecedence:         AgentEnvPrecedenceInstrumentation,
+		labelsFilterMode:           LabelsFilterModeDeny,
+		logger:                     logf.Log.WithName("config"),
+		openshiftRoutes:            newOpenShiftRoutesWrapper(),
+		vpa:                        newVPAWrapper(),
+		version:                    version.Get(),
+		autoscalingVersion:         autodetect.DefaultAutoscalingVersion,
+		nativeSidecars:             autodetect.DefaultNativeSidecarsAvailability,
+		sleepAction:                autodetect.DefaultSleepActionAvailability,
+		knative:                    autodetect.DefaultKnativeAvailability,
+		onOpenShiftRoutesChange:    newOnChange(),
+		onVPAChange:                newOnChange(),
+		onAutoscalingVersionChange: newOnChange(),
+		onConfigChange:             newOnChange(),
+		metricsRegistry:            metrics.Registry,
 	}
 	for _, opt := range opts {
 		opt(&o)
 	}
 
 	return Config{
-		autoDetect:              o.autoDetect,
-		autoDetectFrequency:     o.autoDetectFrequency,
-		logger:                  o.logger,
-		openshiftRoutes:         o.openshiftRoutes,
-		onOpenShiftRoutesChange: o.onOpenShiftRoutesChange,
-		labelsFilter:            o.labelsFilter,
-		autoscalingVersion:      o.autoscalingVersion,
+		autoDetect:                 o.autoDetect,
+		autoDetectFrequency:        o.autoDetectFrequency,
+		autoDetectJitter:           o.autoDetectJitter,
+		autoDetectInitialDelay:     o.autoDetectInitialDelay,
+		logger:                     o.logger,
+		eventRecorder:              o.eventRecorder,
+		eventObject:                o.eventObject,
+		openshiftRoutes:            o.openshiftRoutes,
+		onOpenShiftRoutesChange:    newCooldownOnChange(o.onOpenShiftRoutesChange, o.openshiftRoutesCooldown),
+		vpa:                        o.vpa,
+		onVPAChange:                o.onVPAChange,
+		onAutoscalingVersionChange: o.onAutoscalingVersionChange,
+		onConfigChange:             o.onConfigChange,
+		mu:                         &sync.RWMutex{},
+		defaults:                   o,
+		forbidden:                  make(map[string]time.Time),
+		redetect:                   make(chan struct{}, 1),
+		labelsFilter:               o.labelsFilter,
+		labelsFilterRegexps:        compileLabelsFilter(o.logger, o.labelsFilter),
+		labelsAllowList:            o.labelsAllowList,
+		labelsAllowListRegexps:     compileLabelsFilter(o.logger, o.labelsAllowList),
+		labelsFilterMode:           o.labelsFilterMode,
+		autoscalingVersion:         o.autoscalingVersion,
+		nativeSidecars:             o.nativeSidecars,
+		sleepAction:                o.sleepAction,
+		knative:                    o.knative,
+		agentInitDeadline:          o.agentInitDeadline,
+		initContainerNamePrefix:    o.initContainerNamePrefix,
+		minPodRequests:             o.minPodRequests,
+		languageScheduling:         o.languageScheduling,
+		serviceNameLabels:          o.serviceNameLabels,
+		serviceVersionKeys:         o.serviceVersionKeys,
+		initContainerPosition:      o.initContainerPosition,
+		initContainerBefore:        o.initContainerBefore,
+		agentImageRollout:          o.agentImageRollout,
+		podAgentImages:             o.podAgentImages,
+		secretCacheTTL:             o.secretCacheTTL,
+		secretFailureThreshold:     o.secretFailureThreshold,
+		secretCooldown:             o.secretCooldown,
+		secretRetryAttempts:        o.secretRetryAttempts,
+		secretRetryInterval:        o.secretRetryInterval,
+		fallbackLicenseKeySecret:   o.fallbackLicenseKeySecret,
+		existingAgentEnvVars:       o.existingAgentEnvVars,
+		attributeLabels:            o.attributeLabels,
+		webhookSelfCheckInterval:   o.webhookSelfCheckInterval,
+		imageRepository:            o.imageRepository,
+		imageChannel:               o.imageChannel,
+		region:                     o.region,
+		otlpProtocol:               o.otlpProtocol,
+		agentEnvDefaults:           o.agentEnvDefaults,
+		composeInstrumentations:    o.composeInstrumentations,
+		agentInitRunAsUser:         o.agentInitRunAsUser,
+		agentInitRunAsGroup:        o.agentInitRunAsGroup,
+		freezeAutoDetect:           o.freezeAutoDetect,
+		agentWarmup:                o.agentWarmup,
+		metricsRegistry:            o.metricsRegistry,
+		firstDetect:                make(chan struct{}),
+		firstDetectOnce:            &sync.Once{},
+		minAgentVersions:           o.minAgentVersions,
+		agentProfiling:             o.agentProfiling,
+		openshiftRoutesCooldown:    o.openshiftRoutesCooldown,
+		agentDNSPolicy:             o.agentDNSPolicy,
+		agentDNSConfig:             o.agentDNSConfig,
+		agentContainer:             o.agentContainer,
+		namespaceRollout:           o.namespaceRollout,
+		agentReadinessGate:         o.agentReadinessGate,
+		maxLanguagesPerPod:         o.maxLanguagesPerPod,
+		stripEnvVars:               o.stripEnvVars,
+		otlpClientCert:             o.otlpClientCert,
+		nodeLabelAttributes:        o.nodeLabelAttributes,
+		otlpSignalEndpoints:        o.otlpSignalEndpoints,
+		instrumentationSync:        o.instrumentationSync,
+		clusterName:                o.clusterName,
+		agentProxy:                 o.agentProxy,
+		injectionFailureThreshold:  o.injectionFailureThreshold,
+		minTerminationGracePeriod:  o.minTerminationGracePeriod,
+		instrumentationProvider:    o.instrumentationProvider,
+		agentEnvPrecedence:         o.agentEnvPrecedence,
+		maxConcurrentInjections:    o.maxConcurrentInjections,
+		agentVolumeName:            o.agentVolumeName,
+		injectionQueueTimeout:      o.injectionQueueTimeout,
+		allowedImageRegistries:     o.allowedImageRegistries,
+		agentMountPropagation:      o.agentMountPropagation,
 	}
 }
 
 // StartAutoDetect attempts to automatically detect relevant information for this operator. This will block until the first
-// run is executed and will schedule periodic updates.
+// run is executed and will schedule periodic updates, the first of which is delayed by the auto-detect initial delay.
+// TriggerAutoDetect runs an update right away.
 func (c *Config) StartAutoDetect() error {
 	err := c.AutoDetect()
 	go c.periodicAutoDetect()
@@ -77,65 +398,711 @@ func (c *Config) StartAutoDetect() error {
 	return err
 }
 
+// WaitForFirstDetect blocks until the first auto-detection that applied anything, or auto-detection being frozen, so
+// that what depends on the detected state, such as the controllers, doesn't act on the defaults. An error is returned
+// when the context is done first.
+func (c *Config) WaitForFirstDetect(ctx context.Context) error {
+	select {
+	case <-c.firstDetect:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (c *Config) periodicAutoDetect() {
-	ticker := time.NewTicker(c.autoDetectFrequency)
+	timer := time.NewTimer(c.autoDetectInitialDelay + jitter(c.AutoDetectFrequency(), c.autoDetectJitter))
 
-	for range ticker.C {
+	for {
+		select {
+		case <-timer.C:
+		case <-c.redetect:
+			c.logger.Info("auto-detection triggered")
+		}
 		if err := c.AutoDetect(); err != nil {
 			c.logger.Info("auto-detection failed", "error", err)
 		}
+		timer.Reset(jitter(c.AutoDetectFrequency(), c.autoDetectJitter))
 	}
 }
 
-// AutoDetect attempts to automatically detect relevant information for this operator.
+// maxAutoDetectJitter is the highest auto-detect jitter fraction, below 1 so that the jittered frequency stays positive
+const maxAutoDetectJitter = 0.99
+
+// jitter returns a duration randomly spread by up to ±fraction of d, so that replicas don't all hit the api server at
+// the same time. The fraction is capped at maxAutoDetectJitter, so the duration is never zero or negative.
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	fraction = min(fraction, maxAutoDetectJitter)
+	return d + time.Duration(fraction*(2*rand.Float64()-1)*float64(d))
+}
+
+// Reload replaces the reloadable configuration (labels filter, labels allow-list and filter mode, auto-detect
+// frequency, agent init deadline, agent image repository and channel, region and freeze auto-detect) with the values
+// the config was created with, overridden by the given options. The config change callbacks are called if anything
+// changed. The init container name prefix isn't reloadable, as the pods injected before would no longer be recognized.
+func (c *Config) Reload(opts ...Option) error {
+	o := c.defaults
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c.mu.Lock()
+	changed := !slices.Equal(c.labelsFilter, o.labelsFilter) ||
+		!slices.Equal(c.labelsAllowList, o.labelsAllowList) ||
+		c.labelsFilterMode != o.labelsFilterMode ||
+		c.autoDetectFrequency != o.autoDetectFrequency ||
+		c.agentInitDeadline != o.agentInitDeadline ||
+		c.imageRepository != o.imageRepository ||
+		c.imageChannel != o.imageChannel ||
+		c.region != o.region ||
+		c.freezeAutoDetect != o.freezeAutoDetect
+	c.labelsFilter = o.labelsFilter
+	c.labelsFilterRegexps = compileLabelsFilter(c.logger, o.labelsFilter)
+	c.labelsAllowList = o.labelsAllowList
+	c.labelsAllowListRegexps = compileLabelsFilter(c.logger, o.labelsAllowList)
+	c.labelsFilterMode = o.labelsFilterMode
+	c.autoDetectFrequency = o.autoDetectFrequency
+	c.agentInitDeadline = o.agentInitDeadline
+	c.imageRepository = o.imageRepository
+	c.imageChannel = o.imageChannel
+	c.region = o.region
+	c.freezeAutoDetect = o.freezeAutoDetect
+	c.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	c.logger.V(1).Info("configuration reloaded")
+	return c.onConfigChange.Do()
+}
+
+// AutoDetect attempts to automatically detect relevant information for this operator. Every detection is attempted
+// and each successful one is applied, so a failing detection doesn't hold back the others, nor the first-detect waiters
+// once any detection succeeded. The failures are returned joined.
 func (c *Config) AutoDetect() error {
+	if c.FreezeAutoDetect() {
+		c.logger.V(2).Info("auto-detection is frozen, keeping the last detected configuration")
+		c.firstDetectOnce.Do(func() { close(c.firstDetect) })
+		return nil
+	}
 	c.logger.V(2).Info("auto-detecting the configuration based on the environment")
 
+	detections := []struct {
+		name   string
+		detect func() error
+	}{
+		{"the openshift routes", c.detectOpenShiftRoutes},
+		{"the vertical pod autoscaler", c.detectVPA},
+		{"the autoscaling version", c.detectHPA},
+		{"the native sidecars", c.detectNativeSidecars},
+		{"the sleep action", c.detectSleepAction},
+		{"knative", c.detectKnative},
+	}
+	var errs []error
+	for _, d := range detections {
+		if err := d.detect(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to detect %s > %w", d.name, err))
+		}
+	}
+	if len(errs) < len(detections) {
+		c.firstDetectOnce.Do(func() { close(c.firstDetect) })
+	}
+	return errors.Join(errs...)
+}
+
+// detectOpenShiftRoutes is used to detect the availability of the OpenShift Routes API, leaving it unchanged on error
+func (c *Config) detectOpenShiftRoutes() error {
+	if c.skipForbidden(detectionOpenShiftRoutes) {
+		return nil
+	}
 	ora, err := c.autoDetect.OpenShiftRoutesAvailability()
-	if err != nil {
+	if c.checkForbidden(detectionOpenShiftRoutes, err) {
+		ora = autodetect.OpenShiftRoutesNotAvailable
+	} else if err != nil {
 		return err
 	}
 
 	if c.openshiftRoutes.Get() != ora {
 		c.logger.V(1).Info("openshift routes detected", "available", ora)
 		c.openshiftRoutes.Set(ora)
+		c.recordDetectionChange(reasonOpenShiftRoutesChanged, "OpenShift routes detected as %s", ora)
 		if err = c.onOpenShiftRoutesChange.Do(); err != nil {
 			// Don't fail if the callback failed, as auto-detection itself worked.
 			c.logger.Error(err, "configuration change notification failed for callback")
 		}
 	}
+	return nil
+}
+
+// detectVPA is used to detect the availability of the Vertical Pod Autoscaler API, leaving it unchanged on error
+func (c *Config) detectVPA() error {
+	if c.skipForbidden(detectionVPA) {
+		return nil
+	}
+	vpa, err := c.autoDetect.VPAAvailability()
+	if c.checkForbidden(detectionVPA, err) {
+		vpa = autodetect.VPANotAvailable
+	} else if err != nil {
+		return err
+	}
+
+	if c.vpa.Get() != vpa {
+		c.logger.V(1).Info("vertical pod autoscaler detected", "available", vpa)
+		c.vpa.Set(vpa)
+		c.recordDetectionChange(reasonVPAChanged, "Vertical pod autoscaler detected as %s", vpa)
+		if err = c.onVPAChange.Do(); err != nil {
+			// Don't fail if the callback failed, as auto-detection itself worked.
+			c.logger.Error(err, "configuration change notification failed for callback")
+		}
+	}
+	return nil
+}
 
+// detectHPA is used to detect the autoscaling version, leaving it unchanged on error
+func (c *Config) detectHPA() error {
+	if c.skipForbidden(detectionHPA) {
+		return nil
+	}
 	hpaVersion, err := c.autoDetect.HPAVersion()
-	if err != nil {
+	if c.checkForbidden(detectionHPA, err) {
+		hpaVersion = autodetect.DefaultAutoscalingVersion
+	} else if err != nil {
 		return err
 	}
+	c.mu.Lock()
+	changed := c.autoscalingVersion != hpaVersion
 	c.autoscalingVersion = hpaVersion
-	c.logger.V(2).Info("autoscaling version detected", "autoscaling-version", c.autoscalingVersion.String())
+	c.mu.Unlock()
+	c.logger.V(2).Info("autoscaling version detected", "autoscaling-version", hpaVersion.String())
+	if changed {
+		c.logger.V(1).Info("autoscaling version changed", "autoscaling-version", hpaVersion.String())
+		c.recordDetectionChange(reasonAutoscalingVersionChanged, "Autoscaling version detected as %s", hpaVersion)
+		if err = c.onAutoscalingVersionChange.Do(); err != nil {
+			// Don't fail if the callback failed, as auto-detection itself worked.
+			c.logger.Error(err, "configuration change notification failed for callback")
+		}
+	}
+	return nil
+}
 
+// detectNativeSidecars is used to detect whether the cluster runs native sidecars, leaving it unchanged on error
+func (c *Config) detectNativeSidecars() error {
+	if c.skipForbidden(detectionNativeSidecars) {
+		return nil
+	}
+	nativeSidecars, err := c.autoDetect.NativeSidecarsAvailability()
+	if c.checkForbidden(detectionNativeSidecars, err) {
+		nativeSidecars = autodetect.DefaultNativeSidecarsAvailability
+	} else if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	changed := c.nativeSidecars != nativeSidecars
+	c.nativeSidecars = nativeSidecars
+	c.mu.Unlock()
+	if changed {
+		c.logger.V(1).Info("native sidecars detected", "available", nativeSidecars)
+		c.recordDetectionChange(reasonNativeSidecarsChanged, "Native sidecars detected as %s", nativeSidecars)
+	}
 	return nil
 }
 
+// detectSleepAction is used to detect whether the cluster enables the sleep action of the lifecycle hooks, leaving it
+// unchanged on error
+func (c *Config) detectSleepAction() error {
+	if c.skipForbidden(detectionSleepAction) {
+		return nil
+	}
+	sleepAction, err := c.autoDetect.SleepActionAvailability()
+	if c.checkForbidden(detectionSleepAction, err) {
+		sleepAction = autodetect.DefaultSleepActionAvailability
+	} else if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	changed := c.sleepAction != sleepAction
+	c.sleepAction = sleepAction
+	c.mu.Unlock()
+	if changed {
+		c.logger.V(1).Info("sleep action detected", "available", sleepAction)
+		c.recordDetectionChange(reasonSleepActionChanged, "Sleep action detected as %s", sleepAction)
+	}
+	return nil
+}
+
+// detectKnative is used to detect the availability of the Knative Serving API, leaving it unchanged on error
+func (c *Config) detectKnative() error {
+	if c.skipForbidden(detectionKnative) {
+		return nil
+	}
+	knative, err := c.autoDetect.KnativeAvailability()
+	if c.checkForbidden(detectionKnative, err) {
+		knative = autodetect.DefaultKnativeAvailability
+	} else if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	changed := c.knative != knative
+	c.knative = knative
+	c.mu.Unlock()
+	if changed {
+		c.logger.V(1).Info("knative detected", "available", knative)
+		c.recordDetectionChange(reasonKnativeChanged, "Knative detected as %s", knative)
+	}
+	return nil
+}
+
+// skipForbidden is used to check if a detection was forbidden recently, in which case it isn't retried until
+// forbiddenRetryInterval has passed.
+func (c *Config) skipForbidden(detection string) bool {
+	c.mu.RLock()
+	forbiddenAt, ok := c.forbidden[detection]
+	c.mu.RUnlock()
+	return ok && time.Since(forbiddenAt) < forbiddenRetryInterval
+}
+
+// checkForbidden records if a detection was forbidden by RBAC. A warning is only logged when a detection becomes
+// forbidden, instead of on every run, and the state is reported by the autodetect forbidden metric.
+func (c *Config) checkForbidden(detection string, err error) bool {
+	forbidden := apierrors.IsForbidden(err)
+	c.mu.Lock()
+	_, wasForbidden := c.forbidden[detection]
+	if forbidden {
+		c.forbidden[detection] = time.Now()
+	} else {
+		delete(c.forbidden, detection)
+	}
+	c.mu.Unlock()
+
+	if forbidden && !wasForbidden {
+		c.logger.Info("auto-detection is forbidden, using the default until it's permitted", "detection", detection, "error", err.Error())
+		autoDetectForbidden.WithLabelValues(detection).Set(1)
+	} else if !forbidden && wasForbidden {
+		c.logger.Info("auto-detection is permitted again", "detection", detection)
+		autoDetectForbidden.WithLabelValues(detection).Set(0)
+	}
+	return forbidden
+}
+
 // OpenShiftRoutes represents the availability of the OpenShift Routes API.
 func (c *Config) OpenShiftRoutes() autodetect.OpenShiftRoutesAvailability {
 	return c.openshiftRoutes.Get()
 }
 
-// AutoscalingVersion represents the preferred version of autoscaling.
+// VPAAvailability represents the availability of the Vertical Pod Autoscaler API.
+func (c *Config) VPAAvailability() autodetect.VPAAvailability {
+	return c.vpa.Get()
+}
+
+// AutoscalingVersion represents the preferred version of autoscaling. The operator doesn't create or adjust any
+// HorizontalPodAutoscaler, so it's only reported in the capabilities and the instrumentation status, which the health
+// monitor refreshes through the change callback.
 func (c *Config) AutoscalingVersion() autodetect.AutoscalingVersion {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.autoscalingVersion
 }
 
+// NativeSidecars represents whether the cluster runs the init containers with an Always restart policy as sidecars,
+// in which case the agent init containers are inserted before them.
+func (c *Config) NativeSidecars() autodetect.NativeSidecarsAvailability {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nativeSidecars
+}
+
+// SleepAction represents whether the cluster enables the sleep action of the lifecycle hooks, which the agent warmup
+// relies on.
+func (c *Config) SleepAction() autodetect.SleepActionAvailability {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sleepAction
+}
+
+// Knative represents whether the Knative Serving API is available, in which case the pods of the Knative revisions are
+// injected within the mutations Knative allows.
+func (c *Config) Knative() autodetect.KnativeAvailability {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.knative
+}
+
 // LabelsFilter Returns the filters converted to regex strings used to filter out unwanted labels from propagations.
 func (c *Config) LabelsFilter() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.labelsFilter
 }
 
+// LabelsAllowList Returns the regexes of the labels propagated in the allow mode, see LabelsFilterMode.
+func (c *Config) LabelsAllowList() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.labelsAllowList
+}
+
+// LabelsFilterMode is how the labels propagated are filtered. In the deny mode, the default, the labels filter drops
+// the labels matching it. In the allow mode, the labels allow-list is applied first, dropping the labels matching none
+// of its regexes, and the labels filter then drops the allowed labels matching it.
+func (c *Config) LabelsFilterMode() LabelsFilterMode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.labelsFilterMode
+}
+
+// LabelFilteredBy returns the labels filter matching the label key, if any, in which case the label isn't propagated.
+// The labels dropped for not matching the labels allow-list in the allow mode are reported as filtered by it.
+func (c *Config) LabelFilteredBy(key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.labelsFilterMode == LabelsFilterModeAllow &&
+		!slices.ContainsFunc(c.labelsAllowListRegexps, func(re *regexp.Regexp) bool { return re.MatchString(key) }) {
+		return labelsAllowListFilter, true
+	}
+	for _, re := range c.labelsFilterRegexps {
+		if re.MatchString(key) {
+			return re.String(), true
+		}
+	}
+	return "", false
+}
+
+// compileLabelsFilter compiles the labels filter once, rather than on every propagation. Invalid regexes are logged
+// and left out, so they filter nothing.
+func compileLabelsFilter(logger logr.Logger, labelsFilter []string) []*regexp.Regexp {
+	regexps := make([]*regexp.Regexp, 0, len(labelsFilter))
+	for _, filter := range labelsFilter {
+		re, err := regexp.Compile(filter)
+		if err != nil {
+			logger.Error(err, "ignoring invalid labels filter", "filter", filter)
+			continue
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps
+}
+
+// AttributeLabels is the allow-list of pod label keys added to the agent labels, so that they're reported as
+// attributes. Unlike the labels filter, which denies labels from being propagated, nothing is added unless listed.
+func (c *Config) AttributeLabels() []string {
+	return c.attributeLabels
+}
+
+// NodeLabelAttributes is the allow-list of the labels of the node a pod is scheduled on, reported as attributes by its
+// agents. Nothing is added unless listed.
+func (c *Config) NodeLabelAttributes() []NodeLabelAttribute {
+	return c.nodeLabelAttributes
+}
+
+// AutoDetectFrequency is how often the environment is auto-detected.
+func (c *Config) AutoDetectFrequency() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.autoDetectFrequency
+}
+
+// AgentInitDeadline is the maximum duration an agent init container may take before it's reported as stuck. Zero
+// disables the check.
+func (c *Config) AgentInitDeadline() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.agentInitDeadline
+}
+
+// InitContainerNamePrefix is the prefix used for the names of the injected agent init containers.
+func (c *Config) InitContainerNamePrefix() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.initContainerNamePrefix
+}
+
+// AgentEnvDefaults is the agent env vars of every instrumentation. They have the lowest precedence, so the
+// instrumentation and pod annotation env vars win over them.
+func (c *Config) AgentEnvDefaults() []corev1.EnvVar {
+	return c.agentEnvDefaults
+}
+
+// AgentEnvPrecedence is which of the instrumentation and the pod annotation env vars wins when both set a name. The
+// instrumentation wins by default, so that the pods can't override the env vars managed centrally.
+func (c *Config) AgentEnvPrecedence() AgentEnvPrecedence {
+	return c.agentEnvPrecedence
+}
+
+// AgentVolumeName is the name of the shared volume the agents are copied into. Pods which already have a volume of this
+// name get a suffixed one instead.
+func (c *Config) AgentVolumeName() string {
+	return c.agentVolumeName
+}
+
+// AllowedImageRegistries are the registry prefixes the agent images must be from, such as docker.io/newrelic. Any
+// registry is allowed when empty.
+func (c *Config) AllowedImageRegistries() []string {
+	return c.allowedImageRegistries
+}
+
+// AgentImageRollout is whether workloads are restarted when the agent image of their instrumentation changes.
+func (c *Config) AgentImageRollout() bool {
+	return c.agentImageRollout
+}
+
+// PodAgentImages is whether the agent image of a pod can be overridden with the newrelic.com/<language>-image
+// annotation of the pod, for testing an agent build on a single pod.
+func (c *Config) PodAgentImages() bool {
+	return c.podAgentImages
+}
+
+// MaxLanguagesPerPod is the most languages whose agents are injected into a pod, guarding pods from being matched by
+// more instrumentations than intended. 0 when unlimited.
+func (c *Config) MaxLanguagesPerPod() int {
+	return c.maxLanguagesPerPod
+}
+
+// AgentReadinessGate is the condition type of the readiness gate added to pods injected with the health agent, which
+// is set once the agent reports healthy, so that the pods don't receive traffic before. Empty when disabled.
+func (c *Config) AgentReadinessGate() corev1.PodConditionType {
+	return c.agentReadinessGate
+}
+
+// NamespaceRollout is whether workloads left uninstrumented are restarted when their namespace is labeled to match an
+// instrumentation.
+func (c *Config) NamespaceRollout() bool {
+	return c.namespaceRollout
+}
+
+// FreezeAutoDetect is whether the auto-detection is paused, so that the detected state keeps its last values.
+func (c *Config) FreezeAutoDetect() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.freezeAutoDetect
+}
+
+// AgentInitRunAs is the user and group the agent init containers run as, when neither the instrumented container nor
+// the pod set them. Either is nil when unset.
+func (c *Config) AgentInitRunAs() (*int64, *int64) {
+	return c.agentInitRunAsUser, c.agentInitRunAsGroup
+}
+
+// ComposeInstrumentations is whether the instrumentations of the same language matching a pod are composed into one,
+// rather than the pod not being instrumented when they differ.
+func (c *Config) ComposeInstrumentations() bool {
+	return c.composeInstrumentations
+}
+
+// StripEnvVars is the names of the env vars removed from the instrumented containers before the agent env vars are
+// added, for the instrumentations which don't set their own.
+func (c *Config) StripEnvVars() []string {
+	return c.stripEnvVars
+}
+
+// ExistingAgentEnvVars is the list of env vars which, set on any container of a pod, signal that the image already
+// has an agent, so the pod isn't injected.
+func (c *Config) ExistingAgentEnvVars() []string {
+	return c.existingAgentEnvVars
+}
+
+// ImageChannel is the repository and the channel resolving the agent image of the instrumentations without one, as
+// <repository>/<language>:<channel>. Both are empty unless configured.
+func (c *Config) ImageChannel() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.imageRepository, c.imageChannel
+}
+
+// Region is the New Relic region the agents report to, empty for the region of their license key.
+func (c *Config) Region() Region {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.region
+}
+
+// InitContainerInsertPosition is where the agent init containers are inserted among the pod's init containers. The
+// name is only set for InitContainerPositionBefore.
+func (c *Config) InitContainerInsertPosition() (InitContainerPosition, string) {
+	return c.initContainerPosition, c.initContainerBefore
+}
+
+// MinAgentVersion is the minimum agent version of the instrumentations of the given language, such as 8.12.0. It's
+// empty unless configured for the language.
+func (c *Config) MinAgentVersion(language string) string {
+	return c.minAgentVersions[language]
+}
+
+// MinPodRequests is the default minimum summed container requests a pod needs to be injected, used by instrumentations
+// which don't set their own.
+func (c *Config) MinPodRequests() corev1.ResourceList {
+	return c.minPodRequests
+}
+
+// LanguageScheduling is the scheduling added to pods instrumented for the given language.
+func (c *Config) LanguageScheduling(language string) Scheduling {
+	return c.languageScheduling[language]
+}
+
+// AgentContainer is the container of a pod the agents are injected into, unless they were injected into one before.
+func (c *Config) AgentContainer() AgentContainer {
+	return c.agentContainer
+}
+
+// AgentDNS is the DNS policy and config added to the pods instrumented by instrumentations without their own.
+func (c *Config) AgentDNS() (corev1.DNSPolicy, *corev1.PodDNSConfig) {
+	return c.agentDNSPolicy, c.agentDNSConfig
+}
+
+// AgentProfiling is whether the code level metrics and the profilers of the agents are enabled for the
+// instrumentations which don't choose.
+func (c *Config) AgentProfiling() bool {
+	return c.agentProfiling
+}
+
+// AgentWarmup is how long the app container of the given language is held back from becoming ready after starting,
+// giving the agent time to initialize before the app receives traffic. It's 0 unless configured for the language.
+func (c *Config) AgentWarmup(language string) time.Duration {
+	return c.agentWarmup[language]
+}
+
+// AgentMountPropagation is the mount propagation of the agent volume mounts of the containers instrumented for the
+// given language and of its agent init container. It's empty unless configured for the language, which is None.
+func (c *Config) AgentMountPropagation(language string) corev1.MountPropagationMode {
+	return c.agentMountPropagation[language]
+}
+
+// OTLPProtocol is the default OTLP transport of the instrumentations exporting to an endpoint. It's empty unless
+// configured, leaving the protocol to the agent.
+func (c *Config) OTLPProtocol() OTLPProtocol {
+	return c.otlpProtocol
+}
+
+// ClusterName is the name of the cluster added to the telemetry of every instrumented pod, as the k8s.cluster.name
+// attribute. It's empty when it's not added.
+func (c *Config) ClusterName() string {
+	return c.clusterName
+}
+
+// AgentProxy is the proxy of the agents, and the in-cluster traffic excluded from the proxy of the containers setting
+// one. Its URL is empty unless configured, leaving the proxy of the agents to the containers.
+func (c *Config) AgentProxy() AgentProxy {
+	return c.agentProxy
+}
+
+// InjectionConcurrency is the most pod admissions injected at once, and how long the admissions beyond it wait for one
+// to complete before being admitted without injection. A limit of zero disables it.
+func (c *Config) InjectionConcurrency() (int, time.Duration) {
+	return c.maxConcurrentInjections, c.injectionQueueTimeout
+}
+
+// InjectionFailureThreshold is the number of consecutive injection failures of a workload after which its pods are
+// admitted without injection. Zero disables it.
+func (c *Config) InjectionFailureThreshold() int {
+	return c.injectionFailureThreshold
+}
+
+// MinTerminationGracePeriod is the termination grace period, in seconds, the instrumented pods are raised to so that
+// the agents can flush their data on shutdown. Zero leaves the grace period of the pods alone.
+func (c *Config) MinTerminationGracePeriod() int {
+	return c.minTerminationGracePeriod
+}
+
+// InstrumentationProvider identifies the agents injected by the operator, in the metadata they report along with the
+// operator version, so that they can be told apart from the agents configured otherwise. It's empty when it isn't set.
+func (c *Config) InstrumentationProvider() string {
+	return c.instrumentationProvider
+}
+
+// InstrumentationSync is how the pods injected by an instrumentation are kept in sync with it when it changes. It's
+// empty when they aren't.
+func (c *Config) InstrumentationSync() InstrumentationSyncPolicy {
+	return c.instrumentationSync
+}
+
+// OTLPSignalEndpoints is the default urls each signal is exported to by the instrumentations exporting to an endpoint,
+// for the signals they don't set one for. They're empty unless configured, leaving the signals to the combined endpoint.
+func (c *Config) OTLPSignalEndpoints() OTLPSignalEndpoints {
+	return c.otlpSignalEndpoints
+}
+
+// OTLPClientCert is the name of the kubernetes.io/tls secret holding the client certificate presented to the collector
+// by the instrumentations exporting to an endpoint without their own. It's empty unless configured.
+func (c *Config) OTLPClientCert() string {
+	return c.otlpClientCert
+}
+
+// SecretCacheTTL is how long a resolved license key secret is remembered for. Zero disables the cache.
+func (c *Config) SecretCacheTTL() time.Duration {
+	return c.secretCacheTTL
+}
+
+// SecretCircuitBreaker is the number of consecutive license key secret resolution failures opening the circuit, and
+// how long it stays open for. A threshold of zero disables the circuit breaker.
+func (c *Config) SecretCircuitBreaker() (int, time.Duration) {
+	return c.secretFailureThreshold, c.secretCooldown
+}
+
+// SecretRetry is the number of times the resolution of a license key secret which isn't found is retried, and how long
+// to wait between the attempts. Zero attempts disables the retry.
+func (c *Config) SecretRetry() (int, time.Duration) {
+	return c.secretRetryAttempts, c.secretRetryInterval
+}
+
+// FallbackLicenseKeySecret is the name of the license key secret used when the one of the instrumentations still isn't
+// found once its resolution is retried. It's empty unless configured.
+func (c *Config) FallbackLicenseKeySecret() string {
+	return c.fallbackLicenseKeySecret
+}
+
+// ServiceNameLabels is the list of pod label keys used, in order, for the agent app name. The first label the pod has
+// wins.
+func (c *Config) ServiceNameLabels() []string {
+	return c.serviceNameLabels
+}
+
+// ServiceVersionKeys is the list of label or annotation keys looked up, in order, for the service.version attribute of
+// the agents. The pod is looked up first, then the workload owning it.
+func (c *Config) ServiceVersionKeys() []string {
+	return c.serviceVersionKeys
+}
+
+// WebhookSelfCheckInterval is how often the operator verifies that the pod mutation webhook is being called. Zero
+// disables the self-check.
+func (c *Config) WebhookSelfCheckInterval() time.Duration {
+	return c.webhookSelfCheckInterval
+}
+
 // RegisterOpenShiftRoutesChangeCallback registers the given function as a callback that
 // is called when the OpenShift Routes detection detects a change.
 func (c *Config) RegisterOpenShiftRoutesChangeCallback(f func() error) {
 	c.onOpenShiftRoutesChange.Register(f)
 }
 
+// RegisterAutoscalingVersionChangeCallback registers the given function as a callback that
+// is called when the autoscaling version detection detects a change.
+func (c *Config) RegisterAutoscalingVersionChangeCallback(f func() error) {
+	c.onAutoscalingVersionChange.Register(f)
+}
+
+// RegisterEventRecorder registers the recorder of the events recorded on the object when a detected capability
+// changes, such as the operator deployment.
+func (c *Config) RegisterEventRecorder(recorder record.EventRecorder, object runtime.Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.eventRecorder = recorder
+	c.eventObject = object
+}
+
+// RegisterConfigChangeCallback registers the given function as a callback that
+// is called when the configuration is reloaded with a change.
+func (c *Config) RegisterConfigChangeCallback(f func() error) {
+	c.onConfigChange.Register(f)
+}
+
+// RegisterVPAChangeCallback registers the given function as a callback that
+// is called when the Vertical Pod Autoscaler detection detects a change.
+func (c *Config) RegisterVPAChangeCallback(f func() error) {
+	c.onVPAChange.Register(f)
+}
+
 type openshiftRoutesStore interface {
 	Set(ora autodetect.OpenShiftRoutesAvailability)
 	Get() autodetect.OpenShiftRoutesAvailability
@@ -165,3 +1132,33 @@ func (p *openshiftRoutesWrapper) Get() autodetect.OpenShiftRoutesAvailability {
 	p.mu.Unlock()
 	return ora
 }
+
+type vpaStore interface {
+	Set(vpa autodetect.VPAAvailability)
+	Get() autodetect.VPAAvailability
+}
+
+func newVPAWrapper() vpaStore {
+	return &vpaWrapper{
+		current: autodetect.VPANotAvailable,
+		mu:      &sync.Mutex{},
+	}
+}
+
+type vpaWrapper struct {
+	mu      *sync.Mutex
+	current autodetect.VPAAvailability
+}
+
+func (p *vpaWrapper) Set(vpa autodetect.VPAAvailability) {
+	p.mu.Lock()
+	p.current = vpa
+	p.mu.Unlock()
+}
+
+func (p *vpaWrapper) Get() autodetect.VPAAvailability {
+	p.mu.Lock()
+	vpa := p.current
+	p.mu.Unlock()
+	return vpa
+}
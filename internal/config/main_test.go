@@ -17,12 +17,20 @@ limitations under the License.
 package config_test
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
 	"github.com/newrelic/k8s-agents-operator/internal/config"
@@ -66,6 +74,217 @@ func TestOnPlatformChangeCallback(t *testing.T) {
 	assert.True(t, calledBack)
 }
 
+func TestOnVPAChangeCallback(t *testing.T) {
+	// prepare
+	calledBack := false
+	mock := &mockAutoDetect{
+		VPAAvailabilityFunc: func() (autodetect.VPAAvailability, error) {
+			return autodetect.VPAAvailable, nil
+		},
+	}
+	cfg := config.New(
+		config.WithAutoDetect(mock),
+		config.WithOnVPAChangeCallback(func() error {
+			calledBack = true
+			return nil
+		}),
+	)
+
+	// sanity check
+	require.Equal(t, autodetect.VPANotAvailable, cfg.VPAAvailability())
+
+	// test
+	err := cfg.AutoDetect()
+	require.NoError(t, err)
+
+	// verify
+	assert.Equal(t, autodetect.VPAAvailable, cfg.VPAAvailability())
+	assert.True(t, calledBack)
+}
+
+func TestOnAutoscalingVersionChangeCallback(t *testing.T) {
+	// prepare
+	calledBack := 0
+	mock := &mockAutoDetect{
+		HPAVersionFunc: func() (autodetect.AutoscalingVersion, error) {
+			return autodetect.AutoscalingVersionV2Beta2, nil
+		},
+	}
+	cfg := config.New(
+		config.WithAutoDetect(mock),
+		config.WithOnAutoscalingVersionChangeCallback(func() error {
+			calledBack++
+			return nil
+		}),
+	)
+
+	// sanity check
+	require.Equal(t, autodetect.AutoscalingVersionV2, cfg.AutoscalingVersion())
+
+	// test
+	require.NoError(t, cfg.AutoDetect())
+	require.NoError(t, cfg.AutoDetect())
+
+	// verify
+	assert.Equal(t, autodetect.AutoscalingVersionV2Beta2, cfg.AutoscalingVersion())
+	assert.Equal(t, 1, calledBack)
+}
+
+func TestAutoDetect_PartialFailure(t *testing.T) {
+	// prepare
+	var routesCalledBack, autoscalingCalledBack bool
+	mock := &mockAutoDetect{
+		OpenShiftRoutesAvailabilityFunc: func() (autodetect.OpenShiftRoutesAvailability, error) {
+			return autodetect.OpenShiftRoutesAvailable, nil
+		},
+		VPAAvailabilityFunc: func() (autodetect.VPAAvailability, error) {
+			return autodetect.VPANotAvailable, errors.New("api server unavailable")
+		},
+		HPAVersionFunc: func() (autodetect.AutoscalingVersion, error) {
+			return autodetect.AutoscalingVersionV2Beta2, nil
+		},
+	}
+	cfg := config.New(
+		config.WithAutoDetect(mock),
+		config.WithOnOpenShiftRoutesChangeCallback(func() error {
+			routesCalledBack = true
+			return nil
+		}),
+		config.WithOnAutoscalingVersionChangeCallback(func() error {
+			autoscalingCalledBack = true
+			return nil
+		}),
+	)
+
+	// test
+	err := cfg.AutoDetect()
+
+	// verify
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vertical pod autoscaler")
+	assert.Equal(t, autodetect.OpenShiftRoutesAvailable, cfg.OpenShiftRoutes())
+	assert.Equal(t, autodetect.AutoscalingVersionV2Beta2, cfg.AutoscalingVersion())
+	assert.Equal(t, autodetect.VPANotAvailable, cfg.VPAAvailability())
+	assert.True(t, routesCalledBack)
+	assert.True(t, autoscalingCalledBack)
+}
+
+func TestFreezeAutoDetect(t *testing.T) {
+	// prepare
+	mock := &mockAutoDetect{
+		VPAAvailabilityFunc: func() (autodetect.VPAAvailability, error) {
+			return autodetect.VPAAvailable, nil
+		},
+	}
+	cfg := config.New(config.WithAutoDetect(mock))
+	require.NoError(t, cfg.Reload(config.WithFreezeAutoDetect(true)))
+
+	// test
+	require.NoError(t, cfg.AutoDetect())
+
+	// verify
+	assert.True(t, cfg.FreezeAutoDetect())
+	assert.Equal(t, autodetect.VPANotAvailable, cfg.VPAAvailability())
+
+	// resuming detects again
+	require.NoError(t, cfg.Reload())
+	require.NoError(t, cfg.AutoDetect())
+	assert.False(t, cfg.FreezeAutoDetect())
+	assert.Equal(t, autodetect.VPAAvailable, cfg.VPAAvailability())
+}
+
+func TestWaitForFirstDetect(t *testing.T) {
+	// prepare
+	var fail atomic.Bool
+	fail.Store(true)
+	errUnavailable := errors.New("api server unavailable")
+	mock := &mockAutoDetect{
+		OpenShiftRoutesAvailabilityFunc: func() (autodetect.OpenShiftRoutesAvailability, error) {
+			if fail.Load() {
+				return autodetect.OpenShiftRoutesNotAvailable, errUnavailable
+			}
+			return autodetect.OpenShiftRoutesNotAvailable, nil
+		},
+		VPAAvailabilityFunc: func() (autodetect.VPAAvailability, error) {
+			if fail.Load() {
+				return autodetect.VPANotAvailable, errUnavailable
+			}
+			return autodetect.VPAAvailable, nil
+		},
+		HPAVersionFunc: func() (autodetect.AutoscalingVersion, error) {
+			if fail.Load() {
+				return autodetect.DefaultAutoscalingVersion, errUnavailable
+			}
+			return autodetect.DefaultAutoscalingVersion, nil
+		},
+		NativeSidecarsAvailabilityFunc: func() (autodetect.NativeSidecarsAvailability, error) {
+			if fail.Load() {
+				return autodetect.DefaultNativeSidecarsAvailability, errUnavailable
+			}
+			return autodetect.DefaultNativeSidecarsAvailability, nil
+		},
+		KnativeAvailabilityFunc: func() (autodetect.KnativeAvailability, error) {
+			if fail.Load() {
+				return autodetect.DefaultKnativeAvailability, errUnavailable
+			}
+			return autodetect.DefaultKnativeAvailability, nil
+		},
+		SleepActionAvailabilityFunc: func() (autodetect.SleepActionAvailability, error) {
+			if fail.Load() {
+				return autodetect.DefaultSleepActionAvailability, errUnavailable
+			}
+			return autodetect.DefaultSleepActionAvailability, nil
+		},
+	}
+	cfg := config.New(config.WithAutoDetect(mock))
+	waitFor := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		return cfg.WaitForFirstDetect(ctx)
+	}
+
+	// nothing detected yet
+	assert.ErrorIs(t, waitFor(), context.DeadlineExceeded)
+
+	// a run where every detection failed doesn't count
+	require.Error(t, cfg.AutoDetect())
+	assert.ErrorIs(t, waitFor(), context.DeadlineExceeded)
+
+	// the first successful detection unblocks the waiters, before and after it
+	fail.Store(false)
+	done := make(chan error)
+	go func() { done <- cfg.WaitForFirstDetect(context.Background()) }()
+	require.NoError(t, cfg.AutoDetect())
+	assert.NoError(t, <-done)
+	assert.NoError(t, waitFor())
+	assert.Equal(t, autodetect.VPAAvailable, cfg.VPAAvailability())
+	require.NoError(t, cfg.AutoDetect())
+}
+
+func TestWaitForFirstDetect_PartialFailure(t *testing.T) {
+	// prepare
+	mock := &mockAutoDetect{
+		VPAAvailabilityFunc: func() (autodetect.VPAAvailability, error) {
+			return autodetect.VPANotAvailable, errors.New("api server unavailable")
+		},
+	}
+	cfg := config.New(config.WithAutoDetect(mock))
+
+	// test
+	require.Error(t, cfg.AutoDetect())
+
+	// verify a failing detection doesn't hold back the waiters once the others were applied
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, cfg.WaitForFirstDetect(ctx))
+}
+
+func TestWaitForFirstDetect_Frozen(t *testing.T) {
+	cfg := config.New(config.WithAutoDetect(&mockAutoDetect{}), config.WithFreezeAutoDetect(true))
+	require.NoError(t, cfg.AutoDetect())
+	assert.NoError(t, cfg.WaitForFirstDetect(context.Background()))
+}
+
 func TestAutoDetectInBackground(t *testing.T) {
 	// prepare
 	var ac int64
@@ -94,19 +313,258 @@ func TestAutoDetectInBackground(t *testing.T) {
 	assert.GreaterOrEqual(t, c, int64(2))
 }
 
+func TestAutoDetectInitialDelay(t *testing.T) {
+	// prepare
+	var ac int64
+	tickTime := 50 * time.Millisecond
+	mock := &mockAutoDetect{
+		OpenShiftRoutesAvailabilityFunc: func() (autodetect.OpenShiftRoutesAvailability, error) {
+			atomic.AddInt64(&ac, 1)
+			return autodetect.OpenShiftRoutesNotAvailable, nil
+		},
+	}
+	cfg := config.New(
+		config.WithAutoDetect(mock),
+		config.WithAutoDetectFrequency(tickTime),
+		config.WithAutoDetectInitialDelay(200*time.Millisecond),
+	)
+
+	// test
+	err := cfg.StartAutoDetect()
+	require.NoError(t, err)
+
+	// verify the initial detection ran, but no periodic run happened before the delay
+	time.Sleep(2 * tickTime)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&ac))
+	time.Sleep(200 * time.Millisecond)
+	assert.GreaterOrEqual(t, atomic.LoadInt64(&ac), int64(2))
+}
+
 var _ autodetect.AutoDetect = (*mockAutoDetect)(nil)
 
 type mockAutoDetect struct {
 	OpenShiftRoutesAvailabilityFunc func() (autodetect.OpenShiftRoutesAvailability, error)
+	VPAAvailabilityFunc             func() (autodetect.VPAAvailability, error)
+	HPAVersionFunc                  func() (autodetect.AutoscalingVersion, error)
+	NativeSidecarsAvailabilityFunc  func() (autodetect.NativeSidecarsAvailability, error)
+	KnativeAvailabilityFunc         func() (autodetect.KnativeAvailability, error)
+	SleepActionAvailabilityFunc     func() (autodetect.SleepActionAvailability, error)
+}
+
+func (m *mockAutoDetect) SleepActionAvailability() (autodetect.SleepActionAvailability, error) {
+	if m.SleepActionAvailabilityFunc != nil {
+		return m.SleepActionAvailabilityFunc()
+	}
+	return autodetect.DefaultSleepActionAvailability, nil
+}
+
+func (m *mockAutoDetect) KnativeAvailability() (autodetect.KnativeAvailability, error) {
+	if m.KnativeAvailabilityFunc != nil {
+		return m.KnativeAvailabilityFunc()
+	}
+	return autodetect.DefaultKnativeAvailability, nil
+}
+
+func (m *mockAutoDetect) NativeSidecarsAvailability() (autodetect.NativeSidecarsAvailability, error) {
+	if m.NativeSidecarsAvailabilityFunc != nil {
+		return m.NativeSidecarsAvailabilityFunc()
+	}
+	return autodetect.DefaultNativeSidecarsAvailability, nil
 }
 
 func (m *mockAutoDetect) HPAVersion() (autodetect.AutoscalingVersion, error) {
+	if m.HPAVersionFunc != nil {
+		return m.HPAVersionFunc()
+	}
 	return autodetect.DefaultAutoscalingVersion, nil
 }
 
+func (m *mockAutoDetect) VPAAvailability() (autodetect.VPAAvailability, error) {
+	if m.VPAAvailabilityFunc != nil {
+		return m.VPAAvailabilityFunc()
+	}
+	return autodetect.VPANotAvailable, nil
+}
+
 func (m *mockAutoDetect) OpenShiftRoutesAvailability() (autodetect.OpenShiftRoutesAvailability, error) {
 	if m.OpenShiftRoutesAvailabilityFunc != nil {
 		return m.OpenShiftRoutesAvailabilityFunc()
 	}
 	return autodetect.OpenShiftRoutesNotAvailable, nil
 }
+
+func TestAgentInitDeadline(t *testing.T) {
+	cfg := config.New()
+	assert.Equal(t, time.Duration(0), cfg.AgentInitDeadline())
+
+	cfg = config.New(config.WithAgentInitDeadline(2 * time.Minute))
+	assert.Equal(t, 2*time.Minute, cfg.AgentInitDeadline())
+}
+
+func TestInitContainerNamePrefix(t *testing.T) {
+	cfg := config.New()
+	assert.Equal(t, "newrelic-instrumentation", cfg.InitContainerNamePrefix())
+
+	cfg = config.New(config.WithInitContainerNamePrefix(""))
+	assert.Equal(t, "newrelic-instrumentation", cfg.InitContainerNamePrefix())
+
+	cfg = config.New(config.WithInitContainerNamePrefix("acme-agent"))
+	assert.Equal(t, "acme-agent", cfg.InitContainerNamePrefix())
+}
+
+func TestLanguageScheduling(t *testing.T) {
+	java := config.Scheduling{Tolerations: []corev1.Toleration{{Key: "agents", Operator: corev1.TolerationOpExists}}}
+	cfg := config.New(config.WithLanguageScheduling("java", java))
+	assert.Equal(t, java, cfg.LanguageScheduling("java"))
+	assert.Equal(t, config.Scheduling{}, cfg.LanguageScheduling("python"))
+}
+
+func TestReload(t *testing.T) {
+	calls := 0
+	cfg := config.New(
+		config.WithAgentInitDeadline(time.Minute),
+		config.WithOnConfigChangeCallback(func() error {
+			calls++
+			return nil
+		}),
+	)
+
+	require.NoError(t, cfg.Reload(
+		config.WithAgentInitDeadline(5*time.Minute),
+		config.WithImageRepository("docker.io/newrelic"),
+		config.WithImageChannel("stable"),
+		config.WithRegion(config.RegionEU),
+		config.WithLabelsFilter([]string{"^app$"}),
+	))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 5*time.Minute, cfg.AgentInitDeadline())
+	repository, channel := cfg.ImageChannel()
+	assert.Equal(t, "docker.io/newrelic", repository)
+	assert.Equal(t, "stable", channel)
+	assert.Equal(t, config.RegionEU, cfg.Region())
+	assert.Equal(t, []string{"^app$"}, cfg.LabelsFilter())
+
+	// reloading the same values doesn't notify
+	require.NoError(t, cfg.Reload(
+		config.WithAgentInitDeadline(5*time.Minute),
+		config.WithImageRepository("docker.io/newrelic"),
+		config.WithImageChannel("stable"),
+		config.WithRegion(config.RegionEU),
+		config.WithLabelsFilter([]string{"^app$"}),
+	))
+	assert.Equal(t, 1, calls)
+
+	// reloading without options goes back to the values the config was created with
+	require.NoError(t, cfg.Reload())
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, time.Minute, cfg.AgentInitDeadline())
+	repository, channel = cfg.ImageChannel()
+	assert.Empty(t, repository)
+	assert.Empty(t, channel)
+	assert.Empty(t, cfg.Region())
+	assert.Nil(t, cfg.LabelsFilter())
+
+	// the init container name prefix isn't reloadable
+	require.NoError(t, cfg.Reload(config.WithInitContainerNamePrefix("acme-agent")))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "newrelic-instrumentation", cfg.InitContainerNamePrefix())
+}
+
+func TestLabelFilteredBy(t *testing.T) {
+	cfg := config.New(config.WithLabelsFilter([]string{"^app$", "[", "kubernetes"}))
+
+	filter, filtered := cfg.LabelFilteredBy("app")
+	assert.True(t, filtered)
+	assert.Equal(t, "^app$", filter)
+	filter, filtered = cfg.LabelFilteredBy("app.kubernetes.io/name")
+	assert.True(t, filtered)
+	assert.Equal(t, "kubernetes", filter)
+	_, filtered = cfg.LabelFilteredBy("team")
+	assert.False(t, filtered, "invalid filters should filter nothing")
+
+	require.NoError(t, cfg.Reload(config.WithLabelsFilter([]string{"^team$"})))
+	_, filtered = cfg.LabelFilteredBy("app")
+	assert.False(t, filtered)
+	_, filtered = cfg.LabelFilteredBy("team")
+	assert.True(t, filtered)
+}
+
+func TestLabelFilteredBy_AllowList(t *testing.T) {
+	cfg := config.New(
+		config.WithLabelsAllowList([]string{"^app", "^team$"}),
+		config.WithLabelsFilter([]string{"^app.kubernetes.io/"}),
+	)
+	_, filtered := cfg.LabelFilteredBy("tier")
+	assert.False(t, filtered, "the allow-list only applies in the allow mode")
+
+	require.NoError(t, cfg.Reload(
+		config.WithLabelsAllowList([]string{"^app", "^team$"}),
+		config.WithLabelsFilter([]string{"^app.kubernetes.io/"}),
+		config.WithLabelsFilterMode(config.LabelsFilterModeAllow),
+	))
+	assert.Equal(t, config.LabelsFilterModeAllow, cfg.LabelsFilterMode())
+	_, filtered = cfg.LabelFilteredBy("app")
+	assert.False(t, filtered)
+	_, filtered = cfg.LabelFilteredBy("team")
+	assert.False(t, filtered)
+	filter, filtered := cfg.LabelFilteredBy("tier")
+	assert.True(t, filtered)
+	assert.Equal(t, "labels allow-list", filter)
+	filter, filtered = cfg.LabelFilteredBy("app.kubernetes.io/name")
+	assert.True(t, filtered, "the labels filter applies to the allowed labels")
+	assert.Equal(t, "^app.kubernetes.io/", filter)
+
+	require.NoError(t, cfg.Reload(config.WithLabelsAllowList(nil), config.WithLabelsFilterMode(config.LabelsFilterModeAllow)))
+	_, filtered = cfg.LabelFilteredBy("app")
+	assert.True(t, filtered, "an empty allow-list allows nothing")
+}
+
+func TestAutoDetectForbidden(t *testing.T) {
+	var calls int32
+	forbidden := apierrors.NewForbidden(schema.GroupResource{}, "", errors.New("cannot list api groups"))
+	registry := prometheus.NewRegistry()
+	cfg := config.New(
+		config.WithMetricsRegistry(registry),
+		config.WithAutoDetect(&mockAutoDetect{
+			OpenShiftRoutesAvailabilityFunc: func() (autodetect.OpenShiftRoutesAvailability, error) {
+				atomic.AddInt32(&calls, 1)
+				return autodetect.OpenShiftRoutesAvailable, forbidden
+			},
+			VPAAvailabilityFunc: func() (autodetect.VPAAvailability, error) {
+				return autodetect.VPAAvailable, nil
+			},
+		}),
+	)
+	require.NoError(t, cfg.RegisterMetrics())
+
+	// the forbidden detection falls back to the default, and the other detections still run
+	require.NoError(t, cfg.AutoDetect())
+	assert.Equal(t, autodetect.OpenShiftRoutesNotAvailable, cfg.OpenShiftRoutes())
+	assert.Equal(t, autodetect.VPAAvailable, cfg.VPAAvailability())
+	expectedMetric := `
+# HELP operator_autodetect_forbidden Whether an auto-detection is forbidden by RBAC (1) or permitted (0)
+# TYPE operator_autodetect_forbidden gauge
+operator_autodetect_forbidden{detection="openshift_routes"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expectedMetric), "operator_autodetect_forbidden"))
+
+	// the forbidden detection isn't retried straight away
+	require.NoError(t, cfg.AutoDetect())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	cfg := config.New(config.WithMetricsRegistry(registry))
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "operator_test_total", Help: "Test counter"})
+
+	require.NoError(t, cfg.RegisterMetrics(counter))
+	require.NoError(t, cfg.RegisterMetrics(counter), "registering the same collectors again should be skipped")
+	counter.Inc()
+	count, err := testutil.GatherAndCount(registry, "operator_test_total")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	conflicting := prometheus.NewCounter(prometheus.CounterOpts{Name: "operator_test_total", Help: "Test counter"})
+	assert.Error(t, cfg.RegisterMetrics(conflicting))
+}
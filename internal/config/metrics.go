@@ -0,0 +1,49 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// autoDetectForbidden is set for each detection the operator isn't permitted to run
+	autoDetectForbidden = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "operator_autodetect_forbidden",
+			Help: "Whether an auto-detection is forbidden by RBAC (1) or permitted (0)",
+		},
+		[]string{"detection"},
+	)
+)
+
+// RegisterMetrics is used to register the collectors, along with the auto-detect metrics, with the metrics registry of
+// the config. Collectors already registered with it are skipped, so that every package can register its metrics.
+func (c *Config) RegisterMetrics(collectors ...prometheus.Collector) error {
+	for _, collector := range append([]prometheus.Collector{autoDetectForbidden}, collectors...) {
+		if err := c.metricsRegistry.Register(collector); err != nil {
+			var alreadyRegistered prometheus.AlreadyRegisteredError
+			if errors.As(err, &alreadyRegistered) && alreadyRegistered.ExistingCollector == collector {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
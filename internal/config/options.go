@@ -20,6 +20,10 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
 	"github.com/newrelic/k8s-agents-operator/internal/version"
@@ -29,16 +33,169 @@ import (
 type Option func(c *options)
 
 type options struct {
-	autoDetect              autodetect.AutoDetect
-	version                 version.Version
-	logger                  logr.Logger
-	onOpenShiftRoutesChange changeHandler
-	labelsFilter            []string
-	openshiftRoutes         openshiftRoutesStore
-	autoDetectFrequency     time.Duration
-	autoscalingVersion      autodetect.AutoscalingVersion
+	autoDetect                 autodetect.AutoDetect
+	version                    version.Version
+	logger                     logr.Logger
+	eventRecorder              record.EventRecorder
+	eventObject                runtime.Object
+	onOpenShiftRoutesChange    changeHandler
+	onVPAChange                changeHandler
+	onAutoscalingVersionChange changeHandler
+	onConfigChange             changeHandler
+	labelsFilter               []string
+	labelsAllowList            []string
+	labelsFilterMode           LabelsFilterMode
+	openshiftRoutes            openshiftRoutesStore
+	vpa                        vpaStore
+	autoDetectFrequency        time.Duration
+	autoDetectJitter           float64
+	autoDetectInitialDelay     time.Duration
+	autoscalingVersion         autodetect.AutoscalingVersion
+	nativeSidecars             autodetect.NativeSidecarsAvailability
+	sleepAction                autodetect.SleepActionAvailability
+	knative                    autodetect.KnativeAvailability
+	agentInitDeadline          time.Duration
+	initContainerNamePrefix    string
+	minPodRequests             corev1.ResourceList
+	languageScheduling         map[string]Scheduling
+	serviceNameLabels          []string
+	serviceVersionKeys         []string
+	initContainerPosition      InitContainerPosition
+	initContainerBefore        string
+	agentImageRollout          bool
+	podAgentImages             bool
+	secretCacheTTL             time.Duration
+	secretFailureThreshold     int
+	secretCooldown             time.Duration
+	secretRetryAttempts        int
+	secretRetryInterval        time.Duration
+	fallbackLicenseKeySecret   string
+	existingAgentEnvVars       []string
+	attributeLabels            []string
+	webhookSelfCheckInterval   time.Duration
+	imageRepository            string
+	imageChannel               string
+	region                     Region
+	otlpProtocol               OTLPProtocol
+	agentEnvDefaults           []corev1.EnvVar
+	composeInstrumentations    bool
+	agentInitRunAsUser         *int64
+	agentInitRunAsGroup        *int64
+	freezeAutoDetect           bool
+	agentWarmup                map[string]time.Duration
+	metricsRegistry            prometheus.Registerer
+	minAgentVersions           map[string]string
+	agentProfiling             bool
+	openshiftRoutesCooldown    time.Duration
+	agentDNSPolicy             corev1.DNSPolicy
+	agentDNSConfig             *corev1.PodDNSConfig
+	agentContainer             AgentContainer
+	namespaceRollout           bool
+	agentReadinessGate         corev1.PodConditionType
+	maxLanguagesPerPod         int
+	stripEnvVars               []string
+	otlpClientCert             string
+	nodeLabelAttributes        []NodeLabelAttribute
+	otlpSignalEndpoints        OTLPSignalEndpoints
+	instrumentationSync        InstrumentationSyncPolicy
+	clusterName                string
+	agentProxy                 AgentProxy
+	injectionFailureThreshold  int
+	minTerminationGracePeriod  int
+	instrumentationProvider    string
+	agentEnvPrecedence         AgentEnvPrecedence
+	maxConcurrentInjections    int
+	injectionQueueTimeout      time.Duration
+	agentVolumeName            string
+	allowedImageRegistries     []string
+	agentMountPropagation      map[string]corev1.MountPropagationMode
 }
 
+func WithAgentContainer(agentContainer AgentContainer) Option {
+	return func(o *options) {
+		o.agentContainer = agentContainer
+	}
+}
+func WithAgentDNS(policy corev1.DNSPolicy, dnsConfig *corev1.PodDNSConfig) Option {
+	return func(o *options) {
+		o.agentDNSPolicy = policy
+		o.agentDNSConfig = dnsConfig
+	}
+}
+func WithAgentEnvDefaults(env []corev1.EnvVar) Option {
+	return func(o *options) {
+		o.agentEnvDefaults = env
+	}
+}
+func WithAgentEnvPrecedence(precedence AgentEnvPrecedence) Option {
+	return func(o *options) {
+		o.agentEnvPrecedence = precedence
+	}
+}
+func WithAgentInitDeadline(d time.Duration) Option {
+	return func(o *options) {
+		o.agentInitDeadline = d
+	}
+}
+func WithAgentInitRunAs(user, group *int64) Option {
+	return func(o *options) {
+		o.agentInitRunAsUser = user
+		o.agentInitRunAsGroup = group
+	}
+}
+func WithAgentMountPropagation(language string, mode corev1.MountPropagationMode) Option {
+	return func(o *options) {
+		if o.agentMountPropagation == nil {
+			o.agentMountPropagation = make(map[string]corev1.MountPropagationMode)
+		}
+		o.agentMountPropagation[language] = mode
+	}
+}
+func WithAgentProfiling(enabled bool) Option {
+	return func(o *options) {
+		o.agentProfiling = enabled
+	}
+}
+func WithAgentProxy(proxy AgentProxy) Option {
+	return func(o *options) {
+		o.agentProxy = proxy
+	}
+}
+func WithAgentReadinessGate(conditionType corev1.PodConditionType) Option {
+	return func(o *options) {
+		o.agentReadinessGate = conditionType
+	}
+}
+func WithAgentVolumeName(name string) Option {
+	return func(o *options) {
+		if name != "" {
+			o.agentVolumeName = name
+		}
+	}
+}
+func WithAgentWarmup(language string, warmup time.Duration) Option {
+	return func(o *options) {
+		if o.agentWarmup == nil {
+			o.agentWarmup = make(map[string]time.Duration)
+		}
+		o.agentWarmup[language] = warmup
+	}
+}
+func WithAgentImageRollout(enabled bool) Option {
+	return func(o *options) {
+		o.agentImageRollout = enabled
+	}
+}
+func WithAllowedImageRegistries(registries []string) Option {
+	return func(o *options) {
+		o.allowedImageRegistries = registries
+	}
+}
+func WithAttributeLabels(labels []string) Option {
+	return func(o *options) {
+		o.attributeLabels = labels
+	}
+}
 func WithAutoDetect(a autodetect.AutoDetect) Option {
 	return func(o *options) {
 		o.autoDetect = a
@@ -49,11 +206,171 @@ func WithAutoDetectFrequency(t time.Duration) Option {
 		o.autoDetectFrequency = t
 	}
 }
+func WithAutoDetectInitialDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.autoDetectInitialDelay = d
+	}
+}
+func WithAutoDetectJitter(fraction float64) Option {
+	return func(o *options) {
+		o.autoDetectJitter = min(max(fraction, 0), maxAutoDetectJitter)
+	}
+}
+func WithClusterName(name string) Option {
+	return func(o *options) {
+		o.clusterName = name
+	}
+}
+func WithComposeInstrumentations(enabled bool) Option {
+	return func(o *options) {
+		o.composeInstrumentations = enabled
+	}
+}
+func WithEventRecorder(recorder record.EventRecorder, object runtime.Object) Option {
+	return func(o *options) {
+		o.eventRecorder = recorder
+		o.eventObject = object
+	}
+}
+func WithExistingAgentEnvVars(envVars []string) Option {
+	return func(o *options) {
+		o.existingAgentEnvVars = envVars
+	}
+}
+func WithFallbackLicenseKeySecret(name string) Option {
+	return func(o *options) {
+		o.fallbackLicenseKeySecret = name
+	}
+}
+func WithFreezeAutoDetect(frozen bool) Option {
+	return func(o *options) {
+		o.freezeAutoDetect = frozen
+	}
+}
+func WithImageChannel(channel string) Option {
+	return func(o *options) {
+		o.imageChannel = channel
+	}
+}
+func WithImageRepository(repository string) Option {
+	return func(o *options) {
+		o.imageRepository = repository
+	}
+}
+func WithInjectionConcurrency(limit int, queueTimeout time.Duration) Option {
+	return func(o *options) {
+		o.maxConcurrentInjections = limit
+		o.injectionQueueTimeout = queueTimeout
+	}
+}
+func WithInjectionFailureThreshold(threshold int) Option {
+	return func(o *options) {
+		o.injectionFailureThreshold = threshold
+	}
+}
+func WithInitContainerInsertPosition(position InitContainerPosition, before string) Option {
+	return func(o *options) {
+		if position == "" {
+			return
+		}
+		o.initContainerPosition = position
+		o.initContainerBefore = before
+	}
+}
+func WithInitContainerNamePrefix(prefix string) Option {
+	return func(o *options) {
+		if prefix != "" {
+			o.initContainerNamePrefix = prefix
+		}
+	}
+}
+func WithKnative(knative autodetect.KnativeAvailability) Option {
+	return func(o *options) {
+		o.knative = knative
+	}
+}
+func WithLabelsFilter(labelsFilter []string) Option {
+	return func(o *options) {
+		o.labelsFilter = labelsFilter
+	}
+}
+func WithLabelsAllowList(labelsAllowList []string) Option {
+	return func(o *options) {
+		o.labelsAllowList = labelsAllowList
+	}
+}
+func WithLabelsFilterMode(mode LabelsFilterMode) Option {
+	return func(o *options) {
+		o.labelsFilterMode = mode
+	}
+}
+func WithLanguageScheduling(language string, scheduling Scheduling) Option {
+	return func(o *options) {
+		if o.languageScheduling == nil {
+			o.languageScheduling = make(map[string]Scheduling)
+		}
+		o.languageScheduling[language] = scheduling
+	}
+}
 func WithLogger(logger logr.Logger) Option {
 	return func(o *options) {
 		o.logger = logger
 	}
 }
+func WithMetricsRegistry(registry prometheus.Registerer) Option {
+	return func(o *options) {
+		o.metricsRegistry = registry
+	}
+}
+func WithInstrumentationProvider(provider string) Option {
+	return func(o *options) {
+		o.instrumentationProvider = provider
+	}
+}
+func WithInstrumentationSync(policy InstrumentationSyncPolicy) Option {
+	return func(o *options) {
+		o.instrumentationSync = policy
+	}
+}
+func WithMaxLanguagesPerPod(maxLanguages int) Option {
+	return func(o *options) {
+		o.maxLanguagesPerPod = maxLanguages
+	}
+}
+func WithMinAgentVersion(language, version string) Option {
+	return func(o *options) {
+		if o.minAgentVersions == nil {
+			o.minAgentVersions = make(map[string]string)
+		}
+		o.minAgentVersions[language] = version
+	}
+}
+func WithMinPodRequests(minPodRequests corev1.ResourceList) Option {
+	return func(o *options) {
+		o.minPodRequests = minPodRequests
+	}
+}
+func WithMinTerminationGracePeriod(seconds int) Option {
+	return func(o *options) {
+		o.minTerminationGracePeriod = seconds
+	}
+}
+func WithOnAutoscalingVersionChangeCallback(f func() error) Option {
+	return func(o *options) {
+		if o.onAutoscalingVersionChange == nil {
+			o.onAutoscalingVersionChange = newOnChange()
+		}
+		o.onAutoscalingVersionChange.Register(f)
+	}
+}
+func WithOnConfigChangeCallback(f func() error) Option {
+	return func(o *options) {
+		if o.onConfigChange == nil {
+			o.onConfigChange = newOnChange()
+		}
+		o.onConfigChange.Register(f)
+	}
+}
 func WithOnOpenShiftRoutesChangeCallback(f func() error) Option {
 	return func(o *options) {
 		if o.onOpenShiftRoutesChange == nil {
@@ -62,13 +379,113 @@ func WithOnOpenShiftRoutesChangeCallback(f func() error) Option {
 		o.onOpenShiftRoutesChange.Register(f)
 	}
 }
+func WithOnVPAChangeCallback(f func() error) Option {
+	return func(o *options) {
+		if o.onVPAChange == nil {
+			o.onVPAChange = newOnChange()
+		}
+		o.onVPAChange.Register(f)
+	}
+}
+func WithNamespaceRollout(enabled bool) Option {
+	return func(o *options) {
+		o.namespaceRollout = enabled
+	}
+}
+func WithNativeSidecars(nativeSidecars autodetect.NativeSidecarsAvailability) Option {
+	return func(o *options) {
+		o.nativeSidecars = nativeSidecars
+	}
+}
+func WithNodeLabelAttributes(attributes []NodeLabelAttribute) Option {
+	return func(o *options) {
+		o.nodeLabelAttributes = attributes
+	}
+}
+func WithOpenShiftRoutesChangeCooldown(cooldown time.Duration) Option {
+	return func(o *options) {
+		o.openshiftRoutesCooldown = cooldown
+	}
+}
+func WithOTLPClientCert(secretName string) Option {
+	return func(o *options) {
+		o.otlpClientCert = secretName
+	}
+}
+func WithOTLPProtocol(protocol OTLPProtocol) Option {
+	return func(o *options) {
+		o.otlpProtocol = protocol
+	}
+}
+func WithOTLPSignalEndpoints(endpoints OTLPSignalEndpoints) Option {
+	return func(o *options) {
+		o.otlpSignalEndpoints = endpoints
+	}
+}
 func WithPlatform(ora autodetect.OpenShiftRoutesAvailability) Option {
 	return func(o *options) {
 		o.openshiftRoutes.Set(ora)
 	}
 }
+func WithPodAgentImages(enabled bool) Option {
+	return func(o *options) {
+		o.podAgentImages = enabled
+	}
+}
+func WithRegion(region Region) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+func WithSecretCacheTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.secretCacheTTL = ttl
+	}
+}
+func WithSecretCircuitBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return func(o *options) {
+		o.secretFailureThreshold = failureThreshold
+		o.secretCooldown = cooldown
+	}
+}
+func WithSecretRetry(attempts int, interval time.Duration) Option {
+	return func(o *options) {
+		o.secretRetryAttempts = attempts
+		o.secretRetryInterval = interval
+	}
+}
+func WithServiceNameLabels(labels []string) Option {
+	return func(o *options) {
+		o.serviceNameLabels = labels
+	}
+}
+func WithServiceVersionKeys(keys []string) Option {
+	return func(o *options) {
+		o.serviceVersionKeys = keys
+	}
+}
+func WithSleepAction(sleepAction autodetect.SleepActionAvailability) Option {
+	return func(o *options) {
+		o.sleepAction = sleepAction
+	}
+}
+func WithStripEnvVars(names []string) Option {
+	return func(o *options) {
+		o.stripEnvVars = names
+	}
+}
+func WithVPA(vpa autodetect.VPAAvailability) Option {
+	return func(o *options) {
+		o.vpa.Set(vpa)
+	}
+}
 func WithVersion(v version.Version) Option {
 	return func(o *options) {
 		o.version = v
 	}
 }
+func WithWebhookSelfCheckInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.webhookSelfCheckInterval = d
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net/http"
+	"time"
+)
+
+// AutoDetectPath is the path the auto-detect handler is served on
+const AutoDetectPath = "/debug/autodetect"
+
+// TriggerAutoDetect is used to run the periodic auto-detection right away, for example right after installing a CRD,
+// rather than waiting for its next run. The detections forbidden recently are retried as well. It doesn't block, and
+// the triggers received while a run is pending are merged into it.
+func (c *Config) TriggerAutoDetect() {
+	c.mu.Lock()
+	for detection := range c.forbidden {
+		c.forbidden[detection] = time.Time{}
+	}
+	c.mu.Unlock()
+	select {
+	case c.redetect <- struct{}{}:
+	default:
+	}
+}
+
+// AutoDetectHandler triggers the auto-detection when posted to, see TriggerAutoDetect. The detected capabilities are
+// served by the capabilities handler once it ran.
+func AutoDetectHandler(c *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		c.TriggerAutoDetect()
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
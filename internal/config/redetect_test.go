@@ -0,0 +1,75 @@
+package config_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestTriggerAutoDetect(t *testing.T) {
+	var routes, vpa atomic.Int32
+	var changes atomic.Int32
+	forbidden := apierrors.NewForbidden(schema.GroupResource{}, "", errors.New("cannot list api groups"))
+	cfg := config.New(
+		config.WithAutoDetect(&mockAutoDetect{
+			OpenShiftRoutesAvailabilityFunc: func() (autodetect.OpenShiftRoutesAvailability, error) {
+				routes.Add(1)
+				return autodetect.OpenShiftRoutesNotAvailable, forbidden
+			},
+			VPAAvailabilityFunc: func() (autodetect.VPAAvailability, error) {
+				if vpa.Add(1) > 1 {
+					return autodetect.VPAAvailable, nil
+				}
+				return autodetect.VPANotAvailable, nil
+			},
+		}),
+		config.WithAutoDetectFrequency(time.Hour),
+		config.WithOnVPAChangeCallback(func() error {
+			changes.Add(1)
+			return nil
+		}),
+	)
+	require.NoError(t, cfg.StartAutoDetect())
+	require.Equal(t, autodetect.VPANotAvailable, cfg.VPAAvailability())
+
+	// the detection runs right away rather than in an hour, retrying the forbidden detection, and the change callback
+	// is called
+	cfg.TriggerAutoDetect()
+	assert.Eventually(t, func() bool { return cfg.VPAAvailability() == autodetect.VPAAvailable }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), changes.Load())
+	assert.Equal(t, int32(2), routes.Load())
+}
+
+func TestAutoDetectHandler(t *testing.T) {
+	var calls atomic.Int32
+	cfg := config.New(
+		config.WithAutoDetect(&mockAutoDetect{
+			OpenShiftRoutesAvailabilityFunc: func() (autodetect.OpenShiftRoutesAvailability, error) {
+				calls.Add(1)
+				return autodetect.OpenShiftRoutesNotAvailable, nil
+			},
+		}),
+		config.WithAutoDetectFrequency(time.Hour),
+	)
+	require.NoError(t, cfg.StartAutoDetect())
+
+	rec := httptest.NewRecorder()
+	config.AutoDetectHandler(&cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, config.AutoDetectPath, nil))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	rec = httptest.NewRecorder()
+	config.AutoDetectHandler(&cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, config.AutoDetectPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
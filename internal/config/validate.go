@@ -0,0 +1,57 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/newrelic/k8s-agents-operator/api/v1beta1"
+)
+
+// MissingImagesError is returned by Validate when the agent image of the languages can't be resolved for the
+// instrumentations without an image, which would otherwise leave their pods silently uninstrumented
+type MissingImagesError struct {
+	Languages []string
+	Reason    string
+}
+
+func (e *MissingImagesError) Error() string {
+	return fmt.Sprintf("no agent image can be resolved for languages %s: %s", strings.Join(e.Languages, ", "), e.Reason)
+}
+
+// Validate is used to check that the configuration can resolve the agent image of the given languages. The image
+// channel only resolves images once both the repository and the channel are set, so configuring just one of them is
+// reported as a *MissingImagesError listing the languages. An image repository which isn't from one of the allowed
+// image registries is rejected too, as none of the images it resolves would be admitted.
+func (c *Config) Validate(languages []string) error {
+	repository, channel := c.ImageChannel()
+	if err := v1beta1.ValidateImageRegistry(strings.TrimSuffix(repository, "/"), c.AllowedImageRegistries()); err != nil {
+		return fmt.Errorf("image repository %w", err)
+	}
+	if (repository == "") == (channel == "") || len(languages) == 0 {
+		return nil
+	}
+	reason := "the image channel is set without an image repository"
+	if channel == "" {
+		reason = "the image repository is set without an image channel"
+	}
+	languages = slices.Clone(languages)
+	slices.Sort(languages)
+	return &MissingImagesError{Languages: slices.Compact(languages), Reason: reason}
+}
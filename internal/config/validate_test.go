@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	languages := []string{"python", "java", "java"}
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected *MissingImagesError
+	}{
+		{name: "no image channel"},
+		{name: "image channel", opts: []Option{WithImageRepository("newrelic"), WithImageChannel("stable")}},
+		{
+			name: "channel without repository",
+			opts: []Option{WithImageChannel("stable")},
+			expected: &MissingImagesError{
+				Languages: []string{"java", "python"},
+				Reason:    "the image channel is set without an image repository",
+			},
+		},
+		{
+			name: "repository without channel",
+			opts: []Option{WithImageRepository("newrelic")},
+			expected: &MissingImagesError{
+				Languages: []string{"java", "python"},
+				Reason:    "the image repository is set without an image channel",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := New(test.opts...)
+			err := cfg.Validate(languages)
+			if test.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var missing *MissingImagesError
+			if assert.True(t, errors.As(err, &missing)) {
+				assert.Equal(t, test.expected, missing)
+			}
+		})
+	}
+}
+
+func TestValidate_AllowedImageRegistries(t *testing.T) {
+	allowed := WithAllowedImageRegistries([]string{"docker.io/newrelic"})
+	cfg := New(allowed, WithImageRepository("newrelic"), WithImageChannel("stable"))
+	assert.NoError(t, cfg.Validate([]string{"java"}))
+
+	cfg = New(allowed, WithImageRepository("quay.io/acme/"), WithImageChannel("stable"))
+	assert.EqualError(t, cfg.Validate([]string{"java"}), `image repository "quay.io/acme" is not from an allowed registry (docker.io/newrelic)`)
+}
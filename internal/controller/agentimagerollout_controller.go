@@ -0,0 +1,273 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+const (
+	instrumentationVersionAnnotation = "newrelic.com/instrumentation-versions"
+	// agentImageAnnotationPrefix is the prefix of the pod template annotation, suffixed by the language, holding the
+	// agent image a workload was restarted for. Changing it is what triggers the rollout.
+	agentImageAnnotationPrefix = "newrelic.com/agent-image-"
+	// disruptionBudgetRequeueDelay is how long a rollout blocked by a pod disruption budget waits before trying again
+	disruptionBudgetRequeueDelay = 30 * time.Second
+)
+
+// AgentImageRolloutReconciler restarts the workloads with pods running an agent image other than the one in their
+// Instrumentation, so that agent upgrades reach existing pods. The restart is a rollout of the workload, which respects
+// its update strategy, such as maxUnavailable. Restarts which would violate a pod disruption budget are paused until
+// the budget allows disruptions again.
+type AgentImageRolloutReconciler struct {
+	client.Client
+	Config            *config.Config
+	Recorder          record.EventRecorder
+	operatorNamespace string
+}
+
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
+
+// Reconcile restarts the workloads of the pods injected by the instrumentation with an outdated agent image
+func (r *AgentImageRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Namespace, "name", req.Name)
+	logger.V(2).Info("start agent image rollout reconciliation")
+
+	inst := current.Instrumentation{}
+	err := r.Client.Get(ctx, req.NamespacedName, &inst)
+	if apierrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	image := apm.AgentImage(r.Config, inst)
+	if inst.DeletionTimestamp != nil || image == "" {
+		return ctrl.Result{}, nil
+	}
+
+	var pods corev1.PodList
+	if err = r.Client.List(ctx, &pods); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	initContainerName := apm.AgentInitContainerName(r.Config.InitContainerNamePrefix(), inst.Spec.Agent.Language)
+	annotation := agentImageAnnotationPrefix + inst.Spec.Agent.Language
+	restarted := map[types.NamespacedName]bool{}
+	blocked := false
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		// a pod overriding its agent image with the annotation isn't outdated, the override is what it's meant to run
+		podImage, _ := apm.PodAgentImage(r.Config, inst, *pod)
+		if !isPodInjectedBy(pod, req.NamespacedName) || !isAgentImageOutdated(pod, initContainerName, podImage) {
+			continue
+		}
+		workload, err := getWorkload(ctx, r.Client, pod)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if workload == nil {
+			continue
+		}
+		key := types.NamespacedName{Namespace: workload.GetNamespace(), Name: workload.GetName()}
+		if restarted[key] {
+			continue
+		}
+		restarted[key] = true
+		if isKnativeRevisionWorkload(ctx, r.Config, r.Recorder, workload) {
+			continue
+		}
+		budget, err := getBlockingDisruptionBudget(ctx, r.Client, workload)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if budget != "" {
+			logger.Info("pausing the workload restart for the agent image change, it would violate the pod disruption budget",
+				"workload_namespace", workload.GetNamespace(),
+				"workload_name", workload.GetName(),
+				"pod_disruption_budget", budget,
+			)
+			blocked = true
+			continue
+		}
+		if err = restartWorkload(ctx, r.Client, workload, annotation, image, "restarting workload for the agent image change", "agent_image", image); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if blocked {
+		return ctrl.Result{RequeueAfter: disruptionBudgetRequeueDelay}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// isPodInjectedBy is used to check if the pod was injected by the instrumentation
+func isPodInjectedBy(pod *corev1.Pod, inst types.NamespacedName) bool {
+	_, ok := podInstrumentationVersion(pod, inst)
+	return ok
+}
+
+// podInstrumentationVersion is used to get the version, uid/generation, of the instrumentation the pod was injected by
+func podInstrumentationVersion(pod *corev1.Pod, inst types.NamespacedName) (string, bool) {
+	v, ok := pod.Annotations[instrumentationVersionAnnotation]
+	if !ok {
+		return "", false
+	}
+	instVersions := map[string]string{}
+	if err := json.Unmarshal([]byte(v), &instVersions); err != nil {
+		return "", false
+	}
+	version, ok := instVersions[inst.String()]
+	return version, ok
+}
+
+// isAgentImageOutdated is used to check if the agent init container of the pod copies an agent image other than the
+// given one
+func isAgentImageOutdated(pod *corev1.Pod, initContainerName string, image string) bool {
+	injected, ok := apm.InjectedAgentImage(*pod, initContainerName)
+	return ok && injected != image
+}
+
+// getWorkload returns the deployment, statefulset or daemonset managing the pod, or nil for pods managed by anything
+// else, as those can't be restarted by a rollout
+func getWorkload(ctx context.Context, c client.Client, pod *corev1.Pod) (client.Object, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, nil
+	}
+	switch owner.Kind {
+	case "ReplicaSet":
+		var replicaSet appsv1.ReplicaSet
+		if err := c.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: owner.Name}, &replicaSet); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		rsOwner := metav1.GetControllerOf(&replicaSet)
+		if rsOwner == nil || rsOwner.Kind != "Deployment" {
+			return nil, nil
+		}
+		return getObject(ctx, c, &appsv1.Deployment{}, pod.Namespace, rsOwner.Name)
+	case "StatefulSet":
+		return getObject(ctx, c, &appsv1.StatefulSet{}, pod.Namespace, owner.Name)
+	case "DaemonSet":
+		return getObject(ctx, c, &appsv1.DaemonSet{}, pod.Namespace, owner.Name)
+	}
+	return nil, nil
+}
+
+func getObject(ctx context.Context, c client.Client, obj client.Object, namespace string, name string) (client.Object, error) {
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, obj); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	return obj, nil
+}
+
+// getBlockingDisruptionBudget returns the name of a pod disruption budget covering the workload's pods which allows no
+// more disruptions, or an empty string when restarting the workload wouldn't violate any budget. Budgets are only
+// checked before the restart starts, after which the rollout is paced by the workload's own update strategy.
+func getBlockingDisruptionBudget(ctx context.Context, c client.Client, workload client.Object) (string, error) {
+	template, err := podTemplate(workload)
+	if err != nil {
+		return "", err
+	}
+	var budgets policyv1.PodDisruptionBudgetList
+	if err = c.List(ctx, &budgets, client.InNamespace(workload.GetNamespace())); err != nil {
+		return "", err
+	}
+	podLabels := labels.Set(template.Labels)
+	for _, budget := range budgets.Items {
+		selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+		if err != nil || selector.Empty() || !selector.Matches(podLabels) {
+			continue
+		}
+		if budget.Status.DisruptionsAllowed < 1 {
+			return budget.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// podTemplate is used to get the pod template of the workload
+func podTemplate(workload client.Object) (*corev1.PodTemplateSpec, error) {
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		return &w.Spec.Template, nil
+	case *appsv1.StatefulSet:
+		return &w.Spec.Template, nil
+	case *appsv1.DaemonSet:
+		return &w.Spec.Template, nil
+	}
+	return nil, fmt.Errorf("unsupported workload %T", workload)
+}
+
+// restartWorkload sets the annotation on the pod template of the workload, which rolls out new pods, logging the
+// message with the key values. A workload which already has the annotation with the value is being rolled out, so it's
+// left alone.
+func restartWorkload(ctx context.Context, c client.Client, workload client.Object, annotation string, value string, msg string, keysAndValues ...any) error {
+	logger := log.FromContext(ctx)
+
+	template, err := podTemplate(workload)
+	if err != nil {
+		return err
+	}
+	if template.Annotations[annotation] == value {
+		return nil
+	}
+
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[annotation] = value
+	logger.Info(msg, append([]any{
+		"workload_namespace", workload.GetNamespace(),
+		"workload_name", workload.GetName(),
+	}, keysAndValues...)...)
+	return c.Patch(ctx, workload, patch)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AgentImageRolloutReconciler) SetupWithManager(mgr ctrl.Manager, operatorNamespace string) error {
+	r.operatorNamespace = operatorNamespace
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("agentimagerollout").
+		For(&current.Instrumentation{}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return object.GetNamespace() == r.operatorNamespace
+		})).
+		Complete(r)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+// instrumentationVersionAnnotationPrefix is the prefix of the pod template annotation, suffixed by the language,
+// holding the instrumentation version a workload was restarted for
+const instrumentationVersionAnnotationPrefix = "newrelic.com/instrumentation-version-"
+
+// InstrumentationSyncReconciler keeps the pods injected by an instrumentation in sync with it when it changes. Their
+// agent images annotation is fixed to match the agent init container they run. With the restart policy, the workloads
+// of the pods injected by an older generation of the instrumentation are also restarted, so that their pods are
+// injected again with its current spec. Like the agent image rollout, restarts which would violate a pod disruption
+// budget are paused until the budget allows disruptions again.
+type InstrumentationSyncReconciler struct {
+	client.Client
+	Config            *config.Config
+	Recorder          record.EventRecorder
+	operatorNamespace string
+}
+
+// Reconcile syncs the pods injected by the instrumentation
+func (r *InstrumentationSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Namespace, "name", req.Name)
+	logger.V(2).Info("start instrumentation sync reconciliation")
+
+	inst := current.Instrumentation{}
+	err := r.Client.Get(ctx, req.NamespacedName, &inst)
+	if apierrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if inst.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	var pods corev1.PodList
+	if err = r.Client.List(ctx, &pods); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	initContainerName := apm.AgentInitContainerName(r.Config.InitContainerNamePrefix(), inst.Spec.Agent.Language)
+	restart := r.Config.InstrumentationSync() == config.InstrumentationSyncPolicyRestart
+	version := fmt.Sprintf("%s/%d", inst.UID, inst.Generation)
+	annotation := instrumentationVersionAnnotationPrefix + inst.Spec.Agent.Language
+	restarted := map[types.NamespacedName]bool{}
+	blocked := false
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		podVersion, ok := podInstrumentationVersion(pod, req.NamespacedName)
+		if !ok {
+			continue
+		}
+		if err = r.stampAgentImage(ctx, pod, req.NamespacedName, initContainerName); err != nil {
+			return ctrl.Result{}, err
+		}
+		if !restart || podVersion == version {
+			continue
+		}
+		workload, err := getWorkload(ctx, r.Client, pod)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if workload == nil {
+			continue
+		}
+		key := types.NamespacedName{Namespace: workload.GetNamespace(), Name: workload.GetName()}
+		if restarted[key] {
+			continue
+		}
+		restarted[key] = true
+		if isKnativeRevisionWorkload(ctx, r.Config, r.Recorder, workload) {
+			continue
+		}
+		budget, err := getBlockingDisruptionBudget(ctx, r.Client, workload)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if budget != "" {
+			logger.Info("pausing the workload restart for the instrumentation change, it would violate the pod disruption budget",
+				"workload_namespace", workload.GetNamespace(),
+				"workload_name", workload.GetName(),
+				"pod_disruption_budget", budget,
+			)
+			blocked = true
+			continue
+		}
+		if err = restartWorkload(ctx, r.Client, workload, annotation, version, "restarting workload for the instrumentation change", "instrumentation_version", version); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if blocked {
+		return ctrl.Result{RequeueAfter: disruptionBudgetRequeueDelay}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// stampAgentImage is used to patch the agent images annotation of the pod when it doesn't match the agent init
+// container. A malformed annotation is replaced.
+func (r *InstrumentationSyncReconciler) stampAgentImage(ctx context.Context, pod *corev1.Pod, inst types.NamespacedName, initContainerName string) error {
+	stamped := pod.DeepCopy()
+	changed, err := apm.StampAgentImage(stamped, inst, initContainerName)
+	if err != nil {
+		log.FromContext(ctx).Info("replacing the malformed agent images annotation", "pod_namespace", pod.Namespace, "pod_name", pod.Name, "reason", err.Error())
+		delete(stamped.Annotations, apm.AgentImagesAnnotation)
+		if changed, err = apm.StampAgentImage(stamped, inst, initContainerName); err != nil {
+			return err
+		}
+	}
+	if !changed {
+		return nil
+	}
+	log.FromContext(ctx).V(1).Info("syncing the agent images annotation", "pod_namespace", pod.Namespace, "pod_name", pod.Name)
+	return client.IgnoreNotFound(r.Client.Patch(ctx, stamped, client.MergeFrom(pod)))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InstrumentationSyncReconciler) SetupWithManager(mgr ctrl.Manager, operatorNamespace string) error {
+	r.operatorNamespace = operatorNamespace
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("instrumentationsync").
+		For(&current.Instrumentation{}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return object.GetNamespace() == r.operatorNamespace
+		})).
+		Complete(r)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+const (
+	// knativeServingGroup is the api group of the Knative Serving resources, such as the revisions
+	knativeServingGroup = "serving.knative.dev"
+	// reasonKnativeRevisionNotRestarted is the reason of the event recorded on the deployment of a Knative revision
+	// which would have been restarted
+	reasonKnativeRevisionNotRestarted = "KnativeRevisionNotRestarted"
+)
+
+// knativeRevision is used to get the name of the Knative revision controlling the workload, empty when it isn't managed
+// by Knative
+func knativeRevision(workload client.Object) string {
+	owner := metav1.GetControllerOf(workload)
+	if owner == nil || owner.Kind != "Revision" || !strings.HasPrefix(owner.APIVersion, knativeServingGroup+"/") {
+		return ""
+	}
+	return owner.Name
+}
+
+// isKnativeRevisionWorkload is used to check if the workload is the deployment of a Knative revision, which isn't
+// restarted, reporting it when it is. Knative revisions are immutable, and Knative reverts the changes to their
+// deployments, so patching the pod template would only churn the pods. Only checked when Knative is available, and
+// once per workload by the reconcilers, which skip the workloads they already handled.
+func isKnativeRevisionWorkload(ctx context.Context, cfg *config.Config, recorder record.EventRecorder, workload client.Object) bool {
+	if cfg.Knative() != autodetect.KnativeAvailable {
+		return false
+	}
+	revision := knativeRevision(workload)
+	if revision == "" {
+		return false
+	}
+	reportKnativeRevision(ctx, recorder, workload, revision)
+	return true
+}
+
+// reportKnativeRevision is used to log, and record an event for, the deployment of a Knative revision which isn't
+// restarted. A new revision of the Knative service is what rolls out the change.
+func reportKnativeRevision(ctx context.Context, recorder record.EventRecorder, workload client.Object, revision string) {
+	log.FromContext(ctx).Info("skipping the restart of the workload, it's managed by a knative revision",
+		"workload_namespace", workload.GetNamespace(),
+		"workload_name", workload.GetName(),
+		"knative_revision", revision,
+	)
+	if recorder != nil {
+		recorder.Eventf(workload, corev1.EventTypeWarning, reasonKnativeRevisionNotRestarted,
+			"Skipped restarting the deployment of Knative revision %s, Knative reverts the changes to it. Create a new revision of the Knative service to roll out the agent change.", revision)
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func TestIsKnativeRevisionWorkload(t *testing.T) {
+	vtrue := true
+	revisionDeployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default", Name: "hello-00001-deployment",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "serving.knative.dev/v1", Kind: "Revision", Name: "hello-00001", Controller: &vtrue}},
+	}}
+	tests := []struct {
+		name           string
+		knative        autodetect.KnativeAvailability
+		workload       *appsv1.Deployment
+		expected       bool
+		expectedEvents int
+	}{
+		{name: "deployment of a revision", knative: autodetect.KnativeAvailable, workload: revisionDeployment, expected: true, expectedEvents: 1},
+		{name: "knative not available", knative: autodetect.KnativeNotAvailable, workload: revisionDeployment},
+		{
+			name:     "deployment",
+			knative:  autodetect.KnativeAvailable,
+			workload: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.New(config.WithKnative(test.knative))
+			recorder := record.NewFakeRecorder(10)
+			assert.Equal(t, test.expected, isKnativeRevisionWorkload(context.Background(), &cfg, recorder, test.workload))
+			assert.Len(t, recorder.Events, test.expectedEvents)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+// agentInitDuration is the time the agent init containers took to pull their image and copy the agent, from the time
+// they could start until they completed
+var agentInitDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "operator_agent_init_duration_seconds",
+		Help:    "Time taken by the agent init containers to pull the agent image and complete, by language and image",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+	},
+	[]string{"language", "image"},
+)
+
+// RegisterMetrics is used to register the metrics of the package with the metrics registry of the config
+func RegisterMetrics(cfg *config.Config) error {
+	return cfg.RegisterMetrics(agentInitDuration)
+}
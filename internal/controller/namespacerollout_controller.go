@@ -0,0 +1,205 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+	"github.com/newrelic/k8s-agents-operator/internal/instrumentation"
+)
+
+// namespaceRolloutAnnotation is the pod template annotation holding a hash of the instrumentations matching the
+// namespace a workload was restarted for. Changing it is what triggers the rollout.
+const namespaceRolloutAnnotation = "newrelic.com/namespace-rollout"
+
+// NamespaceRolloutReconciler restarts the workloads with pods left uninstrumented until their namespace was labeled to
+// match an Instrumentation, so that labeling a namespace brings its existing workloads into coverage. Only the label
+// changes which make an instrumentation match the namespace are reconciled, and the restarts respect the pod
+// disruption budgets like the agent image rollouts.
+type NamespaceRolloutReconciler struct {
+	client.Client
+	Config            *config.Config
+	Recorder          record.EventRecorder
+	locator           *instrumentation.NewrelicInstrumentationLocator
+	operatorNamespace string
+}
+
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
+
+// Reconcile restarts the workloads of the pods in the namespace which an instrumentation matches but which weren't
+// injected
+func (r *NamespaceRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("name", req.Name)
+	logger.V(2).Info("start namespace rollout reconciliation")
+
+	ns := corev1.Namespace{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: req.Name}, &ns)
+	if apierrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if ns.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	matching, err := r.matchingInstrumentations(ctx, ns.Labels)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(matching) == 0 {
+		return ctrl.Result{}, nil
+	}
+	// stable for as long as the same instrumentations match, unlike the resource version of the namespace, so that
+	// unrelated updates of the namespace don't restart the workloads again
+	value := fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(matching, ","))))[:16]
+
+	var pods corev1.PodList
+	if err = r.Client.List(ctx, &pods, client.InNamespace(ns.Name)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	restarted := map[types.NamespacedName]bool{}
+	blocked := false
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if _, injected := pod.Annotations[instrumentationVersionAnnotation]; injected || pod.DeletionTimestamp != nil {
+			continue
+		}
+		insts, err := r.locator.GetInstrumentations(ctx, ns, *pod)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(insts) == 0 {
+			continue
+		}
+		workload, err := getWorkload(ctx, r.Client, pod)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if workload == nil {
+			continue
+		}
+		key := types.NamespacedName{Namespace: workload.GetNamespace(), Name: workload.GetName()}
+		if restarted[key] {
+			continue
+		}
+		restarted[key] = true
+		if isKnativeRevisionWorkload(ctx, r.Config, r.Recorder, workload) {
+			continue
+		}
+		budget, err := getBlockingDisruptionBudget(ctx, r.Client, workload)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if budget != "" {
+			logger.Info("pausing the workload restart for the namespace instrumentation, it would violate the pod disruption budget",
+				"workload_namespace", workload.GetNamespace(),
+				"workload_name", workload.GetName(),
+				"pod_disruption_budget", budget,
+			)
+			blocked = true
+			continue
+		}
+		if err = restartWorkload(ctx, r.Client, workload, namespaceRolloutAnnotation, value, "restarting workload for the namespace instrumentation"); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if blocked {
+		return ctrl.Result{RequeueAfter: disruptionBudgetRequeueDelay}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// matchingInstrumentations is used to get the sorted uids of the instrumentations whose namespace label selector matches
+// the namespace labels
+func (r *NamespaceRolloutReconciler) matchingInstrumentations(ctx context.Context, nsLabels map[string]string) ([]string, error) {
+	var insts current.InstrumentationList
+	if err := r.Client.List(ctx, &insts, client.InNamespace(r.operatorNamespace)); err != nil {
+		return nil, err
+	}
+	var matching []string
+	for _, inst := range insts.Items {
+		selector, err := metav1.LabelSelectorAsSelector(&inst.Spec.NamespaceLabelSelector)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(nsLabels)) {
+			matching = append(matching, string(inst.UID))
+		}
+	}
+	slices.Sort(matching)
+	return matching, nil
+}
+
+// isNewlyMatched is used to check if the label change makes an instrumentation match the namespace which didn't before
+func (r *NamespaceRolloutReconciler) isNewlyMatched(ctx context.Context, oldLabels map[string]string, newLabels map[string]string) bool {
+	if maps.Equal(oldLabels, newLabels) {
+		return false
+	}
+	oldMatching, err := r.matchingInstrumentations(ctx, oldLabels)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to list the instrumentations for the namespace rollout")
+		return false
+	}
+	newMatching, err := r.matchingInstrumentations(ctx, newLabels)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to list the instrumentations for the namespace rollout")
+		return false
+	}
+	return slices.ContainsFunc(newMatching, func(uid string) bool { return !slices.Contains(oldMatching, uid) })
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NamespaceRolloutReconciler) SetupWithManager(mgr ctrl.Manager, operatorNamespace string) error {
+	r.operatorNamespace = operatorNamespace
+	r.locator = instrumentation.NewNewRelicInstrumentationLocator(mgr.GetLogger().WithName("namespace-rollout"), r.Client, operatorNamespace)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("namespacerollout").
+		For(&corev1.Namespace{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc:  func(event.CreateEvent) bool { return false },
+			DeleteFunc:  func(event.DeleteEvent) bool { return false },
+			GenericFunc: func(event.GenericEvent) bool { return false },
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectNew.GetName() != "kube-system" && r.isNewlyMatched(context.Background(), e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+			},
+		}).
+		Complete(r)
+}
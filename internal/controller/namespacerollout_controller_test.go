@@ -0,0 +1,52 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+)
+
+func TestNamespaceRolloutReconciler_IsNewlyMatched(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, current.AddToScheme(scheme))
+	inst := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "newrelic", Name: "java", UID: "01234567-89ab-cdef-0123-456789abcdef"},
+		Spec: current.InstrumentationSpec{NamespaceLabelSelector: metav1.LabelSelector{
+			MatchLabels: map[string]string{"newrelic": "enabled"},
+		}},
+	}
+	r := &NamespaceRolloutReconciler{
+		Client:            fake.NewClientBuilder().WithScheme(scheme).WithObjects(inst).Build(),
+		operatorNamespace: "newrelic",
+	}
+	ctx := context.Background()
+
+	assert.True(t, r.isNewlyMatched(ctx, map[string]string{"team": "shop"}, map[string]string{"team": "shop", "newrelic": "enabled"}))
+	assert.False(t, r.isNewlyMatched(ctx, map[string]string{"newrelic": "enabled"}, map[string]string{"newrelic": "enabled", "team": "shop"}),
+		"an unrelated label change of a namespace which already matched doesn't restart it again")
+	assert.False(t, r.isNewlyMatched(ctx, map[string]string{"newrelic": "enabled"}, map[string]string{}))
+	assert.False(t, r.isNewlyMatched(ctx, map[string]string{"team": "shop"}, map[string]string{"team": "cart"}))
+}
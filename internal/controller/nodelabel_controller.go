@@ -0,0 +1,119 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+)
+
+// NodeLabelReconciler copies the labels of the node a pod is scheduled on to the pod annotations read by its agents,
+// as the node of a pod isn't known yet when it's injected. The agents read them when their containers start, which is
+// after the agent init containers complete, so a pod scheduled once the copy is done reports them. This is best effort:
+// the containers aren't held back until the copy is done, and the ones which started before it report empty values
+// until they're restarted, which is logged.
+type NodeLabelReconciler struct {
+	client.Client
+}
+
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+
+// Reconcile copies the node labels listed by the pod to its annotations
+func (r *NodeLabelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Namespace, "name", req.Name)
+
+	pod := corev1.Pod{}
+	err := r.Client.Get(ctx, req.NamespacedName, &pod)
+	if apierrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil || pod.Annotations[apm.NodeLabelsAnnotation] == "" {
+		return ctrl.Result{}, nil
+	}
+
+	node := corev1.Node{}
+	if err = r.Client.Get(ctx, client.ObjectKey{Name: pod.Spec.NodeName}, &node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	changed := false
+	for _, label := range strings.Split(pod.Annotations[apm.NodeLabelsAnnotation], ",") {
+		value, ok := node.Labels[label]
+		annotation := apm.NodeLabelAnnotation(label)
+		if !ok || pod.Annotations[annotation] == value {
+			continue
+		}
+		pod.Annotations[annotation] = value
+		changed = true
+	}
+	if !changed {
+		return ctrl.Result{}, nil
+	}
+	if err = r.Client.Patch(ctx, &pod, patch); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	logger.V(1).Info("copied the node labels to the pod annotations", "node", node.Name)
+	if started := startedContainers(pod); len(started) > 0 {
+		logger.Info("copied the node labels after containers started, they report empty node labels until restarted",
+			"node", node.Name, "containers", started)
+	}
+	return ctrl.Result{}, nil
+}
+
+// startedContainers is used to get the names of the containers of the pod which already started, and so already read
+// their env vars
+func startedContainers(pod corev1.Pod) []string {
+	var started []string
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running != nil || status.State.Terminated != nil || status.LastTerminationState.Terminated != nil {
+			started = append(started, status.Name)
+		}
+	}
+	return started
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NodeLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("nodelabel").
+		For(&corev1.Pod{}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			pod, ok := object.(*corev1.Pod)
+			return ok && pod.Spec.NodeName != "" && pod.Annotations[apm.NodeLabelsAnnotation] != ""
+		})).
+		Complete(r)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+)
+
+func TestNodeLabelReconciler_Reconcile(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{
+		"topology.kubernetes.io/region": "us-east-1",
+	}}}
+	newPod := func(name string, statuses ...corev1.ContainerStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name, Annotations: map[string]string{
+				apm.NodeLabelsAnnotation: "topology.kubernetes.io/region,node.kubernetes.io/instance-type",
+			}},
+			Spec:   corev1.PodSpec{NodeName: "node-a"},
+			Status: corev1.PodStatus{ContainerStatuses: statuses},
+		}
+	}
+	waiting := corev1.ContainerStatus{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}}
+	running := corev1.ContainerStatus{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}
+	c := fake.NewClientBuilder().WithObjects(node, newPod("initializing", waiting), newPod("running", running)).Build()
+	r := &NodeLabelReconciler{Client: c}
+
+	for _, name := range []string{"initializing", "running"} {
+		key := types.NamespacedName{Namespace: "shop", Name: name}
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		// the labels are copied either way, the containers which already started only get them once restarted
+		pod := corev1.Pod{}
+		require.NoError(t, c.Get(context.Background(), key, &pod))
+		assert.Equal(t, "us-east-1", pod.Annotations[apm.NodeLabelAnnotation("topology.kubernetes.io/region")], name)
+		_, ok := pod.Annotations[apm.NodeLabelAnnotation("node.kubernetes.io/instance-type")]
+		assert.False(t, ok, "a label the node doesn't have isn't copied, so its env var is empty")
+	}
+}
+
+func TestStartedContainers(t *testing.T) {
+	pod := corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+		{Name: "waiting", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}},
+		{Name: "running", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		{Name: "restarting", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+	}}}
+	assert.Equal(t, []string{"running", "restarting"}, startedContainers(pod))
+	assert.Empty(t, startedContainers(corev1.Pod{}))
+}
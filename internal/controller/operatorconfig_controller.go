@@ -0,0 +1,119 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+// OperatorConfigReconciler reconciles the OperatorConfig object into the operator config
+type OperatorConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config *config.Config
+}
+
+//+kubebuilder:rbac:groups=newrelic.com,resources=operatorconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=newrelic.com,resources=operatorconfigs/status,verbs=get;update;patch
+
+// Reconcile reloads the operator config from the OperatorConfig. When it's deleted, the config goes back to the
+// values from the command line flags.
+func (r *OperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("name", req.Name)
+	logger.V(2).Info("start operator config reconciliation")
+
+	operatorConfig := current.OperatorConfig{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: req.Name}, &operatorConfig)
+	if apierrors.IsNotFound(err) {
+		logger.V(2).Info("operator config reconciliation; operator config deleted event")
+		if err = r.Config.Reload(); err != nil {
+			logger.Error(err, "configuration change notification failed for callback")
+		}
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err = r.Config.Reload(operatorConfigOptions(operatorConfig.Spec)...); err != nil {
+		// Don't fail if the callback failed, as the config itself was reloaded.
+		logger.Error(err, "configuration change notification failed for callback")
+	}
+
+	if operatorConfig.Status.ObservedGeneration != operatorConfig.Generation {
+		patch := client.MergeFrom(operatorConfig.DeepCopy())
+		operatorConfig.Status.ObservedGeneration = operatorConfig.Generation
+		if err = r.Client.Status().Patch(ctx, &operatorConfig, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// operatorConfigOptions maps the fields set in the OperatorConfig spec onto config options
+func operatorConfigOptions(spec current.OperatorConfigSpec) []config.Option {
+	var opts []config.Option
+	if spec.LabelsFilter != nil {
+		opts = append(opts, config.WithLabelsFilter(spec.LabelsFilter))
+	}
+	if spec.LabelsAllowList != nil {
+		opts = append(opts, config.WithLabelsAllowList(spec.LabelsAllowList))
+	}
+	if spec.LabelsFilterMode != "" {
+		opts = append(opts, config.WithLabelsFilterMode(config.LabelsFilterMode(spec.LabelsFilterMode)))
+	}
+	if spec.AutoDetectFrequency != nil && spec.AutoDetectFrequency.Duration > 0 {
+		opts = append(opts, config.WithAutoDetectFrequency(spec.AutoDetectFrequency.Duration))
+	}
+	if spec.AgentInitDeadline != nil {
+		opts = append(opts, config.WithAgentInitDeadline(spec.AgentInitDeadline.Duration))
+	}
+	if spec.ImageRepository != "" {
+		opts = append(opts, config.WithImageRepository(spec.ImageRepository))
+	}
+	if spec.ImageChannel != "" {
+		opts = append(opts, config.WithImageChannel(spec.ImageChannel))
+	}
+	if spec.Region != "" {
+		opts = append(opts, config.WithRegion(config.Region(spec.Region)))
+	}
+	if spec.FreezeAutoDetect != nil {
+		opts = append(opts, config.WithFreezeAutoDetect(*spec.FreezeAutoDetect))
+	}
+	return opts
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&current.OperatorConfig{}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return object.GetName() == current.OperatorConfigName
+		})).
+		Complete(r)
+}
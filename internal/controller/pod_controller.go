@@ -18,11 +18,15 @@ package controller
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/strings/slices"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -31,19 +35,30 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 	"github.com/newrelic/k8s-agents-operator/internal/instrumentation"
 )
 
+const reasonAgentInitDeadlineExceeded = "AgentInitDeadlineExceeded"
+
 // PodReconciler reconciles a Pod object
 type PodReconciler struct {
 	client.Client
 	Scheme            *runtime.Scheme
+	Recorder          record.EventRecorder
+	Config            *config.Config
 	healthMonitor     *instrumentation.HealthMonitor
 	operatorNamespace string
+	startTime         time.Time
+	// observedAgentInits is the uid of the pods, by name, whose agent init durations were already observed
+	observedAgentInits sync.Map
+	// reportedAgentInitDeadlines is the uid of the pods, by name, whose exceeded agent init deadline was already reported
+	reportedAgentInitDeadlines sync.Map
 }
 
 //+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
-//+kubebuilder:rbac:groups="",resources=pods/status,verbs=get
+//+kubebuilder:rbac:groups="",resources=pods/status,verbs=get;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -71,6 +86,8 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		pod.Namespace = req.Namespace
 		logger.V(2).Info("pod reconciliation; pod deleted event")
 		r.healthMonitor.PodRemove(&pod)
+		r.observedAgentInits.Delete(req.NamespacedName)
+		r.reportedAgentInitDeadlines.Delete(req.NamespacedName)
 		return ctrl.Result{}, nil
 	}
 	if err != nil {
@@ -79,13 +96,17 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	if pod.DeletionTimestamp != nil {
 		logger.V(2).Info("pod reconciliation; pod deleting event")
 		r.healthMonitor.PodRemove(&pod)
+		r.observedAgentInits.Delete(req.NamespacedName)
+		r.reportedAgentInitDeadlines.Delete(req.NamespacedName)
 		return ctrl.Result{}, nil
 	}
 
 	logger.V(2).Info("pod reconciliation; pod created event", "namespace", req.Namespace, "name", req.Name)
 	r.healthMonitor.PodSet(&pod)
+	r.observeAgentInitDurations(&pod)
 
 	if pod.Status.Phase == corev1.PodPending {
+		r.checkAgentInitDeadline(ctx, &pod)
 		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
 	}
 
@@ -96,6 +117,7 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 func (r *PodReconciler) SetupWithManager(mgr ctrl.Manager, healthMonitor *instrumentation.HealthMonitor, operatorNamespace string) error {
 	r.healthMonitor = healthMonitor
 	r.operatorNamespace = operatorNamespace
+	r.startTime = time.Now()
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 100}).
 		For(&corev1.Pod{}).
@@ -118,6 +140,103 @@ func (r *PodReconciler) SetupWithManager(mgr ctrl.Manager, healthMonitor *instru
 		Complete(r)
 }
 
+// checkAgentInitDeadline records an event on the pod if any of the agent init containers have not completed within the
+// configured deadline, so that a stuck agent download is visible instead of the pod silently waiting in init. It's
+// reported once per pod, as pending pods are requeued until they start.
+func (r *PodReconciler) checkAgentInitDeadline(ctx context.Context, pod *corev1.Pod) {
+	if r.Config == nil {
+		return
+	}
+	deadline := r.Config.AgentInitDeadline()
+	if deadline <= 0 || pod.Status.StartTime == nil {
+		return
+	}
+	elapsed := time.Since(pod.Status.StartTime.Time)
+	if elapsed < deadline {
+		return
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	if uid, ok := r.reportedAgentInitDeadlines.Load(key); ok && uid == pod.UID {
+		return
+	}
+	reported := false
+	for _, status := range pod.Status.InitContainerStatuses {
+		if !apm.IsAgentInitContainer(r.Config.InitContainerNamePrefix(), status.Name) {
+			continue
+		}
+		if status.State.Terminated != nil && status.State.Terminated.ExitCode == 0 {
+			continue
+		}
+		log.FromContext(ctx).Info("agent init container exceeded deadline",
+			"container", status.Name,
+			"deadline", deadline.String(),
+			"elapsed", elapsed.String(),
+		)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(pod, corev1.EventTypeWarning, reasonAgentInitDeadlineExceeded,
+				"agent init container %q has not completed within %s", status.Name, deadline.String())
+		}
+		reported = true
+	}
+	if reported {
+		r.reportedAgentInitDeadlines.Store(key, pod.UID)
+	}
+}
+
+// observeAgentInitDurations records the duration of the agent init containers of the pod once they have all
+// completed. Each one is measured from the time it could start, which is when the pod started or the init container
+// before it completed, so that it includes pulling the agent image. Pods initialized before the operator started are
+// skipped, since they may have been observed already.
+func (r *PodReconciler) observeAgentInitDurations(pod *corev1.Pod) {
+	if r.Config == nil || pod.Status.StartTime == nil {
+		return
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	if uid, ok := r.observedAgentInits.Load(key); ok && uid == pod.UID {
+		return
+	}
+
+	type agentInit struct {
+		language string
+		image    string
+		duration time.Duration
+	}
+	var agentInits []agentInit
+	prefix := r.Config.InitContainerNamePrefix()
+	readyAt := pod.Status.StartTime.Time
+	for _, status := range pod.Status.InitContainerStatuses {
+		canStartAt := readyAt
+		if terminated := status.State.Terminated; terminated != nil {
+			readyAt = terminated.FinishedAt.Time
+		} else if running := status.State.Running; running != nil {
+			readyAt = running.StartedAt.Time
+		}
+		if !apm.IsAgentInitContainer(prefix, status.Name) {
+			continue
+		}
+		terminated := status.State.Terminated
+		if terminated == nil || terminated.ExitCode != 0 {
+			return
+		}
+		if terminated.FinishedAt.Time.Before(r.startTime) {
+			return
+		}
+		agentInits = append(agentInits, agentInit{
+			language: strings.TrimPrefix(status.Name, prefix+"-"),
+			image:    status.Image,
+			duration: terminated.FinishedAt.Sub(canStartAt),
+		})
+	}
+	if len(agentInits) == 0 {
+		return
+	}
+
+	r.observedAgentInits.Store(key, pod.UID)
+	for _, observed := range agentInits {
+		agentInitDuration.WithLabelValues(observed.language, observed.image).Observe(observed.duration.Seconds())
+	}
+}
+
 func (r *PodReconciler) isPod(object client.Object) bool {
 	ns, ok := object.(*corev1.Pod)
 	if !ok {
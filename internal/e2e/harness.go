@@ -0,0 +1,250 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package e2e runs the operator's webhooks against an envtest API server, so that injection changes can be validated
+// end to end without a cluster. The API server binaries are taken from KUBEBUILDER_ASSETS, as set by `make go-test`,
+// or from bin/k8s.
+package e2e
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	webhookruntime "sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/api/v1alpha2"
+	"github.com/newrelic/k8s-agents-operator/api/v1beta1"
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+	"github.com/newrelic/k8s-agents-operator/internal/instrumentation"
+	"github.com/newrelic/k8s-agents-operator/internal/webhook"
+)
+
+// DefaultOperatorNamespace is the namespace the instrumentations and the license key secret are created in
+const DefaultOperatorNamespace = "newrelic"
+
+// fakeLicenseKey has the format of a license key, so that the secret is replicated
+const fakeLicenseKey = "fakesecretabc12300000000000000000000NRAL"
+
+// pollInterval and pollTimeout bound the waits for the webhook server and the manager cache
+const (
+	pollInterval = 50 * time.Millisecond
+	pollTimeout  = 30 * time.Second
+)
+
+// Harness is an envtest API server with the operator's instrumentation and pod webhooks registered, configured the
+// same way as the operator
+type Harness struct {
+	// Client talks to the API server directly, so the objects it creates go through the webhooks
+	Client            client.Client
+	OperatorNamespace string
+
+	testEnv  *envtest.Environment
+	mgrCache client.Reader
+	cancel   context.CancelFunc
+	done     chan error
+}
+
+// Start is used to start the API server and the webhooks, with the operator configured by the options
+func Start(ctx context.Context, opts ...config.Option) (*Harness, error) {
+	root := repositoryRoot()
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join(root, "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+		BinaryAssetsDirectory: filepath.Join(root, "bin", "k8s",
+			fmt.Sprintf("1.29.0-%s-%s", runtime.GOOS, runtime.GOARCH)),
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{filepath.Join(root, "config", "webhook")},
+		},
+	}
+	restConfig, err := testEnv.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the api server > %w", err)
+	}
+	h := &Harness{OperatorNamespace: DefaultOperatorNamespace, testEnv: testEnv, done: make(chan error, 1)}
+	if err = h.start(ctx, restConfig, opts); err != nil {
+		return nil, errors.Join(err, h.Stop())
+	}
+	return h, nil
+}
+
+func (h *Harness) start(ctx context.Context, restConfig *rest.Config, opts []config.Option) error {
+	scheme := k8sruntime.NewScheme()
+	for _, addToScheme := range []func(*k8sruntime.Scheme) error{
+		clientgoscheme.AddToScheme, admissionv1.AddToScheme, v1alpha2.AddToScheme, v1beta1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			return fmt.Errorf("failed to register scheme > %w", err)
+		}
+	}
+
+	var err error
+	h.Client, err = client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("failed to create the client > %w", err)
+	}
+
+	webhookInstallOptions := &h.testEnv.WebhookInstallOptions
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme: scheme,
+		WebhookServer: webhookruntime.NewServer(webhookruntime.Options{
+			Host:    webhookInstallOptions.LocalServingHost,
+			Port:    webhookInstallOptions.LocalServingPort,
+			CertDir: webhookInstallOptions.LocalServingCertDir,
+		}),
+		LeaderElection: false,
+		Metrics:        metricsserver.Options{BindAddress: "0"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the manager > %w", err)
+	}
+	h.mgrCache = mgr.GetClient()
+
+	logger := zap.New(zap.UseDevMode(true))
+	cfg := config.New(opts...)
+	if err = v1alpha2.SetupWebhookWithManager(mgr, h.OperatorNamespace, cfg.MinAgentVersion, cfg.AllowedImageRegistries()); err != nil {
+		return fmt.Errorf("failed to register the v1alpha2 instrumentation webhook > %w", err)
+	}
+	if err = v1beta1.SetupWebhookWithManager(mgr, h.OperatorNamespace, cfg.MinAgentVersion, cfg.AllowedImageRegistries()); err != nil {
+		return fmt.Errorf("failed to register the v1beta1 instrumentation webhook > %w", err)
+	}
+	if err = webhook.SetupWebhookWithManager(mgr, h.OperatorNamespace, logger, &cfg); err != nil {
+		return fmt.Errorf("failed to register the pod webhook > %w", err)
+	}
+
+	mgrCtx, cancel := context.WithCancel(ctx)
+	h.cancel = cancel
+	go func() {
+		h.done <- mgr.Start(mgrCtx)
+	}()
+
+	addr := fmt.Sprintf("%s:%d", webhookInstallOptions.LocalServingHost, webhookInstallOptions.LocalServingPort)
+	dialer := &net.Dialer{Timeout: time.Second}
+	if err = wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true, func(context.Context) (bool, error) {
+		// #nosec G402
+		conn, tlsErr := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if tlsErr != nil {
+			return false, nil
+		}
+		_ = conn.Close()
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("failed to wait for the webhook server > %w", err)
+	}
+
+	return h.CreateNamespace(ctx, h.OperatorNamespace, nil)
+}
+
+// Stop is used to stop the webhooks and the API server
+func (h *Harness) Stop() error {
+	var err error
+	if h.cancel != nil {
+		h.cancel()
+		err = <-h.done
+	}
+	return errors.Join(err, h.testEnv.Stop())
+}
+
+// CreateNamespace is used to create a namespace with the labels, which the namespace label selectors match
+func (h *Harness) CreateNamespace(ctx context.Context, name string, labels map[string]string) error {
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	if err := h.Client.Create(ctx, &ns); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %q > %w", name, err)
+	}
+	return h.waitForCache(ctx, client.ObjectKey{Name: name}, &corev1.Namespace{})
+}
+
+// ApplyInstrumentation is used to create the instrumentation, in the operator namespace when it has none, along with
+// the license key secret it refers to. It returns once the pod webhook can see the instrumentation.
+func (h *Harness) ApplyInstrumentation(ctx context.Context, inst current.Instrumentation) (current.Instrumentation, error) {
+	if inst.Namespace == "" {
+		inst.Namespace = h.OperatorNamespace
+	}
+	secretName := inst.Spec.LicenseKeySecret
+	if secretName == "" {
+		secretName = instrumentation.DefaultLicenseKeySecretName
+	}
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: inst.Namespace},
+		Data:       map[string][]byte{apm.LicenseKey: []byte(fakeLicenseKey)},
+	}
+	if err := h.Client.Create(ctx, &secret); err != nil && !apierrors.IsAlreadyExists(err) {
+		return inst, fmt.Errorf("failed to create the license key secret > %w", err)
+	}
+	if err := h.Client.Create(ctx, &inst); err != nil {
+		return inst, fmt.Errorf("failed to create instrumentation %q > %w", inst.Name, err)
+	}
+	if err := h.waitForCache(ctx, client.ObjectKeyFromObject(&inst), &current.Instrumentation{}); err != nil {
+		return inst, err
+	}
+	return inst, nil
+}
+
+// DeleteInstrumentation is used to delete the instrumentation, returning once the pod webhook no longer sees it
+func (h *Harness) DeleteInstrumentation(ctx context.Context, inst current.Instrumentation) error {
+	if err := h.Client.Delete(ctx, &inst); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete instrumentation %q > %w", inst.Name, err)
+	}
+	return wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true, func(ctx context.Context) (bool, error) {
+		err := h.mgrCache.Get(ctx, client.ObjectKeyFromObject(&inst), &current.Instrumentation{})
+		return apierrors.IsNotFound(err), nil
+	})
+}
+
+// CreatePod is used to create the pod through the pod webhook, returning the pod as it was admitted
+func (h *Harness) CreatePod(ctx context.Context, pod corev1.Pod) (corev1.Pod, error) {
+	if err := h.Client.Create(ctx, &pod); err != nil {
+		return pod, fmt.Errorf("failed to create pod %q > %w", pod.Name, err)
+	}
+	return pod, nil
+}
+
+// waitForCache is used to wait for the manager cache, which the pod webhook reads from, to have the object
+func (h *Harness) waitForCache(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true, func(ctx context.Context) (bool, error) {
+		err := h.mgrCache.Get(ctx, key, obj)
+		return err == nil, nil
+	}); err != nil {
+		return fmt.Errorf("failed to wait for %s to be cached > %w", key, err)
+	}
+	return nil
+}
+
+// repositoryRoot is the root of the repository, so that the harness works from the directory of any test package
+func repositoryRoot() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
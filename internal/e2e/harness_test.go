@@ -0,0 +1,131 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+)
+
+var harness *Harness
+
+func TestMain(m *testing.M) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var err error
+	harness, err = Start(ctx)
+	if err != nil {
+		fmt.Printf("failed to start the harness: %v", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	if err = harness.Stop(); err != nil {
+		fmt.Printf("failed to stop the harness: %v", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+func TestHarness_Inject(t *testing.T) {
+	tests := []struct {
+		name              string
+		language          string
+		podLabels         map[string]string
+		expectedInjected  bool
+		expectedEnvName   string
+		expectedEnvValue  string
+		expectedInitImage string
+	}{
+		{
+			name:              "java",
+			language:          "java",
+			podLabels:         map[string]string{"app": "java"},
+			expectedInjected:  true,
+			expectedEnvName:   "JAVA_TOOL_OPTIONS",
+			expectedEnvValue:  "-javaagent:/newrelic-instrumentation/newrelic-agent.jar",
+			expectedInitImage: "newrelic/newrelic-java-init:latest",
+		},
+		{
+			name:              "nodejs",
+			language:          "nodejs",
+			podLabels:         map[string]string{"app": "nodejs"},
+			expectedInjected:  true,
+			expectedEnvName:   "NODE_OPTIONS",
+			expectedEnvValue:  "--require /newrelic-instrumentation/newrelicinstrumentation.js",
+			expectedInitImage: "newrelic/newrelic-node-init:latest",
+		},
+		{
+			name:      "pod label selector mismatch",
+			language:  "java",
+			podLabels: map[string]string{"app": "other"},
+		},
+	}
+	ctx := context.Background()
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			namespace := fmt.Sprintf("e2e-%d", i)
+			if err := harness.CreateNamespace(ctx, namespace, nil); err != nil {
+				t.Fatal(err)
+			}
+			image := test.expectedInitImage
+			if image == "" {
+				image = "newrelic/newrelic-java-init:latest"
+			}
+			inst, err := harness.ApplyInstrumentation(ctx, current.Instrumentation{
+				ObjectMeta: metav1.ObjectMeta{Name: "e2e-" + test.name},
+				Spec: current.InstrumentationSpec{
+					Agent:                  current.Agent{Language: test.language, Image: image},
+					PodLabelSelector:       metav1.LabelSelector{MatchLabels: map[string]string{"app": test.language}},
+					NamespaceLabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": namespace}},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if err := harness.DeleteInstrumentation(ctx, inst); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			pod, err := harness.CreatePod(ctx, corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace, Labels: test.podLabels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			initIndex := slices.IndexFunc(pod.Spec.InitContainers, func(c corev1.Container) bool {
+				return c.Name == "newrelic-instrumentation-"+test.language
+			})
+			if !test.expectedInjected {
+				if initIndex != -1 || len(pod.Spec.Containers[0].Env) > 0 {
+					t.Fatalf("expected the pod not to be injected, got %v", pod.Spec)
+				}
+				return
+			}
+			if initIndex == -1 {
+				t.Fatalf("expected the agent init container, got %v", pod.Spec.InitContainers)
+			}
+			if actual := pod.Spec.InitContainers[initIndex].Image; actual != test.expectedInitImage {
+				t.Errorf("expected the agent init image %q, got %q", test.expectedInitImage, actual)
+			}
+			if !slices.Contains(pod.Spec.Containers[0].Env, corev1.EnvVar{Name: test.expectedEnvName, Value: test.expectedEnvValue}) {
+				t.Errorf("expected %s=%s, got %v", test.expectedEnvName, test.expectedEnvValue, pod.Spec.Containers[0].Env)
+			}
+			if _, ok := pod.Annotations["newrelic.com/instrumentation-versions"]; !ok {
+				t.Errorf("expected the instrumentation versions annotation, got %v", pod.Annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+)
+
+// matchesCohort is used to check if the pod is routed to the instrumentation by its cohort label. The pod is routed to
+// the instrumentation its label value names, or else to the default instrumentations of the label. Instrumentations
+// without a cohort match every pod.
+func matchesCohort(inst current.Instrumentation, pod corev1.Pod, insts []current.Instrumentation) bool {
+	if inst.Spec.Cohort == nil {
+		return true
+	}
+	value := pod.Labels[inst.Spec.Cohort.Label]
+	if value == inst.Name {
+		return true
+	}
+	if !inst.Spec.Cohort.Default {
+		return false
+	}
+	for _, other := range insts {
+		if other.Namespace == inst.Namespace && other.Name == value &&
+			other.Spec.Cohort != nil && other.Spec.Cohort.Label == inst.Spec.Cohort.Label {
+			return false
+		}
+	}
+	return true
+}
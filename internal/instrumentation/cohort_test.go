@@ -0,0 +1,55 @@
+package instrumentation
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+)
+
+func TestMatchesCohort(t *testing.T) {
+	cohort := func(name string, isDefault bool) current.Instrumentation {
+		return current.Instrumentation{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "newrelic"},
+			Spec: current.InstrumentationSpec{
+				Cohort: &current.Cohort{Label: "newrelic.com/agent-cohort", Default: isDefault},
+			},
+		}
+	}
+	stable := cohort("java-stable", true)
+	candidate := cohort("java-candidate", false)
+	uncohorted := current.Instrumentation{ObjectMeta: metav1.ObjectMeta{Name: "python", Namespace: "newrelic"}}
+	insts := []current.Instrumentation{stable, candidate, uncohorted}
+
+	tests := []struct {
+		name     string
+		cohort   string
+		expected []string
+	}{
+		{name: "no label", expected: []string{"java-stable", "python"}},
+		{name: "candidate", cohort: "java-candidate", expected: []string{"java-candidate", "python"}},
+		{name: "stable", cohort: "java-stable", expected: []string{"java-stable", "python"}},
+		{name: "unknown instrumentation", cohort: "java-other", expected: []string{"java-stable", "python"}},
+		{name: "instrumentation without the cohort", cohort: "python", expected: []string{"java-stable", "python"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}}}
+			if test.cohort != "" {
+				pod.Labels["newrelic.com/agent-cohort"] = test.cohort
+			}
+			var actual []string
+			for _, inst := range insts {
+				if matchesCohort(inst, pod, insts) {
+					actual = append(actual, inst.Name)
+				}
+			}
+			if diff := cmp.Diff(test.expected, actual); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+)
+
+// ComposeLanguageInstrumentations is used to compose the instrumentations of each language into one, rather than
+// rejecting a pod matched by several instrumentations of the same language, which smooths migrating from one
+// instrumentation to another. The newest instrumentation, ordered by name when created at the same time, wins every
+// conflicting setting. The older ones only fill in the settings it leaves empty, and add the env vars, containers,
+// resource attributes, tolerations and node affinity requirements it doesn't have. The composed instrumentation keeps the
+// name of the newest one, which is the one recorded on the pod.
+func ComposeLanguageInstrumentations(instCandidates []*current.Instrumentation) []*current.Instrumentation {
+	byLanguage := map[string][]*current.Instrumentation{}
+	var languages []string
+	for _, candidate := range instCandidates {
+		language := candidate.Spec.Agent.Language
+		if _, ok := byLanguage[language]; !ok {
+			languages = append(languages, language)
+		}
+		byLanguage[language] = append(byLanguage[language], candidate)
+	}
+
+	composed := make([]*current.Instrumentation, 0, len(languages))
+	for _, language := range languages {
+		insts := byLanguage[language]
+		if len(insts) == 1 {
+			composed = append(composed, insts[0])
+			continue
+		}
+		slices.SortStableFunc(insts, func(a, b *current.Instrumentation) int {
+			if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+				return b.CreationTimestamp.Compare(a.CreationTimestamp.Time)
+			}
+			return strings.Compare(a.Name, b.Name)
+		})
+		inst := insts[0].DeepCopy()
+		for _, older := range insts[1:] {
+			composeSpec(&inst.Spec, older.Spec)
+		}
+		composed = append(composed, inst)
+	}
+	return composed
+}
+
+// composeSpec is used to fill in the settings of the spec left empty with the ones of the older spec. The selectors,
+// schedule and cohort only decide which pods an instrumentation matches, so they're kept as they are.
+func composeSpec(spec *current.InstrumentationSpec, older current.InstrumentationSpec) {
+	if spec.Agent.Image == "" {
+		spec.Agent.Image = older.Agent.Image
+	}
+	if spec.Agent.InstallerImage == "" {
+		spec.Agent.InstallerImage = older.Agent.InstallerImage
+	}
+	if spec.Agent.VolumeSizeLimit == nil && older.Agent.VolumeSizeLimit != nil {
+		volumeSizeLimit := older.Agent.VolumeSizeLimit.DeepCopy()
+		spec.Agent.VolumeSizeLimit = &volumeSizeLimit
+	}
+	if reflect.DeepEqual(spec.Agent.Resources, corev1.ResourceRequirements{}) {
+		spec.Agent.Resources = *older.Agent.Resources.DeepCopy()
+	}
+	if len(spec.Agent.StartupWrapper) == 0 {
+		spec.Agent.StartupWrapper = slices.Clone(older.Agent.StartupWrapper)
+	}
+	if len(spec.Agent.StripEnv) == 0 {
+		spec.Agent.StripEnv = slices.Clone(older.Agent.StripEnv)
+	}
+	if spec.Agent.InstallPath == "" {
+		spec.Agent.InstallPath = older.Agent.InstallPath
+	}
+	spec.Agent.CommandArgs = spec.Agent.CommandArgs || older.Agent.CommandArgs
+	if len(spec.Agent.InitCommand) == 0 {
+		spec.Agent.InitCommand = slices.Clone(older.Agent.InitCommand)
+		spec.Agent.InitArgs = slices.Clone(older.Agent.InitArgs)
+	}
+	for _, env := range older.Agent.Env {
+		if !slices.ContainsFunc(spec.Agent.Env, func(e corev1.EnvVar) bool { return e.Name == env.Name }) {
+			spec.Agent.Env = append(spec.Agent.Env, *env.DeepCopy())
+		}
+	}
+
+	if spec.AppName.Template == "" && spec.AppName.Fallback == "" {
+		spec.AppName = older.AppName
+	}
+	if len(spec.Propagators) == 0 {
+		spec.Propagators = slices.Clone(older.Propagators)
+	}
+	if spec.Sampler.Type == "" && spec.Sampler.Argument == "" {
+		spec.Sampler = older.Sampler
+	}
+	if spec.LicenseKeySecret == "" {
+		spec.LicenseKeySecret = older.LicenseKeySecret
+	}
+	if spec.HealthAgent.IsEmpty() {
+		spec.HealthAgent = *older.HealthAgent.DeepCopy()
+	}
+	if spec.AgentConfigMap == "" {
+		spec.AgentConfigMap = older.AgentConfigMap
+	}
+	if !spec.Exporter.HasEndpoint() {
+		spec.Exporter = older.Exporter
+	}
+	if spec.Mode == "" {
+		spec.Mode = older.Mode
+	}
+	if spec.Profiling == nil && older.Profiling != nil {
+		profiling := *older.Profiling
+		spec.Profiling = &profiling
+	}
+	if spec.DNSPolicy == "" {
+		spec.DNSPolicy = older.DNSPolicy
+	}
+	if spec.DNSConfig == nil && older.DNSConfig != nil {
+		spec.DNSConfig = older.DNSConfig.DeepCopy()
+	}
+	for _, container := range older.Containers {
+		if !slices.ContainsFunc(spec.Containers, func(c current.ContainerEnv) bool { return c.Name == container.Name }) {
+			spec.Containers = append(spec.Containers, *container.DeepCopy())
+		}
+	}
+	if len(spec.MinPodRequests) == 0 {
+		spec.MinPodRequests = older.MinPodRequests.DeepCopy()
+	}
+
+	if len(older.Resource.Attributes) > 0 {
+		attributes := maps.Clone(older.Resource.Attributes)
+		maps.Copy(attributes, spec.Resource.Attributes)
+		spec.Resource.Attributes = attributes
+	}
+	spec.Resource.AddK8sUIDAttributes = spec.Resource.AddK8sUIDAttributes || older.Resource.AddK8sUIDAttributes
+
+	for _, toleration := range older.Tolerations {
+		if !slices.ContainsFunc(spec.Tolerations, func(t corev1.Toleration) bool { return t.MatchToleration(&toleration) }) {
+			spec.Tolerations = append(spec.Tolerations, *toleration.DeepCopy())
+		}
+	}
+	for _, requirement := range older.RequiredNodeAffinity {
+		if !slices.ContainsFunc(spec.RequiredNodeAffinity, func(r corev1.NodeSelectorRequirement) bool {
+			return reflect.DeepEqual(r, requirement)
+		}) {
+			spec.RequiredNodeAffinity = append(spec.RequiredNodeAffinity, *requirement.DeepCopy())
+		}
+	}
+}
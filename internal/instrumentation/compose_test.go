@@ -0,0 +1,178 @@
+package instrumentation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/api/v1beta1"
+)
+
+func TestComposeLanguageInstrumentations(t *testing.T) {
+	created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	oldJava := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "java-old", CreationTimestamp: metav1.NewTime(created)},
+		Spec: current.InstrumentationSpec{
+			Agent: current.Agent{
+				Language: "java",
+				Image:    "newrelic/java:1.0",
+				Env: []corev1.EnvVar{
+					{Name: "NEW_RELIC_LOG_LEVEL", Value: "info"},
+					{Name: "NEW_RELIC_DISTRIBUTED_TRACING_ENABLED", Value: "true"},
+				},
+			},
+			AgentConfigMap: "java-config",
+			Resource:       v1beta1.Resource{Attributes: map[string]string{"team": "payments", "env": "prod"}},
+			Tolerations:    []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "apm"}},
+		},
+	}
+	newJava := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "java-new", CreationTimestamp: metav1.NewTime(created.Add(time.Hour))},
+		Spec: current.InstrumentationSpec{
+			Agent: current.Agent{
+				Language: "java",
+				Image:    "newrelic/java:2.0",
+				Env:      []corev1.EnvVar{{Name: "NEW_RELIC_LOG_LEVEL", Value: "debug"}},
+			},
+			Resource: v1beta1.Resource{Attributes: map[string]string{"env": "staging"}},
+		},
+	}
+	python := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "python"},
+		Spec:       current.InstrumentationSpec{Agent: current.Agent{Language: "python", Image: "newrelic/python:1.0"}},
+	}
+
+	actual := ComposeLanguageInstrumentations([]*current.Instrumentation{oldJava, python, newJava})
+	expected := []*current.Instrumentation{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "java-new", CreationTimestamp: metav1.NewTime(created.Add(time.Hour))},
+			Spec: current.InstrumentationSpec{
+				Agent: current.Agent{
+					Language: "java",
+					Image:    "newrelic/java:2.0",
+					Env: []corev1.EnvVar{
+						{Name: "NEW_RELIC_LOG_LEVEL", Value: "debug"},
+						{Name: "NEW_RELIC_DISTRIBUTED_TRACING_ENABLED", Value: "true"},
+					},
+				},
+				AgentConfigMap: "java-config",
+				Resource:       v1beta1.Resource{Attributes: map[string]string{"team": "payments", "env": "staging"}},
+				Tolerations:    []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "apm"}},
+			},
+		},
+		python,
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+	if newJava.Spec.AgentConfigMap != "" || len(newJava.Spec.Agent.Env) != 1 {
+		t.Error("expected the matched instrumentations to be left unchanged")
+	}
+}
+
+func TestComposeLanguageInstrumentations_SameCreationTime(t *testing.T) {
+	a := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "a"},
+		Spec:       current.InstrumentationSpec{Agent: current.Agent{Language: "java", Image: "newrelic/java:a"}},
+	}
+	b := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "b"},
+		Spec:       current.InstrumentationSpec{Agent: current.Agent{Language: "java", Image: "newrelic/java:b"}},
+	}
+	actual := ComposeLanguageInstrumentations([]*current.Instrumentation{b, a})
+	if len(actual) != 1 || actual[0].Name != "a" || actual[0].Spec.Agent.Image != "newrelic/java:a" {
+		t.Errorf("expected instrumentation a to win, got %v", actual)
+	}
+}
+
+// TestComposeSpec_EveryField fails when a field is added to the spec without composeSpec filling it in, or without
+// listing it as not composed
+func TestComposeSpec_EveryField(t *testing.T) {
+	notComposed := map[string]bool{
+		"PodLabelSelector":       true,
+		"NamespaceLabelSelector": true,
+		"OwnerKinds":             true,
+		"SchedulerNameSelector":  true,
+		"PodFieldSelector":       true,
+		"Schedule":               true,
+		"Cohort":                 true,
+		"Agent.Language":         true,
+	}
+
+	var paths [][]int
+	var names []string
+	specType := reflect.TypeOf(current.InstrumentationSpec{})
+	for i := range specType.NumField() {
+		field := specType.Field(i)
+		if field.Name != "Agent" {
+			paths = append(paths, []int{i})
+			names = append(names, field.Name)
+			continue
+		}
+		for j := range field.Type.NumField() {
+			paths = append(paths, []int{i, j})
+			names = append(names, "Agent."+field.Type.Field(j).Name)
+		}
+	}
+
+	for k, path := range paths {
+		name := names[k]
+		if notComposed[name] {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			var older current.InstrumentationSpec
+			fillValue(reflect.ValueOf(&older).Elem().FieldByIndex(path), 0)
+
+			var spec current.InstrumentationSpec
+			composeSpec(&spec, older)
+
+			if reflect.ValueOf(spec).FieldByIndex(path).IsZero() {
+				t.Errorf("%s of the older spec isn't composed, fill it in with composeSpec or list it as not composed", name)
+			}
+		})
+	}
+}
+
+// fillValue is used to set every exported field of the value to something other than its zero value
+func fillValue(v reflect.Value, depth int) {
+	if depth > 8 {
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillValue(v.Elem(), depth+1)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fillValue(v.Index(0), depth+1)
+	case reflect.Map:
+		key := reflect.New(v.Type().Key()).Elem()
+		fillValue(key, depth+1)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		fillValue(elem, depth+1)
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(key, elem)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Field(i).CanSet() {
+				fillValue(v.Field(i), depth+1)
+			}
+		}
+	}
+}
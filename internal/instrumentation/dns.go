@@ -0,0 +1,57 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// maxDNSNameservers is the most nameservers a pod can have
+const maxDNSNameservers = 3
+
+// applyDNS is used to add the DNS policy and config of the agents to a pod, so that they resolve the New Relic
+// endpoints with the configured resolvers. The policy is only set on pods with the default ClusterFirst policy, and the
+// nameservers, searches and options of the pod are kept. Nameservers beyond the most a pod can have are left out.
+func applyDNS(pod corev1.Pod, policy corev1.DNSPolicy, dnsConfig *corev1.PodDNSConfig) corev1.Pod {
+	if policy != "" && (pod.Spec.DNSPolicy == "" || pod.Spec.DNSPolicy == corev1.DNSClusterFirst) {
+		pod.Spec.DNSPolicy = policy
+	}
+	if dnsConfig == nil {
+		return pod
+	}
+	if pod.Spec.DNSConfig == nil {
+		pod.Spec.DNSConfig = &corev1.PodDNSConfig{}
+	}
+	for _, nameserver := range dnsConfig.Nameservers {
+		if len(pod.Spec.DNSConfig.Nameservers) < maxDNSNameservers && !slices.Contains(pod.Spec.DNSConfig.Nameservers, nameserver) {
+			pod.Spec.DNSConfig.Nameservers = append(pod.Spec.DNSConfig.Nameservers, nameserver)
+		}
+	}
+	for _, search := range dnsConfig.Searches {
+		if !slices.Contains(pod.Spec.DNSConfig.Searches, search) {
+			pod.Spec.DNSConfig.Searches = append(pod.Spec.DNSConfig.Searches, search)
+		}
+	}
+	for _, option := range dnsConfig.Options {
+		if !slices.ContainsFunc(pod.Spec.DNSConfig.Options, func(o corev1.PodDNSConfigOption) bool { return o.Name == option.Name }) {
+			pod.Spec.DNSConfig.Options = append(pod.Spec.DNSConfig.Options, *option.DeepCopy())
+		}
+	}
+	return pod
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// InjectionFailedAnnotation is set on the pods admitted without injection, because their workload kept failing it
+const InjectionFailedAnnotation = "newrelic.com/injection-failed"
+
+const reasonInjectionFailureThreshold = "InjectionFailureThresholdReached"
+
+// controllerRevisionHashLabel is set by the stateful set and daemon set controllers on the pods of each revision
+const controllerRevisionHashLabel = "controller-revision-hash"
+
+// injectionFailureTTL is how long the failures of a workload are kept after its last one, so that the workloads which
+// are gone don't pile up, and the ones which reached the threshold are eventually tried again
+const injectionFailureTTL = time.Hour
+
+// workloadKey identifies the workload of a pod. The direct owner of the pods of a deployment is the replica set of
+// its revision, and stateful set and daemon set pods carry the hash of their revision, so that a changed pod template
+// is a different workload, which is tried again. The jobs of a cron job are the same workload, as each of them is new.
+type workloadKey struct {
+	namespace string
+	kind      string
+	name      string
+	revision  string
+}
+
+// InjectionFailureTracker is used to count the consecutive injection failures of each workload. Once a workload
+// reaches the failure threshold, its pods are no longer injected, failing open, until its pod template changes, the
+// failures expire after the injectionFailureTTL, or the operator restarts.
+type InjectionFailureTracker struct {
+	threshold int
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures map[workloadKey]*workloadFailures
+}
+
+// workloadFailures are the failures of a workload, with the uids of the admission requests they were counted for, so
+// that the reinvocation of the webhook for the same pod isn't counted again
+type workloadFailures struct {
+	count      int
+	admissions []types.UID
+	lastFailed time.Time
+}
+
+// NewInjectionFailureTracker is the constructor for tracking injection failures. A threshold of zero disables it.
+func NewInjectionFailureTracker(threshold int) *InjectionFailureTracker {
+	return &InjectionFailureTracker{
+		threshold: threshold,
+		now:       time.Now,
+		failures:  make(map[workloadKey]*workloadFailures),
+	}
+}
+
+// getWorkloadKey is used to identify the workload of the pod, by its controller, or its generate name or name when
+// it has none
+func getWorkloadKey(ns corev1.Namespace, pod corev1.Pod) workloadKey {
+	key := workloadKey{namespace: ns.Name, kind: "Pod", name: pod.Name, revision: pod.Labels[controllerRevisionHashLabel]}
+	if key.namespace == "" {
+		key.namespace = pod.Namespace
+	}
+	if pod.GenerateName != "" {
+		key.name = pod.GenerateName
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller != nil && *owner.Controller {
+			key.kind, key.name = owner.Kind, owner.Name
+			break
+		}
+	}
+	return key
+}
+
+// getCronJobWorkloadKey is used to identify the pods of the jobs of a cron job by the cron job, looking the job up
+// with the client. Any other workload key is returned as is.
+func getCronJobWorkloadKey(ctx context.Context, c client.Client, key workloadKey) workloadKey {
+	if c == nil || key.kind != "Job" {
+		return key
+	}
+	var job batchv1.Job
+	if err := c.Get(ctx, client.ObjectKey{Namespace: key.namespace, Name: key.name}, &job); err != nil {
+		return key
+	}
+	if owner := metav1.GetControllerOf(&job); owner != nil && owner.Kind == "CronJob" {
+		key.kind, key.name = owner.Kind, owner.Name
+	}
+	return key
+}
+
+// Exceeded is used to check if the workload reached the failure threshold
+func (t *InjectionFailureTracker) Exceeded(key workloadKey) bool {
+	if t == nil || t.threshold <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire()
+	failures, ok := t.failures[key]
+	return ok && failures.count >= t.threshold
+}
+
+// Failed is used to count a failed injection of the workload, returning true when it makes the workload reach the
+// failure threshold. A failure is counted once per admission request uid, an empty uid is always counted.
+func (t *InjectionFailureTracker) Failed(key workloadKey, admission types.UID) bool {
+	if t == nil || t.threshold <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire()
+	failures, ok := t.failures[key]
+	if !ok {
+		failures = &workloadFailures{}
+		t.failures[key] = failures
+	}
+	failures.lastFailed = t.now()
+	if admission != "" {
+		if slices.Contains(failures.admissions, admission) {
+			return false
+		}
+		failures.admissions = append(failures.admissions, admission)
+	}
+	failures.count++
+	if failures.count != t.threshold {
+		return false
+	}
+	injectionFailedWorkloads.WithLabelValues(key.namespace, key.kind, key.name).Set(1)
+	return true
+}
+
+// expire is used to forget the failures of the workloads which didn't fail within the injectionFailureTTL. The lock
+// must be held.
+func (t *InjectionFailureTracker) expire() {
+	now := t.now()
+	for key, failures := range t.failures {
+		if now.Sub(failures.lastFailed) >= injectionFailureTTL {
+			delete(t.failures, key)
+			injectionFailedWorkloads.DeleteLabelValues(key.namespace, key.kind, key.name)
+		}
+	}
+}
+
+// Succeeded is used to forget the failures of the workload, once it was injected
+func (t *InjectionFailureTracker) Succeeded(key workloadKey) {
+	if t == nil || t.threshold <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.failures[key]; ok {
+		delete(t.failures, key)
+		injectionFailedWorkloads.DeleteLabelValues(key.namespace, key.kind, key.name)
+	}
+}
+
+// failedAnnotationValue is the value of the injection failed annotation, with the failure threshold reached
+func (t *InjectionFailureTracker) failedAnnotationValue() string {
+	return "failed " + strconv.Itoa(t.threshold) + " consecutive times"
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestInjectionFailureTracker_Failed(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewInjectionFailureTracker(2)
+	tracker.now = func() time.Time { return now }
+	key := workloadKey{namespace: "shop", kind: "ReplicaSet", name: "shop-1"}
+
+	assert.False(t, tracker.Failed(key, "uid-1"))
+	assert.False(t, tracker.Failed(key, "uid-1"), "the reinvocation for the same admission isn't counted again")
+	assert.False(t, tracker.Exceeded(key))
+	assert.True(t, tracker.Failed(key, "uid-2"))
+	assert.True(t, tracker.Exceeded(key))
+
+	// the failures expire, so the workload is tried again
+	now = now.Add(injectionFailureTTL)
+	assert.False(t, tracker.Exceeded(key))
+	assert.Empty(t, tracker.failures)
+}
+
+func TestGetCronJobWorkloadKey(t *testing.T) {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "report-28950000", OwnerReferences: []metav1.OwnerReference{
+		{APIVersion: "batch/v1", Kind: "CronJob", Name: "report", UID: "cronjob-uid", Controller: ptr.To(true)},
+	}}}
+	c := fake.NewClientBuilder().WithObjects(job).Build()
+	ctx := context.Background()
+
+	assert.Equal(t, workloadKey{namespace: "shop", kind: "CronJob", name: "report"},
+		getCronJobWorkloadKey(ctx, c, workloadKey{namespace: "shop", kind: "Job", name: "report-28950000"}))
+	assert.Equal(t, workloadKey{namespace: "shop", kind: "Job", name: "migrate"},
+		getCronJobWorkloadKey(ctx, c, workloadKey{namespace: "shop", kind: "Job", name: "migrate"}), "a job without a cron job, or which is gone, is kept")
+	assert.Equal(t, workloadKey{namespace: "shop", kind: "ReplicaSet", name: "shop-1"},
+		getCronJobWorkloadKey(ctx, c, workloadKey{namespace: "shop", kind: "ReplicaSet", name: "shop-1"}))
+}
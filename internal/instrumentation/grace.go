@@ -0,0 +1,36 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// applyMinTerminationGracePeriod is used to raise the termination grace period of a pod to the minimum, so that the
+// agents have the time to flush their data on shutdown. Pods without a grace period get the default of 30 seconds, and
+// longer grace periods are kept.
+func applyMinTerminationGracePeriod(pod corev1.Pod, seconds int64) corev1.Pod {
+	gracePeriod := int64(corev1.DefaultTerminationGracePeriodSeconds)
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		gracePeriod = *pod.Spec.TerminationGracePeriodSeconds
+	}
+	if seconds <= 0 || gracePeriod >= seconds {
+		return pod
+	}
+	pod.Spec.TerminationGracePeriodSeconds = &seconds
+	return pod
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"sync"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
 	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
 	"github.com/newrelic/k8s-agents-operator/internal/instrumentation/util/ticker"
 	"github.com/newrelic/k8s-agents-operator/internal/instrumentation/util/worker"
 )
@@ -77,17 +79,23 @@ type event struct {
 // instrumentationMetric contains a copy(pointer to the copy) of the instrumentation, a copy(shared pointer copy) of
 // each matching pod metric (pod + health) along with the aggregated summary of the health of all the pod metric health
 type instrumentationMetric struct {
-	instrumentationID string
-	instrumentation   *current.Instrumentation
-	podMetrics        []*podMetric
-	doneCh            chan struct{}
-	podsMatching      int64
-	podsInjected      int64
-	podsNotReady      int64
-	podsOutdated      int64
-	podsHealthy       int64
-	podsUnhealthy     int64
-	unhealthyPods     []current.UnhealthyPodError
+	instrumentationID  string
+	instrumentation    *current.Instrumentation
+	podMetrics         []*podMetric
+	doneCh             chan struct{}
+	podsMatching       int64
+	podsInjected       int64
+	podsNotReady       int64
+	podsOutdated       int64
+	podsDrifted        int64
+	podsHealthy        int64
+	podsUnhealthy      int64
+	unhealthyPods      []current.UnhealthyPodError
+	autoscalingVersion string
+	// driftOnly is set for the instrumentations without the health agent, which only have their drift reported
+	driftOnly bool
+	// languages has the agent language of each instrumentation, by namespaced name, at the time of the health check
+	languages map[string]string
 }
 
 // resolve marks the instrumentation metric done.  anything waiting via `wait` will continue
@@ -122,12 +130,18 @@ func (im *instrumentationMetric) isDiff() bool {
 	if im.instrumentation.Status.PodsMatching != im.podsMatching {
 		return true
 	}
+	if im.instrumentation.Status.PodsDrifted != im.podsDrifted {
+		return true
+	}
 	if im.instrumentation.Status.PodsHealthy != im.podsHealthy {
 		return true
 	}
 	if im.instrumentation.Status.PodsUnhealthy != im.podsUnhealthy {
 		return true
 	}
+	if im.instrumentation.Status.AutoscalingVersion != im.autoscalingVersion {
+		return true
+	}
 	sort.Slice(im.unhealthyPods, func(i, j int) bool { return im.unhealthyPods[i].Pod < im.unhealthyPods[j].Pod })
 	return !reflect.DeepEqual(im.unhealthyPods, im.instrumentation.Status.UnhealthyPodsErrors)
 }
@@ -139,9 +153,11 @@ func (im *instrumentationMetric) syncStatus() {
 	im.instrumentation.Status.PodsMatching = im.podsMatching
 	im.instrumentation.Status.PodsOutdated = im.podsOutdated
 	im.instrumentation.Status.PodsNotReady = im.podsNotReady
+	im.instrumentation.Status.PodsDrifted = im.podsDrifted
 	im.instrumentation.Status.PodsHealthy = im.podsHealthy
 	im.instrumentation.Status.PodsUnhealthy = im.podsUnhealthy
 	im.instrumentation.Status.UnhealthyPodsErrors = im.unhealthyPods
+	im.instrumentation.Status.AutoscalingVersion = im.autoscalingVersion
 }
 
 // podMetric contains the pod, it's id (used for logging), health (empty by default), and doneCh - which is closed once health has been retrieved
@@ -214,9 +230,16 @@ type HealthMonitor struct {
 
 	healthCheckTimeout time.Duration
 	tickInterval       time.Duration
+
+	readinessGate       corev1.PodConditionType
+	podReadinessUpdater PodReadinessUpdater
+
+	autoscalingVersion func() autodetect.AutoscalingVersion
 }
 
-// NewHealthMonitor returns a new instance of a health monitor which check the health of pods via the health sidecar
+// NewHealthMonitor returns a new instance of a health monitor which check the health of pods via the health sidecar.
+// When the readiness gate is set, its condition is set on the pods which have it once their agent reports healthy.
+// When autoscalingVersion is set, the detected autoscaling version is reported in the status of the instrumentations.
 func NewHealthMonitor(
 	instrumentationStatusUpdater InstrumentationStatusUpdater,
 	healthCheck HealthCheck,
@@ -224,10 +247,16 @@ func NewHealthMonitor(
 	podMetricWorkers int,
 	instrumentationsMetricWorkers int,
 	instrumentationsMetricPersistWorkers int,
+	readinessGate corev1.PodConditionType,
+	podReadinessUpdater PodReadinessUpdater,
+	autoscalingVersion func() autodetect.AutoscalingVersion,
 ) *HealthMonitor {
 	m := &HealthMonitor{
 		healthApi:                    healthCheck,
 		instrumentationStatusUpdater: instrumentationStatusUpdater,
+		readinessGate:                readinessGate,
+		podReadinessUpdater:          podReadinessUpdater,
+		autoscalingVersion:           autoscalingVersion,
 
 		instrumentations: make(map[string]*current.Instrumentation),
 		pods:             make(map[string]*corev1.Pod),
@@ -298,6 +327,7 @@ func (m *HealthMonitor) resourceQueueEvent(ctx context.Context, ev event) {
 	case instRemove:
 		logger.V(1).Info("event", "action", ev.action.String(), "entity", "namespace/"+ev.inst.Namespace+"/instrumentation/"+ev.inst.Name)
 		delete(m.instrumentations, ev.inst.Namespace+"/"+ev.inst.Name)
+		instrumentationDriftPods.DeleteLabelValues(ev.inst.Namespace, ev.inst.Name)
 	case triggerHealthCheck:
 		// skip health check if it's already active
 		if atomic.LoadInt64(&m.healthCheckActive) == 1 {
@@ -371,9 +401,37 @@ func (m *HealthMonitor) podMetricsQueueEvent(ctx context.Context, event []*podMe
 func (m *HealthMonitor) podMetricQueueEvent(ctx context.Context, event *podMetric) {
 	// check the pod health, save the data in the pod metrics
 	health := m.check(ctx, event)
+	m.updateReadinessGate(ctx, event, health)
 	event.resolve(health)
 }
 
+// updateReadinessGate is used to set the readiness gate condition of the pod once its agent reports healthy. It isn't
+// unset when the agent becomes unhealthy later, so that agent issues don't take the pod out of service.
+func (m *HealthMonitor) updateReadinessGate(ctx context.Context, event *podMetric, health Health) {
+	if m.readinessGate == "" || m.podReadinessUpdater == nil || !health.Healthy {
+		return
+	}
+	pod := event.pod
+	if !slices.ContainsFunc(pod.Spec.ReadinessGates, func(g corev1.PodReadinessGate) bool { return g.ConditionType == m.readinessGate }) {
+		return
+	}
+	if slices.ContainsFunc(pod.Status.Conditions, func(c corev1.PodCondition) bool {
+		return c.Type == m.readinessGate && c.Status == corev1.ConditionTrue
+	}) {
+		return
+	}
+	condition := corev1.PodCondition{
+		Type:               m.readinessGate,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "AgentHealthy",
+		Message:            "the agent reported healthy",
+	}
+	if err := m.podReadinessUpdater.UpdatePodReadiness(ctx, pod, condition); err != nil {
+		log.FromContext(ctx).Error(err, "failed to set the agent readiness gate", "pod", event.podID)
+	}
+}
+
 func (m *HealthMonitor) instrumentationMetricsQueueEvent(ctx context.Context, event []*instrumentationMetric) {
 	// calculate the instrumentation metrics individually
 	for _, eventInstrumentationMetric := range event {
@@ -386,7 +444,10 @@ func (m *HealthMonitor) instrumentationMetricQueueEvent(ctx context.Context, eve
 		// wait for pod metrics (health) to be collected
 		_ = eventPodMetrics.wait(ctx)
 		event.podsMatching++
-		if !m.isPodInstrumented(eventPodMetrics.pod) {
+		if isPodDrifted(eventPodMetrics.pod, event.instrumentation, event.languages) {
+			event.podsDrifted++
+		}
+		if event.driftOnly || !m.isPodInstrumented(eventPodMetrics.pod) {
 			continue
 		}
 		if m.isPodOutdated(eventPodMetrics.pod, event.instrumentation) {
@@ -419,6 +480,7 @@ func (m *HealthMonitor) instrumentationMetricPersistQueueEvent(ctx context.Conte
 		"id", event.instrumentationID,
 		"pods", map[string]int64{
 			"matching":  event.podsMatching,
+			"drifted":   event.podsDrifted,
 			"outdated":  event.podsOutdated,
 			"unhealthy": event.podsUnhealthy,
 			"not_ready": event.podsNotReady,
@@ -426,6 +488,7 @@ func (m *HealthMonitor) instrumentationMetricPersistQueueEvent(ctx context.Conte
 			"injected":  event.podsInjected,
 		},
 	)
+	instrumentationDriftPods.WithLabelValues(event.instrumentation.Namespace, event.instrumentation.Name).Set(float64(event.podsDrifted))
 	if event.isDiff() {
 		event.syncStatus()
 		event.instrumentation.Status.LastUpdated = metav1.Now()
@@ -456,13 +519,12 @@ func (m *HealthMonitor) getPodMetrics(ctx context.Context) []*podMetric {
 func (m *HealthMonitor) getInstrumentationMetrics(ctx context.Context, podMetrics []*podMetric) []*instrumentationMetric {
 	logger := log.FromContext(ctx)
 	var instrumentationMetrics = make([]*instrumentationMetric, len(m.instrumentations))
+	languages := make(map[string]string, len(m.instrumentations))
+	for id, instrumentation := range m.instrumentations {
+		languages[id] = instrumentation.Spec.Agent.Language
+	}
 	i := 0
 	for _, instrumentation := range m.instrumentations {
-		// skip instrumentation without the health agent configuration, because it's extra work without any benefit
-		if instrumentation.Spec.HealthAgent.IsEmpty() {
-			continue
-		}
-
 		podSelector, err := metav1.LabelSelectorAsSelector(&instrumentation.Spec.PodLabelSelector)
 		if err != nil {
 			logger.Error(err, "failed to parse instrumentation pod selector",
@@ -498,6 +560,13 @@ func (m *HealthMonitor) getInstrumentationMetrics(ctx context.Context, podMetric
 			instrumentation:   instrumentation,
 			podMetrics:        instPodMetrics,
 			doneCh:            make(chan struct{}),
+			// skip the health of the instrumentations without the health agent configuration, because it's extra work
+			// without any benefit
+			driftOnly: instrumentation.Spec.HealthAgent.IsEmpty(),
+			languages: languages,
+		}
+		if m.autoscalingVersion != nil {
+			instrumentationMetrics[i].autoscalingVersion = m.autoscalingVersion().String()
 		}
 		i++
 	}
@@ -559,6 +628,30 @@ func (m *HealthMonitor) isPodOutdated(pod *corev1.Pod, inst *current.Instrumenta
 	return false
 }
 
+// isPodDrifted is used to check if a pod matching the instrumentation was never injected by it, such as pods created
+// before the instrumentation existed or while the webhook was unavailable. Pods injected by another instrumentation of
+// the same language aren't drifted, as only one of them is injected per language.
+func isPodDrifted(pod *corev1.Pod, inst *current.Instrumentation, languages map[string]string) bool {
+	v, ok := pod.Annotations[instrumentationVersionAnnotation]
+	if !ok {
+		return true
+	}
+	instVersions := map[string]string{}
+	if err := json.Unmarshal([]byte(v), &instVersions); err != nil {
+		return true
+	}
+	instID := types.NamespacedName{Name: inst.Name, Namespace: inst.Namespace}.String()
+	for injectedID := range instVersions {
+		if injectedID == instID {
+			return false
+		}
+		if language, ok := languages[injectedID]; ok && language == inst.Spec.Agent.Language {
+			return false
+		}
+	}
+	return true
+}
+
 // isPodInstrumented check if a pod has been instrumented with the health sidecar
 func (m *HealthMonitor) isPodInstrumented(pod *corev1.Pod) bool {
 	if pod.Annotations == nil {
@@ -692,3 +785,9 @@ func (m *HealthMonitor) InstrumentationSet(instrumentation *current.Instrumentat
 func (m *HealthMonitor) InstrumentationRemove(instrumentation *current.Instrumentation) {
 	_ = m.resourceQueue.Add(context.Background(), event{inst: instrumentation, action: instRemove})
 }
+
+// TriggerHealthCheck to check the health before the next tick, such as when the autoscaling version reported in the
+// status of the instrumentations changed
+func (m *HealthMonitor) TriggerHealthCheck() {
+	_ = m.resourceQueue.Add(context.Background(), event{action: triggerHealthCheck})
+}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"testing"
 	"time"
-	
+
 	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
 
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
@@ -34,6 +35,14 @@ func (f fakeUpdateInstrumentationStatus) UpdateInstrumentationStatus(ctx context
 	return f(ctx, instrumentation)
 }
 
+var _ PodReadinessUpdater = (*fakeUpdatePodReadiness)(nil)
+
+type fakeUpdatePodReadiness func(ctx context.Context, pod *corev1.Pod, condition corev1.PodCondition) error
+
+func (f fakeUpdatePodReadiness) UpdatePodReadiness(ctx context.Context, pod *corev1.Pod, condition corev1.PodCondition) error {
+	return f(ctx, pod, condition)
+}
+
 func TestHealthMonitor(t *testing.T) {
 	containerRestartPolicyAlways := corev1.ContainerRestartPolicyAlways
 	tests := []struct {
@@ -72,6 +81,42 @@ func TestHealthMonitor(t *testing.T) {
 			},
 			expectedInstrumentationStatus: current.InstrumentationStatus{
 				PodsMatching: 1,
+				PodsDrifted:  1,
+			},
+		},
+		{
+			name: "no health agent, matching and drifted",
+			fnHealthCheck: fakeHealthCheck(func(ctx context.Context, url string) (health Health, err error) {
+				logger := log.FromContext(ctx)
+				logger.Info("fake health check")
+				return Health{}, nil
+			}),
+			fnInstrumentationStatusUpdater: fakeUpdateInstrumentationStatus(func(ctx context.Context, instrumentation *current.Instrumentation) error {
+				logger := log.FromContext(ctx)
+				logger.Info("fake instrumentation status updater")
+				return nil
+			}),
+			namespaces: map[string]*corev1.Namespace{
+				"default":  {ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+				"newrelic": {ObjectMeta: metav1.ObjectMeta{Name: "newrelic"}},
+			},
+			pods: map[string]*corev1.Pod{
+				"default/pod0": {ObjectMeta: metav1.ObjectMeta{Name: "pod0", Namespace: "default"}},
+				"default/pod1": {ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default", Annotations: map[string]string{
+					instrumentationVersionAnnotation: `{"newrelic/instrumentation0":"01234567-89ab-cdef-0123-456789abcdef/55"}`,
+				}}},
+				"default/pod2": {ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "default", Annotations: map[string]string{
+					instrumentationVersionAnnotation: `{"newrelic/instrumentation1":"01234567-89ab-cdef-0123-456789abcdef/55"}`,
+				}}},
+			},
+			instrumentations: map[string]*current.Instrumentation{
+				"newrelic/instrumentation0": {
+					ObjectMeta: metav1.ObjectMeta{Name: "instrumentation0", Namespace: "newrelic", UID: "01234567-89ab-cdef-0123-456789abcdef", Generation: 55},
+				},
+			},
+			expectedInstrumentationStatus: current.InstrumentationStatus{
+				PodsMatching: 3,
+				PodsDrifted:  2,
 			},
 		},
 		{
@@ -101,6 +146,7 @@ func TestHealthMonitor(t *testing.T) {
 			},
 			expectedInstrumentationStatus: current.InstrumentationStatus{
 				PodsMatching: 1,
+				PodsDrifted:  1,
 			},
 		},
 		{
@@ -133,6 +179,7 @@ func TestHealthMonitor(t *testing.T) {
 				PodsNotReady: 1,
 				PodsOutdated: 1,
 				PodsInjected: 1,
+				PodsDrifted:  1,
 			},
 		},
 		{
@@ -387,7 +434,7 @@ func TestHealthMonitor(t *testing.T) {
 				instrumentationStatus = instrumentation.Status
 				return nil
 			})
-			hm := NewHealthMonitor(waitForUpdateInstrumentationStatus, test.fnHealthCheck, time.Millisecond*3, 50, 50, 2)
+			hm := NewHealthMonitor(waitForUpdateInstrumentationStatus, test.fnHealthCheck, time.Millisecond*3, 50, 50, 2, "", nil, nil)
 			toCtx, toCtxCancel := context.WithTimeout(ctx, time.Millisecond*5000)
 			defer toCtxCancel()
 			for _, namespace := range test.namespaces {
@@ -412,3 +459,113 @@ func TestHealthMonitor(t *testing.T) {
 		})
 	}
 }
+
+func TestIsPodDrifted(t *testing.T) {
+	inst := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "java0", Namespace: "newrelic"},
+		Spec:       current.InstrumentationSpec{Agent: current.Agent{Language: "java"}},
+	}
+	languages := map[string]string{"newrelic/java0": "java", "newrelic/java1": "java", "newrelic/python0": "python"}
+	podOf := func(annotation string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{instrumentationVersionAnnotation: annotation}}}
+	}
+	tests := []struct {
+		name            string
+		pod             *corev1.Pod
+		expectedDrifted bool
+	}{
+		{name: "not injected", pod: &corev1.Pod{}, expectedDrifted: true},
+		{name: "injected by the instrumentation", pod: podOf(`{"newrelic/java0":"uid/1"}`)},
+		{name: "injected by another instrumentation of the language", pod: podOf(`{"newrelic/java1":"uid/1"}`)},
+		{name: "only injected by another language", pod: podOf(`{"newrelic/python0":"uid/1"}`), expectedDrifted: true},
+		{name: "injected by a removed instrumentation", pod: podOf(`{"newrelic/java2":"uid/1"}`), expectedDrifted: true},
+		{name: "malformed annotation", pod: podOf(`{`), expectedDrifted: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if drifted := isPodDrifted(test.pod, inst, languages); drifted != test.expectedDrifted {
+				t.Errorf("expected drifted %v, got %v", test.expectedDrifted, drifted)
+			}
+		})
+	}
+}
+
+func TestHealthMonitor_UpdateReadinessGate(t *testing.T) {
+	gate := corev1.PodConditionType("newrelic.com/agent-ready")
+	gatedPod := &corev1.Pod{Spec: corev1.PodSpec{ReadinessGates: []corev1.PodReadinessGate{{ConditionType: gate}}}}
+	readyPod := gatedPod.DeepCopy()
+	readyPod.Status.Conditions = []corev1.PodCondition{{Type: gate, Status: corev1.ConditionTrue}}
+	tests := []struct {
+		name            string
+		pod             *corev1.Pod
+		health          Health
+		expectedUpdated bool
+	}{
+		{name: "healthy", pod: gatedPod, health: Health{Healthy: true}, expectedUpdated: true},
+		{name: "unhealthy", pod: gatedPod, health: Health{LastError: "not initialized"}},
+		{name: "without the readiness gate", pod: &corev1.Pod{}, health: Health{Healthy: true}},
+		{name: "already set", pod: readyPod, health: Health{Healthy: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var updated []corev1.PodCondition
+			updater := fakeUpdatePodReadiness(func(ctx context.Context, pod *corev1.Pod, condition corev1.PodCondition) error {
+				updated = append(updated, condition)
+				return nil
+			})
+			m := &HealthMonitor{readinessGate: gate, podReadinessUpdater: updater}
+			m.updateReadinessGate(context.Background(), &podMetric{pod: test.pod, podID: "default/app"}, test.health)
+			if !test.expectedUpdated {
+				if len(updated) > 0 {
+					t.Fatalf("expected no update, got %v", updated)
+				}
+				return
+			}
+			if len(updated) != 1 || updated[0].Type != gate || updated[0].Status != corev1.ConditionTrue {
+				t.Fatalf("expected the readiness gate to be set, got %v", updated)
+			}
+		})
+	}
+}
+
+func TestHealthMonitor_AutoscalingVersion(t *testing.T) {
+	inst := &current.Instrumentation{ObjectMeta: metav1.ObjectMeta{Name: "instrumentation0", Namespace: "newrelic"}}
+	m := &HealthMonitor{
+		instrumentations:   map[string]*current.Instrumentation{"newrelic/instrumentation0": inst},
+		autoscalingVersion: func() autodetect.AutoscalingVersion { return autodetect.AutoscalingVersionV2 },
+	}
+	metrics := m.getInstrumentationMetrics(context.Background(), nil)
+	if len(metrics) != 1 || !metrics[0].isDiff() {
+		t.Fatalf("expected the autoscaling version to be a status change, got %v", metrics)
+	}
+	metrics[0].syncStatus()
+	if inst.Status.AutoscalingVersion != "v2" {
+		t.Errorf("expected the autoscaling version v2, got %q", inst.Status.AutoscalingVersion)
+	}
+	if metrics[0].isDiff() {
+		t.Error("expected no status change once synced")
+	}
+}
+
+func TestHealthMonitor_TriggerHealthCheck(t *testing.T) {
+	statusCh := make(chan current.InstrumentationStatus, 1)
+	updater := fakeUpdateInstrumentationStatus(func(ctx context.Context, instrumentation *current.Instrumentation) error {
+		statusCh <- instrumentation.Status
+		return nil
+	})
+	version := func() autodetect.AutoscalingVersion { return autodetect.AutoscalingVersionV2 }
+	// the tick is too long to run before the test times out, so only the trigger checks the health
+	hm := NewHealthMonitor(updater, fakeHealthCheck(nil), time.Hour, 1, 1, 1, "", nil, version)
+	defer func() { _ = hm.Stop(context.Background()) }()
+	hm.PodSet(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod0", Namespace: "default"}})
+	hm.InstrumentationSet(&current.Instrumentation{ObjectMeta: metav1.ObjectMeta{Name: "instrumentation0", Namespace: "newrelic"}})
+	hm.TriggerHealthCheck()
+	select {
+	case status := <-statusCh:
+		if status.AutoscalingVersion != "v2" {
+			t.Errorf("expected the autoscaling version v2, got %q", status.AutoscalingVersion)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the triggered health check to update the status")
+	}
+}
@@ -2,9 +2,11 @@ package instrumentation
 
 import (
 	"context"
+	"slices"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -13,6 +15,11 @@ type InstrumentationStatusUpdater interface {
 	UpdateInstrumentationStatus(ctx context.Context, instrumentation *current.Instrumentation) error
 }
 
+// PodReadinessUpdater sets the condition of a readiness gate of a pod
+type PodReadinessUpdater interface {
+	UpdatePodReadiness(ctx context.Context, pod *corev1.Pod, condition corev1.PodCondition) error
+}
+
 type InstrumentationStatusUpdaterImpl struct {
 	client.Client
 }
@@ -29,3 +36,20 @@ func (i *InstrumentationStatusUpdaterImpl) UpdateInstrumentationStatus(ctx conte
 	}
 	return nil
 }
+
+// UpdatePodReadiness is used to set the condition on the pod status, replacing the condition of the same type
+func (i *InstrumentationStatusUpdaterImpl) UpdatePodReadiness(ctx context.Context, pod *corev1.Pod, condition corev1.PodCondition) error {
+	patched := pod.DeepCopy()
+	index := slices.IndexFunc(patched.Status.Conditions, func(c corev1.PodCondition) bool { return c.Type == condition.Type })
+	if index == -1 {
+		patched.Status.Conditions = append(patched.Status.Conditions, condition)
+	} else {
+		patched.Status.Conditions[index] = condition
+	}
+	if err := i.Client.Status().Patch(ctx, patched, client.StrategicMergeFrom(pod)); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
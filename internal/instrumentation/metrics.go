@@ -0,0 +1,65 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+var (
+	// instrumentationDriftPods is the number of pods matching an instrumentation which were never injected by it
+	instrumentationDriftPods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "operator_instrumentation_drift_pods",
+			Help: "Number of pods matching an instrumentation that have not been instrumented by it",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	// secretCacheRequests is the number of license key secret resolutions, by whether they were served from the cache
+	secretCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "operator_secret_cache_requests_total",
+			Help: "Number of license key secret resolutions served from the cache (hit) or the api server (miss)",
+		},
+		[]string{"result"},
+	)
+
+	// secretCircuitBreakerState is the state of the circuit breaker around license key secret resolution
+	secretCircuitBreakerState = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "operator_secret_circuit_breaker_state",
+			Help: "State of the license key secret resolution circuit breaker, closed (0), open (1) or half-open (2)",
+		},
+	)
+
+	// injectionFailedWorkloads is set for the workloads which reached the injection failure threshold
+	injectionFailedWorkloads = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "operator_injection_failed_workloads",
+			Help: "Set to 1 for the workloads no longer injected, because they reached the injection failure threshold",
+		},
+		[]string{"namespace", "kind", "name"},
+	)
+)
+
+// RegisterMetrics is used to register the metrics of the package with the metrics registry of the config
+func RegisterMetrics(cfg *config.Config) error {
+	return cfg.RegisterMetrics(instrumentationDriftPods, secretCacheRequests, secretCircuitBreakerState, injectionFailedWorkloads)
+}
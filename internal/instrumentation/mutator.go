@@ -16,17 +16,26 @@ limitations under the License.
 package instrumentation
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 )
 
 // compile time type assertion
@@ -39,8 +48,24 @@ var (
 var (
 	errMultipleInstancesPossible = errors.New("multiple New Relic Instrumentation instances available, cannot determine which one to select")
 	errNoInstancesAvailable      = errors.New("no New Relic Instrumentation instances available")
+	errInvalidLicenseKeyFormat   = errors.New("invalid license key format")
 )
 
+// reasonOTLPClientCertSkipped is the reason of the events recorded when the OTLP client certificate secret of an
+// instrumentation can't be replicated, so its pods are injected without the certificate
+const reasonOTLPClientCertSkipped = "OTLPClientCertSkipped"
+
+// reasonInvalidLicenseKey is the reason of the events recorded when the license key secret of the instrumentations
+// doesn't hold a valid license key, so their pods aren't injected
+const reasonInvalidLicenseKey = "InvalidLicenseKey"
+
+// replicatedFromAnnotation is the annotation of the secrets replicated into the pod namespaces, holding the operator
+// namespace they were copied from, so that only those copies are refreshed
+const replicatedFromAnnotation = "newrelic.com/replicated-from"
+
+// licenseKeyLength is the length of New Relic license keys, including the region prefix and the ingest key suffix
+const licenseKeyLength = 40
+
 type InstrumentationPodMutator struct {
 	logger                 logr.Logger
 	client                 client.Client
@@ -48,6 +73,12 @@ type InstrumentationPodMutator struct {
 	secretReplicator       SecretReplicator
 	instrumentationLocator InstrumentationLocator
 	operatorNamespace      string
+	compose                bool
+	secretRetryAttempts    int
+	secretRetryInterval    time.Duration
+	fallbackSecret         string
+	cfg                    *config.Config
+	recorder               record.EventRecorder
 }
 
 // NewMutator is used to get a new instance of a mutator
@@ -69,9 +100,37 @@ func NewMutator(
 	}
 }
 
+// ConfigureCompose is used to compose the instrumentations of the same language matching a pod, see
+// ComposeLanguageInstrumentations, rather than skipping the pod when they differ
+func (pm *InstrumentationPodMutator) ConfigureCompose(enabled bool) {
+	pm.compose = enabled
+}
+
+// ConfigureSecretResolution is used to retry the resolution of a license key secret which isn't found yet, such as one
+// created by another controller moments after the first pods are scheduled, and to fall back to another secret when it
+// still isn't found. An empty fallback secret disables the fallback.
+func (pm *InstrumentationPodMutator) ConfigureSecretResolution(retryAttempts int, retryInterval time.Duration, fallbackSecret string) {
+	pm.secretRetryAttempts = retryAttempts
+	pm.secretRetryInterval = retryInterval
+	pm.fallbackSecret = fallbackSecret
+}
+
+// ConfigureRecorder is used to set the recorder for the events recorded on the instrumentations, about the pods skipped
+// for a malformed license key and the OTLP client certificates which can't be replicated
+func (pm *InstrumentationPodMutator) ConfigureRecorder(recorder record.EventRecorder) {
+	pm.recorder = recorder
+}
+
+// ConfigureOTLPClientCert is used to replicate the OTLP client certificate secrets of the instrumentations, falling back
+// to the operator default of the configuration, into the namespace of the pods
+func (pm *InstrumentationPodMutator) ConfigureOTLPClientCert(cfg *config.Config) {
+	pm.cfg = cfg
+}
+
 // Mutate is used to mutate a pod based on some instrumentation(s)
 func (pm *InstrumentationPodMutator) Mutate(ctx context.Context, ns corev1.Namespace, pod corev1.Pod) (corev1.Pod, error) {
 	logger := pm.logger.WithValues("namespace", pod.Namespace, "name", pod.Name, "generate_name", pod.GenerateName)
+	span := trace.SpanFromContext(ctx)
 
 	instCandidates, err := pm.instrumentationLocator.GetInstrumentations(ctx, ns, pod)
 	if err != nil {
@@ -79,12 +138,20 @@ func (pm *InstrumentationPodMutator) Mutate(ctx context.Context, ns corev1.Names
 		logger.Error(err, "failed to select a New Relic Instrumentation instance for this pod")
 		return pod, err
 	}
+	if span.IsRecording() {
+		span.AddEvent("instrumentations located", trace.WithAttributes(attribute.StringSlice("instrumentations", instrumentationNames(instCandidates))))
+	}
 	if len(instCandidates) == 0 {
 		logger.Info("no New Relic Instrumentation instance for this Pod")
 		return pod, errNoInstancesAvailable
 	}
 
-	instrumentations, err := GetLanguageInstrumentations(instCandidates)
+	var instrumentations []*current.Instrumentation
+	if pm.compose {
+		instrumentations = ComposeLanguageInstrumentations(instCandidates)
+	} else {
+		instrumentations, err = GetLanguageInstrumentations(instCandidates)
+	}
 	if err != nil {
 		if errors.Is(err, errMultipleInstancesPossible) {
 			pm.logger.Info("too many New Relic Instrumentation instances for this Pod.  only 1 allowed")
@@ -94,20 +161,99 @@ func (pm *InstrumentationPodMutator) Mutate(ctx context.Context, ns corev1.Names
 		return pod, err
 	}
 
+	if span.IsRecording() {
+		span.AddEvent("instrumentations selected", trace.WithAttributes(attribute.StringSlice("instrumentations", instrumentationNames(instrumentations))))
+	}
+
 	if licenseKeySecret, err := GetSecretNameFromInstrumentations(instCandidates); err != nil {
 		logger.Error(err, "failed to identify the correct secret.  all matching instrumentation's must use the same secret")
+		span.AddEvent("agent injection skipped", trace.WithAttributes(attribute.String("reason", err.Error())))
 		return pod, nil
 	} else {
-		err = pm.secretReplicator.ReplicateSecret(ctx, ns, pod, pm.operatorNamespace, licenseKeySecret)
+		var secretName string
+		secretName, err = pm.replicateLicenseKeySecret(ctx, ns, pod, licenseKeySecret)
+		if errors.Is(err, errInvalidLicenseKeyFormat) {
+			logger.Error(err, "skipping agent injection, the license key secret is malformed", "secret_name", licenseKeySecret)
+			span.AddEvent("agent injection skipped", trace.WithAttributes(attribute.String("reason", err.Error())))
+			if pm.recorder != nil {
+				for _, inst := range instrumentations {
+					pm.recorder.Eventf(inst, corev1.EventTypeWarning, reasonInvalidLicenseKey,
+						"Skipped injecting pod %s/%s%s, the license key of secret %s is malformed", ns.Name, pod.Name, pod.GenerateName, licenseKeySecret)
+				}
+			}
+			return pod, nil
+		}
 		if err != nil {
 			logger.Error(err, "failed to replicate secret")
+			span.AddEvent("agent injection skipped", trace.WithAttributes(attribute.String("reason", err.Error())))
 			return pod, nil
 		}
+		span.AddEvent("license key secret replicated")
+		if secretName != licenseKeySecret {
+			logger.Info("license key secret not found, injecting the fallback secret", "secret_name", licenseKeySecret, "fallback_secret_name", secretName)
+			span.AddEvent("fallback license key secret used", trace.WithAttributes(attribute.String("secret", secretName)))
+			for i, inst := range instrumentations {
+				instrumentations[i] = inst.DeepCopy()
+				instrumentations[i].Spec.LicenseKeySecret = secretName
+			}
+		}
 	}
 
+	pm.replicateOTLPClientCerts(ctx, ns, pod, instrumentations)
+
 	return pm.sdkInjector.Inject(ctx, instrumentations, ns, pod), nil
 }
 
+// replicateOTLPClientCerts is used to replicate the OTLP client certificate secrets of the instrumentations into the
+// namespace of the pod. The injectors skip the certificates which aren't there, rather than mounting a missing secret
+// which would keep the pod from starting.
+func (pm *InstrumentationPodMutator) replicateOTLPClientCerts(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, insts []*current.Instrumentation) {
+	replicated := map[string]bool{}
+	for _, inst := range insts {
+		secretName := apm.OTLPClientCertSecret(pm.cfg, *inst)
+		if secretName == "" || replicated[secretName] {
+			continue
+		}
+		replicated[secretName] = true
+		if err := pm.secretReplicator.ReplicateSecret(ctx, ns, pod, pm.operatorNamespace, secretName); err != nil {
+			pm.logger.Error(err, "failed to replicate the OTLP client certificate secret, injecting without it", "secret_name", secretName)
+			if pm.recorder != nil {
+				pm.recorder.Eventf(inst, corev1.EventTypeWarning, reasonOTLPClientCertSkipped,
+					"Injecting pod %s/%s%s without the OTLP client certificate, secret %q can't be replicated: %s", ns.Name, pod.Name, pod.GenerateName, secretName, err)
+			}
+		}
+	}
+}
+
+// replicateLicenseKeySecret is used to replicate the license key secret, retrying while it isn't found, and then
+// replicating the fallback secret when there's one. It returns the name of the secret replicated.
+func (pm *InstrumentationPodMutator) replicateLicenseKeySecret(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, secretName string) (string, error) {
+	err := pm.secretReplicator.ReplicateSecret(ctx, ns, pod, pm.operatorNamespace, secretName)
+	for attempt := 0; attempt < pm.secretRetryAttempts && apierrors.IsNotFound(err); attempt++ {
+		timer := time.NewTimer(pm.secretRetryInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return secretName, err
+		}
+		err = pm.secretReplicator.ReplicateSecret(ctx, ns, pod, pm.operatorNamespace, secretName)
+	}
+	if apierrors.IsNotFound(err) && pm.fallbackSecret != "" && pm.fallbackSecret != secretName {
+		return pm.fallbackSecret, pm.secretReplicator.ReplicateSecret(ctx, ns, pod, pm.operatorNamespace, pm.fallbackSecret)
+	}
+	return secretName, err
+}
+
+// instrumentationNames is used to get the namespaced names of the instrumentations, recorded on the admission span
+func instrumentationNames(insts []*current.Instrumentation) []string {
+	names := make([]string, 0, len(insts))
+	for _, inst := range insts {
+		names = append(names, inst.Namespace+"/"+inst.Name)
+	}
+	return names
+}
+
 // GetLanguageInstrumentations is used to collect all instrumentations and validate that only a single instrumentation
 // exists for each language, and return them together, modifying the slice items in place
 func GetLanguageInstrumentations(instCandidates []*current.Instrumentation) ([]*current.Instrumentation, error) {
@@ -137,6 +283,11 @@ type NewrelicInstrumentationLocator struct {
 	logger            logr.Logger
 	client            client.Client
 	operatorNamespace string
+	// now is the time the injection schedules of the instrumentations are checked against
+	now func() time.Time
+	// instrumentations replace the ones of the cluster when there's no client, and ownerKinds the ones of the pods
+	instrumentations []current.Instrumentation
+	ownerKinds       []string
 }
 
 // NewNewRelicInstrumentationLocator is the constructor for getting instrumentations
@@ -145,6 +296,20 @@ func NewNewRelicInstrumentationLocator(logger logr.Logger, client client.Client,
 		logger:            logger,
 		client:            client,
 		operatorNamespace: operatorNamespace,
+		now:               time.Now,
+	}
+}
+
+// NewStaticInstrumentationLocator is used to locate the instrumentations among the given ones rather than the ones of
+// the cluster, so that an injection can be computed offline. The pods are matched as if owned by the owner kinds, see
+// getOwnerKinds.
+func NewStaticInstrumentationLocator(logger logr.Logger, insts []current.Instrumentation, operatorNamespace string, ownerKinds []string) *NewrelicInstrumentationLocator {
+	return &NewrelicInstrumentationLocator{
+		logger:            logger,
+		operatorNamespace: operatorNamespace,
+		now:               time.Now,
+		instrumentations:  insts,
+		ownerKinds:        ownerKinds,
 	}
 }
 
@@ -153,14 +318,19 @@ func NewNewRelicInstrumentationLocator(logger logr.Logger, client client.Client,
 func (il *NewrelicInstrumentationLocator) GetInstrumentations(ctx context.Context, ns corev1.Namespace, pod corev1.Pod) ([]*current.Instrumentation, error) {
 	logger := il.logger.WithValues("namespace", pod.Namespace, "name", pod.Name, "generate_name", pod.GenerateName)
 
-	var listInst current.InstrumentationList
-	if err := il.client.List(ctx, &listInst); err != nil {
-		return nil, err
+	insts := il.instrumentations
+	if il.client != nil {
+		var listInst current.InstrumentationList
+		if err := il.client.List(ctx, &listInst); err != nil {
+			return nil, err
+		}
+		insts = listInst.Items
 	}
 
 	//nolint:prealloc
 	var candidates []*current.Instrumentation
-	for _, inst := range listInst.Items {
+	var podOwnerKinds []string
+	for _, inst := range insts {
 		if inst.Namespace != il.operatorNamespace {
 			logger.Info("ignoring instrumentation not in operator namespace",
 				"instrumentation_name", inst.Name,
@@ -185,6 +355,14 @@ func (il *NewrelicInstrumentationLocator) GetInstrumentations(ctx context.Contex
 			)
 			continue
 		}
+		podFieldSelector, err := current.ParsePodFieldSelector(inst.Spec.PodFieldSelector)
+		if err != nil {
+			logger.Error(err, "failed to parse pod field selector",
+				"instrumentation_name", inst.Name,
+				"instrumentation_namespace", inst.Namespace,
+			)
+			continue
+		}
 
 		if !podSelector.Matches(fields.Set(pod.Labels)) {
 			continue
@@ -192,6 +370,45 @@ func (il *NewrelicInstrumentationLocator) GetInstrumentations(ctx context.Contex
 		if !namespaceSelector.Matches(fields.Set(ns.Labels)) {
 			continue
 		}
+		if !podFieldSelector.Matches(current.PodFields(pod)) {
+			continue
+		}
+		if len(inst.Spec.OwnerKinds) > 0 {
+			if podOwnerKinds == nil {
+				podOwnerKinds = il.getOwnerKinds(ctx, ns, pod)
+			}
+			if !matchesOwnerKinds(inst.Spec.OwnerKinds, podOwnerKinds) {
+				continue
+			}
+		}
+		if !matchesSchedulerName(inst.Spec.SchedulerNameSelector, pod.Spec.SchedulerName) {
+			continue
+		}
+		if !matchesCohort(inst, pod, insts) {
+			logger.V(1).Info("ignoring instrumentation the pod isn't routed to by its cohort label",
+				"instrumentation_name", inst.Name,
+				"instrumentation_namespace", inst.Namespace,
+				"cohort_label", inst.Spec.Cohort.Label,
+			)
+			continue
+		}
+		if inst.Spec.Schedule != nil {
+			open, err := inst.Spec.Schedule.Contains(il.now())
+			if err != nil {
+				logger.Error(err, "failed to check the injection schedule",
+					"instrumentation_name", inst.Name,
+					"instrumentation_namespace", inst.Namespace,
+				)
+				continue
+			}
+			if !open {
+				logger.V(1).Info("ignoring instrumentation outside of its injection schedule",
+					"instrumentation_name", inst.Name,
+					"instrumentation_namespace", inst.Namespace,
+				)
+				continue
+			}
+		}
 
 		logger.Info("matching instrumentation",
 			"instrumentation_name", inst.Name,
@@ -206,6 +423,33 @@ func (il *NewrelicInstrumentationLocator) GetInstrumentations(ctx context.Contex
 	return candidates, nil
 }
 
+// MatchingInstrumentations is used to get every instrumentation matching the pod, before the ones of the same language
+// are narrowed down to the one injected, so that overlapping instrumentations can be diagnosed. The owner kinds are the
+// kinds of the pod's owner chain, as matched by the owner kinds of the instrumentations.
+func MatchingInstrumentations(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, insts []current.Instrumentation, operatorNamespace string, ownerKinds []string) ([]current.Instrumentation, error) {
+	candidates, err := NewStaticInstrumentationLocator(logr.Discard(), insts, operatorNamespace, ownerKinds).GetInstrumentations(ctx, ns, pod)
+	if err != nil {
+		return nil, err
+	}
+	matching := make([]current.Instrumentation, 0, len(candidates))
+	for _, candidate := range candidates {
+		matching = append(matching, *candidate)
+	}
+	return matching, nil
+}
+
+// matchesSchedulerName is used to check if the pod scheduler is selected. Excluded schedulers take precedence over the
+// included ones, and pods without a scheduler name are scheduled by the default scheduler.
+func matchesSchedulerName(selector current.SchedulerNameSelector, schedulerName string) bool {
+	if schedulerName == "" {
+		schedulerName = corev1.DefaultSchedulerName
+	}
+	if slices.Contains(selector.NotIn, schedulerName) {
+		return false
+	}
+	return len(selector.In) == 0 || slices.Contains(selector.In, schedulerName)
+}
+
 // GetSecretNameFromInstrumentations is used to get a single secret key name from a list of Instrumentation's.  It will
 // use the default if none is provided.  If any of them are different by name, this will fail, as we can only bind a
 // single license key to a single pod.
@@ -242,7 +486,9 @@ func NewNewrelicSecretReplicator(logger logr.Logger, client client.Client) *Newr
 	return &NewrelicSecretReplicator{client: client, logger: logger}
 }
 
-// ReplicateSecret is used to copy the secret from the operator namespace to the pod namespace if the secret doesn't already exist
+// ReplicateSecret is used to copy the secret from the operator namespace to the pod namespace if the secret doesn't already exist.
+// A copy the replicator created is refreshed when the secret of the operator namespace changed, such as a rotated
+// certificate, while a secret created in the pod namespace by anything else is left alone.
 func (sr *NewrelicSecretReplicator) ReplicateSecret(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, operatorNamespace string, secretName string) error {
 	logger := sr.logger.WithValues("namespace", pod.Namespace, "name", pod.Name, "generate_name", pod.GenerateName)
 
@@ -254,8 +500,11 @@ func (sr *NewrelicSecretReplicator) ReplicateSecret(ctx context.Context, ns core
 
 	err := sr.client.Get(ctx, client.ObjectKey{Namespace: ns.Name, Name: secretName}, &secret)
 	if err == nil {
+		if _, ok := secret.Annotations[replicatedFromAnnotation]; ok {
+			return sr.refreshSecret(ctx, logger, secret, operatorNamespace)
+		}
 		logger.Info("secret already exists")
-		return nil
+		return validateLicenseKeyFormat(secret)
 	}
 	if !apierrors.IsNotFound(err) {
 		logger.Error(err, "failed to check for existing secret in pod namespace")
@@ -267,13 +516,18 @@ func (sr *NewrelicSecretReplicator) ReplicateSecret(ctx context.Context, ns core
 		logger.Error(err, "failed to retrieve the secret from operator namespace")
 		return err
 	}
+	if err = validateLicenseKeyFormat(secret); err != nil {
+		return err
+	}
 
 	newSecret := corev1.Secret{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
-			Namespace: ns.Name,
+			Name:        secretName,
+			Namespace:   ns.Name,
+			Annotations: map[string]string{replicatedFromAnnotation: operatorNamespace},
 		},
+		Type: secret.Type,
 		Data: secret.Data,
 	}
 	if err = sr.client.Create(ctx, &newSecret); err != nil {
@@ -283,3 +537,47 @@ func (sr *NewrelicSecretReplicator) ReplicateSecret(ctx context.Context, ns core
 
 	return nil
 }
+
+// refreshSecret is used to update the replicated copy of the secret with the data of the secret in the operator
+// namespace, when they differ. The copy is kept when the secret of the operator namespace can't be read.
+func (sr *NewrelicSecretReplicator) refreshSecret(ctx context.Context, logger logr.Logger, replica corev1.Secret, operatorNamespace string) error {
+	var secret corev1.Secret
+	if err := sr.client.Get(ctx, client.ObjectKey{Namespace: operatorNamespace, Name: replica.Name}, &secret); err != nil {
+		logger.Error(err, "failed to retrieve the secret from operator namespace, keeping the replicated secret")
+		return validateLicenseKeyFormat(replica)
+	}
+	if maps.EqualFunc(secret.Data, replica.Data, bytes.Equal) {
+		return validateLicenseKeyFormat(replica)
+	}
+	if err := validateLicenseKeyFormat(secret); err != nil {
+		return err
+	}
+	logger.Info("refreshing the replicated secret in pod namespace")
+	replica.Data = secret.Data
+	if err := sr.client.Update(ctx, &replica); err != nil {
+		logger.Error(err, "failed to update the replicated secret")
+		return err
+	}
+	return nil
+}
+
+// validateLicenseKeyFormat checks the license key in the secret looks like a New Relic license key, to catch truncated
+// or badly pasted keys. The surrounding whitespace, such as the trailing newline of a key created from a file, is
+// ignored. The key itself is never part of the error. A secret without a license key is left to the agent, as the key is
+// optional in the container env.
+func validateLicenseKeyFormat(secret corev1.Secret) error {
+	licenseKey, ok := secret.Data[apm.LicenseKey]
+	if !ok {
+		return nil
+	}
+	licenseKey = bytes.TrimSpace(licenseKey)
+	if len(licenseKey) != licenseKeyLength {
+		return errInvalidLicenseKeyFormat
+	}
+	for _, c := range licenseKey {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return errInvalidLicenseKeyFormat
+		}
+	}
+	return nil
+}
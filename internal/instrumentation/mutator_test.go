@@ -2,9 +2,10 @@ package instrumentation
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
@@ -12,12 +13,16 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
 	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 )
 
 type FakeInjector func(ctx context.Context, insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) corev1.Pod
@@ -140,7 +145,7 @@ func TestMutatePod(t *testing.T) {
 			initSecrets: []*corev1.Secret{
 				{
 					ObjectMeta: metav1.ObjectMeta{Name: DefaultLicenseKeySecretName, Namespace: "gns1-op"},
-					Data:       map[string][]byte{apm.LicenseKey: []byte(base64.RawStdEncoding.EncodeToString([]byte("abc123")))},
+					Data:       map[string][]byte{apm.LicenseKey: []byte("fakesecretabc12300000000000000000000NRAL")},
 				},
 			},
 			pod:        corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "java-app"}}}},
@@ -213,7 +218,7 @@ func TestMutatePod(t *testing.T) {
 			initSecrets: []*corev1.Secret{
 				{
 					ObjectMeta: metav1.ObjectMeta{Name: DefaultLicenseKeySecretName, Namespace: "gns5-op"},
-					Data:       map[string][]byte{apm.LicenseKey: []byte(base64.RawStdEncoding.EncodeToString([]byte("abc123")))},
+					Data:       map[string][]byte{apm.LicenseKey: []byte("fakesecretabc12300000000000000000000NRAL")},
 				},
 			},
 			operatorNs: "gns5-op",
@@ -285,7 +290,7 @@ func TestMutatePod(t *testing.T) {
 				for _, apmInjector := range apmInjectors {
 					injectorRegistry.MustRegister(apmInjector)
 				}
-				injector = NewNewrelicSdkInjector(logger, k8sClient, injectorRegistry)
+				injector = NewNewrelicSdkInjector(logger, k8sClient, injectorRegistry, nil)
 			}
 			instrumentationLocator := test.instrumentationLocator
 			if instrumentationLocator == nil {
@@ -549,6 +554,40 @@ func TestNewrelicSecretReplicator_ReplicateSecret(t *testing.T) {
 	}
 }
 
+func TestNewrelicSecretReplicator_ReplicateSecret_Refresh(t *testing.T) {
+	ctx := context.Background()
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "otlp-client-cert", Namespace: "newrelic"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert-1"), corev1.TLSPrivateKeyKey: []byte("key-1")},
+	}
+	userOwned := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "otlp-client-cert", Namespace: "user"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("user-cert"), corev1.TLSPrivateKeyKey: []byte("user-key")},
+	}
+	c := fake.NewClientBuilder().WithObjects(source, userOwned).Build()
+	secretReplicator := NewNewrelicSecretReplicator(logr.Discard(), c)
+	replicate := func(namespace string) corev1.Secret {
+		require.NoError(t, secretReplicator.ReplicateSecret(ctx, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, corev1.Pod{}, "newrelic", "otlp-client-cert"))
+		var secret corev1.Secret
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "otlp-client-cert"}, &secret))
+		return secret
+	}
+
+	assert.Equal(t, "cert-1", string(replicate("app").Data[corev1.TLSCertKey]))
+
+	// the certificate is rotated in the operator namespace
+	source.Data = map[string][]byte{corev1.TLSCertKey: []byte("cert-2"), corev1.TLSPrivateKeyKey: []byte("key-2")}
+	require.NoError(t, c.Update(ctx, source))
+	replica := replicate("app")
+	assert.Equal(t, "cert-2", string(replica.Data[corev1.TLSCertKey]))
+	assert.Equal(t, "key-2", string(replica.Data[corev1.TLSPrivateKeyKey]))
+
+	// a secret which wasn't replicated isn't overwritten
+	assert.Equal(t, "user-cert", string(replicate("user").Data[corev1.TLSCertKey]))
+}
+
 func TestGetLanguageInstrumentations(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -1111,3 +1150,352 @@ func TestNewrelicInstrumentationLocator_GetInstrumentations(t *testing.T) {
 		})
 	}
 }
+
+func TestStaticInstrumentationLocator_GetInstrumentations(t *testing.T) {
+	insts := []current.Instrumentation{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "newrelic"},
+			Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "java"},
+				PodLabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "shop"}},
+				OwnerKinds:       []string{"Deployment"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "python", Namespace: "newrelic"},
+			Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "python"},
+				PodLabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node", Namespace: "other"},
+			Spec:       current.InstrumentationSpec{Agent: current.Agent{Language: "nodejs"}},
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "shop"}}}
+
+	locator := NewStaticInstrumentationLocator(logr.Discard(), insts, "newrelic", []string{"ReplicaSet", "Deployment"})
+	actual, err := locator.GetInstrumentations(context.Background(), corev1.Namespace{}, pod)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, "java", actual[0].Name)
+	assert.Equal(t, DefaultLicenseKeySecretName, actual[0].Spec.LicenseKeySecret)
+
+	locator = NewStaticInstrumentationLocator(logr.Discard(), insts, "newrelic", []string{"StatefulSet"})
+	actual, err = locator.GetInstrumentations(context.Background(), corev1.Namespace{}, pod)
+	require.NoError(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestNewrelicInstrumentationLocator_GetInstrumentations_PodFieldSelector(t *testing.T) {
+	insts := []current.Instrumentation{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "newrelic"},
+			Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "java"},
+				PodFieldSelector: "spec.serviceAccountName=payments,spec.nodeName!=edge-1",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "unsupported", Namespace: "newrelic"},
+			Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "python"},
+				PodFieldSelector: "status.phase=Running",
+			},
+		},
+	}
+	locator := NewStaticInstrumentationLocator(logr.Discard(), insts, "newrelic", nil)
+
+	actual, err := locator.GetInstrumentations(context.Background(), corev1.Namespace{}, corev1.Pod{Spec: corev1.PodSpec{ServiceAccountName: "payments"}})
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, "payments", actual[0].Name)
+
+	actual, err = locator.GetInstrumentations(context.Background(), corev1.Namespace{}, corev1.Pod{Spec: corev1.PodSpec{ServiceAccountName: "payments", NodeName: "edge-1"}})
+	require.NoError(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestMatchingInstrumentations(t *testing.T) {
+	insts := []current.Instrumentation{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "newrelic"},
+			Spec:       current.InstrumentationSpec{Agent: current.Agent{Language: "java"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "java-shop", Namespace: "newrelic"},
+			Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "java"},
+				PodLabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "shop"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "java-other", Namespace: "newrelic"},
+			Spec: current.InstrumentationSpec{
+				Agent:            current.Agent{Language: "java"},
+				PodLabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}},
+			},
+		},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "shop"}}}
+
+	actual, err := MatchingInstrumentations(context.Background(), corev1.Namespace{}, pod, insts, "newrelic", nil)
+	require.NoError(t, err)
+	var names []string
+	for _, inst := range actual {
+		names = append(names, inst.Name)
+	}
+	assert.Equal(t, []string{"java", "java-shop"}, names)
+}
+
+func TestValidateLicenseKeyFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        map[string][]byte
+		expectedErr error
+	}{
+		{name: "no license key"},
+		{name: "license key", data: map[string][]byte{apm.LicenseKey: []byte("0123456789abcdef0123456789abcdef01234567")}},
+		{name: "eu license key", data: map[string][]byte{apm.LicenseKey: []byte("eu01xx6789abcdef0123456789abcdef0123NRAL")}},
+		{name: "truncated license key", data: map[string][]byte{apm.LicenseKey: []byte("0123456789abcdef0123456789abcdef")}, expectedErr: errInvalidLicenseKeyFormat},
+		{name: "trailing newline", data: map[string][]byte{apm.LicenseKey: []byte("0123456789abcdef0123456789abcdef01234567\n")}},
+		{name: "truncated license key with a trailing newline", data: map[string][]byte{apm.LicenseKey: []byte("0123456789abcdef0123456789abcdef0123456\n")}, expectedErr: errInvalidLicenseKeyFormat},
+		{name: "empty license key", data: map[string][]byte{apm.LicenseKey: {}}, expectedErr: errInvalidLicenseKeyFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateLicenseKeyFormat(corev1.Secret{Data: test.data})
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("expected %v, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestMatchesSchedulerName(t *testing.T) {
+	tests := []struct {
+		name          string
+		selector      current.SchedulerNameSelector
+		schedulerName string
+		expected      bool
+	}{
+		{name: "no selector", schedulerName: "volcano", expected: true},
+		{name: "included", selector: current.SchedulerNameSelector{In: []string{"volcano"}}, schedulerName: "volcano", expected: true},
+		{name: "not included", selector: current.SchedulerNameSelector{In: []string{"volcano"}}, schedulerName: "default-scheduler"},
+		{name: "excluded", selector: current.SchedulerNameSelector{NotIn: []string{"volcano"}}, schedulerName: "volcano"},
+		{name: "not excluded", selector: current.SchedulerNameSelector{NotIn: []string{"volcano"}}, schedulerName: "default-scheduler", expected: true},
+		{name: "excluded wins", selector: current.SchedulerNameSelector{In: []string{"volcano"}, NotIn: []string{"volcano"}}, schedulerName: "volcano"},
+		{name: "blank is the default scheduler", selector: current.SchedulerNameSelector{In: []string{"default-scheduler"}}, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := matchesSchedulerName(test.selector, test.schedulerName); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInstrumentationPodMutator_Mutate_SecretResolution(t *testing.T) {
+	notFound := apierrors.NewNotFound(corev1.Resource("secrets"), "newrelic-key-secret")
+	tests := []struct {
+		name             string
+		createdAfter     int
+		fallbackSecret   string
+		expectedSecret   string
+		expectedAttempts []string
+	}{
+		{
+			name:             "created while retrying",
+			createdAfter:     2,
+			expectedSecret:   "newrelic-key-secret",
+			expectedAttempts: []string{"newrelic-key-secret", "newrelic-key-secret", "newrelic-key-secret"},
+		},
+		{
+			name:             "fallback secret",
+			createdAfter:     10,
+			fallbackSecret:   "newrelic-key-secret-fallback",
+			expectedSecret:   "newrelic-key-secret-fallback",
+			expectedAttempts: []string{"newrelic-key-secret", "newrelic-key-secret", "newrelic-key-secret", "newrelic-key-secret", "newrelic-key-secret-fallback"},
+		},
+		{
+			name:             "never created",
+			createdAfter:     10,
+			expectedAttempts: []string{"newrelic-key-secret", "newrelic-key-secret", "newrelic-key-secret", "newrelic-key-secret"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts []string
+			var injectedSecret string
+			inst := &current.Instrumentation{Spec: current.InstrumentationSpec{
+				LicenseKeySecret: "newrelic-key-secret",
+				Agent:            current.Agent{Language: "java", Image: "java"},
+			}}
+			mutator := NewMutator(
+				logr.Discard(),
+				nil,
+				SdkInjectorFn(func(ctx context.Context, insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) corev1.Pod {
+					injectedSecret = insts[0].Spec.LicenseKeySecret
+					return pod
+				}),
+				SecretReplicatorFn(func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, operatorNamespace string, secretName string) error {
+					attempts = append(attempts, secretName)
+					if secretName == "newrelic-key-secret" && len(attempts) <= test.createdAfter {
+						return notFound
+					}
+					return nil
+				}),
+				InstrumentationLocatorFn(func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod) ([]*current.Instrumentation, error) {
+					return []*current.Instrumentation{inst}, nil
+				}),
+				"newrelic",
+			)
+			mutator.ConfigureSecretResolution(3, time.Millisecond, test.fallbackSecret)
+
+			_, err := mutator.Mutate(context.Background(), corev1.Namespace{}, corev1.Pod{})
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedAttempts, attempts)
+			assert.Equal(t, test.expectedSecret, injectedSecret)
+			assert.Equal(t, "newrelic-key-secret", inst.Spec.LicenseKeySecret, "the located instrumentation is left as it is")
+		})
+	}
+}
+
+func TestInstrumentationPodMutator_Mutate_OTLPClientCert(t *testing.T) {
+	cfg := config.New(config.WithOTLPClientCert("default-client-cert"))
+	var replicated []string
+	inst := &current.Instrumentation{Spec: current.InstrumentationSpec{
+		LicenseKeySecret: "newrelic-key-secret",
+		Agent:            current.Agent{Language: "java", Image: "java"},
+		Exporter:         current.Exporter{Endpoint: "http://collector:4317"},
+	}}
+	mutator := NewMutator(
+		logr.Discard(),
+		nil,
+		SdkInjectorFn(func(ctx context.Context, insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) corev1.Pod {
+			return pod
+		}),
+		SecretReplicatorFn(func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, operatorNamespace string, secretName string) error {
+			replicated = append(replicated, secretName)
+			if secretName == "default-client-cert" {
+				return apierrors.NewNotFound(corev1.Resource("secrets"), secretName)
+			}
+			return nil
+		}),
+		InstrumentationLocatorFn(func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod) ([]*current.Instrumentation, error) {
+			return []*current.Instrumentation{inst}, nil
+		}),
+		"newrelic",
+	)
+	recorder := record.NewFakeRecorder(1)
+	mutator.ConfigureOTLPClientCert(&cfg)
+	mutator.ConfigureRecorder(recorder)
+
+	_, err := mutator.Mutate(context.Background(), corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}}, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"newrelic-key-secret", "default-client-cert"}, replicated)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, `Warning OTLPClientCertSkipped Injecting pod apps/app without the OTLP client certificate, secret "default-client-cert" can't be replicated`)
+}
+
+func TestInstrumentationPodMutator_Mutate_InvalidLicenseKey(t *testing.T) {
+	inst := &current.Instrumentation{ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "newrelic"}, Spec: current.InstrumentationSpec{
+		LicenseKeySecret: "newrelic-key-secret",
+		Agent:            current.Agent{Language: "java", Image: "java"},
+	}}
+	injected := false
+	mutator := NewMutator(
+		logr.Discard(),
+		nil,
+		SdkInjectorFn(func(ctx context.Context, insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) corev1.Pod {
+			injected = true
+			return pod
+		}),
+		SecretReplicatorFn(func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, operatorNamespace string, secretName string) error {
+			return errInvalidLicenseKeyFormat
+		}),
+		InstrumentationLocatorFn(func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod) ([]*current.Instrumentation, error) {
+			return []*current.Instrumentation{inst}, nil
+		}),
+		"newrelic",
+	)
+	recorder := record.NewFakeRecorder(1)
+	mutator.ConfigureRecorder(recorder)
+
+	_, err := mutator.Mutate(context.Background(), corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}}, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+	require.NoError(t, err)
+	assert.False(t, injected)
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Warning InvalidLicenseKey Skipped injecting pod apps/app, the license key of secret newrelic-key-secret is malformed", <-recorder.Events)
+}
+
+// TestInstrumentationPodMutator_Mutate_Reinvocation mutates a pod again after another webhook added containers, as the
+// api server does when it reinvokes the webhook
+func TestInstrumentationPodMutator_Mutate_Reinvocation(t *testing.T) {
+	inst := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "newrelic"},
+		Spec: current.InstrumentationSpec{
+			LicenseKeySecret: "newrelic-key-secret",
+			Agent:            current.Agent{Language: "java", Image: "java"},
+			Containers:       []current.ContainerEnv{{Name: "worker"}},
+		},
+	}
+	cfg := config.New(config.WithAgentContainer(config.AgentContainer{Position: config.AgentContainerPositionNamed, Name: "server"}))
+	injectorRegistry := apm.NewInjectorRegistry()
+	injectorRegistry.MustRegister(&apm.JavaInjector{})
+	mutator := NewMutator(
+		logr.Discard(),
+		nil,
+		NewNewrelicSdkInjector(logr.Discard(), nil, injectorRegistry, &cfg),
+		SecretReplicatorFn(func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, operatorNamespace string, secretName string) error {
+			return nil
+		}),
+		InstrumentationLocatorFn(func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod) ([]*current.Instrumentation, error) {
+			return []*current.Instrumentation{inst}, nil
+		}),
+		"newrelic",
+	)
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+	}
+
+	injected, err := mutator.Mutate(context.Background(), ns, pod)
+	require.NoError(t, err)
+	require.Len(t, injected.Spec.InitContainers, 1)
+
+	// a mesh injector running after our webhook adds its proxy ahead of the app, and a webhook of the app adds the
+	// named agent container and a container of the instrumentation
+	changed := injected.DeepCopy()
+	changed.Spec.Containers = append([]corev1.Container{{Name: "mesh-proxy", Image: "proxy"}}, changed.Spec.Containers...)
+	changed.Spec.Containers = append(changed.Spec.Containers,
+		corev1.Container{Name: "server", Image: "server"},
+		corev1.Container{Name: "worker", Image: "worker"},
+	)
+
+	reinvoked, err := mutator.Mutate(context.Background(), ns, *changed)
+	require.NoError(t, err)
+
+	// the agents stay in the app container, and nothing is added twice
+	assert.Equal(t, injected.Spec.InitContainers, reinvoked.Spec.InitContainers)
+	assert.Equal(t, injected.Spec.Volumes, reinvoked.Spec.Volumes)
+	assert.Equal(t, injected.Spec.Containers[0], reinvoked.Spec.Containers[1])
+	// the proxy is left alone
+	assert.Equal(t, corev1.Container{Name: "mesh-proxy", Image: "proxy"}, reinvoked.Spec.Containers[0])
+	// the added containers are instrumented like the app container
+	for _, index := range []int{2, 3} {
+		container := reinvoked.Spec.Containers[index]
+		assert.Equal(t, injected.Spec.Containers[0].VolumeMounts, container.VolumeMounts, container.Name)
+		assert.Contains(t, container.Env, corev1.EnvVar{Name: "JAVA_TOOL_OPTIONS", Value: "-javaagent:/newrelic-instrumentation/newrelic-agent.jar"}, container.Name)
+	}
+
+	// reinvoking it again changes nothing
+	again, err := mutator.Mutate(context.Background(), ns, *reinvoked.DeepCopy())
+	require.NoError(t, err)
+	if diff := cmp.Diff(reinvoked, again); diff != "" {
+		t.Errorf("Unexpected pod diff on the second reinvocation (-want +got): %s", diff)
+	}
+}
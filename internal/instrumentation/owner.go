@@ -0,0 +1,76 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"context"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// podOwnerKind is the owner kind of pods without a controller owner
+const podOwnerKind = "Pod"
+
+// +kubebuilder:rbac:groups="batch",resources=jobs,verbs=get;list;watch
+
+// getOwnerKinds is used to get the kind of the pod's controller owner, followed by the kind at the top of the owner
+// chain when it's a different one. Only replicasets owned by deployments and jobs owned by cronjobs are followed. The
+// owner kinds of a static locator are used as they are.
+func (il *NewrelicInstrumentationLocator) getOwnerKinds(ctx context.Context, ns corev1.Namespace, pod corev1.Pod) []string {
+	if il.ownerKinds != nil {
+		return il.ownerKinds
+	}
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil {
+		return []string{podOwnerKind}
+	}
+	kinds := []string{owner.Kind}
+
+	var ownerObj client.Object
+	switch owner.Kind {
+	case "ReplicaSet":
+		ownerObj = &appsv1.ReplicaSet{}
+	case "Job":
+		ownerObj = &batchv1.Job{}
+	default:
+		return kinds
+	}
+	if err := il.client.Get(ctx, client.ObjectKey{Namespace: ns.Name, Name: owner.Name}, ownerObj); err != nil {
+		il.logger.Error(err, "failed to get the pod owner, matching the owner kind only", "owner_kind", owner.Kind, "owner_name", owner.Name)
+		return kinds
+	}
+	if topOwner := metav1.GetControllerOf(ownerObj); topOwner != nil {
+		kinds = append(kinds, topOwner.Kind)
+	}
+	return kinds
+}
+
+// matchesOwnerKinds is used to check if any of the pod owner kinds is listed in the instrumentation owner kinds. An
+// empty list of instrumentation owner kinds matches everything.
+func matchesOwnerKinds(instOwnerKinds []string, podOwnerKinds []string) bool {
+	if len(instOwnerKinds) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(podOwnerKinds, func(kind string) bool {
+		return slices.Contains(instOwnerKinds, kind)
+	})
+}
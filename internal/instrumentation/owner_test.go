@@ -0,0 +1,65 @@
+package instrumentation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewrelicInstrumentationLocator_GetOwnerKinds(t *testing.T) {
+	vtrue := true
+	tests := []struct {
+		name     string
+		owners   []metav1.OwnerReference
+		expected []string
+	}{
+		{name: "no owner", expected: []string{"Pod"}},
+		{name: "no controller owner", owners: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db"}}, expected: []string{"Pod"}},
+		{name: "statefulset", owners: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db", Controller: &vtrue}}, expected: []string{"StatefulSet"}},
+		{
+			name: "multiple owners",
+			owners: []metav1.OwnerReference{
+				{Kind: "Custom", Name: "custom"},
+				{Kind: "DaemonSet", Name: "agent", Controller: &vtrue},
+			},
+			expected: []string{"DaemonSet"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			il := NewNewRelicInstrumentationLocator(logr.Discard(), nil, "operator")
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", OwnerReferences: test.owners}}
+			actual := il.getOwnerKinds(context.Background(), corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, pod)
+			if diff := cmp.Diff(test.expected, actual); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestMatchesOwnerKinds(t *testing.T) {
+	tests := []struct {
+		name           string
+		instOwnerKinds []string
+		podOwnerKinds  []string
+		expected       bool
+	}{
+		{name: "no owner kinds", podOwnerKinds: []string{"ReplicaSet", "Deployment"}, expected: true},
+		{name: "statefulset only, deployment pod", instOwnerKinds: []string{"StatefulSet"}, podOwnerKinds: []string{"ReplicaSet", "Deployment"}},
+		{name: "statefulset only, statefulset pod", instOwnerKinds: []string{"StatefulSet"}, podOwnerKinds: []string{"StatefulSet"}, expected: true},
+		{name: "top owner", instOwnerKinds: []string{"Deployment"}, podOwnerKinds: []string{"ReplicaSet", "Deployment"}, expected: true},
+		{name: "direct owner", instOwnerKinds: []string{"ReplicaSet"}, podOwnerKinds: []string{"ReplicaSet", "Deployment"}, expected: true},
+		{name: "bare pod", instOwnerKinds: []string{"Pod"}, podOwnerKinds: []string{"Pod"}, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := matchesOwnerKinds(test.instOwnerKinds, test.podOwnerKinds); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// applyScheduling is used to add node affinity requirements and tolerations to a pod, so that it's only scheduled
+// onto nodes able to run the agent. Existing affinity and tolerations are kept.
+func applyScheduling(pod corev1.Pod, requirements []corev1.NodeSelectorRequirement, tolerations []corev1.Toleration) corev1.Pod {
+	if len(requirements) > 0 {
+		pod.Spec.Affinity = mergeRequiredNodeAffinity(pod.Spec.Affinity, requirements)
+	}
+	for _, toleration := range tolerations {
+		if !hasToleration(pod.Spec.Tolerations, toleration) {
+			pod.Spec.Tolerations = append(pod.Spec.Tolerations, toleration)
+		}
+	}
+	return pod
+}
+
+// mergeRequiredNodeAffinity adds the requirements to each of the required node selector terms. Terms are ORed, so
+// the requirements have to be part of every term to apply. If there are no terms, a single term is added.
+func mergeRequiredNodeAffinity(affinity *corev1.Affinity, requirements []corev1.NodeSelectorRequirement) *corev1.Affinity {
+	if affinity == nil {
+		affinity = &corev1.Affinity{}
+	}
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	if affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	nodeSelector := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(nodeSelector.NodeSelectorTerms) == 0 {
+		nodeSelector.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+	for i := range nodeSelector.NodeSelectorTerms {
+		term := &nodeSelector.NodeSelectorTerms[i]
+		for _, requirement := range requirements {
+			if !hasNodeSelectorRequirement(term.MatchExpressions, requirement) {
+				term.MatchExpressions = append(term.MatchExpressions, requirement)
+			}
+		}
+	}
+	return affinity
+}
+
+func hasNodeSelectorRequirement(requirements []corev1.NodeSelectorRequirement, requirement corev1.NodeSelectorRequirement) bool {
+	for _, r := range requirements {
+		if reflect.DeepEqual(r, requirement) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasToleration(tolerations []corev1.Toleration, toleration corev1.Toleration) bool {
+	for _, t := range tolerations {
+		if t.MatchToleration(&toleration) && t.Value == toleration.Value && reflect.DeepEqual(t.TolerationSeconds, toleration.TolerationSeconds) {
+			return true
+		}
+	}
+	return false
+}
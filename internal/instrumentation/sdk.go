@@ -17,20 +17,39 @@ limitations under the License.
 package instrumentation
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"reflect"
 	"runtime/debug"
+	"slices"
+	"strings"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
 	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 )
 
 const (
 	DefaultLicenseKeySecretName = "newrelic-key-secret"
+
+	reasonAgentAlreadyPresent    = "AgentAlreadyPresent"
+	reasonMalformedEnvAnnotation = "MalformedEnvAnnotation"
+	reasonAgentPathNotWritable   = "AgentPathNotWritable"
+	reasonAppNameUnresolved      = "AppNameUnresolved"
+	reasonTooManyLanguages       = "TooManyLanguages"
 )
 
 // compile time type assertion
@@ -46,26 +65,84 @@ type NewrelicSdkInjector struct {
 	client           client.Client
 	logger           logr.Logger
 	injectorRegistry *apm.InjectorRegistery
+	config           *config.Config
+	recorder         record.EventRecorder
+	failureTracker   *InjectionFailureTracker
 }
 
-// NewNewrelicSdkInjector is used to create our injector
-func NewNewrelicSdkInjector(logger logr.Logger, client client.Client, injectorRegistry *apm.InjectorRegistery) *NewrelicSdkInjector {
+// NewNewrelicSdkInjector is used to create our injector, which configures the injectors with cfg, or with the defaults
+// when it is nil
+func NewNewrelicSdkInjector(logger logr.Logger, client client.Client, injectorRegistry *apm.InjectorRegistery, cfg *config.Config) *NewrelicSdkInjector {
+	if cfg == nil {
+		defaults := config.New()
+		cfg = &defaults
+	}
 	return &NewrelicSdkInjector{
 		client:           client,
 		logger:           logger,
 		injectorRegistry: injectorRegistry,
+		config:           cfg,
 	}
 }
 
+// ConfigureRecorder is used to set the recorder for the events about skipped injections
+func (i *NewrelicSdkInjector) ConfigureRecorder(recorder record.EventRecorder) {
+	i.recorder = recorder
+}
+
+// ConfigureFailureTracker is used to stop injecting the workloads which keep failing injection
+func (i *NewrelicSdkInjector) ConfigureFailureTracker(tracker *InjectionFailureTracker) {
+	i.failureTracker = tracker
+}
+
 // Inject is used to utilize a list of instrumentations, and if the injectors language matches the instrumentation, trigger the injector
 func (i *NewrelicSdkInjector) Inject(ctx context.Context, insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) corev1.Pod {
-	hadMatchingInjector := false
+	if envName, ok := i.existingAgent(pod); ok {
+		i.logger.Info("skipping agent injection, the pod already has an agent",
+			"pod_namespace", ns.Name,
+			"pod_name", pod.Name,
+			"pod_generate_name", pod.GenerateName,
+			"env", envName,
+		)
+		if i.recorder != nil {
+			for _, inst := range insts {
+				i.recorder.Eventf(inst, corev1.EventTypeNormal, reasonAgentAlreadyPresent,
+					"Skipped injecting pod %s/%s%s, it already sets %s", ns.Name, pod.Name, pod.GenerateName, envName)
+			}
+		}
+		return pod
+	}
+
+	workload := getCronJobWorkloadKey(ctx, i.client, getWorkloadKey(ns, pod))
+	if i.failureTracker.Exceeded(workload) {
+		i.logger.Info("skipping agent injection, the workload reached the injection failure threshold",
+			"pod_namespace", ns.Name,
+			"pod_name", pod.Name,
+			"pod_generate_name", pod.GenerateName,
+			"workload_kind", workload.kind,
+			"workload_name", workload.name,
+		)
+		return i.markInjectionFailed(pod)
+	}
+
+	i.reportMalformedEnvAnnotation(insts, ns, pod)
+	i.reportUnresolvedAppName(insts, ns, pod)
+	insts = i.limitLanguages(insts, ns, pod)
+	insts = i.relaxVPAResources(ctx, insts, ns, pod, workload)
+
+	originalPod := pod
+	hadMatchingInjector, hadError := false, false
 	for _, inst := range insts {
 		for _, injector := range i.injectorRegistry.GetInjectors() {
 			mutatedPod, matchedThisInjector, err := i.injectWithInjector(ctx, injector, inst, ns, pod)
 			hadMatchingInjector = hadMatchingInjector || matchedThisInjector
 			if err != nil {
 				i.logger.Error(err, "Skipping agent injection", "agent_language", inst.Spec.Agent.Language)
+				hadError = true
+				if errors.Is(err, apm.ErrAgentPathNotWritable) && i.recorder != nil {
+					i.recorder.Eventf(inst, corev1.EventTypeWarning, reasonAgentPathNotWritable,
+						"Skipped injecting pod %s/%s%s: %s", ns.Name, pod.Name, pod.GenerateName, err)
+				}
 				continue
 			}
 			pod = mutatedPod
@@ -78,9 +155,228 @@ func (i *NewrelicSdkInjector) Inject(ctx context.Context, insts []*current.Instr
 			"registered_injectors", i.injectorRegistry.GetInjectors().Names(),
 		)
 	}
+	if !hadError {
+		i.failureTracker.Succeeded(workload)
+	} else if i.failureTracker.Failed(workload, admissionUID(ctx)) {
+		i.reportInjectionFailureThreshold(insts, ns, originalPod, workload)
+		return i.markInjectionFailed(originalPod)
+	}
 	return pod
 }
 
+// relaxVPAResources is used to leave the agent resource requirements out of the pods of the workloads managed by a
+// vertical pod autoscaler, so the agent init containers don't raise the pod requests above its recommendations. The
+// limits are left out as well, as the requests would default to them. The instrumentations are copied to do so.
+func (i *NewrelicSdkInjector) relaxVPAResources(ctx context.Context, insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod, workload workloadKey) []*current.Instrumentation {
+	if i.config == nil || i.client == nil || i.config.VPAAvailability() != autodetect.VPAAvailable {
+		return insts
+	}
+	if !slices.ContainsFunc(insts, func(inst *current.Instrumentation) bool {
+		return !reflect.DeepEqual(inst.Spec.Agent.Resources, corev1.ResourceRequirements{})
+	}) {
+		return insts
+	}
+	managed, err := isVPAManaged(ctx, i.client, workload)
+	if err != nil {
+		i.logger.Error(err, "failed to check for a vertical pod autoscaler, keeping the agent resources",
+			"pod_namespace", ns.Name,
+			"workload_kind", workload.kind,
+			"workload_name", workload.name,
+		)
+		return insts
+	}
+	if !managed {
+		return insts
+	}
+	i.logger.V(1).Info("leaving the agent resources out, the workload is managed by a vertical pod autoscaler",
+		"pod_namespace", ns.Name,
+		"pod_name", pod.Name,
+		"pod_generate_name", pod.GenerateName,
+		"workload_kind", workload.kind,
+		"workload_name", workload.name,
+	)
+	relaxed := make([]*current.Instrumentation, len(insts))
+	for idx, inst := range insts {
+		relaxed[idx] = inst.DeepCopy()
+		relaxed[idx].Spec.Agent.Resources = corev1.ResourceRequirements{}
+	}
+	return relaxed
+}
+
+// admissionUID is used to get the uid of the admission request the pod is injected for, empty outside of the webhook
+func admissionUID(ctx context.Context) types.UID {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	return req.UID
+}
+
+// markInjectionFailed is used to annotate the pod admitted without injection, because its workload reached the
+// injection failure threshold
+func (i *NewrelicSdkInjector) markInjectionFailed(pod corev1.Pod) corev1.Pod {
+	annotations := make(map[string]string, len(pod.Annotations)+1)
+	maps.Copy(annotations, pod.Annotations)
+	annotations[InjectionFailedAnnotation] = i.failureTracker.failedAnnotationValue()
+	pod.Annotations = annotations
+	return pod
+}
+
+// reportInjectionFailureThreshold is used to log, and record an event for, the workload reaching the injection failure
+// threshold, after which its pods are admitted without injection
+func (i *NewrelicSdkInjector) reportInjectionFailureThreshold(insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod, workload workloadKey) {
+	i.logger.Info("the workload reached the injection failure threshold, its pods are no longer injected",
+		"pod_namespace", ns.Name,
+		"workload_kind", workload.kind,
+		"workload_name", workload.name,
+		"failure_threshold", i.failureTracker.threshold,
+	)
+	if i.recorder != nil {
+		for _, inst := range insts {
+			i.recorder.Eventf(inst, corev1.EventTypeWarning, reasonInjectionFailureThreshold,
+				"Stopped injecting %s %s/%s, its injection failed %d consecutive times", workload.kind, ns.Name, workload.name, i.failureTracker.threshold)
+		}
+	}
+}
+
+// reportMalformedEnvAnnotation is used to log, and record an event for, the entries of the pod's env annotation which
+// are ignored because they're malformed
+func (i *NewrelicSdkInjector) reportMalformedEnvAnnotation(insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) {
+	value, ok := pod.Annotations[apm.EnvAnnotation]
+	if !ok {
+		return
+	}
+	_, malformed := apm.ParseEnvAnnotation(value)
+	if len(malformed) == 0 {
+		return
+	}
+	i.logger.Info("ignoring malformed env annotation entries",
+		"pod_namespace", ns.Name,
+		"pod_name", pod.Name,
+		"pod_generate_name", pod.GenerateName,
+		"malformed", malformed,
+	)
+	if i.recorder != nil {
+		for _, inst := range insts {
+			i.recorder.Eventf(inst, corev1.EventTypeWarning, reasonMalformedEnvAnnotation,
+				"Ignored the malformed %s entries %q of pod %s/%s%s, expected NAME=value", apm.EnvAnnotation, malformed, ns.Name, pod.Name, pod.GenerateName)
+		}
+	}
+}
+
+// reportUnresolvedAppName is used to log, and record an event for, the instrumentations whose app name template can't
+// be resolved for the pod, so the fallback app name is used instead. Pods we injected before, such as on a webhook
+// reinvocation, were reported already.
+func (i *NewrelicSdkInjector) reportUnresolvedAppName(insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) {
+	if _, ok := pod.Annotations[instrumentationVersionAnnotation]; ok {
+		return
+	}
+	var agentContainer config.AgentContainer
+	if i.config != nil {
+		agentContainer = i.config.AgentContainer()
+	}
+	for _, inst := range insts {
+		if inst.Spec.AppName.Template == "" {
+			continue
+		}
+		fallback, err := apm.ResolveAppName(*inst, ns, pod, agentContainer)
+		if err == nil {
+			continue
+		}
+		if fallback == "" {
+			fallback = "the default app name"
+		} else {
+			fallback = fmt.Sprintf("%q", fallback)
+		}
+		i.logger.Info("using the fallback app name, the app name template can't be resolved",
+			"pod_namespace", ns.Name,
+			"pod_name", pod.Name,
+			"pod_generate_name", pod.GenerateName,
+			"template", inst.Spec.AppName.Template,
+			"reason", err.Error(),
+		)
+		if i.recorder != nil {
+			i.recorder.Eventf(inst, corev1.EventTypeWarning, reasonAppNameUnresolved,
+				"Used %s for pod %s/%s%s, the app name template can't be resolved: %s", fallback, ns.Name, pod.Name, pod.GenerateName, err)
+		}
+	}
+}
+
+// limitLanguages is used to keep the instrumentations of the first languages, up to the configured maximum per pod, so
+// that a too broad selector can't inject every agent into a pod. The instrumentations of the other languages are
+// logged, and an event is recorded for them. The instrumentations come from an unordered list, so they're sorted first,
+// with the languages the pod was already injected with, on reinvocation, ahead of the others.
+func (i *NewrelicSdkInjector) limitLanguages(insts []*current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) []*current.Instrumentation {
+	if i.config == nil || i.config.MaxLanguagesPerPod() <= 0 {
+		return insts
+	}
+	maxLanguages := i.config.MaxLanguagesPerPod()
+	injected := injectedLanguages(insts, pod)
+	insts = slices.Clone(insts)
+	slices.SortStableFunc(insts, func(a, b *current.Instrumentation) int {
+		aInjected, bInjected := slices.Contains(injected, a.Spec.Agent.Language), slices.Contains(injected, b.Spec.Agent.Language)
+		if aInjected != bInjected {
+			if aInjected {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(
+			strings.Compare(a.Spec.Agent.Language, b.Spec.Agent.Language),
+			strings.Compare(a.Namespace, b.Namespace),
+			strings.Compare(a.Name, b.Name),
+		)
+	})
+	var languages []string
+	var kept, skipped []*current.Instrumentation
+	for _, inst := range insts {
+		language := inst.Spec.Agent.Language
+		if !slices.Contains(languages, language) {
+			if len(languages) == maxLanguages {
+				skipped = append(skipped, inst)
+				continue
+			}
+			languages = append(languages, language)
+		}
+		kept = append(kept, inst)
+	}
+	for _, inst := range skipped {
+		i.logger.Info("skipping agent injection, the pod reached the maximum languages",
+			"pod_namespace", ns.Name,
+			"pod_name", pod.Name,
+			"pod_generate_name", pod.GenerateName,
+			"agent_language", inst.Spec.Agent.Language,
+			"injected_languages", languages,
+		)
+		if i.recorder != nil {
+			i.recorder.Eventf(inst, corev1.EventTypeWarning, reasonTooManyLanguages,
+				"Skipped injecting pod %s/%s%s with %s, it already gets the agents of %d languages (%s)",
+				ns.Name, pod.Name, pod.GenerateName, inst.Spec.Agent.Language, maxLanguages, strings.Join(languages, ", "))
+		}
+	}
+	return kept
+}
+
+// injectedLanguages is used to get the languages of the instrumentations named in the instrumentation versions
+// annotation of the pod, which are the ones it was injected with by an earlier invocation of the webhook
+func injectedLanguages(insts []*current.Instrumentation, pod corev1.Pod) []string {
+	v, ok := pod.Annotations[instrumentationVersionAnnotation]
+	if !ok {
+		return nil
+	}
+	instVersions := map[string]string{}
+	if err := json.Unmarshal([]byte(v), &instVersions); err != nil {
+		return nil
+	}
+	var languages []string
+	for _, inst := range insts {
+		if _, ok := instVersions[types.NamespacedName{Name: inst.Name, Namespace: inst.Namespace}.String()]; ok {
+			languages = append(languages, inst.Spec.Agent.Language)
+		}
+	}
+	return languages
+}
+
 func (i *NewrelicSdkInjector) injectWithInjector(ctx context.Context, injector apm.Injector, inst *current.Instrumentation, ns corev1.Namespace, pod corev1.Pod) (mutatedPod corev1.Pod, hadMatchingInjector bool, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -91,7 +387,17 @@ func (i *NewrelicSdkInjector) injectWithInjector(ctx context.Context, injector a
 	if injector.Language() != inst.Spec.Agent.Language {
 		return pod, false, nil
 	}
+	if resourceName, ok := meetsMinPodRequests(pod, i.minPodRequests(inst)); !ok {
+		i.logger.Info("skipping agent injection, the pod requests are below the minimum",
+			"agent_language", inst.Spec.Agent.Language,
+			"resource", resourceName,
+			"newrelic-namespace", inst.Namespace,
+			"newrelic-name", inst.Name,
+		)
+		return pod, true, nil
+	}
 	injector.ConfigureClient(i.client)
+	injector.ConfigureConfig(i.config)
 	injector.ConfigureLogger(i.logger.WithValues("injector", injector.Language()))
 	i.logger.V(1).Info("injecting instrumentation into pod",
 		"agent_language", inst.Spec.Agent.Language,
@@ -99,6 +405,99 @@ func (i *NewrelicSdkInjector) injectWithInjector(ctx context.Context, injector a
 		"newrelic-name", inst.Name,
 	)
 
-	mutatedPod, err = injector.Inject(ctx, *inst, ns, pod)
-	return mutatedPod, true, err
+	injectInst := *inst
+	if injectInst.Spec.Agent.Image, err = apm.PodAgentImage(i.config, *inst, pod); err != nil {
+		i.logger.Info("ignoring the agent image annotation of the pod, using the instrumentation image",
+			"reason", err.Error(),
+			"agent_language", inst.Spec.Agent.Language,
+			"newrelic-namespace", inst.Namespace,
+			"newrelic-name", inst.Name,
+		)
+	}
+	mutatedPod, err = injector.Inject(ctx, injectInst, ns, pod)
+	if err != nil {
+		return mutatedPod, true, err
+	}
+	requirements, tolerations := i.scheduling(inst)
+	mutatedPod = applyScheduling(mutatedPod, requirements, tolerations)
+	policy, dnsConfig := i.dns(inst)
+	mutatedPod = applyDNS(mutatedPod, policy, dnsConfig)
+	if i.config != nil {
+		mutatedPod = applyMinTerminationGracePeriod(mutatedPod, int64(i.config.MinTerminationGracePeriod()))
+	}
+	return mutatedPod, true, nil
+}
+
+// dns returns the DNS policy and config of the instrumentation, each falling back to the operator default
+func (i *NewrelicSdkInjector) dns(inst *current.Instrumentation) (corev1.DNSPolicy, *corev1.PodDNSConfig) {
+	policy, dnsConfig := inst.Spec.DNSPolicy, inst.Spec.DNSConfig
+	if i.config == nil {
+		return policy, dnsConfig
+	}
+	defaultPolicy, defaultDNSConfig := i.config.AgentDNS()
+	if policy == "" {
+		policy = defaultPolicy
+	}
+	if dnsConfig == nil {
+		dnsConfig = defaultDNSConfig
+	}
+	return policy, dnsConfig
+}
+
+// scheduling returns the node affinity requirements and tolerations of the instrumentation, followed by the operator
+// defaults for its language
+func (i *NewrelicSdkInjector) scheduling(inst *current.Instrumentation) ([]corev1.NodeSelectorRequirement, []corev1.Toleration) {
+	requirements := inst.Spec.RequiredNodeAffinity
+	tolerations := inst.Spec.Tolerations
+	if i.config == nil {
+		return requirements, tolerations
+	}
+	scheduling := i.config.LanguageScheduling(inst.Spec.Agent.Language)
+	requirements = append(append([]corev1.NodeSelectorRequirement{}, requirements...), scheduling.RequiredNodeAffinity...)
+	tolerations = append(append([]corev1.Toleration{}, tolerations...), scheduling.Tolerations...)
+	return requirements, tolerations
+}
+
+// existingAgent is used to check if a container of the pod sets one of the env vars signaling an agent baked into the
+// image. Pods we injected before, such as on a webhook reinvocation, are not considered.
+func (i *NewrelicSdkInjector) existingAgent(pod corev1.Pod) (string, bool) {
+	if i.config == nil {
+		return "", false
+	}
+	if _, ok := pod.Annotations[instrumentationVersionAnnotation]; ok {
+		return "", false
+	}
+	for _, envName := range i.config.ExistingAgentEnvVars() {
+		for _, container := range pod.Spec.Containers {
+			if slices.ContainsFunc(container.Env, func(env corev1.EnvVar) bool { return env.Name == envName }) {
+				return envName, true
+			}
+		}
+	}
+	return "", false
+}
+
+// minPodRequests returns the minimum pod requests of the instrumentation, or the operator default when it has none
+func (i *NewrelicSdkInjector) minPodRequests(inst *current.Instrumentation) corev1.ResourceList {
+	if len(inst.Spec.MinPodRequests) > 0 || i.config == nil {
+		return inst.Spec.MinPodRequests
+	}
+	return i.config.MinPodRequests()
+}
+
+// meetsMinPodRequests sums the requests of the pod's containers and compares them to the minimum. If the pod requests
+// less than the minimum of any resource, that resource is returned.
+func meetsMinPodRequests(pod corev1.Pod, minRequests corev1.ResourceList) (corev1.ResourceName, bool) {
+	for resourceName, minQuantity := range minRequests {
+		total := resource.Quantity{}
+		for _, container := range pod.Spec.Containers {
+			if quantity, ok := container.Resources.Requests[resourceName]; ok {
+				total.Add(quantity)
+			}
+		}
+		if total.Cmp(minQuantity) < 0 {
+			return resourceName, false
+		}
+	}
+	return "", true
 }
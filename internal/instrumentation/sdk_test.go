@@ -2,17 +2,22 @@ package instrumentation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/newrelic/k8s-agents-operator/api/current"
 	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 )
 
 var _ apm.Injector = (*ErrorInjector)(nil)
@@ -33,6 +38,8 @@ func (ei *ErrorInjector) ConfigureLogger(logger logr.Logger) {}
 
 func (ei *ErrorInjector) ConfigureClient(client client.Client) {}
 
+func (ei *ErrorInjector) ConfigureConfig(cfg *config.Config) {}
+
 var _ apm.Injector = (*PanicInjector)(nil)
 
 type PanicInjector struct {
@@ -56,6 +63,8 @@ func (pi *PanicInjector) ConfigureLogger(logger logr.Logger) {}
 
 func (pi *PanicInjector) ConfigureClient(client client.Client) {}
 
+func (pi *PanicInjector) ConfigureConfig(cfg *config.Config) {}
+
 var _ apm.Injector = (*AnnotationInjector)(nil)
 
 type AnnotationInjector struct {
@@ -78,6 +87,8 @@ func (ai *AnnotationInjector) ConfigureLogger(logger logr.Logger) {}
 
 func (ai *AnnotationInjector) ConfigureClient(client client.Client) {}
 
+func (ai *AnnotationInjector) ConfigureConfig(cfg *config.Config) {}
+
 func TestNewrelicSdkInjector_Inject(t *testing.T) {
 	vtrue, vzero := true, int64(0)
 	_, _ = vtrue, vzero
@@ -176,7 +187,7 @@ func TestNewrelicSdkInjector_Inject(t *testing.T) {
 			for _, langInst := range test.langInsts {
 				_ = defaulter.Default(ctx, langInst)
 			}
-			injector := NewNewrelicSdkInjector(logger, k8sClient, injectorRegistry)
+			injector := NewNewrelicSdkInjector(logger, k8sClient, injectorRegistry, nil)
 			pod := injector.Inject(ctx, test.langInsts, test.ns, test.pod)
 			if diff := cmp.Diff(test.expectedPod, pod); diff != "" {
 				t.Errorf("Unexpected diff (-want +got): %s", diff)
@@ -191,7 +202,7 @@ func TestNewrelicSdkInjector_Inject_WithPanic(t *testing.T) {
 	injectorRegistry := apm.NewInjectorRegistry()
 	pi := &PanicInjector{}
 	injectorRegistry.MustRegister(pi)
-	injector := NewNewrelicSdkInjector(logger, k8sClient, injectorRegistry)
+	injector := NewNewrelicSdkInjector(logger, k8sClient, injectorRegistry, nil)
 	func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -205,3 +216,382 @@ func TestNewrelicSdkInjector_Inject_WithPanic(t *testing.T) {
 		t.Fatalf("failed to trigger an injected panic")
 	}
 }
+
+func TestNewrelicSdkInjector_Inject_WithExistingAgent(t *testing.T) {
+	ctx := context.Background()
+	injectorRegistry := apm.NewInjectorRegistry()
+	pi := &PanicInjector{}
+	injectorRegistry.MustRegister(pi)
+	cfg := config.New(config.WithExistingAgentEnvVars([]string{"NEW_RELIC_LICENSE_KEY"}))
+	injector := NewNewrelicSdkInjector(logr.Discard(), k8sClient, injectorRegistry, &cfg)
+	recorder := record.NewFakeRecorder(1)
+	injector.ConfigureRecorder(recorder)
+	inst := &current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "panic", Image: "panic"}}}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "sidecar"},
+			{Name: "app", Env: []corev1.EnvVar{{Name: "NEW_RELIC_LICENSE_KEY", Value: "baked-in"}}},
+		}},
+	}
+
+	_ = injector.Inject(ctx, []*current.Instrumentation{inst}, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, pod)
+	if pi.injectAttempted {
+		t.Fatalf("expected the injection to be skipped")
+	}
+	if event := <-recorder.Events; event != "Normal AgentAlreadyPresent Skipped injecting pod default/app, it already sets NEW_RELIC_LICENSE_KEY" {
+		t.Fatalf("unexpected event %q", event)
+	}
+
+	// pods injected before are injected again
+	pod.Annotations = map[string]string{"newrelic.com/instrumentation-versions": "{}"}
+	_ = injector.Inject(ctx, []*current.Instrumentation{inst}, corev1.Namespace{}, pod)
+	if !pi.injectAttempted {
+		t.Fatalf("expected the injection to be attempted")
+	}
+}
+
+func TestNewrelicSdkInjector_Inject_WithUnresolvedAppName(t *testing.T) {
+	ctx := context.Background()
+	injectorRegistry := apm.NewInjectorRegistry()
+	injectorRegistry.MustRegister(&AnnotationInjector{lang: "java"})
+	injector := NewNewrelicSdkInjector(logr.Discard(), k8sClient, injectorRegistry, nil)
+	recorder := record.NewFakeRecorder(1)
+	injector.ConfigureRecorder(recorder)
+	inst := &current.Instrumentation{Spec: current.InstrumentationSpec{
+		Agent:   current.Agent{Language: "java", Image: "java"},
+		AppName: current.AppName{Template: "{namespace.labels.env}-{pod.labels.app}", Fallback: "unnamed"},
+	}}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"app": "checkout"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}
+
+	_ = injector.Inject(ctx, []*current.Instrumentation{inst}, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, pod)
+	if event := <-recorder.Events; event != `Warning AppNameUnresolved Used "unnamed" for pod default/app, the app name template can't be resolved: none of the sources of placeholder {namespace.labels.env} are set` {
+		t.Fatalf("unexpected event %q", event)
+	}
+
+	_ = injector.Inject(ctx, []*current.Instrumentation{inst}, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"env": "prod"}}}, pod)
+	if len(recorder.Events) > 0 {
+		t.Fatalf("unexpected event %q", <-recorder.Events)
+	}
+}
+
+func TestNewrelicSdkInjector_Inject_WithMaxLanguagesPerPod(t *testing.T) {
+	ctx := context.Background()
+	injectorRegistry := apm.NewInjectorRegistry()
+	for _, lang := range []string{"java", "nodejs", "python"} {
+		injectorRegistry.MustRegister(&AnnotationInjector{lang: lang})
+	}
+	cfg := config.New(config.WithMaxLanguagesPerPod(2))
+	injector := NewNewrelicSdkInjector(logr.Discard(), k8sClient, injectorRegistry, &cfg)
+	recorder := record.NewFakeRecorder(1)
+	injector.ConfigureRecorder(recorder)
+	var insts []*current.Instrumentation
+	for _, lang := range []string{"java", "nodejs", "java", "python"} {
+		insts = append(insts, &current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: lang, Image: lang}}})
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}
+
+	actualPod := injector.Inject(ctx, insts, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, pod)
+	expectedAnnotations := map[string]string{"injected-java": "true", "injected-nodejs": "true"}
+	if diff := cmp.Diff(expectedAnnotations, actualPod.Annotations); diff != "" {
+		t.Fatal(diff)
+	}
+	if event := <-recorder.Events; event != "Warning TooManyLanguages Skipped injecting pod default/app with python, it already gets the agents of 2 languages (java, nodejs)" {
+		t.Fatalf("unexpected event %q", event)
+	}
+}
+
+func TestNewrelicSdkInjector_Inject_WithMaxLanguagesPerPod_Reinvocation(t *testing.T) {
+	ctx := context.Background()
+	injectorRegistry := apm.NewInjectorRegistry()
+	for _, lang := range []string{"java", "python"} {
+		injectorRegistry.MustRegister(&AnnotationInjector{lang: lang})
+	}
+	cfg := config.New(config.WithMaxLanguagesPerPod(1))
+	injector := NewNewrelicSdkInjector(logr.Discard(), k8sClient, injectorRegistry, &cfg)
+	recorder := record.NewFakeRecorder(1)
+	injector.ConfigureRecorder(recorder)
+	insts := []*current.Instrumentation{
+		{ObjectMeta: metav1.ObjectMeta{Name: "python", Namespace: "newrelic"}, Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "python", Image: "python"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "newrelic"}, Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "java", Image: "java"}}},
+	}
+	// the pod was injected with python by the first invocation, so it keeps python, even though java sorts first
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Annotations: map[string]string{
+			instrumentationVersionAnnotation: `{"newrelic/python":"01234567-89ab-cdef-0123-456789abcdef/1"}`,
+		}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}
+
+	for _, order := range [][]*current.Instrumentation{insts, {insts[1], insts[0]}} {
+		actualPod := injector.Inject(ctx, order, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, pod)
+		if _, ok := actualPod.Annotations["injected-python"]; !ok {
+			t.Fatalf("expected python to be kept, got %v", actualPod.Annotations)
+		}
+		if _, ok := actualPod.Annotations["injected-java"]; ok {
+			t.Fatalf("expected java to be skipped, got %v", actualPod.Annotations)
+		}
+		if event := <-recorder.Events; event != "Warning TooManyLanguages Skipped injecting pod default/app with java, it already gets the agents of 1 languages (python)" {
+			t.Fatalf("unexpected event %q", event)
+		}
+	}
+}
+
+func TestNewrelicSdkInjector_Inject_WithFailureThreshold(t *testing.T) {
+	ctx := context.Background()
+	injectorRegistry := apm.NewInjectorRegistry()
+	injectorRegistry.MustRegister(&ErrorInjector{err: errors.New("incompatible image")})
+	injectorRegistry.MustRegister(&AnnotationInjector{lang: "java"})
+	injector := NewNewrelicSdkInjector(logr.Discard(), k8sClient, injectorRegistry, nil)
+	recorder := record.NewFakeRecorder(2)
+	injector.ConfigureRecorder(recorder)
+	injector.ConfigureFailureTracker(NewInjectionFailureTracker(2))
+	failing := &current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "error", Image: "error"}}}
+	working := &current.Instrumentation{Spec: current.InstrumentationSpec{Agent: current.Agent{Language: "java", Image: "java"}}}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	podOf := func(replicaSet string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: replicaSet + "-", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: replicaSet, Controller: ptr.To(true)},
+			}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		}
+	}
+
+	actualPod := injector.Inject(ctx, []*current.Instrumentation{failing, working}, ns, podOf("app-1"))
+	if diff := cmp.Diff(map[string]string{"injected-java": "true"}, actualPod.Annotations); diff != "" {
+		t.Fatal(diff)
+	}
+	if len(recorder.Events) > 0 {
+		t.Fatalf("unexpected event %q", <-recorder.Events)
+	}
+
+	actualPod = injector.Inject(ctx, []*current.Instrumentation{failing, working}, ns, podOf("app-1"))
+	if diff := cmp.Diff(map[string]string{InjectionFailedAnnotation: "failed 2 consecutive times"}, actualPod.Annotations); diff != "" {
+		t.Fatal(diff)
+	}
+	for range 2 {
+		if event := <-recorder.Events; event != "Warning InjectionFailureThresholdReached Stopped injecting ReplicaSet default/app-1, its injection failed 2 consecutive times" {
+			t.Fatalf("unexpected event %q", event)
+		}
+	}
+
+	// the workload fails open, even for the instrumentations which would inject it
+	actualPod = injector.Inject(ctx, []*current.Instrumentation{working}, ns, podOf("app-1"))
+	if diff := cmp.Diff(map[string]string{InjectionFailedAnnotation: "failed 2 consecutive times"}, actualPod.Annotations); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// a new revision of the workload is injected again
+	actualPod = injector.Inject(ctx, []*current.Instrumentation{working}, ns, podOf("app-2"))
+	if diff := cmp.Diff(map[string]string{"injected-java": "true"}, actualPod.Annotations); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestMeetsMinPodRequests(t *testing.T) {
+	requests := func(cpu, memory string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}}
+	}
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "app", Resources: requests("250m", "256Mi")},
+		{Name: "sidecar", Resources: requests("10m", "16Mi")},
+		{Name: "no-requests"},
+	}}}
+	tests := []struct {
+		name             string
+		minRequests      corev1.ResourceList
+		expectedResource corev1.ResourceName
+		expectedOk       bool
+	}{
+		{
+			name:       "no minimum",
+			expectedOk: true,
+		},
+		{
+			name:        "summed requests above the minimum",
+			minRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("272Mi")},
+			expectedOk:  true,
+		},
+		{
+			name:             "cpu below the minimum",
+			minRequests:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+			expectedResource: corev1.ResourceCPU,
+		},
+		{
+			name:             "resource not requested",
+			minRequests:      corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+			expectedResource: "nvidia.com/gpu",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resourceName, ok := meetsMinPodRequests(pod, test.minRequests)
+			if ok != test.expectedOk || resourceName != test.expectedResource {
+				t.Errorf("expected (%q, %v), got (%q, %v)", test.expectedResource, test.expectedOk, resourceName, ok)
+			}
+		})
+	}
+}
+
+func TestApplyScheduling(t *testing.T) {
+	gpuNodes := corev1.NodeSelectorRequirement{Key: "agents", Operator: corev1.NodeSelectorOpIn, Values: []string{"enabled"}}
+	zone := corev1.NodeSelectorRequirement{Key: "topology.kubernetes.io/zone", Operator: corev1.NodeSelectorOpExists}
+	agentsToleration := corev1.Toleration{Key: "agents", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}
+	existingToleration := corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "app"}
+	tests := []struct {
+		name         string
+		pod          corev1.Pod
+		requirements []corev1.NodeSelectorRequirement
+		tolerations  []corev1.Toleration
+		expectedPod  corev1.Pod
+	}{
+		{
+			name:        "nothing to apply",
+			pod:         corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}},
+		},
+		{
+			name:         "no affinity",
+			requirements: []corev1.NodeSelectorRequirement{gpuNodes},
+			tolerations:  []corev1.Toleration{agentsToleration},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{
+				Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{gpuNodes}}},
+				}}},
+				Tolerations: []corev1.Toleration{agentsToleration},
+			}},
+		},
+		{
+			name: "existing terms and tolerations",
+			pod: corev1.Pod{Spec: corev1.PodSpec{
+				Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{MatchExpressions: []corev1.NodeSelectorRequirement{zone}},
+						{MatchExpressions: []corev1.NodeSelectorRequirement{gpuNodes}},
+					},
+				}}},
+				Tolerations: []corev1.Toleration{existingToleration, agentsToleration},
+			}},
+			requirements: []corev1.NodeSelectorRequirement{gpuNodes},
+			tolerations:  []corev1.Toleration{agentsToleration},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{
+				Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{MatchExpressions: []corev1.NodeSelectorRequirement{zone, gpuNodes}},
+						{MatchExpressions: []corev1.NodeSelectorRequirement{gpuNodes}},
+					},
+				}}},
+				Tolerations: []corev1.Toleration{existingToleration, agentsToleration},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// apply multiple times to assert that it's idempotent
+			actualPod := test.pod
+			for i := 0; i < 3; i++ {
+				actualPod = applyScheduling(actualPod, test.requirements, test.tolerations)
+			}
+			if diff := cmp.Diff(test.expectedPod, actualPod); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestApplyMinTerminationGracePeriod(t *testing.T) {
+	tests := []struct {
+		name        string
+		gracePeriod *int64
+		minimum     int64
+		expected    *int64
+	}{
+		{name: "disabled", minimum: 0, expected: nil},
+		{name: "default grace period", minimum: 45, expected: ptr.To[int64](45)},
+		{name: "default grace period long enough", minimum: 20, expected: nil},
+		{name: "shorter grace period", gracePeriod: ptr.To[int64](10), minimum: 45, expected: ptr.To[int64](45)},
+		{name: "longer grace period", gracePeriod: ptr.To[int64](60), minimum: 45, expected: ptr.To[int64](60)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{Spec: corev1.PodSpec{TerminationGracePeriodSeconds: test.gracePeriod}}
+			actual := applyMinTerminationGracePeriod(pod, test.minimum)
+			if diff := cmp.Diff(test.expected, actual.Spec.TerminationGracePeriodSeconds); diff != "" {
+				t.Errorf("Unexpected diff (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyDNS(t *testing.T) {
+	ndots := "2"
+	tests := []struct {
+		name        string
+		pod         corev1.Pod
+		policy      corev1.DNSPolicy
+		dnsConfig   *corev1.PodDNSConfig
+		expectedPod corev1.Pod
+	}{
+		{
+			name:        "nothing to apply",
+			pod:         corev1.Pod{Spec: corev1.PodSpec{DNSPolicy: corev1.DNSClusterFirst}},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{DNSPolicy: corev1.DNSClusterFirst}},
+		},
+		{
+			name:      "default policy",
+			pod:       corev1.Pod{Spec: corev1.PodSpec{DNSPolicy: corev1.DNSClusterFirst}},
+			policy:    corev1.DNSNone,
+			dnsConfig: &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.53"}},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{
+				DNSPolicy: corev1.DNSNone,
+				DNSConfig: &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.53"}},
+			}},
+		},
+		{
+			name:        "pod policy wins",
+			pod:         corev1.Pod{Spec: corev1.PodSpec{DNSPolicy: corev1.DNSDefault}},
+			policy:      corev1.DNSNone,
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{DNSPolicy: corev1.DNSDefault}},
+		},
+		{
+			name: "merged with the pod config",
+			pod: corev1.Pod{Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+				Nameservers: []string{"10.0.0.1", "10.0.0.2"},
+				Searches:    []string{"app.internal"},
+				Options:     []corev1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+			}}},
+			dnsConfig: &corev1.PodDNSConfig{
+				Nameservers: []string{"10.0.0.2", "10.0.0.53", "10.0.0.54"},
+				Searches:    []string{"app.internal", "newrelic.internal"},
+				Options:     []corev1.PodDNSConfigOption{{Name: "ndots"}, {Name: "edns0"}},
+			},
+			expectedPod: corev1.Pod{Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+				Nameservers: []string{"10.0.0.1", "10.0.0.2", "10.0.0.53"},
+				Searches:    []string{"app.internal", "newrelic.internal"},
+				Options:     []corev1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}, {Name: "edns0"}},
+			}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// apply multiple times to assert that it's idempotent
+			actualPod := test.pod
+			for i := 0; i < 3; i++ {
+				actualPod = applyDNS(actualPod, test.policy, test.dnsConfig)
+			}
+			if diff := cmp.Diff(test.expectedPod, actualPod); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
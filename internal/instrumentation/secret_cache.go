@@ -0,0 +1,137 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ SecretReplicator = (*CachingSecretReplicator)(nil)
+
+var errSecretCircuitOpen = errors.New("secret resolution circuit breaker is open")
+
+const (
+	circuitClosed   = 0
+	circuitOpen     = 1
+	circuitHalfOpen = 2
+)
+
+// CachingSecretReplicator wraps a SecretReplicator, so that admissions don't all pay the api server latency of
+// resolving the license key secret. Secrets resolved successfully are remembered for the ttl. After failureThreshold
+// consecutive api failures the circuit opens, failing secret resolution right away for the cooldown, after which a
+// single call is let through to probe the api server again. A pod failing secret resolution isn't injected, so the
+// admission fails open.
+type CachingSecretReplicator struct {
+	replicator       SecretReplicator
+	ttl              time.Duration
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+
+	mu        sync.Mutex
+	resolved  map[types.NamespacedName]time.Time
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// NewCachingSecretReplicator is the constructor for caching secret resolution. A ttl of zero disables the cache and a
+// failure threshold of zero disables the circuit breaker.
+func NewCachingSecretReplicator(replicator SecretReplicator, ttl time.Duration, failureThreshold int, cooldown time.Duration) *CachingSecretReplicator {
+	secretCircuitBreakerState.Set(circuitClosed)
+	return &CachingSecretReplicator{
+		replicator:       replicator,
+		ttl:              ttl,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+		resolved:         make(map[types.NamespacedName]time.Time),
+	}
+}
+
+// ReplicateSecret is used to replicate the secret, unless it was resolved within the ttl or the circuit is open
+func (r *CachingSecretReplicator) ReplicateSecret(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, operatorNamespace string, secretName string) error {
+	key := types.NamespacedName{Namespace: ns.Name, Name: secretName}
+
+	r.mu.Lock()
+	now := r.now()
+	if expiry, ok := r.resolved[key]; ok {
+		if now.Before(expiry) {
+			r.mu.Unlock()
+			secretCacheRequests.WithLabelValues("hit").Inc()
+			return nil
+		}
+		delete(r.resolved, key)
+	}
+	secretCacheRequests.WithLabelValues("miss").Inc()
+	switch r.state(now) {
+	case circuitOpen:
+		r.mu.Unlock()
+		return errSecretCircuitOpen
+	case circuitHalfOpen:
+		if r.probing {
+			r.mu.Unlock()
+			return errSecretCircuitOpen
+		}
+		r.probing = true
+	}
+	r.mu.Unlock()
+
+	err := r.replicator.ReplicateSecret(ctx, ns, pod, operatorNamespace, secretName)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probing = false
+	switch {
+	case err == nil:
+		r.failures = 0
+		if r.ttl > 0 {
+			r.resolved[key] = r.now().Add(r.ttl)
+		}
+	case isSecretAPIFailure(err):
+		r.failures++
+		if r.failureThreshold > 0 && r.failures >= r.failureThreshold {
+			r.openUntil = r.now().Add(r.cooldown)
+		}
+	}
+	secretCircuitBreakerState.Set(float64(r.state(r.now())))
+	return err
+}
+
+// state is used to get the state of the circuit. It must be called with the lock held.
+func (r *CachingSecretReplicator) state(now time.Time) int {
+	if r.failureThreshold <= 0 || r.failures < r.failureThreshold {
+		return circuitClosed
+	}
+	if now.Before(r.openUntil) {
+		return circuitOpen
+	}
+	return circuitHalfOpen
+}
+
+// isSecretAPIFailure is used to check if the error is the api server failing, rather than the secret being missing or
+// malformed, which says nothing about the api server health
+func isSecretAPIFailure(err error) bool {
+	return !apierrors.IsNotFound(err) && !apierrors.IsAlreadyExists(err) && !errors.Is(err, errInvalidLicenseKeyFormat)
+}
@@ -0,0 +1,72 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCachingSecretReplicator(t *testing.T) {
+	ctx := context.Background()
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	var replicateErr error
+	var inner SecretReplicatorFn = func(ctx context.Context, ns corev1.Namespace, pod corev1.Pod, operatorNamespace string, secretName string) error {
+		calls++
+		return replicateErr
+	}
+	r := NewCachingSecretReplicator(inner, time.Minute, 2, 30*time.Second)
+	r.now = func() time.Time { return now }
+	replicate := func() error {
+		return r.ReplicateSecret(ctx, ns, corev1.Pod{}, "operator", DefaultLicenseKeySecretName)
+	}
+
+	// resolved secrets are cached for the ttl
+	if err := replicate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := replicate(); err != nil || calls != 1 {
+		t.Fatalf("expected a cache hit, got %v after %d calls", err, calls)
+	}
+	now = now.Add(2 * time.Minute)
+
+	// missing secrets don't open the circuit
+	replicateErr = apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, DefaultLicenseKeySecretName)
+	for i := 0; i < 3; i++ {
+		if err := replicate(); !apierrors.IsNotFound(err) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	}
+	if calls != 4 {
+		t.Fatalf("expected 4 calls, got %d", calls)
+	}
+
+	// api failures open the circuit after the threshold
+	replicateErr = errors.New("timeout")
+	_ = replicate()
+	_ = replicate()
+	if state := testutil.ToFloat64(secretCircuitBreakerState); state != circuitOpen {
+		t.Fatalf("expected the circuit to be open, got %v", state)
+	}
+	if err := replicate(); !errors.Is(err, errSecretCircuitOpen) || calls != 6 {
+		t.Fatalf("expected the circuit to be open, got %v after %d calls", err, calls)
+	}
+
+	// after the cooldown, a successful probe closes the circuit
+	now = now.Add(time.Minute)
+	replicateErr = nil
+	if err := replicate(); err != nil || calls != 7 {
+		t.Fatalf("expected the probe to succeed, got %v after %d calls", err, calls)
+	}
+	if state := testutil.ToFloat64(secretCircuitBreakerState); state != circuitClosed {
+		t.Fatalf("expected the circuit to be closed, got %v", state)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instrumentation
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=autoscaling.k8s.io,resources=verticalpodautoscalers,verbs=get;list;watch
+
+// vpaListGroupVersionKind is the kind of the vertical pod autoscaler lists. They're read as unstructured objects, as
+// the autoscaler API isn't part of the operator dependencies.
+var vpaListGroupVersionKind = schema.GroupVersionKind{Group: "autoscaling.k8s.io", Version: "v1", Kind: "VerticalPodAutoscalerList"}
+
+// vpaUpdateModeOff is the update mode of the vertical pod autoscalers which only recommend resources
+const vpaUpdateModeOff = "Off"
+
+// isVPAManaged is used to check if a vertical pod autoscaler, which doesn't only recommend resources, targets the
+// workload. The pods of a deployment are owned by a replicaset, which is looked up with the client to get the
+// deployment.
+func isVPAManaged(ctx context.Context, c client.Client, key workloadKey) (bool, error) {
+	if key.kind == "ReplicaSet" {
+		var replicaSet appsv1.ReplicaSet
+		if err := c.Get(ctx, client.ObjectKey{Namespace: key.namespace, Name: key.name}, &replicaSet); err != nil {
+			return false, fmt.Errorf("failed to get the replicaset > %w", err)
+		}
+		if owner := metav1.GetControllerOf(&replicaSet); owner != nil && owner.Kind == "Deployment" {
+			key.kind, key.name = owner.Kind, owner.Name
+		}
+	}
+	var vpas unstructured.UnstructuredList
+	vpas.SetGroupVersionKind(vpaListGroupVersionKind)
+	if err := c.List(ctx, &vpas, client.InNamespace(key.namespace)); err != nil {
+		return false, fmt.Errorf("failed to list the vertical pod autoscalers > %w", err)
+	}
+	for _, vpa := range vpas.Items {
+		kind, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "kind")
+		name, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "name")
+		updateMode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+		if kind == key.kind && name == key.name && updateMode != vpaUpdateModeOff {
+			return true, nil
+		}
+	}
+	return false, nil
+}
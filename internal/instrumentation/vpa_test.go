@@ -0,0 +1,117 @@
+package instrumentation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/newrelic/k8s-agents-operator/api/current"
+	"github.com/newrelic/k8s-agents-operator/internal/autodetect"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+func newTestVPA(name string, kind string, targetName string, updateMode string) *unstructured.Unstructured {
+	vpa := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"targetRef": map[string]any{"apiVersion": "apps/v1", "kind": kind, "name": targetName},
+		},
+	}}
+	if updateMode != "" {
+		_ = unstructured.SetNestedField(vpa.Object, updateMode, "spec", "updatePolicy", "updateMode")
+	}
+	vpa.SetGroupVersionKind(vpaListGroupVersionKind.GroupVersion().WithKind("VerticalPodAutoscaler"))
+	vpa.SetNamespace("default")
+	vpa.SetName(name)
+	return vpa
+}
+
+func newTestVPAClient(vpas ...client.Object) client.Client {
+	vtrue := true
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name: "app-5d4f8", Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "app", Controller: &vtrue}},
+	}}
+	return fake.NewClientBuilder().WithObjects(append(vpas, replicaSet)...).Build()
+}
+
+func TestIsVPAManaged(t *testing.T) {
+	tests := []struct {
+		name     string
+		vpas     []client.Object
+		key      workloadKey
+		expected bool
+	}{
+		{
+			name: "no vertical pod autoscaler",
+			key:  workloadKey{namespace: "default", kind: "ReplicaSet", name: "app-5d4f8"},
+		},
+		{
+			name:     "deployment of the replicaset",
+			vpas:     []client.Object{newTestVPA("app", "Deployment", "app", "")},
+			key:      workloadKey{namespace: "default", kind: "ReplicaSet", name: "app-5d4f8"},
+			expected: true,
+		},
+		{
+			name: "another deployment",
+			vpas: []client.Object{newTestVPA("other", "Deployment", "other", "Auto")},
+			key:  workloadKey{namespace: "default", kind: "ReplicaSet", name: "app-5d4f8"},
+		},
+		{
+			name: "recommendations only",
+			vpas: []client.Object{newTestVPA("app", "Deployment", "app", "Off")},
+			key:  workloadKey{namespace: "default", kind: "ReplicaSet", name: "app-5d4f8"},
+		},
+		{
+			name:     "statefulset",
+			vpas:     []client.Object{newTestVPA("db", "StatefulSet", "db", "Initial")},
+			key:      workloadKey{namespace: "default", kind: "StatefulSet", name: "db"},
+			expected: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			managed, err := isVPAManaged(context.Background(), newTestVPAClient(test.vpas...), test.key)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, managed)
+		})
+	}
+}
+
+func TestNewrelicSdkInjector_RelaxVPAResources(t *testing.T) {
+	vtrue := true
+	resources := corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}}
+	inst := &current.Instrumentation{
+		ObjectMeta: metav1.ObjectMeta{Name: "newrelic-java", Namespace: "newrelic"},
+		Spec:       current.InstrumentationSpec{Agent: current.Agent{Language: "java", Resources: resources}},
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		GenerateName: "app-5d4f8-", Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "app-5d4f8", Controller: &vtrue}},
+	}}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	c := newTestVPAClient(newTestVPA("app", "Deployment", "app", "Auto"))
+
+	t.Run("vertical pod autoscaler not available", func(t *testing.T) {
+		cfg := config.New(config.WithVPA(autodetect.VPANotAvailable))
+		injector := NewNewrelicSdkInjector(logr.Discard(), c, nil, &cfg)
+		insts := injector.relaxVPAResources(context.Background(), []*current.Instrumentation{inst}, ns, pod, getWorkloadKey(ns, pod))
+		assert.Equal(t, resources, insts[0].Spec.Agent.Resources)
+	})
+	t.Run("managed by a vertical pod autoscaler", func(t *testing.T) {
+		cfg := config.New(config.WithVPA(autodetect.VPAAvailable))
+		injector := NewNewrelicSdkInjector(logr.Discard(), c, nil, &cfg)
+		insts := injector.relaxVPAResources(context.Background(), []*current.Instrumentation{inst}, ns, pod, getWorkloadKey(ns, pod))
+		assert.Equal(t, corev1.ResourceRequirements{}, insts[0].Spec.Agent.Resources)
+		assert.Equal(t, resources, inst.Spec.Agent.Resources, "expected the instrumentation to be left as is")
+	})
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// injectionsThrottled is the number of pod admissions admitted without injection, as too many were injected at once
+var injectionsThrottled = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "operator_injections_throttled_total",
+		Help: "Number of pod admissions admitted without injection, as the concurrent injection limit was reached for too long",
+	},
+)
+
+// injectionLimiter bounds the pod admissions injected at once, so that a mass rollout doesn't overwhelm the operator
+// with namespace, instrumentation and secret lookups. The admissions beyond the limit wait up to the queue timeout for
+// a slot. A nil limiter doesn't limit anything.
+type injectionLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// newInjectionLimiter is used to create a limiter of the concurrent injections, nil when the limit is zero
+func newInjectionLimiter(limit int, queueTimeout time.Duration) *injectionLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &injectionLimiter{slots: make(chan struct{}, limit), queueTimeout: queueTimeout}
+}
+
+// acquire is used to wait for a slot, returning the func releasing it, or false when none was freed within the queue
+// timeout or the context is done
+func (l *injectionLimiter) acquire(ctx context.Context) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	release := func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, true
+	default:
+	}
+	if l.queueTimeout <= 0 {
+		return nil, false
+	}
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return release, true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return nil, false
+}
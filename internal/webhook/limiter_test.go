@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInjectionLimiter_Acquire(t *testing.T) {
+	var unlimited *injectionLimiter
+	if release, ok := unlimited.acquire(context.Background()); !ok {
+		t.Fatal("expected a nil limiter not to limit")
+	} else {
+		release()
+	}
+	if newInjectionLimiter(0, time.Second) != nil {
+		t.Fatal("expected no limiter without a limit")
+	}
+
+	limiter := newInjectionLimiter(1, 50*time.Millisecond)
+	release, ok := limiter.acquire(context.Background())
+	if !ok {
+		t.Fatal("expected a free slot")
+	}
+	if _, ok = limiter.acquire(context.Background()); ok {
+		t.Fatal("expected the queue timeout to be reached while the slot is taken")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok = limiter.acquire(ctx); ok {
+		t.Fatal("expected a done context not to wait for a slot")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	release, ok = limiter.acquire(context.Background())
+	if !ok {
+		t.Fatal("expected the slot released while queued to be acquired")
+	}
+	release()
+
+	failFast := newInjectionLimiter(1, 0)
+	release, _ = failFast.acquire(context.Background())
+	defer release()
+	if _, ok = failFast.acquire(context.Background()); ok {
+		t.Fatal("expected no queueing without a queue timeout")
+	}
+}
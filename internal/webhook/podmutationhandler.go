@@ -3,9 +3,14 @@ package webhook
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -14,6 +19,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/newrelic/k8s-agents-operator/internal/apm"
+	"github.com/newrelic/k8s-agents-operator/internal/config"
 	"github.com/newrelic/k8s-agents-operator/internal/instrumentation"
 )
 
@@ -22,7 +28,7 @@ var (
 	_ PodMutator = (*instrumentation.InstrumentationPodMutator)(nil)
 )
 
-// +kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=ignore,groups="",resources=pods,verbs=create;update,versions=v1,name=mpod.kb.io,sideEffects=none,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=ignore,reinvocationPolicy=IfNeeded,groups="",resources=pods,verbs=create;update,versions=v1,name=mpod.kb.io,sideEffects=none,admissionReviewVersions=v1
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=list;watch
 // +kubebuilder:rbac:groups=newrelic.com,resources=instrumentations,verbs=get;list;watch
 // +kubebuilder:rbac:groups="apps",resources=replicasets,verbs=get;list;watch
@@ -30,12 +36,17 @@ var (
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;create;delete;deletecollection;patch;update;watch
 
+// injectUntilAnnotation is an RFC 3339 timestamp after which the pod is no longer instrumented
+const injectUntilAnnotation = "newrelic.com/inject-until"
+
 // PodMutationHandler is a webhook handler for mutating Pods
 type PodMutationHandler struct {
 	Client   client.Client
 	Decoder  admission.Decoder
 	Mutators []PodMutator
 	Logger   logr.Logger
+
+	limiter *injectionLimiter
 }
 
 // PodMutator mutates a pod.
@@ -45,14 +56,51 @@ type PodMutator interface {
 
 //var podMutatorLog = ctrl.Log.WithName("pod-mutator")
 
-// Handle manages Pod mutations
+// Handle manages Pod mutations, recording each admission decision as a span
 func (m *PodMutationHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ctx, span := tracer.Start(ctx, "pod admission", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	// the request uid is what the injection failures are counted once per
+	ctx = admission.NewContextWithRequest(ctx, req)
+	res := m.handle(ctx, req)
+	if span.IsRecording() {
+		recordDecision(span, req, res)
+	}
+	return res
+}
+
+func (m *PodMutationHandler) handle(ctx context.Context, req admission.Request) admission.Response {
 	pod := corev1.Pod{}
 	err := m.Decoder.Decode(req, &pod)
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	if isSelfCheckPod(pod) {
+		marshaledPod, err := json.Marshal(acknowledgeSelfCheck(pod))
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+	}
+
+	if expired, err := isInjectionExpired(pod, time.Now()); err != nil {
+		m.Logger.Error(err, "Skipping pod mutation, invalid annotation", "name", pod.Name, "annotation", injectUntilAnnotation)
+		return admission.Allowed("invalid " + injectUntilAnnotation + " annotation")
+	} else if expired {
+		m.Logger.Info("Skipping pod mutation, injection expired", "name", pod.Name, "inject_until", pod.Annotations[injectUntilAnnotation])
+		return admission.Allowed("injection expired")
+	}
+
+	release, ok := m.limiter.acquire(ctx)
+	if !ok {
+		// the webhook fails open, so the pod is admitted rather than left waiting on the api server timeout
+		injectionsThrottled.Inc()
+		m.Logger.Info("Skipping pod mutation, too many concurrent injections", "name", pod.Name, "namespace", req.Namespace)
+		return admission.Allowed("too many concurrent injections")
+	}
+	defer release()
+
 	m.Logger.Info("Mutating Pod", "name", pod.Name)
 
 	// we use the req.Namespace here because the pod might have not been created yet
@@ -87,33 +135,154 @@ func (m *PodMutationHandler) Handle(ctx context.Context, req admission.Request)
 		return res
 	}
 
-	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+	res := admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+	if debugLogger := m.Logger.V(4); debugLogger.Enabled() {
+		if patch, err := redactedPatch(res.Patches); err != nil {
+			debugLogger.Error(err, "failed to redact the pod patch", "name", pod.Name)
+		} else {
+			debugLogger.Info("Mutated Pod", "name", pod.Name, "namespace", req.Namespace, "patch", patch)
+		}
+	}
+	return res
+}
+
+// redactedPatch is used to get the json patch with the values of any secret looking env vars redacted, so that it can
+// be logged
+func redactedPatch(patches any) (string, error) {
+	raw, err := json.Marshal(patches)
+	if err != nil {
+		return "", err
+	}
+	var patch any
+	if err = json.Unmarshal(raw, &patch); err != nil {
+		return "", err
+	}
+	redactEnvValues(patch)
+	raw, err = json.Marshal(patch)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// redactEnvValues walks the decoded json patch, replacing every env var value that the patch operations write. The
+// operations are matched on their json pointer path, as a `replace` of `/spec/containers/0/env/3/value` only carries
+// the raw value, without the name of the env var
+func redactEnvValues(v any) {
+	ops, ok := v.([]any)
+	if !ok {
+		return
+	}
+	for _, op := range ops {
+		op, ok := op.(map[string]any)
+		if !ok {
+			continue
+		}
+		value, ok := op["value"]
+		if !ok {
+			continue
+		}
+		path, _ := op["path"].(string)
+		if isEnvPath(path) {
+			op["value"] = redactEnv(value)
+		} else {
+			op["value"] = redactNestedEnv(value)
+		}
+	}
+}
+
+// isEnvPath is used to check if the json pointer path points to, or into, an env list
+func isEnvPath(path string) bool {
+	return slices.Contains(strings.Split(path, "/"), "env")
+}
+
+// redactEnv is used to redact a value written at, or under, an env list path. It's either the list itself, a single
+// env var, or the raw value of one
+func redactEnv(v any) any {
+	switch value := v.(type) {
+	case string:
+		return "REDACTED"
+	case map[string]any:
+		if _, ok := value["value"].(string); ok {
+			value["value"] = "REDACTED"
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = redactEnv(child)
+		}
+	}
+	return v
+}
+
+// redactNestedEnv is used to redact the env lists nested in a value, such as a complete container being added
+func redactNestedEnv(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, child := range value {
+			if key == "env" {
+				value[key] = redactEnv(child)
+			} else {
+				value[key] = redactNestedEnv(child)
+			}
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = redactNestedEnv(child)
+		}
+	}
+	return v
+}
+
+// isInjectionExpired is used to check if the pod's inject-until annotation is in the past
+func isInjectionExpired(pod corev1.Pod, now time.Time) (bool, error) {
+	injectUntil, ok := pod.Annotations[injectUntilAnnotation]
+	if !ok {
+		return false, nil
+	}
+	until, err := time.Parse(time.RFC3339, injectUntil)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %s %q: %w", injectUntilAnnotation, injectUntil, err)
+	}
+	return !now.Before(until), nil
 }
 
 // SetupWebhookWithManager registers the pod mutation webhook
-func SetupWebhookWithManager(mgr ctrl.Manager, operatorNamespace string, logger logr.Logger) error {
+func SetupWebhookWithManager(mgr ctrl.Manager, operatorNamespace string, logger logr.Logger, cfg *config.Config) error {
 	// Setup InstrumentationMutator
 	mgrClient := mgr.GetClient()
 	injectorRegistry := apm.DefaultInjectorRegistry
-	injector := instrumentation.NewNewrelicSdkInjector(logger, mgrClient, injectorRegistry)
-	secretReplicator := instrumentation.NewNewrelicSecretReplicator(logger, mgrClient)
+	injector := instrumentation.NewNewrelicSdkInjector(logger, mgrClient, injectorRegistry, cfg)
+	injector.ConfigureRecorder(mgr.GetEventRecorderFor("k8s-agents-operator"))
+	injector.ConfigureFailureTracker(instrumentation.NewInjectionFailureTracker(cfg.InjectionFailureThreshold()))
+	failureThreshold, cooldown := cfg.SecretCircuitBreaker()
+	secretReplicator := instrumentation.NewCachingSecretReplicator(
+		instrumentation.NewNewrelicSecretReplicator(logger, mgrClient),
+		cfg.SecretCacheTTL(),
+		failureThreshold,
+		cooldown,
+	)
 	instrumentationLocator := instrumentation.NewNewRelicInstrumentationLocator(logger, mgrClient, operatorNamespace)
+	mutator := instrumentation.NewMutator(
+		logger,
+		mgrClient,
+		injector,
+		secretReplicator,
+		instrumentationLocator,
+		operatorNamespace,
+	)
+	mutator.ConfigureCompose(cfg.ComposeInstrumentations())
+	secretRetryAttempts, secretRetryInterval := cfg.SecretRetry()
+	mutator.ConfigureSecretResolution(secretRetryAttempts, secretRetryInterval, cfg.FallbackLicenseKeySecret())
+	mutator.ConfigureOTLPClientCert(cfg)
+	mutator.ConfigureRecorder(mgr.GetEventRecorderFor("k8s-agents-operator"))
 
 	hookServer := mgr.GetWebhookServer()
 	hookServer.Register("/mutate-v1-pod", &webhook.Admission{Handler: &PodMutationHandler{
-		Client:  mgr.GetClient(),
-		Decoder: admission.NewDecoder(mgr.GetScheme()),
-		Mutators: []PodMutator{
-			instrumentation.NewMutator(
-				logger,
-				mgrClient,
-				injector,
-				secretReplicator,
-				instrumentationLocator,
-				operatorNamespace,
-			),
-		},
-		Logger: logger,
+		Client:   mgr.GetClient(),
+		Decoder:  admission.NewDecoder(mgr.GetScheme()),
+		Mutators: []PodMutator{mutator},
+		Logger:   logger,
+		limiter:  newInjectionLimiter(cfg.InjectionConcurrency()),
 	}})
 
 	return nil
@@ -176,7 +176,7 @@ func TestMain(m *testing.M) {
 	}
 
 	client := mgr.GetClient()
-	injector := instrumentation.NewNewrelicSdkInjector(logger, client, injectorRegistry)
+	injector := instrumentation.NewNewrelicSdkInjector(logger, client, injectorRegistry, nil)
 	secretReplicator := instrumentation.NewNewrelicSecretReplicator(logger, client)
 	instrumentationLocator := instrumentation.NewNewRelicInstrumentationLocator(logger, client, operatorNamespace)
 	mgr.GetWebhookServer().Register("/mutate-v1-pod", &webhookruntime.Admission{
@@ -263,7 +263,7 @@ func TestPodMutationHandler_Handle(t *testing.T) {
 			initSecrets: []corev1.Secret{
 				{
 					ObjectMeta: metav1.ObjectMeta{Name: instrumentation.DefaultLicenseKeySecretName, Namespace: "newrelic"},
-					Data:       map[string][]byte{apm.LicenseKey: []byte("fake-secret-abc123")},
+					Data:       map[string][]byte{apm.LicenseKey: []byte("fakesecretabc12300000000000000000000NRAL")},
 				},
 			},
 			initInstrumentations: []current.Instrumentation{
@@ -329,6 +329,9 @@ func TestPodMutationHandler_Handle(t *testing.T) {
 								{Name: "NEW_RELIC_APP_NAME", Value: "alpine1"},
 								{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 								{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+								{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+								{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+								{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 								{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "new_relic_license_key", Optional: &optionalTrue, LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}}}},
 							},
 						},
@@ -352,7 +355,7 @@ func TestPodMutationHandler_Handle(t *testing.T) {
 			initSecrets: []corev1.Secret{
 				{
 					ObjectMeta: metav1.ObjectMeta{Name: instrumentation.DefaultLicenseKeySecretName, Namespace: "newrelic"},
-					Data:       map[string][]byte{apm.LicenseKey: []byte("fake-secret-abc123")},
+					Data:       map[string][]byte{apm.LicenseKey: []byte("fakesecretabc12300000000000000000000NRAL")},
 				},
 			},
 			initInstrumentations: []current.Instrumentation{
@@ -403,6 +406,9 @@ func TestPodMutationHandler_Handle(t *testing.T) {
 								{Name: "NEW_RELIC_APP_NAME", Value: "alpine2"},
 								{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 								{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+								{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+								{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+								{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 								{Name: "NEW_RELIC_LICENSE_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "new_relic_license_key", Optional: &optionalTrue, LocalObjectReference: corev1.LocalObjectReference{Name: "newrelic-key-secret"}}}},
 							},
 							VolumeMounts: []corev1.VolumeMount{
@@ -429,6 +435,9 @@ func TestPodMutationHandler_Handle(t *testing.T) {
 								{Name: "NEW_RELIC_APP_NAME", Value: "alpine2"},
 								{Name: "NEW_RELIC_LABELS", Value: "operator:auto-injection"},
 								{Name: "NEW_RELIC_K8S_OPERATOR_ENABLED", Value: "true"},
+								{Name: "NEW_RELIC_OPERATOR_VERSION", Value: version.Get().Operator},
+								{Name: "NEW_RELIC_METADATA_INSTRUMENTATION_PROVIDER", Value: "k8s-agents-operator"},
+								{Name: "NEW_RELIC_METADATA_K8S_AGENTS_OPERATOR_VERSION", Value: version.Get().Operator},
 							},
 						},
 					},
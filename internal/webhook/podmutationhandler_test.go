@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestIsInjectionExpired(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		expectedExpired bool
+		expectedErr     bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:        "not expired",
+			annotations: map[string]string{injectUntilAnnotation: "2025-06-02T00:00:00Z"},
+		},
+		{
+			name:            "expired",
+			annotations:     map[string]string{injectUntilAnnotation: "2025-05-31T00:00:00Z"},
+			expectedExpired: true,
+		},
+		{
+			name:            "expires now",
+			annotations:     map[string]string{injectUntilAnnotation: "2025-06-01T00:00:00Z"},
+			expectedExpired: true,
+		},
+		{
+			name:        "invalid",
+			annotations: map[string]string{injectUntilAnnotation: "next tuesday"},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations}}
+			expired, err := isInjectionExpired(pod, now)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expired != test.expectedExpired {
+				t.Errorf("expected expired %v, got %v", test.expectedExpired, expired)
+			}
+		})
+	}
+}
+
+func TestRedactedPatch(t *testing.T) {
+	patches := []map[string]any{
+		{"op": "add", "path": "/spec/containers/0/env", "value": []map[string]any{
+			{"name": "NEW_RELIC_APP_NAME", "value": "test"},
+			{"name": "NEW_RELIC_LICENSE_KEY", "value": "abc123"},
+			{"name": "NEW_RELIC_LICENSE_KEY", "valueFrom": map[string]any{"secretKeyRef": map[string]any{"name": "newrelic-key-secret", "key": "new_relic_license_key"}}},
+		}},
+		{"op": "add", "path": "/spec/containers/0/env/-", "value": map[string]any{"name": "DB_PASSWORD", "value": "hunter2"}},
+		{"op": "replace", "path": "/spec/containers/0/env/3/value", "value": "hunter2"},
+		{"op": "add", "path": "/spec/initContainers/-", "value": map[string]any{"name": "init", "env": []map[string]any{{"name": "NEW_RELIC_LICENSE_KEY", "value": "abc123"}}}},
+		{"op": "add", "path": "/metadata/annotations/key", "value": "kept"},
+	}
+	patch, err := redactedPatch(patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"op":"add","path":"/spec/containers/0/env","value":[{"name":"NEW_RELIC_APP_NAME","value":"REDACTED"},{"name":"NEW_RELIC_LICENSE_KEY","value":"REDACTED"},{"name":"NEW_RELIC_LICENSE_KEY","valueFrom":{"secretKeyRef":{"key":"new_relic_license_key","name":"newrelic-key-secret"}}}]},` +
+		`{"op":"add","path":"/spec/containers/0/env/-","value":{"name":"DB_PASSWORD","value":"REDACTED"}},` +
+		`{"op":"replace","path":"/spec/containers/0/env/3/value","value":"REDACTED"},` +
+		`{"op":"add","path":"/spec/initContainers/-","value":{"env":[{"name":"NEW_RELIC_LICENSE_KEY","value":"REDACTED"}],"name":"init"}},` +
+		`{"op":"add","path":"/metadata/annotations/key","value":"kept"}]`
+	if patch != expected {
+		t.Errorf("unexpected patch\nwant: %s\ngot:  %s", expected, patch)
+	}
+}
+
+func TestPodMutationHandler_Handle_SelfCheck(t *testing.T) {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		GenerateName: "newrelic-webhook-self-check-",
+		Labels:       map[string]string{selfCheckLabel: "true"},
+	}}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no client and no mutators, as the probe pod must be acknowledged without being instrumented
+	handler := &PodMutationHandler{Decoder: admission.NewDecoder(runtime.NewScheme())}
+	res := handler.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Object: runtime.RawExtension{Raw: raw},
+	}})
+	if !res.Allowed {
+		t.Fatalf("expected the probe pod to be allowed, got %v", res.Result)
+	}
+	patch, err := json.Marshal(res.Patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"op":"add","path":"/metadata/annotations","value":{"newrelic.com/webhook-self-check-ack":"true"}}]`
+	if string(patch) != expected {
+		t.Errorf("unexpected patch\nwant: %s\ngot:  %s", expected, patch)
+	}
+}
+
+func TestPodMutationHandler_Handle_Trace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "app",
+		Annotations: map[string]string{injectUntilAnnotation: "2020-01-01T00:00:00Z"},
+	}}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := &PodMutationHandler{Decoder: admission.NewDecoder(runtime.NewScheme())}
+	handler.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Name:      "app",
+		Namespace: "apps",
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}})
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	for key, expected := range map[attribute.Key]attribute.Value{
+		"k8s.namespace.name":  attribute.StringValue("apps"),
+		"k8s.pod.name":        attribute.StringValue("app"),
+		"admission.operation": attribute.StringValue("CREATE"),
+		"admission.mutated":   attribute.BoolValue(false),
+		"admission.reason":    attribute.StringValue("injection expired"),
+	} {
+		if actual := attributes[key]; actual != expected {
+			t.Errorf("expected %s=%v, got %v", key, expected.Emit(), actual.Emit())
+		}
+	}
+}
+
+func TestPodMutationHandler_Handle_ConcurrencyLimit(t *testing.T) {
+	raw, err := json.Marshal(corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := newInjectionLimiter(1, 10*time.Millisecond)
+	release, _ := limiter.acquire(context.Background())
+	defer release()
+	// no client and no mutators, as the pod must be admitted without being looked at while the limit is reached
+	handler := &PodMutationHandler{Decoder: admission.NewDecoder(runtime.NewScheme()), limiter: limiter}
+	res := handler.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Namespace: "apps",
+		Object:    runtime.RawExtension{Raw: raw},
+	}})
+	if !res.Allowed || len(res.Patches) > 0 {
+		t.Fatalf("expected the pod to be allowed unchanged, got %v %v", res.Result, res.Patches)
+	}
+	if res.Result == nil || res.Result.Message != "too many concurrent injections" {
+		t.Errorf("unexpected reason %v", res.Result)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/newrelic/k8s-agents-operator/internal/config"
+)
+
+const (
+	// selfCheckLabel marks the dry-run probe pods, which the pod mutation handler acknowledges instead of mutating
+	selfCheckLabel = "newrelic.com/webhook-self-check"
+	// selfCheckAckAnnotation is added by the pod mutation handler to the probe pods, proving the webhook was called
+	selfCheckAckAnnotation = "newrelic.com/webhook-self-check-ack"
+	selfCheckImage         = "registry.k8s.io/pause:3.10"
+)
+
+var (
+	_ manager.Runnable               = (*SelfCheck)(nil)
+	_ manager.LeaderElectionRunnable = (*SelfCheck)(nil)
+
+	errSelfCheckNotAcknowledged = errors.New("the pod mutation webhook was not called for the probe pod")
+)
+
+// webhookSelfCheckHealthy is whether the last self-check saw the pod mutation webhook getting called
+var webhookSelfCheckHealthy = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "operator_webhook_self_check_healthy",
+		Help: "Whether the last self-check saw the pod mutation webhook get called (1) or not (0)",
+	},
+)
+
+// RegisterMetrics is used to register the metrics of the package with the metrics registry of the config
+func RegisterMetrics(cfg *config.Config) error {
+	return cfg.RegisterMetrics(webhookSelfCheckHealthy, injectionsThrottled)
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=create
+
+// SelfCheck periodically creates a dry-run probe pod in the operator namespace, verifying that the api server calls
+// the pod mutation webhook. A misconfigured MutatingWebhookConfiguration, webhook service or certificate would
+// otherwise stop the injection silently, as the webhook fails open. Dry-run pods are never persisted, but the create
+// permission on pods is still required.
+type SelfCheck struct {
+	client            client.Client
+	logger            logr.Logger
+	operatorNamespace string
+	interval          time.Duration
+}
+
+// NewSelfCheck is the constructor for the webhook self-check
+func NewSelfCheck(client client.Client, logger logr.Logger, operatorNamespace string, interval time.Duration) *SelfCheck {
+	return &SelfCheck{
+		client:            client,
+		logger:            logger,
+		operatorNamespace: operatorNamespace,
+		interval:          interval,
+	}
+}
+
+// NeedLeaderElection is false, as each replica serves the webhook and the probe has no side effects
+func (s *SelfCheck) NeedLeaderElection() bool {
+	return false
+}
+
+// Start runs the self-check every interval until the context is done
+func (s *SelfCheck) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.check(ctx); err != nil {
+				webhookSelfCheckHealthy.Set(0)
+				s.logger.Error(err, "webhook self-check failed, pods are not being instrumented", "namespace", s.operatorNamespace)
+				continue
+			}
+			webhookSelfCheckHealthy.Set(1)
+			s.logger.V(2).Info("webhook self-check succeeded")
+		}
+	}
+}
+
+// check is used to create the dry-run probe pod, returning an error unless the webhook acknowledged it
+func (s *SelfCheck) check(ctx context.Context) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "newrelic-webhook-self-check-",
+			Namespace:    s.operatorNamespace,
+			Labels:       map[string]string{selfCheckLabel: "true"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "probe", Image: selfCheckImage}},
+		},
+	}
+	if err := s.client.Create(ctx, pod, client.DryRunAll); err != nil {
+		return err
+	}
+	if _, ok := pod.Annotations[selfCheckAckAnnotation]; !ok {
+		return errSelfCheckNotAcknowledged
+	}
+	return nil
+}
+
+// isSelfCheckPod is used to check if the pod is a self-check probe
+func isSelfCheckPod(pod corev1.Pod) bool {
+	_, ok := pod.Labels[selfCheckLabel]
+	return ok
+}
+
+// acknowledgeSelfCheck is used to mark the probe pod as seen by the webhook
+func acknowledgeSelfCheck(pod corev1.Pod) corev1.Pod {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[selfCheckAckAnnotation] = "true"
+	return pod
+}
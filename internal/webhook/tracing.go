@@ -0,0 +1,51 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/newrelic/k8s-agents-operator/internal/tracing"
+)
+
+var tracer = tracing.Tracer("github.com/newrelic/k8s-agents-operator/internal/webhook")
+
+// recordDecision is used to add the admission decision to the span, which is whether the pod was mutated, and
+// otherwise why not. Errors are allowed by the webhook, so they're marked on the span status.
+func recordDecision(span trace.Span, req admission.Request, res admission.Response) {
+	span.SetAttributes(
+		attribute.String("k8s.namespace.name", req.Namespace),
+		attribute.String("k8s.pod.name", req.Name),
+		attribute.String("admission.operation", string(req.Operation)),
+		attribute.Bool("admission.mutated", len(res.Patches) > 0),
+		attribute.Int("admission.patches", len(res.Patches)),
+	)
+	if res.Result == nil {
+		return
+	}
+	if res.Result.Message != "" {
+		span.SetAttributes(attribute.String("admission.reason", res.Result.Message))
+	}
+	if res.Result.Code >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, res.Result.Message)
+	}
+}